@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// LegacyKeyID identifies the key configured through EXTENSION_SECRET_KEY.
+const LegacyKeyID = "legacy"
+
+// lastUsedResolution limits how often last-used timestamps are written back to the store.
+const lastUsedResolution = time.Minute
+
+// Keyring issues, authenticates, rotates, and revokes API keys.
+type Keyring struct {
+	store     Store
+	secrets   SigningSecrets
+	legacyMu  sync.RWMutex
+	legacyKey string
+	policy    SignaturePolicy
+	nonces    *NonceCache
+	now       func() time.Time
+}
+
+// NewKeyring creates a keyring backed by store.
+func NewKeyring(store Store) *Keyring {
+	return &Keyring{
+		store:   store,
+		secrets: newMemorySigningSecrets(),
+		policy:  DefaultSignaturePolicy(),
+		nonces:  NewNonceCache(),
+		now:     time.Now,
+	}
+}
+
+// SetSigningSecrets sets where the signing secrets of keys issued from now on are
+// sealed. Until it is called they are kept in memory.
+func (k *Keyring) SetSigningSecrets(secrets SigningSecrets) {
+	k.secrets = secrets
+}
+
+// SetLegacyKey accepts a single shared secret with every scope, for extensions that
+// predate named keys. An empty key disables legacy authentication. It may be called
+// while requests are being served, to rotate the key.
+func (k *Keyring) SetLegacyKey(key string) {
+	k.legacyMu.Lock()
+	defer k.legacyMu.Unlock()
+	k.legacyKey = key
+}
+
+func (k *Keyring) legacy() string {
+	k.legacyMu.RLock()
+	defer k.legacyMu.RUnlock()
+	return k.legacyKey
+}
+
+// Create issues a new key for a tenant. A zero ttl creates a key that never expires.
+// The returned plaintext is the only copy of the secret and cannot be recovered later.
+func (k *Keyring) Create(tenantID, name string, scopes []Scope, ttl time.Duration) (string, *APIKey, error) {
+	return k.create(tenantID, name, scopes, ttl, "")
+}
+
+// create issues a key, recording the key it replaces when rotatedFrom is set.
+func (k *Keyring) create(tenantID, name string, scopes []Scope, ttl time.Duration, rotatedFrom string) (string, *APIKey, error) {
+	if tenantID == "" {
+		return "", nil, fmt.Errorf("tenant is required")
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("key name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !s.valid() {
+			return "", nil, fmt.Errorf("unknown scope %q", s)
+		}
+	}
+
+	id, plaintext, err := generateKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := k.now().UTC()
+	key := &APIKey{
+		ID:          id,
+		TenantID:    tenantID,
+		Name:        name,
+		Hash:        hashKey(plaintext),
+		Scopes:      scopes,
+		CreatedAt:   now,
+		RotatedFrom: rotatedFrom,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+
+	if err := k.secrets.SetSigningSecret(tenantID, id, SigningKey(plaintext)); err != nil {
+		return "", nil, fmt.Errorf("seal signing secret: %w", err)
+	}
+	if err := k.store.Save(key); err != nil {
+		return "", nil, fmt.Errorf("save key: %w", err)
+	}
+	return plaintext, key, nil
+}
+
+// Authenticate resolves a plaintext key to its APIKey, recording when it was last used.
+func (k *Keyring) Authenticate(plaintext string) (*APIKey, error) {
+	if plaintext == "" {
+		return nil, ErrInvalidKey
+	}
+
+	id, ok := parseKey(plaintext)
+	if !ok {
+		return k.authenticateLegacy(plaintext)
+	}
+
+	key, err := k.store.Get(id)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashKey(plaintext))) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	now := k.now().UTC()
+	if key.RevokedAt != nil {
+		return nil, ErrRevokedKey
+	}
+	if !key.Active(now) {
+		return nil, ErrExpiredKey
+	}
+
+	k.touch(key, now)
+	return key, nil
+}
+
+// touch records that key was used, writing to the store at most once per lastUsedResolution.
+func (k *Keyring) touch(key *APIKey, now time.Time) {
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		key.LastUsedAt = &now
+		// Failing to record usage must not lock the caller out
+		_ = k.store.Touch(key.ID, now)
+	}
+}
+
+func (k *Keyring) authenticateLegacy(plaintext string) (*APIKey, error) {
+	legacyKey := k.legacy()
+	if legacyKey == "" || subtle.ConstantTimeCompare([]byte(legacyKey), []byte(plaintext)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	return legacyAPIKey(), nil
+}
+
+// legacyAPIKey describes the shared EXTENSION_SECRET_KEY as an APIKey.
+func legacyAPIKey() *APIKey {
+	return &APIKey{
+		ID:       LegacyKeyID,
+		TenantID: models.DefaultTenantID,
+		Name:     "EXTENSION_SECRET_KEY",
+		Scopes:   AllScopes,
+	}
+}
+
+// List returns every key issued to a tenant, including expired and revoked keys.
+func (k *Keyring) List(tenantID string) ([]*APIKey, error) {
+	all, err := k.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(all))
+	for _, key := range all {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// get returns key id if it belongs to tenantID. Keys of other tenants are reported
+// as not found so their existence is not revealed.
+func (k *Keyring) get(tenantID, id string) (*APIKey, error) {
+	key, err := k.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+// Rotate issues a replacement for key id with the same name and scopes. The old key
+// keeps working for the overlap period so extensions can be updated, then expires.
+func (k *Keyring) Rotate(tenantID, id string, overlap time.Duration) (string, *APIKey, error) {
+	old, err := k.get(tenantID, id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := k.now().UTC()
+	if !old.Active(now) {
+		return "", nil, fmt.Errorf("cannot rotate inactive key %s", id)
+	}
+
+	var ttl time.Duration
+	if old.ExpiresAt != nil {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+
+	plaintext, replacement, err := k.create(old.TenantID, old.Name, old.Scopes, ttl, old.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if overlap < 0 {
+		overlap = 0
+	}
+	expires := now.Add(overlap)
+	if old.ExpiresAt == nil || expires.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &expires
+		if err := k.store.Save(old); err != nil {
+			return "", nil, fmt.Errorf("save key: %w", err)
+		}
+	}
+
+	return plaintext, replacement, nil
+}
+
+// Revoke immediately disables key id.
+func (k *Keyring) Revoke(tenantID, id string) (*APIKey, error) {
+	key, err := k.get(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := k.now().UTC()
+		key.RevokedAt = &now
+		if err := k.store.Save(key); err != nil {
+			return nil, fmt.Errorf("save key: %w", err)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_CreateAndAuthenticate(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	keyring := NewKeyring(store)
+
+	// Act
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	// Assert - only the hash is stored
+	assert.True(t, strings.HasPrefix(plaintext, "mmk_"+key.ID+"_"))
+	stored, err := store.Get(key.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, plaintext)
+	assert.Equal(t, hashKey(plaintext), stored.Hash)
+
+	authed, err := keyring.Authenticate(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authed.ID)
+	assert.True(t, authed.HasScope(ScopeIngest))
+	assert.False(t, authed.HasScope(ScopeAdmin))
+
+	stored, _ = store.Get(key.ID)
+	assert.NotNil(t, stored.LastUsedAt)
+}
+
+func TestKeyring_RejectsBadKeys(t *testing.T) {
+	keyring := NewKeyring(NewMemoryStore())
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	_, err = keyring.Authenticate("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = keyring.Authenticate("mmk_" + key.ID + "_deadbeef")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = keyring.Authenticate("mmk_unknown_" + strings.Split(plaintext, "_")[2])
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	// Legacy key is disabled unless configured
+	_, err = keyring.Authenticate("test-secret")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKeyring_LegacyKey(t *testing.T) {
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.SetLegacyKey("shared-secret")
+
+	key, err := keyring.Authenticate("shared-secret")
+	require.NoError(t, err)
+	assert.Equal(t, LegacyKeyID, key.ID)
+	assert.True(t, key.HasScope(ScopeAdmin))
+
+	_, err = keyring.Authenticate("other-secret")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	// Rotating the legacy key takes effect immediately
+	keyring.SetLegacyKey("other-secret")
+	_, err = keyring.Authenticate("other-secret")
+	require.NoError(t, err)
+	_, err = keyring.Authenticate("shared-secret")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKeyring_RotateOverlap(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	oldKey, old, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest, ScopeRead}, 0)
+	require.NoError(t, err)
+
+	// Act
+	newKey, replacement, err := keyring.Rotate("t1", old.ID, time.Hour)
+	require.NoError(t, err)
+
+	// Assert - both keys valid during overlap
+	assert.Equal(t, old.ID, replacement.RotatedFrom)
+	assert.Equal(t, old.Scopes, replacement.Scopes)
+	_, err = keyring.Authenticate(oldKey)
+	assert.NoError(t, err)
+	_, err = keyring.Authenticate(newKey)
+	assert.NoError(t, err)
+
+	// After the overlap only the new key works
+	now = now.Add(2 * time.Hour)
+	_, err = keyring.Authenticate(oldKey)
+	assert.ErrorIs(t, err, ErrExpiredKey)
+	_, err = keyring.Authenticate(newKey)
+	assert.NoError(t, err)
+
+	// Expired keys cannot be rotated again
+	_, _, err = keyring.Rotate("t1", old.ID, time.Hour)
+	assert.Error(t, err)
+}
+
+// savingStore records every key saved to it.
+type savingStore struct {
+	*MemoryStore
+	saved []APIKey
+}
+
+func (s *savingStore) Save(key *APIKey) error {
+	s.saved = append(s.saved, *key)
+	return s.MemoryStore.Save(key)
+}
+
+func TestKeyring_RotateSavesReplacementOnce(t *testing.T) {
+	// Arrange
+	store := &savingStore{MemoryStore: NewMemoryStore()}
+	keyring := NewKeyring(store)
+	_, old, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+	store.saved = nil
+
+	// Act
+	_, replacement, err := keyring.Rotate("t1", old.ID, time.Hour)
+	require.NoError(t, err)
+
+	// Assert - the replacement is saved already linked to the old key
+	var saves []APIKey
+	for _, key := range store.saved {
+		if key.ID == replacement.ID {
+			saves = append(saves, key)
+		}
+	}
+	require.Len(t, saves, 1)
+	assert.Equal(t, old.ID, saves[0].RotatedFrom)
+}
+
+func TestKeyring_Revoke(t *testing.T) {
+	keyring := NewKeyring(NewMemoryStore())
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	revoked, err := keyring.Revoke("t1", key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = keyring.Authenticate(plaintext)
+	assert.ErrorIs(t, err, ErrRevokedKey)
+
+	_, err = keyring.Revoke("t1", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestKeyring_TouchKeepsRevocation(t *testing.T) {
+	// Arrange: a request read the key just before it was revoked
+	store := NewMemoryStore()
+	keyring := NewKeyring(store)
+	_, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+	stale, err := store.Get(key.ID)
+	require.NoError(t, err)
+	_, err = keyring.Revoke("t1", key.ID)
+	require.NoError(t, err)
+
+	// Act
+	keyring.touch(stale, time.Now())
+
+	// Assert
+	stored, err := store.Get(key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.RevokedAt)
+	assert.NotNil(t, stored.LastUsedAt)
+}
+
+func TestKeyring_TenantIsolation(t *testing.T) {
+	keyring := NewKeyring(NewMemoryStore())
+	_, mine, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+	_, theirs, err := keyring.Create("t2", "firefox", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	keys, err := keyring.List("t1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, mine.ID, keys[0].ID)
+
+	_, _, err = keyring.Rotate("t1", theirs.ID, time.Hour)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = keyring.Revoke("t1", theirs.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestKeyring_CreateValidation(t *testing.T) {
+	keyring := NewKeyring(NewMemoryStore())
+
+	_, _, err := keyring.Create("t1", "", []Scope{ScopeRead}, 0)
+	assert.Error(t, err)
+
+	_, _, err = keyring.Create("", "x", []Scope{ScopeRead}, 0)
+	assert.Error(t, err)
+
+	_, _, err = keyring.Create("t1", "x", nil, 0)
+	assert.Error(t, err)
+
+	_, _, err = keyring.Create("t1", "x", []Scope{"root"}, 0)
+	assert.Error(t, err)
+
+	_, err = ParseScopes([]string{"read", "nope"})
+	assert.Error(t, err)
+}
+
+func TestFileStore_PersistsHashes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "keys", "api_keys.json")
+	store, err := NewFileStore(path)
+	require.NoError(t, err)
+	plaintext, key, err := NewKeyring(store).Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	// Act - reload from disk
+	reloaded, err := NewFileStore(path)
+	require.NoError(t, err)
+
+	// Assert
+	authed, err := NewKeyring(reloaded).Authenticate(plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "t1", authed.TenantID)
+	assert.Equal(t, key.ID, authed.ID)
+
+	keys, err := reloaded.List()
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
@@ -0,0 +1,136 @@
+// Package auth manages the API keys used by browser extensions and operators to
+// authenticate with the sync backend.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope grants access to a class of API operations.
+type Scope string
+
+// Supported scopes. ScopeAdmin implies every scope except ScopeTenants, which
+// manages tenants across the whole server and is granted separately.
+const (
+	ScopeIngest  Scope = "ingest"
+	ScopeRead    Scope = "read"
+	ScopeAdmin   Scope = "admin"
+	ScopeTenants Scope = "tenants"
+)
+
+// AllScopes lists every scope.
+var AllScopes = []Scope{ScopeIngest, ScopeRead, ScopeAdmin, ScopeTenants}
+
+// ParseScopes validates a list of scope names.
+func ParseScopes(names []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		s := Scope(strings.TrimSpace(name))
+		if !s.valid() {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		scopes = append(scopes, s)
+	}
+	return scopes, nil
+}
+
+func (s Scope) valid() bool {
+	for _, known := range AllScopes {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returned when authenticating a key.
+var (
+	ErrInvalidKey = errors.New("invalid API key")
+	ErrExpiredKey = errors.New("API key expired")
+	ErrRevokedKey = errors.New("API key revoked")
+	ErrNotFound   = errors.New("API key not found")
+)
+
+// keyPrefix marks plaintext keys issued by this server.
+const keyPrefix = "mmk"
+
+// APIKey is a named credential with a set of scopes, owned by a tenant. Only a hash
+// of the secret is stored.
+type APIKey struct {
+	ID          string     `json:"id"`
+	TenantID    string     `json:"tenantId"`
+	Name        string     `json:"name"`
+	Hash        string     `json:"-"`
+	Scopes      []Scope    `json:"scopes"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	RotatedFrom string     `json:"rotatedFrom,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || (s == ScopeAdmin && scope != ScopeTenants) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key may be used at time now.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// generateKey returns a new key ID and the plaintext key that embeds it.
+// Plaintext keys have the form "mmk_<id>_<secret>".
+func generateKey() (id, plaintext string, err error) {
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("generate key id: %w", err)
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("generate key secret: %w", err)
+	}
+
+	id = hex.EncodeToString(idBytes)
+	plaintext = fmt.Sprintf("%s_%s_%s", keyPrefix, id, hex.EncodeToString(secretBytes))
+	return id, plaintext, nil
+}
+
+// KeyID returns the ID that signed requests must send for a plaintext key:
+// the embedded ID of a named key, or LegacyKeyID for the shared extension key.
+func KeyID(plaintext string) string {
+	if id, ok := parseKey(plaintext); ok {
+		return id
+	}
+	return LegacyKeyID
+}
+
+// parseKey extracts the key ID from a plaintext key.
+func parseKey(plaintext string) (id string, ok bool) {
+	parts := strings.Split(plaintext, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// hashKey returns the stored representation of a plaintext key. Keys carry 256 bits
+// of randomness, so a fast hash is sufficient.
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
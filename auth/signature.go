@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers used by signed requests.
+const (
+	HeaderKeyID     = "X-Key-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+	// HeaderContentSHA256 carries the hex SHA-256 of the body. When it is sent the
+	// body is checked against it as it is read instead of being buffered, so
+	// streamed bodies can be signed.
+	HeaderContentSHA256 = "X-Content-SHA256"
+)
+
+// signingLabel separates the signing secret derived from an API key from the key's
+// stored hash, so the hash cannot be used to sign requests.
+const signingLabel = "monarchmoney-sync request signing v1"
+
+// Errors returned when verifying a signed request.
+var (
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrStaleTimestamp   = errors.New("request timestamp outside allowed window")
+	ErrReplayedNonce    = errors.New("request nonce already used")
+	ErrMissingSignature = errors.New("signed request required")
+	ErrBodyDigest       = errors.New("request body does not match " + HeaderContentSHA256)
+)
+
+// SignaturePolicy controls signed-request verification.
+type SignaturePolicy struct {
+	// Required rejects requests that authenticate with a bare X-Extension-Key.
+	Required bool
+	// MaxSkew is how far a request timestamp may be from server time.
+	MaxSkew time.Duration
+	// MaxBodyBytes bounds the body that is buffered to verify a signature.
+	MaxBodyBytes int64
+}
+
+// DefaultSignaturePolicy returns the policy used when none is configured.
+func DefaultSignaturePolicy() SignaturePolicy {
+	return SignaturePolicy{
+		Required:     false,
+		MaxSkew:      5 * time.Minute,
+		MaxBodyBytes: 10 << 20,
+	}
+}
+
+// SignedRequest holds the parts of an HTTP request covered by its signature.
+type SignedRequest struct {
+	KeyID     string
+	Method    string
+	Path      string
+	Timestamp string
+	Nonce     string
+	Body      []byte
+	// BodyDigest, when set, is the body's hex SHA-256 from HeaderContentSHA256 and
+	// is signed in place of hashing Body. The caller checks the body against it.
+	BodyDigest string
+	Signature  string
+}
+
+// SigningKey derives the HMAC key for a plaintext API key: the hex HMAC-SHA256 of a
+// fixed label keyed with the key. Clients sign with this value rather than the key
+// itself. The server keeps it sealed in the credential vault, since it cannot be
+// derived from the key's stored hash.
+func SigningKey(plaintext string) string {
+	mac := hmac.New(sha256.New, []byte(plaintext))
+	mac.Write([]byte(signingLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BodyDigest returns the hex SHA-256 of a request body.
+func BodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignRequest returns the hex HMAC-SHA256 signature of a request. The signed string is
+// method, path (including query), timestamp, nonce, and the hex SHA-256 of the body,
+// joined by newlines.
+func SignRequest(signingKey, method, path, timestamp, nonce string, body []byte) string {
+	return SignRequestDigest(signingKey, method, path, timestamp, nonce, BodyDigest(body))
+}
+
+// SignRequestDigest is SignRequest for a body given by its BodyDigest, such as one
+// that is streamed.
+func SignRequestDigest(signingKey, method, path, timestamp, nonce, bodyDigest string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write([]byte(bodyDigest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidBodyDigest reports whether digest is a hex SHA-256.
+func ValidBodyDigest(digest string) bool {
+	b, err := hex.DecodeString(digest)
+	return err == nil && len(b) == sha256.Size
+}
+
+// SetSignaturePolicy configures signed-request verification.
+func (k *Keyring) SetSignaturePolicy(policy SignaturePolicy) {
+	defaults := DefaultSignaturePolicy()
+	if policy.MaxSkew <= 0 {
+		policy.MaxSkew = defaults.MaxSkew
+	}
+	if policy.MaxBodyBytes <= 0 {
+		policy.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	k.policy = policy
+}
+
+// SignaturePolicy returns the active signed-request policy.
+func (k *Keyring) SignaturePolicy() SignaturePolicy {
+	return k.policy
+}
+
+// AuthenticateSigned verifies a signed request and resolves its API key. The timestamp
+// must be within the policy's skew and each nonce may only be used once per key.
+func (k *Keyring) AuthenticateSigned(req SignedRequest) (*APIKey, error) {
+	if req.KeyID == "" || req.Signature == "" || req.Nonce == "" {
+		return nil, ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrStaleTimestamp
+	}
+	now := k.now().UTC()
+	if skew := now.Sub(time.Unix(unix, 0)); skew > k.policy.MaxSkew || skew < -k.policy.MaxSkew {
+		return nil, ErrStaleTimestamp
+	}
+
+	key, signingKey, err := k.signingKeyFor(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := req.BodyDigest
+	if digest == "" {
+		digest = BodyDigest(req.Body)
+	}
+	expected := SignRequestDigest(signingKey, req.Method, req.Path, req.Timestamp, req.Nonce, digest)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Nonces only need remembering while their timestamp is still acceptable
+	if !k.nonces.Add(req.KeyID+":"+req.Nonce, now.Add(2*k.policy.MaxSkew), now) {
+		return nil, ErrReplayedNonce
+	}
+
+	if key.ID != LegacyKeyID {
+		if key.RevokedAt != nil {
+			return nil, ErrRevokedKey
+		}
+		if !key.Active(now) {
+			return nil, ErrExpiredKey
+		}
+		k.touch(key, now)
+	}
+
+	return key, nil
+}
+
+// signingKeyFor returns the key and HMAC signing key for a key ID.
+func (k *Keyring) signingKeyFor(id string) (*APIKey, string, error) {
+	if id == LegacyKeyID {
+		legacyKey := k.legacy()
+		if legacyKey == "" {
+			return nil, "", ErrInvalidKey
+		}
+		return legacyAPIKey(), SigningKey(legacyKey), nil
+	}
+
+	key, err := k.store.Get(id)
+	if err != nil {
+		return nil, "", ErrInvalidKey
+	}
+	// Keys issued before signing secrets were sealed have none, and must be
+	// rotated to sign requests
+	secret, err := k.secrets.SigningSecret(key.TenantID, key.ID)
+	if err != nil {
+		return nil, "", ErrInvalidKey
+	}
+	return key, secret, nil
+}
+
+// SigningSecrets keeps the secrets that signed requests are verified with. They
+// must be sealed, such as in the credential vault: anyone holding one can sign
+// requests as its key.
+type SigningSecrets interface {
+	SetSigningSecret(tenantID, keyID, secret string) error
+	SigningSecret(tenantID, keyID string) (string, error)
+}
+
+// memorySigningSecrets keeps signing secrets in memory. They are lost on restart.
+type memorySigningSecrets struct {
+	mu      sync.RWMutex
+	secrets map[string]string
+}
+
+func newMemorySigningSecrets() *memorySigningSecrets {
+	return &memorySigningSecrets{secrets: make(map[string]string)}
+}
+
+func (m *memorySigningSecrets) SetSigningSecret(tenantID, keyID, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[tenantID+"/"+keyID] = secret
+	return nil
+}
+
+func (m *memorySigningSecrets) SigningSecret(tenantID, keyID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	secret, ok := m.secrets[tenantID+"/"+keyID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// NonceCache remembers recently used nonces until they expire.
+type NonceCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	// sweepAt is the next time expired entries are purged
+	sweepAt time.Time
+}
+
+// NewNonceCache creates an empty nonce cache.
+func NewNonceCache() *NonceCache {
+	return &NonceCache{entries: make(map[string]time.Time)}
+}
+
+// Add records nonce until expires. It returns false if the nonce is already present.
+func (c *NonceCache) Add(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.sweepAt) {
+		for n, exp := range c.entries {
+			if now.After(exp) {
+				delete(c.entries, n)
+			}
+		}
+		c.sweepAt = now.Add(time.Minute)
+	}
+
+	if exp, ok := c.entries[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	c.entries[nonce] = expires
+	return true
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedRequest(t *testing.T, keyring *Keyring, keyID, plaintext, nonce string, at time.Time) SignedRequest {
+	t.Helper()
+	body := []byte(`{"orderNumber":"123"}`)
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return SignedRequest{
+		KeyID:     keyID,
+		Method:    "POST",
+		Path:      "/api/walmart/orders",
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Body:      body,
+		Signature: SignRequest(SigningKey(plaintext), "POST", "/api/walmart/orders", timestamp, nonce, body),
+	}
+}
+
+func TestAuthenticateSigned_Valid(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	// Act
+	authed, err := keyring.AuthenticateSigned(signedRequest(t, keyring, key.ID, plaintext, "n-1", now))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authed.ID)
+}
+
+func TestAuthenticateSigned_RejectsStoredHash(t *testing.T) {
+	// Arrange: someone who read the key file knows the key's hash
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	_, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+	body := []byte(`{"orderNumber":"123"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	// Act
+	_, err = keyring.AuthenticateSigned(SignedRequest{
+		KeyID:     key.ID,
+		Method:    "POST",
+		Path:      "/api/walmart/orders",
+		Timestamp: timestamp,
+		Nonce:     "n-1",
+		Body:      body,
+		Signature: SignRequest(key.Hash, "POST", "/api/walmart/orders", timestamp, "n-1", body),
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestAuthenticateSigned_BodyDigest(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+	req := signedRequest(t, keyring, key.ID, plaintext, "n-1", now)
+	req.BodyDigest = BodyDigest(req.Body)
+	req.Body = nil
+
+	// Act
+	authed, err := keyring.AuthenticateSigned(req)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authed.ID)
+}
+
+func TestAuthenticateSigned_RejectsReplay(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	req := signedRequest(t, keyring, key.ID, plaintext, "n-1", now)
+	_, err = keyring.AuthenticateSigned(req)
+	require.NoError(t, err)
+
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrReplayedNonce)
+}
+
+func TestAuthenticateSigned_RejectsStaleTimestamp(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	keyring.SetSignaturePolicy(SignaturePolicy{MaxSkew: time.Minute})
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	_, err = keyring.AuthenticateSigned(signedRequest(t, keyring, key.ID, plaintext, "old", now.Add(-2*time.Minute)))
+	assert.ErrorIs(t, err, ErrStaleTimestamp)
+
+	_, err = keyring.AuthenticateSigned(signedRequest(t, keyring, key.ID, plaintext, "future", now.Add(2*time.Minute)))
+	assert.ErrorIs(t, err, ErrStaleTimestamp)
+
+	req := signedRequest(t, keyring, key.ID, plaintext, "bad", now)
+	req.Timestamp = "yesterday"
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrStaleTimestamp)
+}
+
+func TestAuthenticateSigned_RejectsTampering(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	keyring := NewKeyring(NewMemoryStore())
+	keyring.now = func() time.Time { return now }
+	plaintext, key, err := keyring.Create("t1", "chrome", []Scope{ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	req := signedRequest(t, keyring, key.ID, plaintext, "n-body", now)
+	req.Body = []byte(`{"orderNumber":"999"}`)
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	req = signedRequest(t, keyring, key.ID, plaintext, "n-path", now)
+	req.Path = "/api/walmart/orders/batch"
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	req = signedRequest(t, keyring, key.ID, "mmk_other_secret", "n-key", now)
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	req = signedRequest(t, keyring, "unknown", plaintext, "n-id", now)
+	_, err = keyring.AuthenticateSigned(req)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestAuthenticateSigned_LegacyKey(t *testing.T) {
+	now := time.Now()
+	keyring := NewKeyring(NewMemoryStore())
+
+	_, err := keyring.AuthenticateSigned(signedRequest(t, keyring, LegacyKeyID, "shared", "n-1", now))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	keyring.SetLegacyKey("shared")
+	key, err := keyring.AuthenticateSigned(signedRequest(t, keyring, LegacyKeyID, "shared", "n-2", now))
+	require.NoError(t, err)
+	assert.Equal(t, LegacyKeyID, key.ID)
+}
+
+func TestNonceCache_Expiry(t *testing.T) {
+	cache := NewNonceCache()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, cache.Add("a", now.Add(time.Minute), now))
+	assert.False(t, cache.Add("a", now.Add(time.Minute), now.Add(30*time.Second)))
+
+	// Once expired the entry is purged and the nonce may be seen again
+	later := now.Add(2 * time.Minute)
+	assert.True(t, cache.Add("a", later.Add(time.Minute), later))
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"monarchmoney-sync-backend/internal/jsonfile"
+	"monarchmoney-sync-backend/models"
+)
+
+// Store persists API keys.
+type Store interface {
+	Save(key *APIKey) error
+	// Touch sets only the key's LastUsedAt, leaving changes made since it was read,
+	// such as a revocation, in place. Touching an unknown key is not an error.
+	Touch(id string, at time.Time) error
+	Get(id string) (*APIKey, error)
+	List() ([]*APIKey, error)
+}
+
+// MemoryStore keeps API keys in memory. Keys are lost on restart.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryStore creates an empty in-memory key store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]APIKey)}
+}
+
+// Save inserts or replaces a key.
+func (s *MemoryStore) Save(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+// Touch records when a key was last used.
+func (s *MemoryStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil
+	}
+	key.LastUsedAt = &at
+	s.keys[id] = key
+	return nil
+}
+
+// Get returns the key with the given ID, or ErrNotFound.
+func (s *MemoryStore) Get(id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+// List returns all keys ordered by creation time.
+func (s *MemoryStore) List() ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		key := k
+		keys = append(keys, &key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// FileStore keeps API keys in memory and writes them to a JSON file on every change.
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex
+}
+
+// storedKey is the on-disk form of an APIKey, which includes the hash.
+type storedKey struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// NewFileStore loads keys from path, creating the file on first write if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	var stored []storedKey
+	if _, err := jsonfile.Read(path, &stored); err != nil {
+		return nil, err
+	}
+	for _, sk := range stored {
+		key := sk.APIKey
+		key.Hash = sk.Hash
+		if key.TenantID == "" {
+			// Keys issued before tenancy belong to the default tenant
+			key.TenantID = models.DefaultTenantID
+		}
+		s.keys[key.ID] = key
+	}
+
+	return s, nil
+}
+
+// Save inserts or replaces a key and rewrites the key file.
+func (s *FileStore) Save(key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.Save(key); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// Touch records when a key was last used and rewrites the key file.
+func (s *FileStore) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.MemoryStore.Touch(id, at); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// flush writes every key to disk. Callers hold s.mu.
+func (s *FileStore) flush() error {
+	keys, err := s.MemoryStore.List()
+	if err != nil {
+		return err
+	}
+	stored := make([]storedKey, 0, len(keys))
+	for _, k := range keys {
+		stored = append(stored, storedKey{APIKey: *k, Hash: k.Hash})
+	}
+	return jsonfile.Write(s.path, stored)
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"monarchmoney-sync-backend/client"
+	"monarchmoney-sync-backend/config"
+	"monarchmoney-sync-backend/importer"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store/sqlstore"
+
+	"github.com/joho/godotenv"
+)
+
+// command is a subcommand of the server binary. Names may be two words, such as
+// "config check".
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"serve", "serve [-config file]", "Run the HTTP server (the default)", runServe},
+	{"import", "import [flags] file...", "Import orders from JSON, NDJSON, Walmart or Amazon CSV, or email receipt files into a running server", runImport},
+	{"reprocess", "reprocess [flags] -from date -to date", "Re-run processing for stored orders in a date range, for one retailer or all of them", runReprocess},
+	{"migrate", "migrate [up | down [-steps n] | status]", "Apply, revert, or list database migrations", runMigrate},
+	{"config check", "config check [file]", "Print the effective configuration and check it", runConfigCheck},
+	{"keys create", "keys create [flags] -name name", "Issue an API key on a running server", runKeysCreate},
+}
+
+// run dispatches to the subcommand named by args and returns the exit code.
+// Without a subcommand the server is started, as before subcommands existed.
+func run(args []string) int {
+	switch {
+	case len(args) == 0:
+		return runServe(args)
+	case args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help":
+		usage(os.Stdout)
+		return 0
+	case strings.HasPrefix(args[0], "-"):
+		return runServe(args)
+	}
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd.run(args[len(words):])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", strings.Join(args, " "))
+	usage(os.Stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage, cmd.summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(w, "\nRun a command with -h for its flags.")
+}
+
+// loadConfig loads configuration the same way for every command: from path, or
+// CONFIG_FILE when path is empty, overlaid with the environment and .env.
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		return config.LoadConfig()
+	}
+	_ = godotenv.Load()
+	return config.Load(path)
+}
+
+// clientFlags are the flags shared by commands that call a running server.
+type clientFlags struct {
+	config string
+	server string
+	key    string
+	sign   bool
+}
+
+func addClientFlags(fs *flag.FlagSet) *clientFlags {
+	f := &clientFlags{}
+	fs.StringVar(&f.config, "config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	fs.StringVar(&f.server, "server", "", "server URL (default http://localhost:$PORT)")
+	fs.StringVar(&f.key, "key", "", "API key (default $EXTENSION_SECRET_KEY)")
+	fs.BoolVar(&f.sign, "sign", false, "sign requests (default $REQUIRE_SIGNED_REQUESTS)")
+	return f
+}
+
+// client creates an API client from the flags, falling back to the server's own
+// configuration so commands work unchanged on the host running the server.
+func (f *clientFlags) client(fs *flag.FlagSet) (*client.Client, *config.Config, error) {
+	cfg, err := loadConfig(f.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server, key, sign := f.server, f.key, cfg.RequireSignedRequests
+	if server == "" {
+		server = "http://localhost:" + cfg.Port
+	}
+	if key == "" {
+		key = cfg.ExtensionKey
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "sign" {
+			sign = f.sign
+		}
+	})
+
+	var opts []client.Option
+	if sign {
+		opts = append(opts, client.WithSignedRequests())
+	}
+	return client.New(server, key, opts...), cfg, nil
+}
+
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	batchSize := fs.Int("batch-size", 0, "orders per request (default $BATCH_MAX_ORDERS)")
+	format := fs.String("format", "auto", "file format: json, csv (Walmart purchase history or Amazon Retail.OrderHistory, told apart by header), eml (email receipt), or auto to use the file extension")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: import [flags] file...")
+		return 2
+	}
+
+	c, cfg, err := cf.client(fs)
+	if err != nil {
+		return fail("Invalid configuration", err)
+	}
+	var orders []models.Order
+	for _, path := range fs.Args() {
+		read, err := orderReader(*format, path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fail("Failed to open orders file", err)
+		}
+		fileOrders, err := read(f)
+		f.Close()
+		if err != nil {
+			return fail("Failed to read orders", fmt.Errorf("%s: %w", path, err))
+		}
+		orders = append(orders, fileOrders...)
+	}
+
+	size := *batchSize
+	if size <= 0 {
+		size = cfg.BatchMaxOrders
+	}
+	resp, err := c.ImportOrders(context.Background(), orders, size)
+	if resp != nil && len(resp.Results) > 0 {
+		printResults(resp)
+	}
+	if err != nil {
+		return fail("Import failed", err)
+	}
+	if resp.FailedCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// orderReader picks the importer for a file format, guessing from the file
+// extension when format is "auto".
+func orderReader(format, path string) (func(io.Reader) ([]models.Order, error), error) {
+	if format == "auto" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = "csv"
+		case ".eml":
+			format = "eml"
+		default:
+			format = "json"
+		}
+	}
+	switch format {
+	case "json":
+		return importer.ReadJSON, nil
+	case "csv":
+		return readOrderHistoryCSV, nil
+	case "eml":
+		return readReceipt, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q: use json, csv, eml, or auto", format)
+	}
+}
+
+// readOrderHistoryCSV reads a Walmart or Amazon order history export. Only
+// Amazon's has a Total Owed column.
+func readOrderHistoryCSV(r io.Reader) ([]models.Order, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rest := io.MultiReader(strings.NewReader(header), br)
+	if strings.Contains(strings.ToLower(header), "total owed") {
+		return importer.ReadAmazonCSV(rest)
+	}
+	return importer.ReadWalmartCSV(rest)
+}
+
+func readReceipt(r io.Reader) ([]models.Order, error) {
+	order, err := importer.ReadReceipt(r)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Order{*order}, nil
+}
+
+func runReprocess(args []string) int {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	from := fs.String("from", "", "first order date, YYYY-MM-DD")
+	to := fs.String("to", "", "last order date, YYYY-MM-DD")
+	retailer := fs.String("retailer", "", "only reprocess this retailer's orders, such as walmart or amazon (default every retailer)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "Usage: reprocess [flags] -from YYYY-MM-DD -to YYYY-MM-DD")
+		return 2
+	}
+	retailers := models.Retailers()
+	if *retailer != "" {
+		r, err := models.ParseRetailer(*retailer)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		retailers = []models.Retailer{r}
+	}
+
+	c, _, err := cf.client(fs)
+	if err != nil {
+		return fail("Invalid configuration", err)
+	}
+	combined := &models.BatchOrdersResponse{}
+	for _, r := range retailers {
+		resp, err := c.Reprocess(context.Background(), r, *from, *to)
+		if err != nil {
+			if len(combined.Results) > 0 {
+				printResults(combined)
+			}
+			return fail("Reprocess failed", fmt.Errorf("%s: %w", r, err))
+		}
+		combined.ProcessedCount += resp.ProcessedCount
+		combined.FailedCount += resp.FailedCount
+		combined.Results = append(combined.Results, resp.Results...)
+	}
+	printResults(combined)
+	if combined.FailedCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runKeysCreate(args []string) int {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	name := fs.String("name", "", "key name, such as the browser it is for")
+	scopes := fs.String("scopes", "ingest,read", "comma-separated scopes: ingest, read, admin")
+	expires := fs.Duration("expires", 0, "lifetime such as 720h (default never)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "Usage: keys create [flags] -name name")
+		return 2
+	}
+
+	c, _, err := cf.client(fs)
+	if err != nil {
+		return fail("Invalid configuration", err)
+	}
+	created, err := c.CreateKey(context.Background(), *name, strings.Split(*scopes, ","), *expires)
+	if err != nil {
+		return fail("Failed to create key", err)
+	}
+
+	fmt.Printf("Created key %s (%s) for tenant %s with scopes %s\n",
+		created.APIKey.ID, created.APIKey.Name, created.APIKey.TenantID, *scopes)
+	if created.APIKey.ExpiresAt != nil {
+		fmt.Printf("Expires %s\n", created.APIKey.ExpiresAt.Format(time.RFC3339))
+	}
+	fmt.Printf("\n%s\n\nStore this key now; it cannot be shown again.\n", created.Key)
+	return 0
+}
+
+// runMigrate applies, reverts, or lists database migrations. The action may be
+// followed by its own flags, as in "migrate down -steps 2".
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	steps := fs.Int("steps", 1, "number of migrations to revert with down")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	action := "up"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 2
+		}
+	}
+	if fs.NArg() > 0 || (action != "up" && action != "down" && action != "status") || *steps < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: migrate [-config file] [up | down [-steps n] | status]")
+		return 2
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return fail("Invalid configuration", err)
+	}
+	if cfg.DatabaseURL == "" {
+		fmt.Println("DATABASE_URL is not set; orders are kept in memory, so there is nothing to migrate.")
+		return 0
+	}
+	db, err := sqlstore.Open(cfg.DatabaseURL, databaseOptions(cfg))
+	if err != nil {
+		return fail("Failed to open database", err)
+	}
+	defer db.Close()
+	m, err := sqlstore.NewMigrator(db)
+	if err != nil {
+		return fail("Failed to load migrations", err)
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return fail("Failed to read migrations", err)
+		}
+		printMigrationStatus(statuses)
+		return 0
+	case "down":
+		reverted, err := m.Down(ctx, *steps)
+		for _, mig := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return fail("Migration failed", err)
+		}
+	default:
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("Applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return fail("Migration failed", err)
+		}
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return fail("Failed to read migrations", err)
+	}
+	fmt.Printf("Database schema is at version %d of %d.\n", version, m.Latest())
+	return 0
+}
+
+func printMigrationStatus(statuses []sqlstore.MigrationStatus) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Local().Format(time.RFC3339)
+		}
+		if !s.Known {
+			applied += " (unknown to this binary)"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	_ = tw.Flush()
+}
+
+// runConfigCheck prints the effective configuration with secrets redacted, then
+// any validation problems. An optional argument names the config file.
+func runConfigCheck(args []string) int {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		fmt.Printf("Configuration could not be loaded:\n%s\n", indent(err))
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+	}
+	_ = tw.Flush()
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("\nConfiguration is invalid:\n%s\n", indent(err))
+		return 1
+	}
+	fmt.Println("\nConfiguration is valid.")
+	return 0
+}
+
+// printResults prints a batch summary followed by each failed order.
+func printResults(resp *models.BatchOrdersResponse) {
+	fmt.Printf("Processed %d orders, %d failed\n", resp.ProcessedCount, resp.FailedCount)
+	for _, r := range resp.Results {
+		if !r.Success {
+			fmt.Printf("  %s: %s\n", r.OrderNumber, r.Error)
+		}
+	}
+}
+
+// fail prints an error for a command and returns its exit code.
+func fail(msg string, err error) int {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 401 {
+		msg += " (check -key, or set -sign if the server requires signed requests)"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	return 1
+}
+
+// indent formats each line of a joined error as a list item.
+func indent(err error) string {
+	lines := strings.Split(err.Error(), "\n")
+	for i, line := range lines {
+		lines[i] = "  - " + line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,206 @@
+// Package client calls the sync backend's HTTP API. It is used by the command-line
+// tools to import and reprocess orders and manage keys on a running server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+)
+
+// Client is an API client authenticated with one API key.
+type Client struct {
+	baseURL string
+	apiKey  string
+	sign    bool
+	http    *http.Client
+	now     func() time.Time
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithSignedRequests signs every request with the API key's HMAC signing key, for
+// servers that set REQUIRE_SIGNED_REQUESTS.
+func WithSignedRequests() Option {
+	return func(c *Client) { c.sign = true }
+}
+
+// WithHTTPClient overrides the HTTP client used for requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// New creates a client for the server at baseURL, such as http://localhost:8080.
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is a non-2xx response from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// ImportOrders submits orders in batches of at most batchSize through each
+// retailer's batch endpoint, so they get the same validation and deduplication as
+// orders sent by the extension. A batch holds consecutive orders from one
+// retailer; orders without one are sent as models.DefaultRetailer's. Results from
+// every batch are combined in the order the orders were given.
+func (c *Client) ImportOrders(ctx context.Context, orders []models.Order, batchSize int) (*models.BatchOrdersResponse, error) {
+	if batchSize <= 0 {
+		batchSize = len(orders)
+	}
+	combined := &models.BatchOrdersResponse{}
+	for start := 0; start < len(orders); {
+		retailer := orders[start].Retailer
+		end := start + 1
+		for end < len(orders) && end-start < batchSize && orders[end].Retailer == retailer {
+			end++
+		}
+		if retailer == "" {
+			retailer = models.DefaultRetailer
+		}
+
+		var resp models.BatchOrdersResponse
+		req := models.BatchOrdersRequest{Orders: orders[start:end]}
+		path := "/api/" + url.PathEscape(string(retailer)) + "/orders/batch"
+		if err := c.do(ctx, http.MethodPost, path, req, &resp); err != nil {
+			return combined, fmt.Errorf("import orders %d-%d: %w", start+1, end, err)
+		}
+		combined.ProcessedCount += resp.ProcessedCount
+		combined.FailedCount += resp.FailedCount
+		combined.Results = append(combined.Results, resp.Results...)
+		combined.Timestamp = resp.Timestamp
+		start = end
+	}
+	combined.Success = combined.ProcessedCount > 0 || combined.FailedCount == 0
+	return combined, nil
+}
+
+// Reprocess runs the retailer's stored orders dated from to to (YYYY-MM-DD,
+// inclusive) through processing again. An empty retailer is
+// models.DefaultRetailer.
+func (c *Client) Reprocess(ctx context.Context, retailer models.Retailer, from, to string) (*models.BatchOrdersResponse, error) {
+	if retailer == "" {
+		retailer = models.DefaultRetailer
+	}
+	var resp models.BatchOrdersResponse
+	body := map[string]string{"from": from, "to": to}
+	path := "/api/" + url.PathEscape(string(retailer)) + "/orders/reprocess"
+	if err := c.do(ctx, http.MethodPost, path, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreatedKey is a newly issued API key. Key is the only copy of the secret.
+type CreatedKey struct {
+	Key    string       `json:"key"`
+	APIKey *auth.APIKey `json:"apiKey"`
+}
+
+// CreateKey issues an API key in the caller's tenant. A zero expiresIn creates a
+// key that never expires.
+func (c *Client) CreateKey(ctx context.Context, name string, scopes []string, expiresIn time.Duration) (*CreatedKey, error) {
+	body := map[string]interface{}{"name": name, "scopes": scopes}
+	if expiresIn > 0 {
+		body["expiresIn"] = expiresIn.String()
+	}
+	var created CreatedKey
+	if err := c.do(ctx, http.MethodPost, "/api/keys", body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.sign {
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		timestamp := strconv.FormatInt(c.now().Unix(), 10)
+		digest := auth.BodyDigest(body)
+		signature := auth.SignRequestDigest(auth.SigningKey(c.apiKey), method, req.URL.RequestURI(), timestamp, nonce, digest)
+		req.Header.Set(auth.HeaderKeyID, auth.KeyID(c.apiKey))
+		req.Header.Set(auth.HeaderContentSHA256, digest)
+		req.Header.Set(auth.HeaderTimestamp, timestamp)
+		req.Header.Set(auth.HeaderNonce, nonce)
+		req.Header.Set(auth.HeaderSignature, signature)
+	} else {
+		req.Header.Set("X-Extension-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/handlers"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, policy auth.SignaturePolicy) *httptest.Server {
+	t.Helper()
+	handlers.SetOrderStore(store.NewMemory())
+	t.Cleanup(func() { handlers.SetOrderStore(nil) })
+
+	keyring := auth.NewKeyring(auth.NewMemoryStore())
+	keyring.SetLegacyKey("test-secret")
+	keyring.SetSignaturePolicy(policy)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api", handlers.AuthMiddleware(keyring))
+	retailer := api.Group("/:retailer", handlers.RequireRetailer())
+	retailer.POST("/orders/batch", handlers.RequireScope(auth.ScopeIngest), handlers.ReceiveBatchOrders)
+	retailer.POST("/orders/reprocess", handlers.RequireScope(auth.ScopeIngest), handlers.ReprocessOrders)
+	api.POST("/keys", handlers.RequireScope(auth.ScopeAdmin), handlers.CreateAPIKey(keyring))
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_ImportAndReprocess(t *testing.T) {
+	// Arrange
+	srv := newTestServer(t, auth.DefaultSignaturePolicy())
+	c := New(srv.URL+"/", "test-secret")
+	orders := []models.Order{
+		{OrderNumber: "1001", OrderDate: "2024-01-10"},
+		{OrderNumber: "1002", OrderDate: "2024-01-11"},
+		{OrderNumber: "", OrderDate: "2024-01-12"},
+	}
+
+	// Act
+	imported, err := c.ImportOrders(context.Background(), orders, 2)
+	require.NoError(t, err)
+	reprocessed, err := c.Reprocess(context.Background(), "", "2024-01-11", "2024-01-31")
+	require.NoError(t, err)
+
+	// Assert
+	assert.True(t, imported.Success)
+	assert.Equal(t, 2, imported.ProcessedCount)
+	assert.Equal(t, 1, imported.FailedCount)
+	assert.Len(t, imported.Results, 3)
+	assert.Equal(t, 1, reprocessed.ProcessedCount)
+	assert.Equal(t, "1002", reprocessed.Results[0].OrderNumber)
+}
+
+func TestClient_ImportsEachRetailerThroughItsRoute(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	srv := newTestServer(t, auth.DefaultSignaturePolicy())
+	handlers.SetOrderStore(orders)
+	c := New(srv.URL, "test-secret")
+
+	// Act
+	imported, err := c.ImportOrders(context.Background(), []models.Order{
+		{OrderNumber: "1001", OrderDate: "2024-01-10"},
+		{Retailer: models.RetailerAmazon, OrderNumber: "112-1", OrderDate: "2024-01-11"},
+		{Retailer: models.RetailerAmazon, OrderNumber: "112-2", OrderDate: "2024-01-12"},
+		{Retailer: models.RetailerWalmart, OrderNumber: "1002", OrderDate: "2024-01-13"},
+	}, 10)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 4, imported.ProcessedCount)
+	require.Len(t, imported.Results, 4)
+	assert.Equal(t, "112-1", imported.Results[1].OrderNumber)
+	amazon, err := orders.ListOrders(context.Background(), models.DefaultTenantID, store.OrderFilter{Retailer: models.RetailerAmazon})
+	require.NoError(t, err)
+	assert.Len(t, amazon, 2)
+}
+
+func TestClient_ReprocessesTheGivenRetailer(t *testing.T) {
+	// Arrange
+	srv := newTestServer(t, auth.DefaultSignaturePolicy())
+	c := New(srv.URL, "test-secret")
+	_, err := c.ImportOrders(context.Background(), []models.Order{
+		{OrderNumber: "1001", OrderDate: "2024-01-10"},
+		{Retailer: models.RetailerAmazon, OrderNumber: "112-1", OrderDate: "2024-01-11"},
+	}, 10)
+	require.NoError(t, err)
+
+	// Act
+	amazon, err := c.Reprocess(context.Background(), models.RetailerAmazon, "2024-01-01", "2024-01-31")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, amazon.ProcessedCount)
+	require.Len(t, amazon.Results, 1)
+	assert.Equal(t, "112-1", amazon.Results[0].OrderNumber)
+}
+
+func TestClient_SignedRequests(t *testing.T) {
+	// Arrange
+	policy := auth.DefaultSignaturePolicy()
+	policy.Required = true
+	srv := newTestServer(t, policy)
+
+	// Act
+	created, err := New(srv.URL, "test-secret", WithSignedRequests()).
+		CreateKey(context.Background(), "cli", []string{"ingest"}, time.Hour)
+	require.NoError(t, err)
+	_, importErr := New(srv.URL, created.Key, WithSignedRequests()).
+		ImportOrders(context.Background(), []models.Order{{OrderNumber: "1001"}}, 0)
+	_, unsignedErr := New(srv.URL, created.Key).
+		ImportOrders(context.Background(), []models.Order{{OrderNumber: "1001"}}, 0)
+
+	// Assert
+	assert.Equal(t, "cli", created.APIKey.Name)
+	assert.NotNil(t, created.APIKey.ExpiresAt)
+	assert.NoError(t, importErr)
+	var apiErr *APIError
+	require.ErrorAs(t, unsignedErr, &apiErr)
+	assert.Equal(t, 401, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Message, "signed request required")
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,25 +18,220 @@ type Config struct {
 	OllamaEndpoint string
 	OpenAIAPIKey   string
 	ClaudeAPIKey   string
+
+	// HTTP server
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// StreamTimeout replaces the read and write timeouts for streaming imports;
+	// zero means none
+	StreamTimeout   time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	// Categorization: "ollama", "openai", "claude", or empty when not yet chosen
+	LLMProvider string
+
+	// Monarch: refunds are matched to transactions with each tenant's stored
+	// Monarch token unless MonarchMatchRefunds is off
+	MonarchGraphQLURL   string
+	MonarchTimeout      time.Duration
+	MonarchMatchRefunds bool
+
+	// Health checks
+	HealthCheckTimeout  time.Duration
+	HealthCheckCacheTTL time.Duration
+
+	// Sentry
+	SentrySampleRate       float64
+	SentryTracesSampleRate float64
+	SentryOrderMessages    bool
+	SentrySendOrderDetails bool
+
+	// Logging
+	LogLevel  string
+	LogFormat string
+
+	// Tracing
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingSampleRatio float64
+
+	// API keys and request signing
+	APIKeysFile           string
+	RequireSignedRequests bool
+	SignatureMaxSkew      time.Duration
+
+	// Database: orders are kept in memory when DatabaseURL is empty
+	DatabaseURL             string
+	DatabaseAutoMigrate     bool
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+
+	// Tenants
+	TenantsFile string
+
+	// Credential vault
+	VaultMasterKey     string
+	VaultMasterKeyFile string
+	VaultPreviousKeys  []string
+	CredentialsFile    string
+
+	// Webhooks
+	WebhookURL            string
+	WebhookSecret         string
+	WebhookEvents         []string
+	WebhookCheckpointFile string
+	// WebhookSubscriptionsFile persists subscriptions made through the API; their
+	// secrets are sealed in the credential vault
+	WebhookSubscriptionsFile    string
+	WebhookAllowPrivateNetworks bool
+
+	// Email receipts: the IMAP poller is enabled when IMAPAddr is set
+	IMAPAddr          string
+	IMAPUsername      string
+	IMAPPassword      string
+	IMAPTLS           string
+	IMAPFolder        string
+	IMAPProcessedFlag string
+	IMAPPollInterval  time.Duration
+	IMAPTenant        string
+
+	// Request limits
+	BatchMaxOrders      int
+	BatchWorkers        int
+	MaxRequestBodyBytes int64
+
+	// Rate limits, in the form "<count>/<period>" such as "100/m"; "off" disables
+	RateLimitIP     string
+	RateLimitKey    string
+	RateLimitIngest string
+	RateLimitAdmin  string
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For header is
+	// believed when determining the client IP. None are trusted by default.
+	TrustedProxies []string
+
+	// settings records where each value came from, for config check
+	settings []Setting
 }
 
-// LoadConfig loads configuration from environment variables with fallback to defaults.
-func LoadConfig() *Config {
+// LoadConfig loads configuration from the file named by CONFIG_FILE, if set,
+// overlaid with environment variables, with fallback to defaults. Call Validate to
+// check that the result is safe to run with.
+func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load() // Ignore error as it's OK if .env doesn't exist
 
+	return Load(os.Getenv("CONFIG_FILE"))
+}
+
+// Load loads configuration from a YAML or TOML file, when path is not empty,
+// overlaid with environment variables. File keys are the environment variable
+// names in lower case, optionally nested by prefix, so sentry: {dsn: ...} sets
+// SENTRY_DSN. Unknown file keys and values that cannot be parsed are errors.
+func Load(path string) (*Config, error) {
+	l := &loader{}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = values
+	}
+
 	cfg := &Config{
-		Port:           getEnv("PORT", "8080"),
-		GinMode:        getEnv("GIN_MODE", "debug"),
-		SentryDSN:      getEnv("SENTRY_DSN", ""),
-		ExtensionKey:   getEnv("EXTENSION_SECRET_KEY", "test-secret"),
-		MonarchAPIKey:  getEnv("MONARCH_API_KEY", ""),
-		OllamaEndpoint: getEnv("OLLAMA_ENDPOINT", "http://localhost:11434"),
-		OpenAIAPIKey:   getEnv("OPENAI_API_KEY", ""),
-		ClaudeAPIKey:   getEnv("CLAUDE_API_KEY", ""),
+		Port:           l.getEnv("PORT", "8080"),
+		GinMode:        l.getEnv("GIN_MODE", "debug"),
+		SentryDSN:      l.getSecret("SENTRY_DSN", ""),
+		ExtensionKey:   l.getSecret("EXTENSION_SECRET_KEY", DefaultExtensionKey),
+		MonarchAPIKey:  l.getSecret("MONARCH_API_KEY", ""),
+		OllamaEndpoint: l.getEnv("OLLAMA_ENDPOINT", "http://localhost:11434"),
+		OpenAIAPIKey:   l.getSecret("OPENAI_API_KEY", ""),
+		ClaudeAPIKey:   l.getSecret("CLAUDE_API_KEY", ""),
+
+		ReadTimeout:     l.getEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:    l.getEnvDuration("HTTP_WRITE_TIMEOUT", 5*time.Minute),
+		StreamTimeout:   l.getEnvDuration("HTTP_STREAM_TIMEOUT", time.Hour),
+		IdleTimeout:     l.getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout: l.getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		LLMProvider: l.getEnv("LLM_PROVIDER", ""),
+
+		MonarchGraphQLURL:   l.getEnv("MONARCH_GRAPHQL_URL", "https://api.monarchmoney.com/graphql"),
+		MonarchTimeout:      l.getEnvDuration("MONARCH_TIMEOUT", 30*time.Second),
+		MonarchMatchRefunds: l.getEnvBool("MONARCH_MATCH_REFUNDS", true),
+
+		HealthCheckTimeout:  l.getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		HealthCheckCacheTTL: l.getEnvDuration("HEALTH_CHECK_CACHE_TTL", 30*time.Second),
+
+		SentrySampleRate:       l.getEnvFloat("SENTRY_SAMPLE_RATE", 1.0),
+		SentryTracesSampleRate: l.getEnvFloat("SENTRY_TRACES_SAMPLE_RATE", 0.1),
+		SentryOrderMessages:    l.getEnvBool("SENTRY_ORDER_MESSAGES", false),
+		SentrySendOrderDetails: l.getEnvBool("SENTRY_SEND_ORDER_DETAILS", false),
+
+		LogLevel: l.getEnv("LOG_LEVEL", "info"),
+
+		TracingExporter:    l.getEnv("OTEL_TRACES_EXPORTER", "none"),
+		TracingEndpoint:    l.getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""),
+		TracingSampleRatio: l.getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1.0),
+
+		APIKeysFile:           l.getEnv("API_KEYS_FILE", ""),
+		RequireSignedRequests: l.getEnvBool("REQUIRE_SIGNED_REQUESTS", false),
+		SignatureMaxSkew:      l.getEnvDuration("SIGNATURE_MAX_SKEW", 5*time.Minute),
+
+		DatabaseURL:             l.getSecret("DATABASE_URL", ""),
+		DatabaseAutoMigrate:     l.getEnvBool("DATABASE_AUTO_MIGRATE", true),
+		DatabaseMaxOpenConns:    l.getEnvInt("DATABASE_MAX_OPEN_CONNS", 10),
+		DatabaseMaxIdleConns:    l.getEnvInt("DATABASE_MAX_IDLE_CONNS", 5),
+		DatabaseConnMaxLifetime: l.getEnvDuration("DATABASE_CONN_MAX_LIFETIME", 30*time.Minute),
+
+		TenantsFile: l.getEnv("TENANTS_FILE", ""),
+
+		VaultMasterKey:     l.getEnv("VAULT_MASTER_KEY", ""),
+		VaultMasterKeyFile: l.getEnv("VAULT_MASTER_KEY_FILE", ""),
+		VaultPreviousKeys:  l.getEnvList("VAULT_PREVIOUS_MASTER_KEYS"),
+		CredentialsFile:    l.getEnv("CREDENTIALS_FILE", ""),
+
+		WebhookURL:            l.getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:         l.getEnv("WEBHOOK_SECRET", ""),
+		WebhookEvents:         l.getEnvList("WEBHOOK_EVENTS"),
+		WebhookCheckpointFile: l.getEnv("WEBHOOK_CHECKPOINT_FILE", ""),
+
+		WebhookSubscriptionsFile:    l.getEnv("WEBHOOK_SUBSCRIPTIONS_FILE", ""),
+		WebhookAllowPrivateNetworks: l.getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+
+		IMAPAddr:          l.getEnv("IMAP_ADDR", ""),
+		IMAPUsername:      l.getEnv("IMAP_USERNAME", ""),
+		IMAPPassword:      l.getSecret("IMAP_PASSWORD", ""),
+		IMAPTLS:           l.getEnv("IMAP_TLS", "implicit"),
+		IMAPFolder:        l.getEnv("IMAP_FOLDER", "INBOX"),
+		IMAPProcessedFlag: l.getEnv("IMAP_PROCESSED_FLAG", "$MonarchSynced"),
+		IMAPPollInterval:  l.getEnvDuration("IMAP_POLL_INTERVAL", 5*time.Minute),
+		IMAPTenant:        l.getEnv("IMAP_TENANT", "default"),
+
+		BatchMaxOrders:      l.getEnvInt("BATCH_MAX_ORDERS", 500),
+		BatchWorkers:        l.getEnvInt("BATCH_WORKERS", 4),
+		MaxRequestBodyBytes: int64(l.getEnvInt("MAX_REQUEST_BODY_BYTES", 10<<20)),
+
+		RateLimitIP:     l.getEnv("RATE_LIMIT_IP", "100/m"),
+		RateLimitKey:    l.getEnv("RATE_LIMIT_KEY", "1000/h"),
+		RateLimitIngest: l.getEnv("RATE_LIMIT_INGEST", "off"),
+		RateLimitAdmin:  l.getEnv("RATE_LIMIT_ADMIN", "off"),
+		TrustedProxies:  l.getEnvList("TRUSTED_PROXIES"),
+	}
+
+	// JSON logs in release mode, readable text otherwise
+	defaultLogFormat := "text"
+	if cfg.GinMode == "release" {
+		defaultLogFormat = "json"
 	}
+	cfg.LogFormat = l.getEnv("LOG_FORMAT", defaultLogFormat)
 
-	return cfg
+	if err := l.finish(); err != nil {
+		return nil, err
+	}
+	cfg.settings = l.settings
+	return cfg, nil
 }
 
 // IsSentryEnabled returns true if Sentry error tracking is configured.
@@ -43,10 +239,12 @@ func (c *Config) IsSentryEnabled() bool {
 	return c.SentryDSN != ""
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
+// IsIMAPConfigured returns true if email receipts should be polled from an IMAP folder.
+func (c *Config) IsIMAPConfigured() bool {
+	return c.IMAPAddr != ""
+}
+
+// IsWebhookConfigured returns true if a webhook subscription is configured via environment.
+func (c *Config) IsWebhookConfigured() bool {
+	return c.WebhookURL != "" && c.WebhookSecret != ""
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoadConfig_Defaults(t *testing.T) {
@@ -15,13 +16,18 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	_ = os.Unsetenv("EXTENSION_SECRET_KEY")
 
 	// Act
-	cfg := LoadConfig()
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
 
 	// Assert
 	assert.Equal(t, "8080", cfg.Port)
 	assert.Equal(t, "debug", cfg.GinMode)
 	assert.Equal(t, "", cfg.SentryDSN)
 	assert.Equal(t, "test-secret", cfg.ExtensionKey)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, "text", cfg.LogFormat)
+	assert.Equal(t, "", cfg.DatabaseURL)
+	assert.True(t, cfg.DatabaseAutoMigrate)
 }
 
 func TestLoadConfig_FromEnvironment(t *testing.T) {
@@ -38,13 +44,15 @@ func TestLoadConfig_FromEnvironment(t *testing.T) {
 	}()
 
 	// Act
-	cfg := LoadConfig()
+	cfg, err := LoadConfig()
+	require.NoError(t, err)
 
 	// Assert
 	assert.Equal(t, "3000", cfg.Port)
 	assert.Equal(t, "release", cfg.GinMode)
 	assert.Equal(t, "https://test@sentry.io/123", cfg.SentryDSN)
 	assert.Equal(t, "my-secret", cfg.ExtensionKey)
+	assert.Equal(t, "json", cfg.LogFormat)
 }
 
 func TestConfig_IsSentryEnabled(t *testing.T) {
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources a setting can come from.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	// SourceSecretFile is a value read from the file named by a *_FILE setting.
+	SourceSecretFile = "secret file"
+)
+
+// redacted replaces secret values in Settings.
+const redacted = "[redacted]"
+
+// secretKeys are settings whose values are never printed.
+var secretKeys = map[string]bool{
+	"EXTENSION_SECRET_KEY":       true,
+	"MONARCH_API_KEY":            true,
+	"OPENAI_API_KEY":             true,
+	"CLAUDE_API_KEY":             true,
+	"DATABASE_URL":               true,
+	"IMAP_PASSWORD":              true,
+	"SENTRY_DSN":                 true,
+	"VAULT_MASTER_KEY":           true,
+	"VAULT_PREVIOUS_MASTER_KEYS": true,
+	"WEBHOOK_SECRET":             true,
+}
+
+// Setting is one effective configuration value and where it came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings returns every effective setting in load order, with secrets redacted.
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, len(c.settings))
+	for i, s := range c.settings {
+		if secretKeys[s.Key] && s.Value != "" {
+			s.Value = redacted
+		}
+		settings[i] = s
+	}
+	return settings
+}
+
+// loader resolves settings from the environment, then the config file, then
+// defaults, recording parse errors instead of silently using the default.
+type loader struct {
+	file     map[string]string
+	used     map[string]bool
+	settings []Setting
+	errs     []error
+}
+
+func (l *loader) lookup(key, defaultValue string) string {
+	value, source := l.resolve(key)
+	if source == "" {
+		value, source = defaultValue, SourceDefault
+	}
+	l.record(key, value, source)
+	return value
+}
+
+// resolve returns a setting from the environment or the config file, with an
+// empty source when it is set in neither.
+func (l *loader) resolve(key string) (value, source string) {
+	if v := os.Getenv(key); v != "" {
+		return v, SourceEnv
+	}
+	if v := l.file[key]; v != "" {
+		return v, SourceFile
+	}
+	return "", ""
+}
+
+func (l *loader) record(key, value, source string) {
+	if l.used == nil {
+		l.used = make(map[string]bool)
+	}
+	l.used[key] = true
+	l.settings = append(l.settings, Setting{Key: key, Value: value, Source: source})
+}
+
+func (l *loader) invalid(key, value, kind string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: invalid %s %q", key, kind, value))
+}
+
+func (l *loader) getEnv(key, defaultValue string) string {
+	return l.lookup(key, defaultValue)
+}
+
+// getSecret reads a secret from key, or from the file named by key_FILE as mounted
+// by Docker and Kubernetes secrets. Trailing newlines in the file are ignored.
+func (l *loader) getSecret(key, defaultValue string) string {
+	path := l.lookup(key+"_FILE", "")
+	if path == "" {
+		return l.lookup(key, defaultValue)
+	}
+	if _, source := l.resolve(key); source != "" {
+		l.errs = append(l.errs, fmt.Errorf("%s and %s_FILE are mutually exclusive", key, key))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		l.record(key, defaultValue, SourceDefault)
+		return defaultValue
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	l.record(key, value, SourceSecretFile)
+	return value
+}
+
+func (l *loader) getEnvInt(key string, defaultValue int) int {
+	raw := l.lookup(key, strconv.Itoa(defaultValue))
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		l.invalid(key, raw, "integer")
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) getEnvBool(key string, defaultValue bool) bool {
+	raw := l.lookup(key, strconv.FormatBool(defaultValue))
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.invalid(key, raw, "boolean")
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration parses a duration such as "30s".
+func (l *loader) getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	raw := l.lookup(key, defaultValue.String())
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		l.invalid(key, raw, "duration")
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) getEnvFloat(key string, defaultValue float64) float64 {
+	raw := l.lookup(key, strconv.FormatFloat(defaultValue, 'g', -1, 64))
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.invalid(key, raw, "number")
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList splits a comma-separated value, ignoring blank entries.
+func (l *loader) getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(l.lookup(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// finish reports parse errors and config file keys that match no setting.
+func (l *loader) finish() error {
+	var unknown []string
+	for key := range l.file {
+		if !l.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		l.errs = append(l.errs, fmt.Errorf("config file: unknown setting %q", strings.ToLower(key)))
+	}
+	return errors.Join(l.errs...)
+}
+
+// readFile reads a YAML or TOML config file, chosen by extension, into settings
+// keyed by environment variable name.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format (want .yaml, .yml, or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", raw, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten converts nested tables to environment variable names, joining keys with
+// underscores, and formats values as they would appear in the environment.
+func flatten(prefix string, raw map[string]interface{}, values map[string]string) error {
+	for k, v := range raw {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, v, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := scalar(key, item)
+				if err != nil {
+					return err
+				}
+				items = append(items, s)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(key, v)
+			if err != nil {
+				return err
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s: unsupported value of type %T", strings.ToLower(key), v)
+	}
+}
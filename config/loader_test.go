@@ -0,0 +1,197 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_YAMLFile(t *testing.T) {
+	// Arrange
+	t.Setenv("PORT", "")
+	t.Setenv("BATCH_WORKERS", "16")
+	path := writeConfigFile(t, "config.yaml", `
+port: 9090
+gin_mode: release
+extension_secret_key: file-secret
+batch_workers: 2
+shutdown_timeout: 45s
+webhook_events: [order.failed, order.needs_review]
+sentry:
+  dsn: https://key@sentry.example/1
+  sample_rate: 0.5
+rate-limit:
+  ip: 10/s
+`)
+
+	// Act
+	cfg, err := Load(path)
+
+	// Assert: the environment overrides the file
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.Port)
+	assert.Equal(t, "release", cfg.GinMode)
+	assert.Equal(t, "file-secret", cfg.ExtensionKey)
+	assert.Equal(t, 16, cfg.BatchWorkers)
+	assert.Equal(t, 45*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, []string{"order.failed", "order.needs_review"}, cfg.WebhookEvents)
+	assert.Equal(t, "https://key@sentry.example/1", cfg.SentryDSN)
+	assert.Equal(t, 0.5, cfg.SentrySampleRate)
+	assert.Equal(t, "10/s", cfg.RateLimitIP)
+	assert.Equal(t, "json", cfg.LogFormat)
+}
+
+func TestLoad_TOMLFile(t *testing.T) {
+	// Arrange
+	path := writeConfigFile(t, "config.toml", `
+llm_provider = "ollama"
+
+[ollama]
+endpoint = "http://ollama:11434"
+`)
+
+	// Act
+	cfg, err := Load(path)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", cfg.LLMProvider)
+	assert.Equal(t, "http://ollama:11434", cfg.OllamaEndpoint)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "unknown file key",
+			file:    "config.yaml",
+			content: "prot: 8080\n",
+			wantErr: `config file: unknown setting "prot"`,
+		},
+		{
+			name:    "invalid file value",
+			file:    "config.yaml",
+			content: "batch_workers: many\n",
+			wantErr: `BATCH_WORKERS: invalid integer "many"`,
+		},
+		{
+			name:    "invalid env value",
+			env:     map[string]string{"SHUTDOWN_TIMEOUT": "soon"},
+			wantErr: `SHUTDOWN_TIMEOUT: invalid duration "soon"`,
+		},
+		{
+			name:    "unsupported format",
+			file:    "config.json",
+			content: "{}",
+			wantErr: "unsupported format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var path string
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file, tt.content)
+			}
+
+			// Act
+			_, err := Load(path)
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfig_Settings(t *testing.T) {
+	// Arrange
+	t.Setenv("MONARCH_API_KEY", "monarch-token")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	// Act
+	cfg, err := Load("")
+	require.NoError(t, err)
+	settings := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+
+	// Assert
+	assert.Equal(t, Setting{Key: "MONARCH_API_KEY", Value: "[redacted]", Source: SourceEnv}, settings["MONARCH_API_KEY"])
+	assert.Equal(t, Setting{Key: "LOG_LEVEL", Value: "debug", Source: SourceEnv}, settings["LOG_LEVEL"])
+	assert.Equal(t, SourceDefault, settings["PORT"].Source)
+	assert.Equal(t, "", settings["OPENAI_API_KEY"].Value)
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "extension_key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("mounted-secret\n"), 0o600))
+	t.Setenv("EXTENSION_SECRET_KEY", "")
+	t.Setenv("EXTENSION_SECRET_KEY_FILE", keyFile)
+	configFile := writeConfigFile(t, "config.yaml", "claude_api_key_file: "+keyFile+"\n")
+
+	// Act
+	cfg, err := Load(configFile)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "mounted-secret", cfg.ExtensionKey)
+	assert.Equal(t, "mounted-secret", cfg.ClaudeAPIKey)
+	for _, s := range cfg.Settings() {
+		if s.Key == "EXTENSION_SECRET_KEY" {
+			assert.Equal(t, Setting{Key: s.Key, Value: "[redacted]", Source: SourceSecretFile}, s)
+		}
+	}
+}
+
+func TestLoad_SecretFileErrors(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		// Arrange
+		t.Setenv("MONARCH_API_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		// Act
+		_, err := Load("")
+
+		// Assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MONARCH_API_KEY_FILE")
+	})
+
+	t.Run("both set", func(t *testing.T) {
+		// Arrange
+		keyFile := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(keyFile, []byte("from-file"), 0o600))
+		t.Setenv("OPENAI_API_KEY", "from-env")
+		t.Setenv("OPENAI_API_KEY_FILE", keyFile)
+
+		// Act
+		_, err := Load("")
+
+		// Assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "OPENAI_API_KEY and OPENAI_API_KEY_FILE are mutually exclusive")
+	})
+}
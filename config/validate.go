@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"monarchmoney-sync-backend/ratelimit"
+)
+
+// DefaultExtensionKey is the development shared secret used when
+// EXTENSION_SECRET_KEY is unset. It is rejected in release mode.
+const DefaultExtensionKey = "test-secret"
+
+// LLM providers accepted by LLM_PROVIDER.
+const (
+	LLMProviderOllama = "ollama"
+	LLMProviderOpenAI = "openai"
+	LLMProviderClaude = "claude"
+)
+
+// Validate reports insecure or inconsistent settings. Every problem is included in
+// the returned error, not just the first.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	release := c.GinMode == "release"
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		fail("PORT: must be a number between 1 and 65535, got %q", c.Port)
+	}
+	switch c.GinMode {
+	case "debug", "release", "test":
+	default:
+		fail("GIN_MODE: must be debug, release, or test, got %q", c.GinMode)
+	}
+	if release && c.ExtensionKey == DefaultExtensionKey {
+		fail("EXTENSION_SECRET_KEY: the default development key is not allowed in release mode")
+	}
+
+	switch c.LLMProvider {
+	case "":
+		if release {
+			fail("LLM_PROVIDER: no categorizer configured (want ollama, openai, or claude)")
+		}
+	case LLMProviderOllama:
+		if u, err := url.Parse(c.OllamaEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("OLLAMA_ENDPOINT: must be an absolute URL when LLM_PROVIDER is ollama, got %q", c.OllamaEndpoint)
+		}
+	case LLMProviderOpenAI, LLMProviderClaude:
+		// API keys may be stored per tenant in the vault instead of the environment
+	default:
+		fail("LLM_PROVIDER: unknown provider %q (want ollama, openai, or claude)", c.LLMProvider)
+	}
+
+	if u, err := url.Parse(c.MonarchGraphQLURL); err != nil || u.Scheme == "" || u.Host == "" {
+		fail("MONARCH_GRAPHQL_URL: must be an absolute URL, got %q", c.MonarchGraphQLURL)
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("LOG_LEVEL: must be debug, info, warn, or error, got %q", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "json", "text":
+	default:
+		fail("LOG_FORMAT: must be json or text, got %q", c.LogFormat)
+	}
+	switch c.TracingExporter {
+	case "", "none", "otlp", "stdout":
+	default:
+		fail("OTEL_TRACES_EXPORTER: must be none, otlp, or stdout, got %q", c.TracingExporter)
+	}
+
+	for _, r := range []struct {
+		key   string
+		value float64
+	}{
+		{"SENTRY_SAMPLE_RATE", c.SentrySampleRate},
+		{"SENTRY_TRACES_SAMPLE_RATE", c.SentryTracesSampleRate},
+		{"OTEL_TRACES_SAMPLER_ARG", c.TracingSampleRatio},
+	} {
+		if r.value < 0 || r.value > 1 {
+			fail("%s: must be between 0 and 1, got %g", r.key, r.value)
+		}
+	}
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout},
+		{"MONARCH_TIMEOUT", c.MonarchTimeout},
+		{"SIGNATURE_MAX_SKEW", c.SignatureMaxSkew},
+		{"DATABASE_CONN_MAX_LIFETIME", c.DatabaseConnMaxLifetime},
+	} {
+		if d.value <= 0 {
+			fail("%s: must be positive", d.key)
+		}
+	}
+	if c.StreamTimeout < 0 {
+		fail("HTTP_STREAM_TIMEOUT: must not be negative")
+	}
+	for _, n := range []struct {
+		key   string
+		value int64
+	}{
+		{"BATCH_MAX_ORDERS", int64(c.BatchMaxOrders)},
+		{"BATCH_WORKERS", int64(c.BatchWorkers)},
+		{"MAX_REQUEST_BODY_BYTES", c.MaxRequestBodyBytes},
+		{"DATABASE_MAX_OPEN_CONNS", int64(c.DatabaseMaxOpenConns)},
+	} {
+		if n.value <= 0 {
+			fail("%s: must be positive, got %d", n.key, n.value)
+		}
+	}
+
+	for _, l := range []struct {
+		key   string
+		value string
+	}{
+		{"RATE_LIMIT_IP", c.RateLimitIP},
+		{"RATE_LIMIT_KEY", c.RateLimitKey},
+		{"RATE_LIMIT_INGEST", c.RateLimitIngest},
+		{"RATE_LIMIT_ADMIN", c.RateLimitAdmin},
+	} {
+		if _, err := ratelimit.ParseLimit(l.value); err != nil {
+			fail("%s: %v", l.key, err)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				fail("TRUSTED_PROXIES: %q is not an IP address or CIDR", proxy)
+			}
+		}
+	}
+
+	if c.DatabaseMaxIdleConns < 0 || c.DatabaseMaxIdleConns > c.DatabaseMaxOpenConns {
+		fail("DATABASE_MAX_IDLE_CONNS: must be between 0 and DATABASE_MAX_OPEN_CONNS, got %d", c.DatabaseMaxIdleConns)
+	}
+	if (c.WebhookURL == "") != (c.WebhookSecret == "") {
+		fail("WEBHOOK_URL and WEBHOOK_SECRET must be set together")
+	}
+	if c.CredentialsFile != "" && c.VaultMasterKey == "" && c.VaultMasterKeyFile == "" {
+		fail("CREDENTIALS_FILE: requires VAULT_MASTER_KEY or VAULT_MASTER_KEY_FILE")
+	}
+	if c.WebhookSubscriptionsFile != "" && c.CredentialsFile == "" {
+		fail("WEBHOOK_SUBSCRIPTIONS_FILE: requires CREDENTIALS_FILE to keep subscription secrets")
+	}
+	if c.VaultMasterKey != "" && c.VaultMasterKeyFile != "" {
+		fail("VAULT_MASTER_KEY and VAULT_MASTER_KEY_FILE are mutually exclusive")
+	}
+	if c.IsIMAPConfigured() {
+		if _, port, err := net.SplitHostPort(c.IMAPAddr); err != nil || port == "" {
+			fail("IMAP_ADDR: must be host:port, got %q", c.IMAPAddr)
+		}
+		if c.IMAPUsername == "" || c.IMAPPassword == "" {
+			fail("IMAP_USERNAME and IMAP_PASSWORD are required when IMAP_ADDR is set")
+		}
+		switch c.IMAPTLS {
+		case "implicit", "starttls":
+		case "none":
+			if release {
+				fail("IMAP_TLS: none sends the password in the clear and is not allowed in release mode")
+			}
+		default:
+			fail("IMAP_TLS: must be implicit, starttls, or none, got %q", c.IMAPTLS)
+		}
+		if c.IMAPFolder == "" {
+			fail("IMAP_FOLDER: must not be empty")
+		}
+		// A keyword is an IMAP atom; \Seen is the only system flag that makes sense
+		if c.IMAPProcessedFlag != `\Seen` && (c.IMAPProcessedFlag == "" || strings.ContainsAny(c.IMAPProcessedFlag, ` (){%*"\]`)) {
+			fail("IMAP_PROCESSED_FLAG: must be \\Seen or a keyword such as $MonarchSynced, got %q", c.IMAPProcessedFlag)
+		}
+		if c.IMAPPollInterval < 10*time.Second {
+			fail("IMAP_POLL_INTERVAL: must be at least 10s, got %s", c.IMAPPollInterval)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := Load("")
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestConfig_Validate_Defaults(t *testing.T) {
+	// Act & Assert: the development defaults are valid outside release mode
+	assert.NoError(t, validConfig(t).Validate())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"invalid port", func(c *Config) { c.Port = "http" }, "PORT: must be a number"},
+		{"port out of range", func(c *Config) { c.Port = "70000" }, "PORT: must be a number"},
+		{"unknown gin mode", func(c *Config) { c.GinMode = "prod" }, "GIN_MODE"},
+		{"default secret in release", func(c *Config) {
+			c.GinMode = "release"
+			c.LLMProvider = LLMProviderOllama
+		}, "EXTENSION_SECRET_KEY: the default development key"},
+		{"no categorizer in release", func(c *Config) {
+			c.GinMode = "release"
+			c.ExtensionKey = "real-secret"
+		}, "LLM_PROVIDER: no categorizer configured"},
+		{"unknown categorizer", func(c *Config) { c.LLMProvider = "gemini" }, `unknown provider "gemini"`},
+		{"ollama without endpoint", func(c *Config) {
+			c.LLMProvider = LLMProviderOllama
+			c.OllamaEndpoint = ""
+		}, "OLLAMA_ENDPOINT"},
+		{"relative monarch url", func(c *Config) { c.MonarchGraphQLURL = "/graphql" }, "MONARCH_GRAPHQL_URL"},
+		{"zero monarch timeout", func(c *Config) { c.MonarchTimeout = 0 }, "MONARCH_TIMEOUT: must be positive"},
+		{"sample rate out of range", func(c *Config) { c.SentrySampleRate = 2 }, "SENTRY_SAMPLE_RATE"},
+		{"zero shutdown timeout", func(c *Config) { c.ShutdownTimeout = 0 }, "SHUTDOWN_TIMEOUT: must be positive"},
+		{"invalid rate limit", func(c *Config) { c.RateLimitIP = "lots" }, "RATE_LIMIT_IP"},
+		{"webhook without secret", func(c *Config) { c.WebhookURL = "https://example.com/hook" }, "WEBHOOK_URL and WEBHOOK_SECRET"},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, "TRUSTED_PROXIES"},
+		{"idle connections above pool size", func(c *Config) { c.DatabaseMaxIdleConns = 50 }, "DATABASE_MAX_IDLE_CONNS"},
+		{"credentials file without key", func(c *Config) { c.CredentialsFile = "creds.json" }, "CREDENTIALS_FILE"},
+		{"webhook subscriptions without credentials file", func(c *Config) { c.WebhookSubscriptionsFile = "webhooks.json" }, "WEBHOOK_SUBSCRIPTIONS_FILE"},
+		{"imap without port", func(c *Config) { withIMAP(c); c.IMAPAddr = "imap.example.com" }, "IMAP_ADDR: must be host:port"},
+		{"imap without password", func(c *Config) { withIMAP(c); c.IMAPPassword = "" }, "IMAP_USERNAME and IMAP_PASSWORD"},
+		{"unknown imap tls mode", func(c *Config) { withIMAP(c); c.IMAPTLS = "ssl" }, "IMAP_TLS: must be"},
+		{"imap without tls in release", func(c *Config) {
+			withIMAP(c)
+			c.GinMode = "release"
+			c.IMAPTLS = "none"
+		}, "IMAP_TLS: none sends the password in the clear"},
+		{"imap system flag", func(c *Config) { withIMAP(c); c.IMAPProcessedFlag = `\Flagged` }, "IMAP_PROCESSED_FLAG"},
+		{"imap flag with space", func(c *Config) { withIMAP(c); c.IMAPProcessedFlag = "Monarch Synced" }, "IMAP_PROCESSED_FLAG"},
+		{"imap poll interval too short", func(c *Config) { withIMAP(c); c.IMAPPollInterval = time.Second }, "IMAP_POLL_INTERVAL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			cfg := validConfig(t)
+			tt.modify(cfg)
+
+			// Act
+			err := cfg.Validate()
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+// withIMAP enables the IMAP poller with valid settings.
+func withIMAP(c *Config) {
+	c.IMAPAddr = "imap.example.com:993"
+	c.IMAPUsername = "receipts@example.com"
+	c.IMAPPassword = "app-password"
+}
+
+func TestConfig_Validate_IMAP(t *testing.T) {
+	// Arrange
+	cfg := validConfig(t)
+	withIMAP(cfg)
+
+	// Act & Assert
+	assert.NoError(t, cfg.Validate())
+	cfg.IMAPProcessedFlag = `\Seen`
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfig_Validate_ReportsEveryProblem(t *testing.T) {
+	// Arrange
+	cfg := validConfig(t)
+	cfg.Port = "0"
+	cfg.LogLevel = "loud"
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PORT")
+	assert.Contains(t, err.Error(), "LOG_LEVEL")
+}
+
+func TestConfig_Validate_Release(t *testing.T) {
+	// Arrange
+	cfg := validConfig(t)
+	cfg.GinMode = "release"
+	cfg.ExtensionKey = "real-secret"
+	cfg.LLMProvider = LLMProviderOpenAI
+
+	// Act & Assert
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audited actions.
+const (
+	AuditCredentialSet    = "credential.set"
+	AuditCredentialDelete = "credential.delete"
+	AuditAPIKeyCreate     = "api_key.create"
+	AuditAPIKeyRotate     = "api_key.rotate"
+	AuditAPIKeyRevoke     = "api_key.revoke"
+	AuditRefundSplit      = "refund.split"
+)
+
+// auditLog records changes to credentials, API keys, and Monarch transactions. It
+// defaults to an in-memory log so handlers work without a database configured.
+var auditLog store.AuditStore = store.NewMemory()
+
+// SetAuditLog sets the store that audited changes are recorded in.
+func SetAuditLog(s store.AuditStore) {
+	if s == nil {
+		s = store.NewMemory()
+	}
+	auditLog = s
+}
+
+// audit records a change made by actor. The change has already happened, so a
+// failure to record it is logged rather than returned.
+func audit(ctx context.Context, tenantID, actor, action, subject string, details any) {
+	entry := &store.AuditEntry{
+		TenantID: tenantID,
+		Actor:    actor,
+		Action:   action,
+		Subject:  subject,
+	}
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err == nil {
+			entry.Details = data
+		}
+	}
+	if err := auditLog.AppendAudit(ctx, entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to record audit entry", "action", action, "subject", subject, "error", err)
+	}
+}
+
+// auditRequest records a change made by the request's API key in its tenant.
+func auditRequest(c *gin.Context, action, subject string, details any) {
+	audit(c.Request.Context(), TenantIDFromContext(c), requestActor(c), action, subject, details)
+}
+
+// requestActor names who made a request: its API key's ID.
+func requestActor(c *gin.Context) string {
+	if key := APIKeyFromContext(c); key != nil {
+		return "key:" + key.ID
+	}
+	return "anonymous"
+}
+
+// ListAudit returns the caller's tenant's audit log, newest first. The optional
+// subject query parameter, such as "transaction:123", narrows it to one subject
+// and limit caps the number of entries, 100 by default.
+func ListAudit(c *gin.Context) {
+	filter := store.AuditFilter{Subject: c.Query("subject"), Limit: 100}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid limit: must be a positive integer",
+			})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := auditLog.ListAudit(c.Request.Context(), TenantIDFromContext(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to list audit entries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
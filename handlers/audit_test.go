@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAudit_RecordsCredentialChanges(t *testing.T) {
+	// Arrange
+	SetAuditLog(store.NewMemory())
+	t.Cleanup(func() { SetAuditLog(nil) })
+	keyring := newTestKeyring()
+	router, _ := newCredentialRouter(t, keyring)
+	router.GET("/api/audit", AuthMiddleware(keyring), RequireScope(auth.ScopeAdmin), ListAudit)
+	doKeyRequest(router, "PUT", "/api/credentials/monarch_token", "test-secret", []byte(`{"value":"token-1234"}`))
+	doKeyRequest(router, "DELETE", "/api/credentials/monarch_token", "test-secret", nil)
+
+	// Act
+	w := doKeyRequest(router, "GET", "/api/audit?subject=credential:monarch_token", "test-secret", nil)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "token-1234")
+	var response struct {
+		Entries []store.AuditEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, AuditCredentialDelete, response.Entries[0].Action)
+	assert.Equal(t, AuditCredentialSet, response.Entries[1].Action)
+	assert.Equal(t, "key:"+auth.LegacyKeyID, response.Entries[1].Actor)
+}
+
+func TestListAudit_InvalidLimit(t *testing.T) {
+	keyring := newTestKeyring()
+	router, _ := newCredentialRouter(t, keyring)
+	router.GET("/api/audit", AuthMiddleware(keyring), ListAudit)
+
+	w := doKeyRequest(router, "GET", "/api/audit?limit=0", "test-secret", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
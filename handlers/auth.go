@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyContextKey is the gin context key holding the authenticated *auth.APIKey.
+const apiKeyContextKey = "apiKey"
+
+// AuthMiddleware validates requests using the X-Extension-Key header, or an HMAC
+// signature (X-Key-ID, X-Timestamp, X-Nonce, X-Signature) when one is present.
+// When the keyring's signature policy requires it, unsigned requests are rejected.
+func AuthMiddleware(keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			key *auth.APIKey
+			err error
+		)
+
+		switch {
+		case c.GetHeader(auth.HeaderSignature) != "":
+			var cleanup func()
+			key, cleanup, err = authenticateSigned(c, keyring)
+			defer cleanup()
+		case keyring.SignaturePolicy().Required:
+			err = auth.ErrMissingSignature
+		default:
+			key, err = keyring.Authenticate(c.GetHeader("X-Extension-Key"))
+		}
+
+		if err != nil {
+			message := "Unauthorized: Missing or invalid extension key"
+			if !errors.Is(err, auth.ErrInvalidKey) {
+				message = fmt.Sprintf("Unauthorized: %v", err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": message,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// authenticateSigned verifies the request signature. A request that sends its body's
+// digest in X-Content-SHA256 has its body spooled to a temporary file and checked
+// against it before handlers run, so streams of any size can be signed; otherwise
+// the body is buffered, up to the policy's limit. Either way the body is restored
+// for downstream handlers, and cleanup removes what was spooled once they are done.
+func authenticateSigned(c *gin.Context, keyring *auth.Keyring) (key *auth.APIKey, cleanup func(), err error) {
+	cleanup = func() {}
+	digest := c.GetHeader(auth.HeaderContentSHA256)
+	var body []byte
+	switch {
+	case digest != "":
+		if !auth.ValidBodyDigest(digest) {
+			return nil, cleanup, auth.ErrInvalidSignature
+		}
+	case c.Request.Body != nil:
+		limit := keyring.SignaturePolicy().MaxBodyBytes
+		body, err = io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+		if err != nil {
+			return nil, cleanup, auth.ErrInvalidSignature
+		}
+		if int64(len(body)) > limit {
+			return nil, cleanup, fmt.Errorf("signed request body exceeds %d bytes", limit)
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key, err = keyring.AuthenticateSigned(auth.SignedRequest{
+		KeyID:      c.GetHeader(auth.HeaderKeyID),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.RequestURI(),
+		Timestamp:  c.GetHeader(auth.HeaderTimestamp),
+		Nonce:      c.GetHeader(auth.HeaderNonce),
+		Body:       body,
+		BodyDigest: digest,
+		Signature:  c.GetHeader(auth.HeaderSignature),
+	})
+	if err != nil || digest == "" || c.Request.Body == nil {
+		return key, cleanup, err
+	}
+
+	// The signature is checked first so only signed bodies are spooled
+	spooled, err := spoolBody(c.Request.Body, digest)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	c.Request.Body = spooled
+	return key, func() { _ = spooled.Close() }, nil
+}
+
+// spoolBody copies a body to a temporary file and checks it against the digest it
+// was signed with, so no handler acts on a body that was tampered with. The file
+// is removed when it is closed.
+func spoolBody(body io.Reader, digest string) (*spooledBody, error) {
+	f, err := os.CreateTemp("", "signed-body-*")
+	if err != nil {
+		return nil, fmt.Errorf("spool request body: %w", err)
+	}
+	spooled := &spooledBody{f}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), body); err != nil {
+		_ = spooled.Close()
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), digest) {
+		_ = spooled.Close()
+		return nil, auth.ErrBodyDigest
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = spooled.Close()
+		return nil, fmt.Errorf("spool request body: %w", err)
+	}
+	return spooled, nil
+}
+
+// spooledBody is a request body spooled to a temporary file, which Close removes.
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	if rmErr := os.Remove(b.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// RequireScope rejects requests whose API key does not grant scope.
+// It must run after AuthMiddleware.
+func RequireScope(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKeyFromContext(c)
+		if key == nil || !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Forbidden: API key lacks %q scope", scope),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, if any.
+func APIKeyFromContext(c *gin.Context) *auth.APIKey {
+	value, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil
+	}
+	key, _ := value.(*auth.APIKey)
+	return key
+}
+
+// TenantIDFromContext returns the tenant that owns the authenticating API key.
+// Requests without a key belong to the default tenant.
+func TenantIDFromContext(c *gin.Context) string {
+	if key := APIKeyFromContext(c); key != nil && key.TenantID != "" {
+		return key.TenantID
+	}
+	return models.DefaultTenantID
+}
+
+// CreateAPIKeyRequest is the body accepted by CreateAPIKey.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresIn is an optional Go duration such as "720h".
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+// RotateAPIKeyRequest is the body accepted by RotateAPIKey.
+type RotateAPIKeyRequest struct {
+	// Overlap is how long the old key keeps working, as a Go duration. Defaults to 24h.
+	Overlap string `json:"overlap,omitempty"`
+}
+
+// APIKeyResponse returns a newly issued key. The plaintext key is only shown once.
+type APIKeyResponse struct {
+	Key    string       `json:"key"`
+	APIKey *auth.APIKey `json:"apiKey"`
+}
+
+// defaultRotationOverlap is how long a rotated key remains valid when no overlap is given.
+const defaultRotationOverlap = 24 * time.Hour
+
+// ListAPIKeys returns metadata for every API key issued to the caller's tenant.
+func ListAPIKeys(keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := keyring.List(TenantIDFromContext(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to list API keys",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"keys": keys,
+		})
+	}
+}
+
+// CreateAPIKey issues a new named API key.
+func CreateAPIKey(keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+			})
+			return
+		}
+
+		scopes, err := auth.ParseScopes(req.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		var ttl time.Duration
+		if req.ExpiresIn != "" {
+			if ttl, err = time.ParseDuration(req.ExpiresIn); err != nil || ttl <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid expiresIn: must be a positive duration such as 720h",
+				})
+				return
+			}
+		}
+
+		// Only callers that manage tenants may hand out that ability
+		caller := APIKeyFromContext(c)
+		for _, s := range scopes {
+			if s == auth.ScopeTenants && (caller == nil || !caller.HasScope(auth.ScopeTenants)) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  "error",
+					"message": "Forbidden: cannot grant scope tenants",
+				})
+				return
+			}
+		}
+
+		plaintext, key, err := keyring.Create(TenantIDFromContext(c), req.Name, scopes, ttl)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		auditRequest(c, AuditAPIKeyCreate, "api_key:"+key.ID, gin.H{"name": key.Name, "scopes": key.Scopes})
+		c.JSON(http.StatusCreated, APIKeyResponse{Key: plaintext, APIKey: key})
+	}
+}
+
+// RotateAPIKey issues a replacement key and schedules the old one to expire.
+func RotateAPIKey(keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RotateAPIKeyRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+				})
+				return
+			}
+		}
+
+		overlap := defaultRotationOverlap
+		if req.Overlap != "" {
+			var err error
+			if overlap, err = time.ParseDuration(req.Overlap); err != nil || overlap < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid overlap: must be a non-negative duration such as 24h",
+				})
+				return
+			}
+		}
+
+		plaintext, key, err := keyring.Rotate(TenantIDFromContext(c), c.Param("id"), overlap)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, auth.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		auditRequest(c, AuditAPIKeyRotate, "api_key:"+c.Param("id"), gin.H{"replacement": key.ID, "overlap": overlap.String()})
+		c.JSON(http.StatusCreated, APIKeyResponse{Key: plaintext, APIKey: key})
+	}
+}
+
+// RevokeAPIKey immediately disables an API key.
+func RevokeAPIKey(keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := keyring.Revoke(TenantIDFromContext(c), c.Param("id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, auth.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		auditRequest(c, AuditAPIKeyRevoke, "api_key:"+key.ID, nil)
+		c.JSON(http.StatusOK, key)
+	}
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKeyring returns a keyring that accepts the legacy "test-secret" key.
+func newTestKeyring() *auth.Keyring {
+	keyring := auth.NewKeyring(auth.NewMemoryStore())
+	keyring.SetLegacyKey("test-secret")
+	return keyring
+}
+
+func newKeyRouter(keyring *auth.Keyring) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api", AuthMiddleware(keyring))
+	api.POST("/ingest", RequireScope(auth.ScopeIngest), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"key": APIKeyFromContext(c).ID})
+	})
+	keys := api.Group("/keys", RequireScope(auth.ScopeAdmin))
+	keys.GET("", ListAPIKeys(keyring))
+	keys.POST("", CreateAPIKey(keyring))
+	keys.POST("/:id/rotate", RotateAPIKey(keyring))
+	keys.DELETE("/:id", RevokeAPIKey(keyring))
+	return router
+}
+
+func doKeyRequest(router *gin.Engine, method, path, key string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Extension-Key", key)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddleware_NamedKeyScopes(t *testing.T) {
+	// Arrange
+	keyring := newTestKeyring()
+	router := newKeyRouter(keyring)
+	readKey, _, err := keyring.Create(models.DefaultTenantID, "dashboard", []auth.Scope{auth.ScopeRead}, 0)
+	require.NoError(t, err)
+	ingestKey, ingest, err := keyring.Create(models.DefaultTenantID, "chrome", []auth.Scope{auth.ScopeIngest}, 0)
+	require.NoError(t, err)
+
+	// Act & Assert - ingest key can ingest but not administer keys
+	w := doKeyRequest(router, "POST", "/api/ingest", ingestKey, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), ingest.ID)
+
+	w = doKeyRequest(router, "GET", "/api/keys", ingestKey, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// read-only key cannot ingest
+	w = doKeyRequest(router, "POST", "/api/ingest", readKey, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "ingest")
+}
+
+func TestAPIKeyEndpoints_CreateRotateRevoke(t *testing.T) {
+	// Arrange
+	keyring := newTestKeyring()
+	router := newKeyRouter(keyring)
+
+	// Act - create a key with the legacy admin key
+	w := doKeyRequest(router, "POST", "/api/keys", "test-secret",
+		[]byte(`{"name":"chrome-laptop","scopes":["ingest"],"expiresIn":"720h"}`))
+
+	// Assert
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Key)
+	assert.NotNil(t, created.APIKey.ExpiresAt)
+	assert.NotContains(t, w.Body.String(), "hash")
+
+	w = doKeyRequest(router, "POST", "/api/ingest", created.Key, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Act - rotate with overlap, both keys work
+	w = doKeyRequest(router, "POST", "/api/keys/"+created.APIKey.ID+"/rotate", "test-secret",
+		[]byte(`{"overlap":"1h"}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var rotated APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+	assert.Equal(t, created.APIKey.ID, rotated.APIKey.RotatedFrom)
+
+	assert.Equal(t, http.StatusOK, doKeyRequest(router, "POST", "/api/ingest", created.Key, nil).Code)
+	assert.Equal(t, http.StatusOK, doKeyRequest(router, "POST", "/api/ingest", rotated.Key, nil).Code)
+
+	// Act - revoke the old key
+	w = doKeyRequest(router, "DELETE", "/api/keys/"+created.APIKey.ID, "test-secret", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doKeyRequest(router, "POST", "/api/ingest", created.Key, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "revoked")
+
+	// Listing shows both keys
+	w = doKeyRequest(router, "GET", "/api/keys", "test-secret", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), rotated.APIKey.ID)
+}
+
+func TestAPIKeyEndpoints_Validation(t *testing.T) {
+	router := newKeyRouter(newTestKeyring())
+
+	w := doKeyRequest(router, "POST", "/api/keys", "test-secret", []byte(`{"name":"x","scopes":["superuser"]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doKeyRequest(router, "POST", "/api/keys", "test-secret", []byte(`{"name":"x","scopes":["read"],"expiresIn":"soon"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doKeyRequest(router, "POST", "/api/keys/missing/rotate", "test-secret", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doKeyRequest(router, "DELETE", "/api/keys/missing", "test-secret", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAuthMiddleware_ExpiredKey(t *testing.T) {
+	keyring := newTestKeyring()
+	router := newKeyRouter(keyring)
+	key, _, err := keyring.Create(models.DefaultTenantID, "short-lived", []auth.Scope{auth.ScopeIngest}, time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	w := doKeyRequest(router, "POST", "/api/ingest", key, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "expired")
+}
+
+func signedKeyRequest(router *gin.Engine, keyID, plaintext, nonce string, body []byte) *httptest.ResponseRecorder {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/ingest?source=test", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.HeaderKeyID, keyID)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderNonce, nonce)
+	req.Header.Set(auth.HeaderSignature,
+		auth.SignRequest(auth.SigningKey(plaintext), "POST", "/api/ingest?source=test", timestamp, nonce, body))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddleware_SignedRequest(t *testing.T) {
+	// Arrange
+	keyring := newTestKeyring()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/ingest", AuthMiddleware(keyring), func(c *gin.Context) {
+		var payload map[string]string
+		_ = c.ShouldBindJSON(&payload)
+		c.JSON(http.StatusOK, gin.H{"key": APIKeyFromContext(c).ID, "order": payload["orderNumber"]})
+	})
+	plaintext, key, err := keyring.Create(models.DefaultTenantID, "chrome", []auth.Scope{auth.ScopeIngest}, 0)
+	require.NoError(t, err)
+	body := []byte(`{"orderNumber":"123"}`)
+
+	// Act - signed request succeeds and the body is still readable
+	w := signedKeyRequest(router, key.ID, plaintext, "nonce-1", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"order":"123"`)
+	assert.Contains(t, w.Body.String(), key.ID)
+
+	// Replaying the same nonce is rejected
+	w = signedKeyRequest(router, key.ID, plaintext, "nonce-1", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "nonce")
+}
+
+func TestAuthMiddleware_SignedStreamDigest(t *testing.T) {
+	// Arrange: the body is checked against X-Content-SHA256 as the handler reads it
+	keyring := newTestKeyring()
+	keyring.SetSignaturePolicy(auth.SignaturePolicy{MaxBodyBytes: 16})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/ingest", AuthMiddleware(keyring), func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"bytes": len(data)})
+	})
+	body := []byte(strings.Repeat(`{"orderNumber":"123"}`+"\n", 100))
+	send := func(nonce string, sent []byte) *httptest.ResponseRecorder {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		digest := auth.BodyDigest(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/ingest", bytes.NewBuffer(sent))
+		req.Header.Set(auth.HeaderKeyID, auth.LegacyKeyID)
+		req.Header.Set(auth.HeaderTimestamp, timestamp)
+		req.Header.Set(auth.HeaderNonce, nonce)
+		req.Header.Set(auth.HeaderContentSHA256, digest)
+		req.Header.Set(auth.HeaderSignature,
+			auth.SignRequestDigest(auth.SigningKey("test-secret"), "POST", "/api/ingest", timestamp, nonce, digest))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Act - a body far over the buffering limit streams through
+	w := send("nonce-1", body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"bytes":%d`, len(body)))
+
+	// A body that differs from the signed digest is rejected before the handler
+	tampered := bytes.Replace(body, []byte("123"), []byte("999"), 1)
+	w = send("nonce-2", tampered)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), auth.HeaderContentSHA256)
+}
+
+func TestAuthMiddleware_TamperedDigestBodyIsNotStored(t *testing.T) {
+	// Arrange: a signed request whose body is swapped after signing
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	keyring := newTestKeyring()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	retailer := router.Group("/api", AuthMiddleware(keyring)).Group("/:retailer", RequireRetailer())
+	retailer.POST("/orders", ReceiveOrders)
+	retailer.POST("/orders/stream", StreamOrders)
+	signed := []byte(`{"orderNumber":"GOOD","orderDate":"2024-01-15"}` + "\n")
+	tampered := []byte(`{"orderNumber":"EVIL","orderDate":"2024-01-15"}` + "\n")
+
+	for i, path := range []string{"/api/walmart/orders", "/api/walmart/orders/stream"} {
+		t.Run(path, func(t *testing.T) {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			nonce := fmt.Sprintf("nonce-%d", i)
+			digest := auth.BodyDigest(signed)
+			req, _ := http.NewRequest("POST", path, bytes.NewBuffer(tampered))
+			req.Header.Set("Content-Type", NDJSONContentType)
+			req.Header.Set(auth.HeaderKeyID, auth.LegacyKeyID)
+			req.Header.Set(auth.HeaderTimestamp, timestamp)
+			req.Header.Set(auth.HeaderNonce, nonce)
+			req.Header.Set(auth.HeaderContentSHA256, digest)
+			req.Header.Set(auth.HeaderSignature,
+				auth.SignRequestDigest(auth.SigningKey("test-secret"), "POST", path, timestamp, nonce, digest))
+
+			// Act
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			_, err := orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "EVIL")
+			assert.ErrorIs(t, err, store.ErrNotFound)
+		})
+	}
+}
+
+func TestAuthMiddleware_RequireSignedRequests(t *testing.T) {
+	// Arrange
+	keyring := newTestKeyring()
+	keyring.SetSignaturePolicy(auth.SignaturePolicy{Required: true})
+	router := newKeyRouter(keyring)
+
+	// Act - a bare key is no longer enough
+	w := doKeyRequest(router, "POST", "/api/ingest", "test-secret", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "signed request required")
+
+	w = signedKeyRequest(router, auth.LegacyKeyID, "test-secret", "nonce-legacy", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -1,19 +1,26 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"sync"
 	"time"
 
+	"monarchmoney-sync-backend/logging"
 	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/tracing"
+	"monarchmoney-sync-backend/webhooks"
 
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// ReceiveBatchOrders handles multiple Walmart orders in a single request.
+// ReceiveBatchOrders handles multiple orders for the route's retailer in a single
+// request.
 func ReceiveBatchOrders(c *gin.Context) {
 	// Get Sentry hub from context if available
 	hub := sentrygin.GetHubFromContext(c)
@@ -22,13 +29,20 @@ func ReceiveBatchOrders(c *gin.Context) {
 
 	// Parse JSON request body
 	if err := c.ShouldBindJSON(&batchRequest); err != nil {
+		if isBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+			return
+		}
+
 		// Capture validation errors to Sentry
 		if hub != nil {
 			hub.WithScope(func(scope *sentry.Scope) {
 				scope.SetLevel(sentry.LevelWarning)
 				scope.SetContext("batch", map[string]interface{}{
 					"error": err.Error(),
-					"body":  c.Request.Body,
 				})
 				hub.CaptureMessage("Invalid batch JSON received")
 			})
@@ -50,63 +64,239 @@ func ReceiveBatchOrders(c *gin.Context) {
 		return
 	}
 
-	// Process each order
-	results := make([]models.BatchOrderResult, 0, len(batchRequest.Orders))
-	processedCount := 0
-	failedCount := 0
+	// Enforce the configured batch size
+	limits := currentBatchLimits()
+	if len(batchRequest.Orders) > limits.MaxOrders {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": "error",
+			"message": fmt.Sprintf("Batch too large: %d orders exceeds limit of %d",
+				len(batchRequest.Orders), limits.MaxOrders),
+		})
+		return
+	}
 
-	for _, order := range batchRequest.Orders {
-		// Create local copy to avoid implicit memory aliasing
-		orderCopy := order
-		
-		result := models.BatchOrderResult{
-			OrderNumber: orderCopy.OrderNumber,
+	retailer := RetailerFromContext(c)
+	for i := range batchRequest.Orders {
+		if err := assignRetailer(&batchRequest.Orders[i], retailer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid order %s: %v", batchRequest.Orders[i].OrderNumber, err),
+			})
+			return
 		}
+	}
+
+	appMetrics.ObserveBatchSize(len(batchRequest.Orders))
 
-		// Validate individual order
-		if err := validateOrder(orderCopy); err != nil {
-			result.Success = false
-			result.Error = err.Error()
-			failedCount++
+	// Process orders concurrently, keeping results in request order
+	response := processOrders(c, batchRequest.Orders, limits.Workers, processBatchOrder)
+
+	// Log batch summary
+	logging.FromContext(c.Request.Context()).Info("Batch processed",
+		"successful", response.ProcessedCount, "failed", response.FailedCount, "total", len(batchRequest.Orders))
+
+	c.JSON(http.StatusOK, response)
+}
+
+// processOrders processes the caller's tenant's orders as one batch and tallies
+// the results. The batch succeeds if any order was processed, or if none failed.
+func processOrders(c *gin.Context, orders []models.Order, workers int, process orderProcessor) models.BatchOrdersResponse {
+	results := processBatch(c.Request.Context(), sentrygin.GetHubFromContext(c), TenantIDFromContext(c), orders, workers, process)
+
+	response := models.BatchOrdersResponse{
+		Results:   results,
+		Timestamp: time.Now(),
+	}
+	for _, result := range results {
+		if result.Success {
+			response.ProcessedCount++
 		} else {
-			// Process the order (same logic as single order endpoint)
-			processingID := fmt.Sprintf("proc_%s_%d", orderCopy.OrderNumber, time.Now().Unix())
+			response.FailedCount++
+		}
+	}
+	response.Success = response.ProcessedCount > 0 || response.FailedCount == 0
+	return response
+}
+
+// BatchLimits bounds the work a single batch request may perform.
+type BatchLimits struct {
+	// MaxOrders is the largest number of orders accepted in one batch.
+	MaxOrders int
+	// Workers is the number of orders processed concurrently.
+	Workers int
+}
+
+// DefaultBatchLimits returns the limits used when none are configured.
+func DefaultBatchLimits() BatchLimits {
+	return BatchLimits{
+		MaxOrders: 500,
+		Workers:   4,
+	}
+}
+
+var (
+	batchLimitsMu sync.RWMutex
+	batchLimits   = DefaultBatchLimits()
+)
+
+// SetBatchLimits configures batch processing limits. Non-positive values keep the defaults.
+func SetBatchLimits(limits BatchLimits) {
+	defaults := DefaultBatchLimits()
+	if limits.MaxOrders <= 0 {
+		limits.MaxOrders = defaults.MaxOrders
+	}
+	if limits.Workers <= 0 {
+		limits.Workers = defaults.Workers
+	}
+
+	batchLimitsMu.Lock()
+	defer batchLimitsMu.Unlock()
+	batchLimits = limits
+}
+
+func currentBatchLimits() BatchLimits {
+	batchLimitsMu.RLock()
+	defer batchLimitsMu.RUnlock()
+	return batchLimits
+}
+
+// orderProcessor processes a single order of a batch.
+type orderProcessor func(ctx context.Context, hub *sentry.Hub, tenantID string, order models.Order) models.BatchOrderResult
 
-			// Log the order
-			logBatchOrder(orderCopy)
+// processBatch processes orders with a bounded pool of workers. Results are returned
+// in the same order as the input. Orders not yet started when ctx is cancelled are
+// reported as failed.
+func processBatch(ctx context.Context, hub *sentry.Hub, tenantID string, orders []models.Order, workers int, process orderProcessor) []models.BatchOrderResult {
+	results := make([]models.BatchOrderResult, len(orders))
+	if workers > len(orders) {
+		workers = len(orders)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		// Sentry scopes are not safe to share across goroutines
+		var workerHub *sentry.Hub
+		if hub != nil {
+			workerHub = hub.Clone()
+		}
 
-			// Track in Sentry
-			if hub != nil {
-				trackBatchOrderInSentry(hub, orderCopy, processingID)
+		wg.Add(1)
+		go func(hub *sentry.Hub) {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = process(ctx, hub, tenantID, orders[i])
 			}
+		}(workerHub)
+	}
 
-			// Update sync tracker
-			updateSyncTracker(&orderCopy)
+	scheduled := 0
+dispatch:
+	for scheduled < len(orders) && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case indexes <- scheduled:
+			scheduled++
+		}
+	}
+	close(indexes)
+	wg.Wait()
 
-			result.Success = true
-			result.ProcessingID = processingID
-			processedCount++
+	for i := scheduled; i < len(orders); i++ {
+		results[i] = models.BatchOrderResult{
+			OrderNumber: orders[i].OrderNumber,
+			Success:     false,
+			Error:       "request cancelled before order was processed",
 		}
+	}
 
-		results = append(results, result)
+	if scheduled < len(orders) {
+		logging.FromContext(ctx).Warn("Batch cancelled",
+			"unprocessed", len(orders)-scheduled, "total", len(orders), "error", ctx.Err())
 	}
 
-	// Log batch summary
-	log.Printf("Batch processed: %d successful, %d failed out of %d total orders\n",
-		processedCount, failedCount, len(batchRequest.Orders))
+	return results
+}
 
-	// Determine overall success
-	success := processedCount > 0 || failedCount == 0
+// processBatchOrder validates, stores, and processes a single order from a batch.
+func processBatchOrder(ctx context.Context, hub *sentry.Hub, tenantID string, order models.Order) (result models.BatchOrderResult) {
+	ctx, span := tracing.Start(ctx, "order.process",
+		attribute.String("tenant.id", tenantID),
+		attribute.String("order.number", order.OrderNumber))
+	defer func() {
+		var err error
+		if !result.Success {
+			err = errors.New(result.Error)
+		}
+		tracing.End(span, err)
+	}()
 
-	response := models.BatchOrdersResponse{
-		Success:        success,
-		ProcessedCount: processedCount,
-		FailedCount:    failedCount,
-		Results:        results,
-		Timestamp:      time.Now(),
+	result = models.BatchOrderResult{
+		OrderNumber: order.OrderNumber,
+	}
+	if order.Retailer == "" {
+		order.Retailer = models.DefaultRetailer
 	}
+	appMetrics.OrderReceived(orderSource(&order))
 
-	c.JSON(http.StatusOK, response)
+	// Validate individual order
+	if err := validateOrder(order); err != nil {
+		result.Success = false
+		result.Error = err.Error()
+
+		appMetrics.OrderFailed(orderSource(&order))
+		publishOrderEvent(tenantID, webhooks.EventOrderFailed, &order, "", err.Error())
+		return result
+	}
+
+	// Process the order (same logic as single order endpoint)
+	processingID := fmt.Sprintf("proc_%s_%d", order.OrderNumber, time.Now().Unix())
+
+	// Log the order
+	logBatchOrder(ctx, order)
+
+	// Track in Sentry
+	if hub != nil && sentryOrderMessages {
+		trackBatchOrderInSentry(hub, order, processingID)
+	}
+
+	// Store the order for the tenant
+	created, err := saveOrder(ctx, tenantID, &order, processingID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to store order", "order_number", order.OrderNumber, "error", err)
+		appMetrics.OrderFailed(orderSource(&order))
+		result.Success = false
+		result.Error = ErrOrderNotStored.Error()
+		return result
+	}
+
+	// An order received before, such as one re-imported from a CSV export, is
+	// only replaced
+	if created {
+		updateSyncTracker(tenantID, &order)
+		publishOrderEvent(tenantID, webhooks.EventOrderReceived, &order, processingID, "")
+		appMetrics.OrderProcessed(orderSource(&order))
+	}
+
+	result.Success = true
+	result.ProcessingID = processingID
+	return result
+}
+
+// ErrOrderNotStored is returned by ProcessOrder when a valid order could not be
+// saved. Unlike a validation failure, it is worth retrying.
+var ErrOrderNotStored = errors.New("failed to store order")
+
+// ProcessOrder processes one order received outside an HTTP request, such as by a
+// background importer, exactly like an order in a batch. The result reports
+// validation failures; the error is ErrOrderNotStored when only saving failed.
+func ProcessOrder(ctx context.Context, tenantID string, order models.Order) (models.BatchOrderResult, error) {
+	result := processBatchOrder(ctx, nil, tenantID, order)
+	if !result.Success && result.Error == ErrOrderNotStored.Error() {
+		return result, ErrOrderNotStored
+	}
+	return result, nil
 }
 
 // validateOrder validates a single order in the batch
@@ -118,6 +308,9 @@ func validateOrder(order models.Order) error {
 	if order.OrderDate == "" {
 		return fmt.Errorf("missing order date")
 	}
+	if !order.Retailer.Valid() {
+		return fmt.Errorf("unknown retailer %q", order.Retailer)
+	}
 
 	// Validate items if present
 	if order.Items != nil {
@@ -131,22 +324,36 @@ func validateOrder(order models.Order) error {
 		}
 	}
 
-	return nil
+	return validateShipments(order)
 }
 
-// logBatchOrder logs a single order from a batch
-func logBatchOrder(order models.Order) {
-	itemCount := 0
-	if order.Items != nil {
-		itemCount = len(order.Items)
+// validateShipments checks that shipments are identified and that items only
+// reference shipments of their own order.
+func validateShipments(order models.Order) error {
+	ids := make(map[string]bool, len(order.Shipments))
+	for i, s := range order.Shipments {
+		if s.ID == "" {
+			return fmt.Errorf("missing id for shipment %d", i+1)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("duplicate shipment id %q", s.ID)
+		}
+		if s.Total < 0 {
+			return fmt.Errorf("invalid total for shipment %q: must be non-negative", s.ID)
+		}
+		ids[s.ID] = true
 	}
-
-	logMsg := fmt.Sprintf("Batch order: %s", order.OrderNumber)
-	if order.OrderTotal != nil {
-		logMsg += fmt.Sprintf(", Total: $%.2f", *order.OrderTotal)
+	for i, item := range order.Items {
+		if item.ShipmentID != "" && !ids[item.ShipmentID] {
+			return fmt.Errorf("item %d references unknown shipment %q", i+1, item.ShipmentID)
+		}
 	}
-	logMsg += fmt.Sprintf(", Items: %d", itemCount)
-	log.Println(logMsg)
+	return nil
+}
+
+// logBatchOrder logs a single order from a batch
+func logBatchOrder(ctx context.Context, order models.Order) {
+	logging.FromContext(ctx).Info("Batch order", orderLogAttrs(&order)...)
 }
 
 // trackBatchOrderInSentry tracks a batch order in Sentry
@@ -171,8 +378,7 @@ func trackBatchOrderInSentry(hub *sentry.Hub, order models.Order, processingID s
 		}
 
 		scope.SetContext("order", contextData)
-		scope.SetTag("order.source", "walmart.batch")
+		scope.SetTag("order.source", orderSource(&order)+".batch")
 		hub.CaptureMessage("Batch order processed successfully")
 	})
 }
-
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -199,7 +201,7 @@ func TestReceiveBatchOrders_MissingAuth(t *testing.T) {
 	// Test batch endpoint with missing auth
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware())
+	router.Use(AuthMiddleware(newTestKeyring()))
 	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
 
 	batchRequest := models.BatchOrdersRequest{
@@ -342,7 +344,7 @@ func TestLogBatchOrder(_ *testing.T) {
 	}
 
 	// This should not panic and should log properly
-	logBatchOrder(order)
+	logBatchOrder(context.Background(), order)
 	
 	// Test with nil items
 	orderNoItems := models.Order{
@@ -350,7 +352,7 @@ func TestLogBatchOrder(_ *testing.T) {
 		OrderDate:   "2024-01-16",
 		OrderTotal:  &orderTotal,
 	}
-	logBatchOrder(orderNoItems)
+	logBatchOrder(context.Background(), orderNoItems)
 	
 	// Test with nil total
 	orderNoTotal := models.Order{
@@ -360,6 +362,156 @@ func TestLogBatchOrder(_ *testing.T) {
 			{Name: "Item1", Price: 25.00, Quantity: 2},
 		},
 	}
-	logBatchOrder(orderNoTotal)
+	logBatchOrder(context.Background(), orderNoTotal)
 }
 
+
+func TestReceiveBatchOrders_PreservesOrderWithWorkers(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	SetBatchLimits(BatchLimits{MaxOrders: 100, Workers: 8})
+	defer SetBatchLimits(DefaultBatchLimits())
+
+	router := gin.New()
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+
+	batchRequest := models.BatchOrdersRequest{}
+	for i := 0; i < 50; i++ {
+		order := models.Order{OrderNumber: fmt.Sprintf("ORD-%02d", i), OrderDate: "2024-01-15"}
+		if i%10 == 0 {
+			order.OrderDate = ""
+		}
+		batchRequest.Orders = append(batchRequest.Orders, order)
+	}
+	jsonData, _ := json.Marshal(batchRequest)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/batch", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.BatchOrdersResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 45, response.ProcessedCount)
+	assert.Equal(t, 5, response.FailedCount)
+	assert.Len(t, response.Results, 50)
+	for i, result := range response.Results {
+		assert.Equal(t, fmt.Sprintf("ORD-%02d", i), result.OrderNumber)
+		assert.Equal(t, i%10 != 0, result.Success)
+	}
+}
+
+func TestReceiveBatchOrders_TooManyOrders(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	SetBatchLimits(BatchLimits{MaxOrders: 2, Workers: 1})
+	defer SetBatchLimits(DefaultBatchLimits())
+
+	router := gin.New()
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+
+	jsonData := []byte(`{"orders":[
+		{"orderNumber":"1","orderDate":"2024-01-15"},
+		{"orderNumber":"2","orderDate":"2024-01-15"},
+		{"orderNumber":"3","orderDate":"2024-01-15"}]}`)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/batch", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "exceeds limit of 2")
+}
+
+func TestReceiveBatchOrders_BodyTooLarge(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxBodySize(64))
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+
+	jsonData := []byte(`{"orders":[{"orderNumber":"123456789","orderDate":"2024-01-15"},{"orderNumber":"987654321","orderDate":"2024-01-16"}]}`)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/batch", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestProcessBatch_CancelledContext(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	orders := []models.Order{
+		{OrderNumber: "C1", OrderDate: "2024-01-15"},
+		{OrderNumber: "C2", OrderDate: "2024-01-15"},
+	}
+
+	// Act
+	results := processBatch(ctx, nil, models.DefaultTenantID, orders, 2, processBatchOrder)
+
+	// Assert
+	assert.Len(t, results, 2)
+	for i, result := range results {
+		assert.Equal(t, orders[i].OrderNumber, result.OrderNumber)
+		assert.False(t, result.Success)
+		assert.Contains(t, result.Error, "cancelled")
+	}
+}
+
+func TestValidateOrder_Shipments(t *testing.T) {
+	base := func() models.Order {
+		return models.Order{
+			OrderNumber: "112-1",
+			OrderDate:   "2024-02-10",
+			Items: []models.OrderItem{
+				{Name: "Charger", Price: 19.99, Quantity: 1, ShipmentID: "1"},
+				{Name: "Filters", Price: 5.49, Quantity: 1},
+			},
+			Shipments: []models.Shipment{{ID: "1", Total: 21.64}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(o *models.Order)
+		wantErr string
+	}{
+		{"valid", func(o *models.Order) {}, ""},
+		{"missing id", func(o *models.Order) { o.Shipments[0].ID = "" }, "missing id for shipment 1"},
+		{"duplicate id", func(o *models.Order) {
+			o.Shipments = append(o.Shipments, models.Shipment{ID: "1"})
+		}, `duplicate shipment id "1"`},
+		{"negative total", func(o *models.Order) { o.Shipments[0].Total = -1 }, `invalid total for shipment "1": must be non-negative`},
+		{"unknown shipment", func(o *models.Order) { o.Items[1].ShipmentID = "2" }, `item 2 references unknown shipment "2"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := base()
+			order.Retailer = models.RetailerAmazon
+			tt.modify(&order)
+
+			err := validateOrder(order)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
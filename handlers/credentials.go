@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"monarchmoney-sync-backend/vault"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetCredentialRequest is the body accepted by SetCredential.
+type SetCredentialRequest struct {
+	Value string `json:"value" binding:"required"`
+}
+
+// ListCredentials describes the caller's tenant's stored credentials. Secret
+// values are never returned.
+func ListCredentials(v *vault.Vault) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		creds, err := v.List(TenantIDFromContext(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to list credentials",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"credentials": creds,
+		})
+	}
+}
+
+// SetCredential stores or replaces one of the caller's tenant's credentials.
+// The new value takes effect immediately without a restart.
+func SetCredential(v *vault.Vault) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if !vault.ValidName(name) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Unknown credential %q", name),
+			})
+			return
+		}
+
+		var req SetCredentialRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+			})
+			return
+		}
+
+		cred, err := v.Set(TenantIDFromContext(c), name, req.Value)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Failed to store credential: %v", err),
+			})
+			return
+		}
+
+		auditRequest(c, AuditCredentialSet, "credential:"+name, nil)
+		c.JSON(http.StatusOK, cred)
+	}
+}
+
+// DeleteCredential removes one of the caller's tenant's credentials.
+func DeleteCredential(v *vault.Vault) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if !vault.ValidName(name) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Unknown credential %q", name),
+			})
+			return
+		}
+
+		if err := v.Delete(TenantIDFromContext(c), name); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, vault.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		auditRequest(c, AuditCredentialDelete, "credential:"+name, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
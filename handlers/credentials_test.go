@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/vault"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCredentialRouter(t *testing.T, keyring *auth.Keyring) (*gin.Engine, *vault.Vault) {
+	t.Helper()
+	key, err := vault.GenerateMasterKey()
+	require.NoError(t, err)
+	v := vault.New(vault.NewMemoryStore(), vault.NewKeys(key))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	creds := router.Group("/api/credentials", AuthMiddleware(keyring), RequireScope(auth.ScopeAdmin))
+	creds.GET("", ListCredentials(v))
+	creds.PUT("/:name", SetCredential(v))
+	creds.DELETE("/:name", DeleteCredential(v))
+	return router, v
+}
+
+func TestCredentialEndpoints_SetListDelete(t *testing.T) {
+	// Arrange
+	keyring := newTestKeyring()
+	router, v := newCredentialRouter(t, keyring)
+
+	// Act
+	w := doKeyRequest(router, "PUT", "/api/credentials/monarch_token", "test-secret",
+		[]byte(`{"value":"monarch-session-token-9876"}`))
+
+	// Assert - the secret is stored but never echoed
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "monarch-session-token")
+	assert.Contains(t, w.Body.String(), "...9876")
+
+	secret, err := v.Get(models.DefaultTenantID, vault.MonarchToken)
+	require.NoError(t, err)
+	assert.Equal(t, "monarch-session-token-9876", secret)
+
+	w = doKeyRequest(router, "GET", "/api/credentials", "test-secret", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Credentials []vault.Credential `json:"credentials"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Credentials, 1)
+	assert.Equal(t, vault.MonarchToken, listed.Credentials[0].Name)
+
+	w = doKeyRequest(router, "DELETE", "/api/credentials/monarch_token", "test-secret", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doKeyRequest(router, "DELETE", "/api/credentials/monarch_token", "test-secret", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCredentialEndpoints_ScopedToTenant(t *testing.T) {
+	keyring := newTestKeyring()
+	router, _ := newCredentialRouter(t, keyring)
+	otherKey, _, err := keyring.Create("smiths", "admin", []auth.Scope{auth.ScopeAdmin}, 0)
+	require.NoError(t, err)
+
+	w := doKeyRequest(router, "PUT", "/api/credentials/openai_api_key", "test-secret", []byte(`{"value":"sk-default"}`))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doKeyRequest(router, "GET", "/api/credentials", otherKey, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "openai_api_key")
+
+	w = doKeyRequest(router, "DELETE", "/api/credentials/openai_api_key", otherKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSetCredential_Validation(t *testing.T) {
+	router, _ := newCredentialRouter(t, newTestKeyring())
+
+	w := doKeyRequest(router, "PUT", "/api/credentials/aws_secret", "test-secret", []byte(`{"value":"x"}`))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doKeyRequest(router, "PUT", "/api/credentials/monarch_token", "test-secret", []byte(`{}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
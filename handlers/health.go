@@ -4,6 +4,8 @@ package handlers
 import (
 	"net/http"
 
+	"monarchmoney-sync-backend/health"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,3 +16,17 @@ func HealthCheck(c *gin.Context) {
 		"status": "ok",
 	})
 }
+
+// ReadinessCheck checks every dependency and reports per-component status and
+// latency. It responds 503 when any component fails so orchestrators stop routing
+// traffic to this instance; liveness is reported separately by HealthCheck.
+func ReadinessCheck(checks *health.Checks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checks.Run(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
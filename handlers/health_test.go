@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/health"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -24,3 +31,41 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "ok")
 }
+
+func TestReadinessCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		monarchErr error
+		wantCode   int
+		wantStatus string
+	}{
+		{"all ok", nil, http.StatusOK, health.StatusReady},
+		{"skipped component", health.ErrNotConfigured, http.StatusOK, health.StatusReady},
+		{"failed component", errors.New("token rejected"), http.StatusServiceUnavailable, health.StatusNotReady},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			gin.SetMode(gin.TestMode)
+			checks := health.NewChecks(time.Second)
+			checks.Add("database", health.CheckFunc(func(context.Context) error { return nil }))
+			checks.Add("monarch", health.CheckFunc(func(context.Context) error { return tt.monarchErr }))
+			router := gin.New()
+			router.GET("/health/ready", ReadinessCheck(checks))
+
+			// Act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/health/ready", nil)
+			router.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tt.wantCode, w.Code)
+			var report health.Report
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, health.StatusOK, report.Components["database"].Status)
+			assert.Len(t, report.Components, 2)
+		})
+	}
+}
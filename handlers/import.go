@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"monarchmoney-sync-backend/importer"
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvUploadField is the multipart form field holding an uploaded export.
+const csvUploadField = "file"
+
+// csvImporters read each retailer's order history export.
+var csvImporters = map[models.Retailer]func(io.Reader) ([]models.Order, error){
+	models.RetailerWalmart: importer.ReadWalmartCSV,
+	models.RetailerAmazon:  importer.ReadAmazonCSV,
+}
+
+// ImportOrdersCSV imports the route's retailer's order history CSV export, such
+// as Walmart's purchase history or Amazon's Retail.OrderHistory, uploaded as the "file" field of a multipart form.
+// The rows are grouped into orders and processed exactly like a batch request, so
+// the same validation applies and orders that were already received are updated
+// rather than duplicated.
+func ImportOrdersCSV(c *gin.Context) {
+	retailer := RetailerFromContext(c)
+	readCSV, ok := csvImporters[retailer]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("CSV import is not available for %s", retailer.Name()),
+		})
+		return
+	}
+
+	file, header, err := c.Request.FormFile(csvUploadField)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Expected a multipart form with a %q file field", csvUploadField),
+		})
+		return
+	}
+	defer file.Close()
+
+	orders, err := readCSV(file)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid CSV: %v", err),
+		})
+		return
+	}
+
+	limits := currentBatchLimits()
+	if len(orders) > limits.MaxOrders {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": "error",
+			"message": fmt.Sprintf("Import too large: %d orders exceeds limit of %d",
+				len(orders), limits.MaxOrders),
+		})
+		return
+	}
+
+	appMetrics.ObserveBatchSize(len(orders))
+	response := processOrders(c, orders, limits.Workers, processBatchOrder)
+
+	logging.FromContext(c.Request.Context()).Info("CSV imported",
+		"retailer", retailer, "filename", header.Filename, "successful", response.ProcessedCount, "failed", response.FailedCount)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ReceiveReceipt imports an order from a raw Walmart email receipt, the RFC 5322
+// message as saved in an .eml file, sent as the request body. Messages that are
+// not receipts are rejected with 422 so mail forwarding rules can tell them apart
+// from malformed requests.
+func ReceiveReceipt(c *gin.Context) {
+	if retailer := RetailerFromContext(c); retailer != models.RetailerWalmart {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Email receipts are not available for %s", retailer.Name()),
+		})
+		return
+	}
+
+	order, err := importer.ReadReceipt(c.Request.Body)
+	if err != nil {
+		switch {
+		case isBodyTooLarge(err):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+		case errors.Is(err, importer.ErrNoReceipt):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid email: %v", err),
+			})
+		}
+		return
+	}
+
+	response := processOrders(c, []models.Order{*order}, 1, processBatchOrder)
+
+	logging.FromContext(c.Request.Context()).Info("Receipt imported",
+		"order_number", order.OrderNumber, "success", response.Success)
+
+	c.JSON(http.StatusOK, response)
+}
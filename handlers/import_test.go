@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doUploadRequest posts content as the named multipart file field.
+func doUploadRequest(router *gin.Engine, path, field string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile(field, "orders.csv")
+	_, _ = part.Write(content)
+	_ = form.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportOrdersCSV(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	csv, err := os.ReadFile("../testdata/walmart_orders.csv")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/import", ImportOrdersCSV)
+
+	// Act: importing the same export twice must not duplicate orders
+	first := doUploadRequest(router, "/import", "file", csv)
+	second := doUploadRequest(router, "/import", "file", csv)
+
+	// Assert
+	for _, w := range []*httptest.ResponseRecorder{first, second} {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response models.BatchOrdersResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.Equal(t, 2, response.ProcessedCount)
+		assert.Equal(t, 0, response.FailedCount)
+		require.Len(t, response.Results, 2)
+		assert.Equal(t, "200012345678901", response.Results[0].OrderNumber)
+	}
+
+	saved, err := orders.ListOrders(context.Background(), models.DefaultTenantID, store.OrderFilter{})
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	rec, err := orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "200012345678901")
+	require.NoError(t, err)
+	assert.Len(t, rec.Order.Items, 3)
+}
+
+func TestImportOrdersCSV_ReimportHasNoReceiptSideEffects(t *testing.T) {
+	// Arrange
+	SetOrderStore(store.NewMemory())
+	defer SetOrderStore(nil)
+	csv, err := os.ReadFile("../testdata/walmart_orders.csv")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/import", ImportOrdersCSV)
+	first := doUploadRequest(router, "/import", "file", csv)
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	defer SetEventPublisher(nil)
+	synced := trackerFor(models.DefaultTenantID).OrdersProcessedTotal
+
+	// Act
+	second := doUploadRequest(router, "/import", "file", csv)
+
+	// Assert
+	require.Equal(t, http.StatusOK, second.Code, second.Body.String())
+	assert.Empty(t, publisher.events)
+	assert.Equal(t, synced, trackerFor(models.DefaultTenantID).OrdersProcessedTotal)
+}
+
+func TestImportOrdersCSV_Amazon(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	csv, err := os.ReadFile("../testdata/amazon_order_history.csv")
+	require.NoError(t, err)
+	router := newRetailerRouter()
+
+	// Act
+	w := doUploadRequest(router, "/api/amazon/orders/import", "file", csv)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.BatchOrdersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.ProcessedCount)
+	assert.Equal(t, 0, response.FailedCount)
+
+	rec, err := orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerAmazon, "112-1234567-1234567")
+	require.NoError(t, err)
+	assert.Equal(t, models.RetailerAmazon, rec.Order.Retailer)
+	require.Len(t, rec.Order.Shipments, 2)
+	assert.Equal(t, 53.02, rec.Order.Shipments[0].Total)
+
+	// A Walmart export is not mistaken for an Amazon one
+	walmart, err := os.ReadFile("../testdata/walmart_orders.csv")
+	require.NoError(t, err)
+	w = doUploadRequest(router, "/api/amazon/orders/import", "file", walmart)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing columns: Order ID")
+}
+
+func TestImportOrdersCSV_ValidatesOrders(t *testing.T) {
+	// Arrange
+	SetOrderStore(store.NewMemory())
+	defer SetOrderStore(nil)
+	csv := []byte("Order Number,Order Date,Product Name,Quantity,Item Price\n" +
+		"1001,2024-01-05,Eggs,1,$2.50\n" +
+		"1002,2024-01-06,Rice,0,$3.00\n")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/import", ImportOrdersCSV)
+
+	// Act
+	w := doUploadRequest(router, "/import", "file", csv)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.BatchOrdersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, 1, response.ProcessedCount)
+	assert.Equal(t, 1, response.FailedCount)
+	assert.Equal(t, "invalid quantity for item 1: must be positive", response.Results[1].Error)
+}
+
+func TestImportOrdersCSV_BadRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/import", ImportOrdersCSV)
+
+	t.Run("not multipart", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/import", "", []byte(`{}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong field", func(t *testing.T) {
+		w := doUploadRequest(router, "/import", "upload", []byte("Order Number,Order Date\n1,2024-01-01\n"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid csv", func(t *testing.T) {
+		w := doUploadRequest(router, "/import", "file", []byte("Product Name\nEggs\n"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "missing columns: Order Number, Order Date")
+	})
+
+	t.Run("too many orders", func(t *testing.T) {
+		SetBatchLimits(BatchLimits{MaxOrders: 1})
+		defer SetBatchLimits(BatchLimits{})
+		w := doUploadRequest(router, "/import", "file", []byte("Order Number,Order Date\n1,2024-01-01\n2,2024-01-02\n"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "exceeds limit of 1")
+	})
+}
+
+func TestReceiveReceipt(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	eml, err := os.ReadFile("../testdata/receipts/online_order.eml")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/receipt", ReceiveReceipt)
+
+	// Act
+	w := doKeyRequest(router, "POST", "/receipt", "", eml)
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.BatchOrdersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, 1, response.ProcessedCount)
+
+	rec, err := orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "200012345678901")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-14", rec.Order.OrderDate)
+	assert.Len(t, rec.Order.Items, 3)
+}
+
+func TestReceiveReceipt_Rejected(t *testing.T) {
+	notice, err := os.ReadFile("../testdata/receipts/shipping_notice.eml")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/receipt", ReceiveReceipt)
+
+	t.Run("not a receipt", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/receipt", "", notice)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("not an email", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/receipt", "", []byte("hello"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/store"
+)
+
+// jobQueue holds work that is retried in the background, such as matching refunds
+// whose transactions have not posted yet. It defaults to an in-memory queue so
+// handlers work without a database configured.
+var jobQueue store.JobStore = store.NewMemory()
+
+// SetJobQueue sets the queue that background work is enqueued to.
+func SetJobQueue(s store.JobStore) {
+	if s == nil {
+		s = store.NewMemory()
+	}
+	jobQueue = s
+}
+
+// enqueueJob adds a job of kind for the tenant. A job that cannot be enqueued is
+// logged; the caller's work has already been stored and can be retried by hand.
+func enqueueJob(ctx context.Context, tenantID, kind string, payload any) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		err = jobQueue.EnqueueJob(ctx, &store.Job{TenantID: tenantID, Kind: kind, Payload: data})
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to enqueue job", "kind", kind, "error", err)
+	}
+}
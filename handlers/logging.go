@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"monarchmoney-sync-backend/logging"
+
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID assigns every request an ID, reusing a valid X-Request-ID from the
+// client. The ID is echoed in the response header, added to every log line written
+// through the request context, and tagged on the request's Sentry scope. It must
+// run after the Sentry middleware for the tag to apply.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		c.Header(logging.RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		if hub := sentrygin.GetHubFromContext(c); hub != nil {
+			hub.Scope().SetTag("request_id", id)
+		}
+
+		c.Next()
+	}
+}
+
+// RequestLogger writes one structured line per request. Health checks and metrics
+// scrapes are logged at debug level to keep them out of normal output.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case isProbePath(c.Request.URL.Path):
+			level = slog.LevelDebug
+		}
+
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "Request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		)
+	}
+}
+
+// isProbePath reports whether path is a health check or metrics scrape.
+func isProbePath(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/") || path == "/metrics"
+}
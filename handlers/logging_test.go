@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"monarchmoney-sync-backend/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID_PropagatesToLogsAndResponse(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), RequestLogger())
+	router.POST("/api/walmart/orders", ReceiveOrders)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders",
+		bytes.NewBufferString(`{"orderNumber":"log-1","orderDate":"2024-01-15"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logging.RequestIDHeader, "client-req-42")
+	router.ServeHTTP(w, req)
+
+	// Assert - the handler's log line and the access log both carry the ID
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "client-req-42", w.Header().Get(logging.RequestIDHeader))
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "client-req-42", entry["request_id"])
+		messages = append(messages, entry["msg"].(string))
+	}
+	assert.Contains(t, messages, "Received Walmart order")
+	assert.Contains(t, messages, "Request handled")
+}
+
+func TestRequestID_GeneratesWhenMissingOrInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, logging.RequestIDFromContext(c.Request.Context()))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+	generated := w.Header().Get(logging.RequestIDHeader)
+	assert.NotEmpty(t, generated)
+	assert.Equal(t, generated, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/ping", nil)
+	req.Header.Set(logging.RequestIDHeader, "bad id with spaces")
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, "bad id with spaces", w.Header().Get(logging.RequestIDHeader))
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"time"
+
+	"monarchmoney-sync-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// appMetrics records order metrics. It defaults to nil, which records nothing.
+var appMetrics *metrics.Metrics
+
+// SetMetrics sets the metrics that order handlers record to.
+func SetMetrics(m *metrics.Metrics) {
+	appMetrics = m
+}
+
+// RequestMetrics records the count and latency of every request by method, matched
+// route, and status code.
+func RequestMetrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsHandler serves metrics in the Prometheus text format.
+func MetricsHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return gin.WrapH(m.Handler())
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"monarchmoney-sync-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMetrics_RecordsRoutesAndOrders(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	router := gin.New()
+	router.Use(RequestMetrics(m))
+	router.GET("/metrics", MetricsHandler(m))
+	router.POST("/api/walmart/orders", ReceiveOrders)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders",
+		bytes.NewBufferString(`{"orderNumber":"metrics-1","orderDate":"2024-01-15"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope/123", nil))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Assert
+	body := w.Body.String()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, body, `monarch_sync_http_requests_total{method="POST",route="/api/walmart/orders",status="200"} 1`)
+	assert.Contains(t, body, `route="unmatched",status="404"`)
+	assert.NotContains(t, body, "/nope/123")
+	assert.Contains(t, body, `monarch_sync_orders_processed_total{source="walmart"} 1`)
+}
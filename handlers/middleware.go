@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySize limits request bodies to maxBytes. Handlers see a read error once the
+// limit is exceeded and respond with 413 Request Entity Too Large.
+func MaxBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// Deadlines bounds how long the request may take to read its body and to write its
+// response, replacing deadlines set by earlier middleware so a route can extend
+// them. A zero duration removes that deadline.
+func Deadlines(read, write time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rc := http.NewResponseController(c.Writer)
+		// Writers that cannot set deadlines, such as test recorders, are left as is
+		_ = rc.SetReadDeadline(deadline(read))
+		_ = rc.SetWriteDeadline(deadline(write))
+		c.Next()
+	}
+}
+
+func deadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// isBodyTooLarge reports whether err was caused by MaxBodySize rejecting the body.
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// orderStore persists received orders. It defaults to an in-memory store so
+// handlers work without a database configured.
+var orderStore store.OrderStore = store.NewMemory()
+
+// SetOrderStore sets the store that order handlers save to.
+func SetOrderStore(s store.OrderStore) {
+	if s == nil {
+		s = store.NewMemory()
+	}
+	orderStore = s
+}
+
+// saveOrder stores a processed order for a tenant, reporting whether it is new
+// rather than a replacement for one received before.
+func saveOrder(ctx context.Context, tenantID string, order *models.Order, processingID string) (created bool, err error) {
+	return orderStore.SaveOrder(ctx, &store.OrderRecord{
+		TenantID:     tenantID,
+		Order:        *order,
+		ProcessingID: processingID,
+	})
+}
+
+// ListOrders returns the caller's tenant's stored orders from the route's
+// retailer, newest first. The optional from and to query parameters (YYYY-MM-DD)
+// bound the order date and limit caps the number of results.
+func ListOrders(c *gin.Context) {
+	filter := store.OrderFilter{
+		Retailer: RetailerFromContext(c),
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+	}
+	if !validFilterDates(filter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid date: from and to must be YYYY-MM-DD",
+		})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid limit: must be a positive integer",
+			})
+			return
+		}
+		filter.Limit = n
+	}
+
+	records, err := orderStore.ListOrders(c.Request.Context(), TenantIDFromContext(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to list orders",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": records,
+		"count":  len(records),
+	})
+}
+
+// GetOrder returns one of the caller's tenant's stored orders from the route's
+// retailer by order number.
+func GetOrder(c *gin.Context) {
+	rec, err := loadOrder(c, c.Param("orderNumber"))
+	if err != nil {
+		respondOrderNotLoaded(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rec)
+}
+
+// loadOrder returns one of the caller's tenant's stored orders from the route's
+// retailer, or store.ErrNotFound.
+func loadOrder(c *gin.Context, orderNumber string) (*store.OrderRecord, error) {
+	return orderStore.GetOrder(c.Request.Context(), TenantIDFromContext(c), RetailerFromContext(c), orderNumber)
+}
+
+// respondOrderNotLoaded responds to a loadOrder error.
+func respondOrderNotLoaded(c *gin.Context, err error) {
+	status, message := http.StatusInternalServerError, "Failed to load order"
+	if errors.Is(err, store.ErrNotFound) {
+		status, message = http.StatusNotFound, "Order not found"
+	}
+	c.JSON(status, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// ReprocessRequest selects the stored orders to run through processing again.
+// Dates are YYYY-MM-DD and inclusive.
+type ReprocessRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// ReprocessOrders validates and stores again the caller's tenant's stored orders
+// from the route's retailer within a date range, at most the batch limit of them.
+// The orders were received before, so no events are published and the sync
+// tracker and order metrics are left alone.
+func ReprocessOrders(c *gin.Context) {
+	var req ReprocessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+		})
+		return
+	}
+	filter := store.OrderFilter{Retailer: RetailerFromContext(c), From: req.From, To: req.To}
+	if !validFilterDates(filter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid date: from and to must be YYYY-MM-DD",
+		})
+		return
+	}
+
+	// One more than the limit is listed to tell a full range from one that is too long
+	limits := currentBatchLimits()
+	filter.Limit = limits.MaxOrders + 1
+	records, err := orderStore.ListOrders(c.Request.Context(), TenantIDFromContext(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to list orders",
+		})
+		return
+	}
+	if len(records) > limits.MaxOrders {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": "error",
+			"message": fmt.Sprintf("Too many orders: more than %d orders between %s and %s; reprocess a shorter range",
+				limits.MaxOrders, req.From, req.To),
+		})
+		return
+	}
+
+	orders := make([]models.Order, 0, len(records))
+	for _, rec := range records {
+		orders = append(orders, rec.Order)
+	}
+	response := processOrders(c, orders, limits.Workers, reprocessOrder)
+
+	logging.FromContext(c.Request.Context()).Info("Orders reprocessed",
+		"from", req.From, "to", req.To, "successful", response.ProcessedCount, "failed", response.FailedCount)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// reprocessOrder validates a stored order and saves it again under a new
+// processing ID, without the side effects of receiving it.
+func reprocessOrder(ctx context.Context, _ *sentry.Hub, tenantID string, order models.Order) models.BatchOrderResult {
+	result := models.BatchOrderResult{OrderNumber: order.OrderNumber}
+	if err := validateOrder(order); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	processingID := fmt.Sprintf("proc_%s_%d", order.OrderNumber, time.Now().Unix())
+	if _, err := saveOrder(ctx, tenantID, &order, processingID); err != nil {
+		logging.FromContext(ctx).Error("Failed to store order", "order_number", order.OrderNumber, "error", err)
+		result.Error = ErrOrderNotStored.Error()
+		return result
+	}
+
+	result.Success = true
+	result.ProcessingID = processingID
+	return result
+}
+
+// validFilterDates reports whether the filter's dates are empty or YYYY-MM-DD.
+func validFilterDates(filter store.OrderFilter) bool {
+	for _, date := range []string{filter.From, filter.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReprocessOrders(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	for _, o := range []models.Order{
+		{OrderNumber: "jan-1", OrderDate: "2024-01-10"},
+		{OrderNumber: "jan-2", OrderDate: "2024-01-20"},
+		{OrderNumber: "feb-1", OrderDate: "2024-02-05"},
+	} {
+		_, err := orders.SaveOrder(context.Background(), &store.OrderRecord{TenantID: models.DefaultTenantID, Order: o})
+		require.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/reprocess", AuthMiddleware(newTestKeyring()), RequireScope(auth.ScopeIngest), ReprocessOrders)
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/orders/reprocess", "test-secret",
+		[]byte(`{"from":"2024-01-01","to":"2024-01-31"}`))
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.BatchOrdersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, 2, response.ProcessedCount)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "jan-2", response.Results[0].OrderNumber)
+	assert.Equal(t, "jan-1", response.Results[1].OrderNumber)
+}
+
+func TestReprocessOrders_HasNoReceiptSideEffects(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	defer SetEventPublisher(nil)
+	_, err := orders.SaveOrder(context.Background(), &store.OrderRecord{
+		TenantID: models.DefaultTenantID,
+		Order:    models.Order{Retailer: models.RetailerWalmart, OrderNumber: "jan-1", OrderDate: "2024-01-10"},
+	})
+	require.NoError(t, err)
+	synced := trackerFor(models.DefaultTenantID).OrdersProcessedTotal
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/reprocess", AuthMiddleware(newTestKeyring()), RequireScope(auth.ScopeIngest), ReprocessOrders)
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/orders/reprocess", "test-secret",
+		[]byte(`{"from":"2024-01-01","to":"2024-01-31"}`))
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, publisher.events)
+	assert.Equal(t, synced, trackerFor(models.DefaultTenantID).OrdersProcessedTotal)
+}
+
+func TestReprocessOrders_RejectsMoreThanTheBatchLimit(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	SetBatchLimits(BatchLimits{MaxOrders: 2, Workers: 1})
+	defer SetBatchLimits(DefaultBatchLimits())
+	for _, number := range []string{"jan-1", "jan-2", "jan-3"} {
+		_, err := orders.SaveOrder(context.Background(), &store.OrderRecord{
+			TenantID: models.DefaultTenantID,
+			Order:    models.Order{OrderNumber: number, OrderDate: "2024-01-10"},
+		})
+		require.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/reprocess", AuthMiddleware(newTestKeyring()), RequireScope(auth.ScopeIngest), ReprocessOrders)
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/orders/reprocess", "test-secret",
+		[]byte(`{"from":"2024-01-01","to":"2024-01-31"}`))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "more than 2 orders")
+}
+
+func TestReprocessOrders_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/reprocess", ReprocessOrders)
+
+	for _, body := range []string{`{}`, `{"from":"2024-01-01"}`, `{"from":"01/01/2024","to":"2024-01-31"}`} {
+		w := doKeyRequest(router, "POST", "/reprocess", "", []byte(body))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByIP limits requests per client IP. name scopes the buckets so different
+// route groups can have independent limits.
+func RateLimitByIP(backend ratelimit.Backend, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return rateLimit(backend, limit, func(c *gin.Context) string {
+		return name + ":ip:" + c.ClientIP()
+	})
+}
+
+// RateLimitByKey limits requests per authenticated API key. It must run after
+// AuthMiddleware; unauthenticated requests pass through.
+func RateLimitByKey(backend ratelimit.Backend, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return rateLimit(backend, limit, func(c *gin.Context) string {
+		key := APIKeyFromContext(c)
+		if key == nil {
+			return ""
+		}
+		return name + ":key:" + key.ID
+	})
+}
+
+// rateLimit enforces limit on the bucket chosen by bucketKey, setting X-RateLimit-*
+// headers on every response and Retry-After on 429 responses.
+func rateLimit(backend ratelimit.Backend, limit ratelimit.Limit, bucketKey func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := bucketKey(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		result, err := backend.Allow(c.Request.Context(), key, limit)
+		if err != nil {
+			// Fail open: an unavailable backend should not take the API down
+			logging.FromContext(c.Request.Context()).Error("Rate limit backend error", "bucket", key, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(result.ResetAfter).Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"status":  "error",
+				"message": "Rate limit exceeded, retry in " + strconv.Itoa(retryAfter) + "s",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingBackend simulates an unavailable shared backend.
+type failingBackend struct{}
+
+func (failingBackend) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("backend unavailable")
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimitByIP(ratelimit.NewMemoryBackend(), "api", ratelimit.Limit{Rate: 2, Per: time.Minute}))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = ip + ":12345"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Act & Assert
+	w := request("10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1").Code)
+
+	w = request("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Contains(t, w.Body.String(), "Rate limit exceeded")
+
+	// A different client is unaffected
+	assert.Equal(t, http.StatusOK, request("10.0.0.2").Code)
+}
+
+func TestRateLimitByIP_TrustedProxies(t *testing.T) {
+	// Arrange: only 10.0.0.1 is a trusted proxy
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.1"}))
+	router.Use(RateLimitByIP(ratelimit.NewMemoryBackend(), "api", ratelimit.Limit{Rate: 1, Per: time.Minute}))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	request := func(remote, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remote + ":12345"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Act & Assert - a client cannot dodge its limit by forging the header
+	assert.Equal(t, http.StatusOK, request("203.0.113.7", "198.51.100.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.7", "198.51.100.2"))
+
+	// Clients behind the trusted proxy are limited by their forwarded address
+	assert.Equal(t, http.StatusOK, request("10.0.0.1", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.1", "198.51.100.2"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1", "198.51.100.2"))
+}
+
+func TestRateLimitByKey(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	keyring := newTestKeyring()
+	backend := ratelimit.NewMemoryBackend()
+	router := gin.New()
+	router.Use(AuthMiddleware(keyring))
+	router.Use(RateLimitByKey(backend, "api", ratelimit.Limit{Rate: 1, Per: time.Hour}))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	other, _, err := keyring.Create(models.DefaultTenantID, "other", auth.AllScopes, 0)
+	assert.NoError(t, err)
+
+	request := func(key string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Extension-Key", key)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Act & Assert - each key has its own budget regardless of IP
+	assert.Equal(t, http.StatusOK, request("test-secret"))
+	assert.Equal(t, http.StatusTooManyRequests, request("test-secret"))
+	assert.Equal(t, http.StatusOK, request(other))
+}
+
+func TestRateLimit_DisabledAndFailOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/off", RateLimitByIP(ratelimit.NewMemoryBackend(), "api", ratelimit.Limit{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/broken", RateLimitByIP(failingBackend{}, "api", ratelimit.Limit{Rate: 1, Per: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/off", "/broken"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"monarchmoney-sync-backend/jobs"
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/monarch"
+	"monarchmoney-sync-backend/store"
+	"monarchmoney-sync-backend/webhooks"
+
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+)
+
+// refundStore persists received refunds. It defaults to an in-memory store so
+// handlers work without a database configured.
+var refundStore store.RefundStore = store.NewMemory()
+
+// SetRefundStore sets the store that refund handlers save to.
+func SetRefundStore(s store.RefundStore) {
+	if s == nil {
+		s = store.NewMemory()
+	}
+	refundStore = s
+}
+
+// Transactions reads and splits a tenant's Monarch transactions.
+type Transactions interface {
+	// ListTransactions returns the tenant's transactions dated from to to, which
+	// are inclusive YYYY-MM-DD dates.
+	ListTransactions(ctx context.Context, tenantID, from, to string) ([]models.Transaction, error)
+	// SplitTransaction replaces a transaction's category with splits that add up
+	// to its amount.
+	SplitTransaction(ctx context.Context, tenantID, transactionID string, splits []models.Split) error
+}
+
+// monarchTransactions is where refunds are matched and split. Until it is set,
+// refunds are stored unmatched.
+var monarchTransactions Transactions
+
+// SetTransactions sets the Monarch transactions that refunds are matched to, or
+// nil to only store refunds.
+func SetTransactions(t Transactions) {
+	monarchTransactions = t
+}
+
+// ReceiveRefund records a refund for items returned from one of the route's
+// retailer's orders. Each returned item is linked to the original order's item to
+// take its category, and the refund's Monarch transaction, a credit from the
+// retailer, is split into those categories once it has posted. Posting an
+// unmatched refund again replaces it and looks for its transaction again; a
+// matched one is kept as stored, since its transaction was split by it.
+func ReceiveRefund(c *gin.Context) {
+	hub := sentrygin.GetHubFromContext(c)
+	ctx := c.Request.Context()
+	tenantID := TenantIDFromContext(c)
+	logger := logging.FromContext(ctx)
+
+	var refund models.Refund
+	if err := c.ShouldBindJSON(&refund); err != nil {
+		if isBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+		})
+		return
+	}
+	err := claimRetailer(&refund.Retailer, "refund", RetailerFromContext(c))
+	if err == nil {
+		err = validateRefund(refund)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid refund: %v", err),
+		})
+		return
+	}
+
+	rec, err := loadOrder(c, refund.OrderNumber)
+	if err != nil {
+		respondOrderNotLoaded(c, err)
+		return
+	}
+	previous, err := refundStore.ListRefunds(ctx, tenantID, refund.Retailer, refund.OrderNumber)
+	if err != nil {
+		logger.Error("Failed to load refunds", "order_number", refund.OrderNumber, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to load refunds",
+		})
+		return
+	}
+	for _, p := range previous {
+		if p.Refund.RefundID == refund.RefundID && p.Refund.TransactionID != "" {
+			c.JSON(http.StatusOK, refundResponse(&p.Refund, true, "Refund already matched; the stored refund is kept"))
+			return
+		}
+	}
+
+	// The refund is checked against the order's other refunds as it is saved, so
+	// refunds arriving at once cannot together return more than was bought
+	var invalid error
+	saved := &store.RefundRecord{TenantID: tenantID, Refund: refund}
+	_, err = refundStore.SaveRefund(ctx, saved, func(r *models.Refund, earlier []models.Refund) error {
+		invalid = r.LinkItems(&rec.Order, earlier)
+		if invalid == nil {
+			invalid = r.CheckAmount(&rec.Order, earlier)
+		}
+		return invalid
+	})
+	if invalid != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid refund: %v", invalid),
+		})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to store refund", "refund_id", refund.RefundID, "error", err)
+		if hub != nil {
+			hub.CaptureException(err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to store refund",
+		})
+		return
+	}
+	refund = saved.Refund
+
+	// Matching needs the Monarch client and the tenant's Monarch token
+	configured := monarchTransactions != nil
+	if configured {
+		err := matchRefund(ctx, tenantID, requestActor(c), &refund)
+		switch {
+		case errors.Is(err, monarch.ErrNoToken):
+			configured = false
+		case err != nil:
+			// The refund is stored, and matched by its background job
+			logger.Warn("Failed to match refund transaction", "refund_id", refund.RefundID, "error", err)
+			if hub != nil {
+				hub.CaptureException(err)
+			}
+		}
+	}
+	// Transactions can take days to post, so keep looking in the background
+	if configured && refund.TransactionID == "" {
+		enqueueJob(ctx, tenantID, RefundMatchJob, refundMatchPayload{
+			Retailer:    refund.Retailer,
+			OrderNumber: refund.OrderNumber,
+			RefundID:    refund.RefundID,
+		})
+	}
+
+	logger.Info("Received "+refund.Retailer.Name()+" refund",
+		"refund_id", refund.RefundID, "order_number", refund.OrderNumber, "amount", refund.Amount,
+		"items", len(refund.Items), "transaction_id", refund.TransactionID)
+
+	c.JSON(http.StatusOK, refundResponse(&refund, configured, "Refund received successfully"))
+}
+
+// refundResponse reports a received refund and how far matching it has got;
+// configured is whether its tenant's refunds can be matched at all.
+func refundResponse(refund *models.Refund, configured bool, message string) models.RefundResponse {
+	status := models.RefundMatched
+	switch {
+	case refund.TransactionID != "":
+	case !configured:
+		status = models.RefundMatchNotConfigured
+		message += "; Monarch transaction matching is not configured"
+	default:
+		status = models.RefundMatchPending
+	}
+	return models.RefundResponse{
+		Status:        "success",
+		Message:       message,
+		RefundID:      refund.RefundID,
+		OrderID:       refund.OrderNumber,
+		Matched:       refund.TransactionID != "",
+		MatchStatus:   status,
+		TransactionID: refund.TransactionID,
+		Splits:        refund.Splits(),
+		Timestamp:     time.Now(),
+	}
+}
+
+// ListOrderRefunds returns the refunds of one of the caller's tenant's orders from
+// the route's retailer, oldest first.
+func ListOrderRefunds(c *gin.Context) {
+	rec, err := loadOrder(c, c.Param("orderNumber"))
+	if err != nil {
+		respondOrderNotLoaded(c, err)
+		return
+	}
+
+	records, err := refundStore.ListRefunds(c.Request.Context(), rec.TenantID, rec.Order.Retailer, rec.Order.OrderNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to list refunds",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": records,
+		"count":   len(records),
+	})
+}
+
+// validateRefund checks the refund's amount, date, and returned items.
+func validateRefund(refund models.Refund) error {
+	if refund.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if _, _, err := refund.MatchWindow(); err != nil {
+		return err
+	}
+	if len(refund.Items) == 0 {
+		return fmt.Errorf("no items returned")
+	}
+	for i, item := range refund.Items {
+		if item.Name == "" {
+			return fmt.Errorf("missing name for item %d", i+1)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("invalid quantity for item %q: must be positive", item.Name)
+		}
+		if item.Amount != nil && *item.Amount < 0 {
+			return fmt.Errorf("invalid amount for item %q: must be non-negative", item.Name)
+		}
+	}
+	return nil
+}
+
+// matchRefund looks for a stored refund's Monarch transaction, claims it, and
+// splits it, setting the refund's TransactionID and recording the split as made
+// by actor. Transactions claimed by other refunds are skipped, and a refund whose
+// transaction has not posted yet is left unmatched.
+func matchRefund(ctx context.Context, tenantID, actor string, refund *models.Refund) error {
+	from, to, err := refund.MatchWindow()
+	if err != nil {
+		return err
+	}
+	txns, err := monarchTransactions.ListTransactions(ctx, tenantID, from, to)
+	appMetrics.MonarchCall("list_transactions", err)
+	if err != nil {
+		return fmt.Errorf("list transactions: %w", err)
+	}
+
+	claimed := make(map[string]bool)
+	for {
+		txn, ok := refund.MatchTransaction(txns, claimed)
+		if !ok {
+			return nil
+		}
+		// Claiming first keeps two refunds matched at once from splitting the same
+		// transaction
+		err := refundStore.ClaimTransaction(ctx, tenantID, refund.Retailer, refund.RefundID, txn.ID)
+		if errors.Is(err, store.ErrExists) {
+			claimed[txn.ID] = true
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("claim transaction %s: %w", txn.ID, err)
+		}
+
+		splits := refund.Splits()
+		err = monarchTransactions.SplitTransaction(ctx, tenantID, txn.ID, splits)
+		appMetrics.MonarchCall("split_transaction", err)
+		if err != nil {
+			if err := refundStore.ReleaseTransaction(ctx, tenantID, refund.Retailer, refund.RefundID); err != nil {
+				logging.FromContext(ctx).Error("Failed to release refund transaction",
+					"refund_id", refund.RefundID, "transaction_id", txn.ID, "error", err)
+			}
+			return fmt.Errorf("split transaction %s: %w", txn.ID, err)
+		}
+		refund.TransactionID = txn.ID
+		audit(ctx, tenantID, actor, AuditRefundSplit, "transaction:"+txn.ID, gin.H{
+			"refundId":    refund.RefundID,
+			"orderNumber": refund.OrderNumber,
+			"splits":      splits,
+		})
+		publishRefundEvent(tenantID, webhooks.EventOrderSplitApplied, refund, "")
+		for _, split := range splits {
+			if split.Category == "" {
+				publishRefundEvent(tenantID, webhooks.EventOrderNeedsReview, refund, "part of the refund is uncategorized")
+				break
+			}
+		}
+		return nil
+	}
+}
+
+// RefundMatchJob is the kind of job that looks again for the transaction of a
+// refund stored unmatched.
+const RefundMatchJob = "refund.match"
+
+// refundMatchRetry is how often an unmatched refund's transaction is looked for.
+const refundMatchRetry = 6 * time.Hour
+
+// refundMatchPayload identifies the refund a RefundMatchJob matches.
+type refundMatchPayload struct {
+	Retailer    models.Retailer `json:"retailer"`
+	OrderNumber string          `json:"orderNumber"`
+	RefundID    string          `json:"refundId"`
+}
+
+// MatchRefundJob runs a RefundMatchJob. It looks for the refund's transaction
+// every refundMatchRetry until it is matched or its match window has passed.
+func MatchRefundJob(ctx context.Context, job *store.Job) error {
+	var payload refundMatchPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	if monarchTransactions == nil {
+		return nil
+	}
+
+	previous, err := refundStore.ListRefunds(ctx, job.TenantID, payload.Retailer, payload.OrderNumber)
+	if err != nil {
+		return fmt.Errorf("load refunds: %w", err)
+	}
+	var refund *models.Refund
+	for _, p := range previous {
+		if p.Refund.RefundID == payload.RefundID {
+			refund = &p.Refund
+		}
+	}
+	if refund == nil || refund.TransactionID != "" {
+		return nil
+	}
+
+	actor := fmt.Sprintf("job:%d", job.ID)
+	err = matchRefund(ctx, job.TenantID, actor, refund)
+	if errors.Is(err, monarch.ErrNoToken) {
+		// Posting the refund again matches it once a token is stored
+		logging.FromContext(ctx).Warn("Refund not matched, tenant has no Monarch token",
+			"refund_id", refund.RefundID, "order_number", refund.OrderNumber)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if refund.TransactionID == "" {
+		_, to, err := refund.MatchWindow()
+		if err != nil {
+			return err
+		}
+		end, _ := time.Parse("2006-01-02", to)
+		if time.Now().After(end.AddDate(0, 0, 1)) {
+			logging.FromContext(ctx).Warn("No transaction posted for refund within its match window",
+				"refund_id", refund.RefundID, "order_number", refund.OrderNumber)
+			publishRefundEvent(job.TenantID, webhooks.EventOrderNeedsReview, refund,
+				"no transaction posted within the refund's match window")
+			return nil
+		}
+		return jobs.RetryAt(time.Now().Add(refundMatchRetry), errors.New("refund transaction has not posted yet"))
+	}
+
+	logging.FromContext(ctx).Info("Matched refund transaction",
+		"refund_id", refund.RefundID, "order_number", refund.OrderNumber, "transaction_id", refund.TransactionID)
+	return nil
+}
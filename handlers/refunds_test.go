@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/jobs"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/monarch"
+	"monarchmoney-sync-backend/store"
+	"monarchmoney-sync-backend/webhooks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransactions is a Monarch account holding txns that records the splits
+// applied to them.
+type fakeTransactions struct {
+	txns     []models.Transaction
+	listErr  error
+	splitErr error
+	from     string
+	to       string
+	splits   map[string][]models.Split
+}
+
+func (f *fakeTransactions) ListTransactions(_ context.Context, _, from, to string) ([]models.Transaction, error) {
+	f.from, f.to = from, to
+	return f.txns, f.listErr
+}
+
+func (f *fakeTransactions) SplitTransaction(_ context.Context, _, transactionID string, splits []models.Split) error {
+	if f.splitErr != nil {
+		return f.splitErr
+	}
+	if f.splits == nil {
+		f.splits = make(map[string][]models.Split)
+	}
+	f.splits[transactionID] = splits
+	return nil
+}
+
+// setupRefunds stores an order for refunds to be linked to and resets the refund
+// handlers' state when the test ends.
+func setupRefunds(t *testing.T, txns Transactions) {
+	t.Helper()
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	SetRefundStore(store.NewMemory())
+	SetTransactions(txns)
+	SetJobQueue(store.NewMemory())
+	SetAuditLog(store.NewMemory())
+	t.Cleanup(func() {
+		SetOrderStore(nil)
+		SetRefundStore(nil)
+		SetTransactions(nil)
+		SetJobQueue(nil)
+		SetAuditLog(nil)
+	})
+
+	_, err := orders.SaveOrder(context.Background(), &store.OrderRecord{
+		TenantID: models.DefaultTenantID,
+		Order: models.Order{
+			Retailer:    models.RetailerWalmart,
+			OrderNumber: "1001",
+			OrderDate:   "2024-03-01",
+			Items: []models.OrderItem{
+				{Name: "Paper Towels", Price: 19.99, Quantity: 1, Category: "Household"},
+				{Name: "Milk", Price: 3.50, Quantity: 2, Category: "Groceries"},
+			},
+		},
+	})
+	require.NoError(t, err)
+}
+
+const testRefund = `{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":26.99,
+	"items":[{"name":"Paper Towels","quantity":1},{"name":"Milk","quantity":2}]}`
+
+func TestReceiveRefund_MatchesAndSplitsTransaction(t *testing.T) {
+	// Arrange
+	txns := &fakeTransactions{txns: []models.Transaction{
+		{ID: "purchase", Date: "2024-03-01", Merchant: "Walmart", Amount: -26.99},
+		{ID: "refund", Date: "2024-03-12", Merchant: "Walmart", Amount: 26.99},
+	}}
+	setupRefunds(t, txns)
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	t.Cleanup(func() { SetEventPublisher(nil) })
+	router := newRetailerRouter()
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.RefundResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Matched)
+	assert.Equal(t, models.RefundMatched, response.MatchStatus)
+	assert.Equal(t, "refund", response.TransactionID)
+
+	want := []models.Split{{Category: "Household", Amount: 19.99}, {Category: "Groceries", Amount: 7}}
+	assert.Equal(t, want, response.Splits)
+	assert.Equal(t, want, txns.splits["refund"])
+	assert.Equal(t, "2024-03-08", txns.from)
+	assert.Equal(t, "2024-03-20", txns.to)
+
+	stored, err := refundStore.ListRefunds(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "1001")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "refund", stored[0].Refund.TransactionID)
+	assert.Equal(t, models.RetailerWalmart, stored[0].Refund.Retailer)
+	assert.Equal(t, "Groceries", stored[0].Refund.Items[1].Category)
+
+	entries, err := auditLog.ListAudit(context.Background(), models.DefaultTenantID, store.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditRefundSplit, entries[0].Action)
+	assert.Equal(t, "transaction:refund", entries[0].Subject)
+
+	require.Equal(t, []webhooks.EventType{webhooks.EventOrderSplitApplied}, publisher.events)
+	assert.Equal(t, "1001", publisher.data[0].OrderNumber)
+	assert.Equal(t, "R1", publisher.data[0].RefundID)
+	assert.Equal(t, "refund", publisher.data[0].TransactionID)
+}
+
+func TestReceiveRefund_UncategorizedSplitNeedsReview(t *testing.T) {
+	// Arrange
+	txns := &fakeTransactions{txns: []models.Transaction{
+		{ID: "refund", Date: "2024-03-12", Merchant: "Walmart", Amount: 5},
+	}}
+	setupRefunds(t, txns)
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	t.Cleanup(func() { SetEventPublisher(nil) })
+	_, err := orderStore.SaveOrder(context.Background(), &store.OrderRecord{
+		TenantID: models.DefaultTenantID,
+		Order: models.Order{
+			Retailer:    models.RetailerWalmart,
+			OrderNumber: "1002",
+			OrderDate:   "2024-03-01",
+			Items:       []models.OrderItem{{Name: "Batteries", Price: 5, Quantity: 1}},
+		},
+	})
+	require.NoError(t, err)
+	router := newRetailerRouter()
+	body := `{"refundId":"R5","orderNumber":"1002","refundDate":"2024-03-10","amount":5,
+		"items":[{"name":"Batteries","quantity":1}]}`
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(body))
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []webhooks.EventType{webhooks.EventOrderSplitApplied, webhooks.EventOrderNeedsReview}, publisher.events)
+	assert.Equal(t, "part of the refund is uncategorized", publisher.data[1].Error)
+}
+
+func TestReceiveRefund_StoresUnmatchedRefunds(t *testing.T) {
+	t.Run("without Monarch", func(t *testing.T) {
+		setupRefunds(t, nil)
+		router := newRetailerRouter()
+
+		w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"matched":false`)
+		assert.Contains(t, w.Body.String(), `"matchStatus":"not_configured"`)
+		assert.Contains(t, w.Body.String(), "matching is not configured")
+	})
+
+	t.Run("tenant without a Monarch token", func(t *testing.T) {
+		setupRefunds(t, &fakeTransactions{listErr: monarch.ErrNoToken})
+		router := newRetailerRouter()
+
+		w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"matchStatus":"not_configured"`)
+		_, err := jobQueue.ClaimJob(context.Background(), "w1", time.Minute)
+		assert.ErrorIs(t, err, store.ErrNoJobs, "no job looks for a transaction it cannot list")
+	})
+
+	t.Run("transaction not posted yet", func(t *testing.T) {
+		txns := &fakeTransactions{}
+		setupRefunds(t, txns)
+		router := newRetailerRouter()
+
+		first := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+		txns.txns = []models.Transaction{{ID: "refund", Date: "2024-03-14", Merchant: "WALMART.COM", Amount: 26.99}}
+		again := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		assert.Contains(t, first.Body.String(), `"matchStatus":"pending"`)
+		assert.Contains(t, again.Body.String(), `"transactionId":"refund"`, "posting again retries matching")
+	})
+
+	t.Run("split fails", func(t *testing.T) {
+		txns := &fakeTransactions{
+			txns:     []models.Transaction{{ID: "refund", Date: "2024-03-14", Merchant: "Walmart", Amount: 26.99}},
+			splitErr: errors.New("unavailable"),
+		}
+		setupRefunds(t, txns)
+		router := newRetailerRouter()
+
+		first := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+		txns.splitErr = nil
+		again := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		assert.Contains(t, first.Body.String(), `"matchStatus":"pending"`)
+		assert.Contains(t, again.Body.String(), `"transactionId":"refund"`, "the failed claim is released")
+	})
+
+	t.Run("Monarch unavailable", func(t *testing.T) {
+		setupRefunds(t, &fakeTransactions{listErr: errors.New("unavailable")})
+		router := newRetailerRouter()
+
+		w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"matched":false`)
+		stored, err := refundStore.ListRefunds(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "1001")
+		require.NoError(t, err)
+		assert.Len(t, stored, 1)
+	})
+}
+
+func TestMatchRefundJob(t *testing.T) {
+	claim := func(t *testing.T) *store.Job {
+		t.Helper()
+		job, err := jobQueue.ClaimJob(context.Background(), "w1", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, RefundMatchJob, job.Kind)
+		return job
+	}
+
+	t.Run("matches once the transaction posts", func(t *testing.T) {
+		// Arrange
+		txns := &fakeTransactions{}
+		setupRefunds(t, txns)
+		router := newRetailerRouter()
+		w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+		require.Contains(t, w.Body.String(), `"matched":false`)
+		txns.txns = []models.Transaction{{ID: "refund", Date: "2024-03-14", Merchant: "Walmart", Amount: 26.99}}
+
+		// Act
+		err := MatchRefundJob(context.Background(), claim(t))
+
+		// Assert
+		require.NoError(t, err)
+		stored, err := refundStore.ListRefunds(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "1001")
+		require.NoError(t, err)
+		require.Len(t, stored, 1)
+		assert.Equal(t, "refund", stored[0].Refund.TransactionID)
+		assert.Len(t, txns.splits["refund"], 2)
+		entries, err := auditLog.ListAudit(context.Background(), models.DefaultTenantID, store.AuditFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Regexp(t, `^job:\d+$`, entries[0].Actor)
+	})
+
+	t.Run("retries while the match window is open", func(t *testing.T) {
+		// Arrange
+		setupRefunds(t, &fakeTransactions{})
+		router := newRetailerRouter()
+		today := time.Now().Format("2006-01-02")
+		body := `{"refundId":"R2","orderNumber":"1001","refundDate":"` + today + `","amount":3.50,
+			"items":[{"name":"Milk","quantity":1}]}`
+		doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(body))
+
+		// Act
+		err := MatchRefundJob(context.Background(), claim(t))
+
+		// Assert
+		var retry *jobs.RetryError
+		require.ErrorAs(t, err, &retry)
+		assert.True(t, retry.At.After(time.Now()))
+	})
+
+	t.Run("gives up after the match window", func(t *testing.T) {
+		// Arrange
+		setupRefunds(t, &fakeTransactions{})
+		publisher := &recordingPublisher{}
+		SetEventPublisher(publisher)
+		t.Cleanup(func() { SetEventPublisher(nil) })
+		router := newRetailerRouter()
+		doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		// Act
+		err := MatchRefundJob(context.Background(), claim(t))
+
+		// Assert
+		assert.NoError(t, err)
+		require.Equal(t, []webhooks.EventType{webhooks.EventOrderNeedsReview}, publisher.events)
+		assert.Equal(t, "R1", publisher.data[0].RefundID)
+		assert.Contains(t, publisher.data[0].Error, "match window")
+	})
+
+	t.Run("not enqueued without Monarch", func(t *testing.T) {
+		setupRefunds(t, nil)
+		router := newRetailerRouter()
+
+		doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+
+		_, err := jobQueue.ClaimJob(context.Background(), "w1", time.Minute)
+		assert.ErrorIs(t, err, store.ErrNoJobs)
+	})
+}
+
+func TestReceiveRefund_SkipsTransactionsOfOtherRefunds(t *testing.T) {
+	// Arrange
+	txns := &fakeTransactions{txns: []models.Transaction{
+		{ID: "first", Date: "2024-03-10", Merchant: "Walmart", Amount: 3.50},
+		{ID: "second", Date: "2024-03-11", Merchant: "Walmart", Amount: 3.50},
+	}}
+	setupRefunds(t, txns)
+	router := newRetailerRouter()
+	milk := func(id string) []byte {
+		return []byte(`{"refundId":"` + id + `","orderNumber":"1001","refundDate":"2024-03-10","amount":3.50,
+			"items":[{"name":"Milk","quantity":1}]}`)
+	}
+
+	// Act
+	first := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R1"))
+	second := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R2"))
+	repeated := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R1"))
+	listed := doKeyRequest(router, "GET", "/api/walmart/orders/1001/refunds", "", nil)
+
+	// Assert
+	assert.Contains(t, first.Body.String(), `"transactionId":"first"`)
+	assert.Contains(t, second.Body.String(), `"transactionId":"second"`)
+	assert.Contains(t, repeated.Body.String(), `"transactionId":"first"`, "a matched refund keeps its transaction")
+	assert.Len(t, txns.splits, 2)
+
+	require.Equal(t, http.StatusOK, listed.Code)
+	var response struct {
+		Refunds []store.RefundRecord `json:"refunds"`
+		Count   int                  `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Count)
+}
+
+func TestReceiveRefund_KeepsMatchedRefund(t *testing.T) {
+	// Arrange
+	txns := &fakeTransactions{txns: []models.Transaction{
+		{ID: "refund", Date: "2024-03-12", Merchant: "Walmart", Amount: 26.99},
+	}}
+	setupRefunds(t, txns)
+	router := newRetailerRouter()
+	doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(testRefund))
+	split := txns.splits["refund"]
+	milkOnly := `{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":7,
+		"items":[{"name":"Milk","quantity":2}]}`
+
+	// Act
+	w := doKeyRequest(router, "POST", "/api/walmart/refunds", "", []byte(milkOnly))
+
+	// Assert
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.RefundResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "refund", response.TransactionID)
+	assert.Equal(t, split, response.Splits, "the stored splits are returned")
+	assert.Equal(t, split, txns.splits["refund"], "the transaction is not split again")
+
+	stored, err := refundStore.ListRefunds(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "1001")
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, 26.99, stored[0].Refund.Amount)
+	assert.Len(t, stored[0].Refund.Items, 2)
+}
+
+func TestReceiveRefund_CountsEarlierRefunds(t *testing.T) {
+	setupRefunds(t, nil)
+	router := newRetailerRouter()
+	milk := func(id string, quantity string) []byte {
+		return []byte(`{"refundId":"` + id + `","orderNumber":"1001","refundDate":"2024-03-10","amount":3.50,
+			"items":[{"name":"Milk","quantity":` + quantity + `}]}`)
+	}
+
+	first := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R1", "1"))
+	replaced := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R1", "1"))
+	second := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R2", "1"))
+	third := doKeyRequest(router, "POST", "/api/walmart/refunds", "", milk("R3", "1"))
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, replaced.Code, "a refund does not count against itself")
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, http.StatusBadRequest, third.Code)
+	assert.Contains(t, third.Body.String(), `more of item \"Milk\" returned than order 1001 has`)
+}
+
+func TestReceiveRefund_Rejects(t *testing.T) {
+	setupRefunds(t, nil)
+	router := newRetailerRouter()
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		status  int
+		message string
+	}{
+		{"missing fields", "/api/walmart/refunds", `{"refundId":"R1"}`, http.StatusBadRequest, "Invalid JSON or validation error"},
+		{"another retailer", "/api/walmart/refunds",
+			`{"retailer":"amazon","refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":1,"items":[{"name":"Milk","quantity":1}]}`,
+			http.StatusBadRequest, "Invalid refund: refund is from Amazon, not Walmart"},
+		{"bad date", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"03/10/2024","amount":1,"items":[{"name":"Milk","quantity":1}]}`,
+			http.StatusBadRequest, `Invalid refund: invalid refund date \"03/10/2024\"`},
+		{"no items", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":1,"items":[]}`,
+			http.StatusBadRequest, "Invalid refund: no items returned"},
+		{"bad quantity", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":1,"items":[{"name":"Milk","quantity":0}]}`,
+			http.StatusBadRequest, `Invalid refund: invalid quantity for item \"Milk\": must be positive`},
+		{"item not in order", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":1,"items":[{"name":"Eggs","quantity":1}]}`,
+			http.StatusBadRequest, `Invalid refund: item \"Eggs\" is not in order 1001`},
+		{"more than the items", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":4,"items":[{"name":"Milk","quantity":1}]}`,
+			http.StatusBadRequest, "Invalid refund: amount 4.00 is more than the 3.50 paid for the returned items"},
+		{"unknown order", "/api/walmart/refunds",
+			`{"refundId":"R1","orderNumber":"9999","refundDate":"2024-03-10","amount":1,"items":[{"name":"Milk","quantity":1}]}`,
+			http.StatusNotFound, "Order not found"},
+		{"order from another retailer", "/api/amazon/refunds",
+			`{"refundId":"R1","orderNumber":"1001","refundDate":"2024-03-10","amount":1,"items":[{"name":"Milk","quantity":1}]}`,
+			http.StatusNotFound, "Order not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doKeyRequest(router, "POST", tt.path, "", []byte(tt.body))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+		})
+	}
+}
+
+func TestListOrderRefunds_UnknownOrder(t *testing.T) {
+	setupRefunds(t, nil)
+	router := newRetailerRouter()
+
+	w := doKeyRequest(router, "GET", "/api/walmart/orders/9999/refunds", "", nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
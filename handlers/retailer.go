@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"monarchmoney-sync-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retailerContextKey is the gin context key holding the route's retailer.
+const retailerContextKey = "retailer"
+
+// RequireRetailer resolves the :retailer route parameter of the
+// /api/:retailer/orders route family, responding 404 for unsupported retailers.
+func RequireRetailer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		retailer, err := models.ParseRetailer(c.Param("retailer"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Unknown retailer %q", c.Param("retailer")),
+			})
+			return
+		}
+		c.Set(retailerContextKey, retailer)
+		c.Next()
+	}
+}
+
+// RetailerFromContext returns the retailer named by the route. Routes without one
+// belong to models.DefaultRetailer.
+func RetailerFromContext(c *gin.Context) models.Retailer {
+	if value, ok := c.Get(retailerContextKey); ok {
+		if retailer, ok := value.(models.Retailer); ok {
+			return retailer
+		}
+	}
+	return models.DefaultRetailer
+}
+
+// assignRetailer sets the route's retailer on an order that does not name one. An
+// order from another retailer was most likely sent to the wrong route.
+func assignRetailer(order *models.Order, retailer models.Retailer) error {
+	return claimRetailer(&order.Retailer, "order", retailer)
+}
+
+// claimRetailer sets the route's retailer on a received order or refund, named by
+// kind in errors, unless it names another one.
+func claimRetailer(got *models.Retailer, kind string, retailer models.Retailer) error {
+	switch *got {
+	case "":
+		*got = retailer
+	case retailer:
+	default:
+		return fmt.Errorf("%s is from %s, not %s", kind, got.Name(), retailer.Name())
+	}
+	return nil
+}
+
+// orderSource labels order metrics and Sentry events by the order's retailer.
+func orderSource(order *models.Order) string {
+	if order.Retailer == "" {
+		return string(models.DefaultRetailer)
+	}
+	return string(order.Retailer)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRetailerRouter registers order routes under the /api/:retailer family, as
+// main does, alongside a static route to check the two do not conflict.
+func newRetailerRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api")
+	api.GET("/webhooks", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	retailer := api.Group("/:retailer", RequireRetailer())
+	retailer.POST("/orders", ReceiveOrders)
+	retailer.POST("/orders/batch", ReceiveBatchOrders)
+	retailer.POST("/orders/import", ImportOrdersCSV)
+	retailer.GET("/orders", ListOrders)
+	retailer.GET("/orders/:orderNumber", GetOrder)
+	retailer.GET("/orders/:orderNumber/refunds", ListOrderRefunds)
+	retailer.POST("/refunds", ReceiveRefund)
+	return router
+}
+
+func TestRetailerRoutes_StoreOrdersPerRetailer(t *testing.T) {
+	// Arrange
+	orders := store.NewMemory()
+	SetOrderStore(orders)
+	defer SetOrderStore(nil)
+	router := newRetailerRouter()
+
+	// Act
+	walmart := doKeyRequest(router, "POST", "/api/walmart/orders", "", []byte(`{"orderNumber":"1001","orderDate":"2024-01-05"}`))
+	amazon := doKeyRequest(router, "POST", "/api/Amazon/orders", "", []byte(`{"orderNumber":"112-1","orderDate":"2024-01-06"}`))
+	listed := doKeyRequest(router, "GET", "/api/amazon/orders", "", nil)
+	crossed := doKeyRequest(router, "GET", "/api/walmart/orders/112-1", "", nil)
+
+	// Assert
+	require.Equal(t, http.StatusOK, walmart.Code, walmart.Body.String())
+	require.Equal(t, http.StatusOK, amazon.Code, amazon.Body.String())
+
+	rec, err := orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerAmazon, "112-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.RetailerAmazon, rec.Order.Retailer)
+	rec, err = orders.GetOrder(context.Background(), models.DefaultTenantID, models.RetailerWalmart, "1001")
+	require.NoError(t, err)
+	assert.Equal(t, models.RetailerWalmart, rec.Order.Retailer)
+
+	require.Equal(t, http.StatusOK, listed.Code)
+	var response struct {
+		Orders []store.OrderRecord `json:"orders"`
+	}
+	require.NoError(t, json.Unmarshal(listed.Body.Bytes(), &response))
+	require.Len(t, response.Orders, 1)
+	assert.Equal(t, "112-1", response.Orders[0].Order.OrderNumber)
+
+	assert.Equal(t, http.StatusNotFound, crossed.Code, "orders are only found under their own retailer")
+}
+
+func TestRetailerRoutes_SameOrderNumberAtTwoRetailers(t *testing.T) {
+	// Arrange
+	SetOrderStore(store.NewMemory())
+	defer SetOrderStore(nil)
+	router := newRetailerRouter()
+
+	// Act
+	walmart := doKeyRequest(router, "POST", "/api/walmart/orders", "",
+		[]byte(`{"orderNumber":"5001","orderDate":"2024-01-05","items":[{"name":"Milk","price":3.5,"quantity":1}]}`))
+	amazon := doKeyRequest(router, "POST", "/api/amazon/orders", "",
+		[]byte(`{"orderNumber":"5001","orderDate":"2024-01-06","items":[{"name":"Cable","price":9.99,"quantity":1}]}`))
+	gotWalmart := doKeyRequest(router, "GET", "/api/walmart/orders/5001", "", nil)
+	gotAmazon := doKeyRequest(router, "GET", "/api/amazon/orders/5001", "", nil)
+
+	// Assert
+	require.Equal(t, http.StatusOK, walmart.Code, walmart.Body.String())
+	require.Equal(t, http.StatusOK, amazon.Code, amazon.Body.String())
+	require.Equal(t, http.StatusOK, gotWalmart.Code)
+	require.Equal(t, http.StatusOK, gotAmazon.Code)
+	assert.Contains(t, gotWalmart.Body.String(), "Milk", "the Amazon order does not replace the Walmart one")
+	assert.Contains(t, gotAmazon.Body.String(), "Cable")
+}
+
+func TestRetailerRoutes_RejectOrdersFromAnotherRetailer(t *testing.T) {
+	SetOrderStore(store.NewMemory())
+	defer SetOrderStore(nil)
+	router := newRetailerRouter()
+
+	t.Run("single", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/api/target/orders", "",
+			[]byte(`{"retailer":"amazon","orderNumber":"1","orderDate":"2024-01-05"}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "order is from Amazon, not Target")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/api/costco/orders/batch", "",
+			[]byte(`{"orders":[{"orderNumber":"1","orderDate":"2024-01-05"},{"retailer":"walmart","orderNumber":"2","orderDate":"2024-01-05"}]}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid order 2: order is from Walmart, not Costco")
+	})
+
+	t.Run("matching retailer", func(t *testing.T) {
+		w := doKeyRequest(router, "POST", "/api/target/orders", "",
+			[]byte(`{"retailer":"target","orderNumber":"3","orderDate":"2024-01-05"}`))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
+
+func TestRetailerRoutes_UnknownRoutes(t *testing.T) {
+	router := newRetailerRouter()
+
+	t.Run("unknown retailer", func(t *testing.T) {
+		w := doKeyRequest(router, "GET", "/api/kroger/orders", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `Unknown retailer \"kroger\"`)
+	})
+
+	t.Run("static route", func(t *testing.T) {
+		w := doKeyRequest(router, "GET", "/api/webhooks", "", nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("no CSV importer", func(t *testing.T) {
+		w := doUploadRequest(router, "/api/costco/orders/import", "file", []byte("Order Number,Order Date\n1,2024-01-01\n"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "CSV import is not available for Costco")
+	})
+}
+
+func TestRetailerFromContext_DefaultsToWalmart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(nil)
+
+	assert.Equal(t, models.RetailerWalmart, RetailerFromContext(c))
+}
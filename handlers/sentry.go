@@ -0,0 +1,11 @@
+package handlers
+
+// sentryOrderMessages sends an info-level Sentry message for every successfully
+// processed order. It is off by default: at any real volume these messages crowd
+// out errors and spend the Sentry quota.
+var sentryOrderMessages bool
+
+// SetSentryOrderMessages enables or disables per-order success messages in Sentry.
+func SetSentryOrderMessages(enabled bool) {
+	sentryOrderMessages = enabled
+}
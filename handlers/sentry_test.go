@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport captures events instead of sending them to Sentry.
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Flush(time.Duration) bool              { return true }
+func (t *recordingTransport) FlushWithContext(context.Context) bool { return true }
+func (t *recordingTransport) Configure(sentry.ClientOptions)        {}
+func (t *recordingTransport) Close()                                {}
+func (t *recordingTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *recordingTransport) messages() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var messages []string
+	for _, e := range t.events {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+func newSentryRouter(t *testing.T) (*gin.Engine, *recordingTransport) {
+	t.Helper()
+	transport := &recordingTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		sentrygin.SetHubOnContext(c, sentry.NewHub(client, sentry.NewScope()))
+	})
+	router.POST("/api/walmart/orders", ReceiveOrders)
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+	return router, transport
+}
+
+func postJSON(router *gin.Engine, path, body string) int {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSentryOrderMessages_OffByDefault(t *testing.T) {
+	router, transport := newSentryRouter(t)
+
+	assert.Equal(t, http.StatusOK, postJSON(router, "/api/walmart/orders", `{"orderNumber":"s-1","orderDate":"2024-01-15"}`))
+	assert.Equal(t, http.StatusOK, postJSON(router, "/api/walmart/orders/batch", `{"orders":[{"orderNumber":"s-2","orderDate":"2024-01-15"}]}`))
+
+	assert.Empty(t, transport.messages())
+}
+
+func TestSentryOrderMessages_Enabled(t *testing.T) {
+	SetSentryOrderMessages(true)
+	defer SetSentryOrderMessages(false)
+	router, transport := newSentryRouter(t)
+
+	assert.Equal(t, http.StatusOK, postJSON(router, "/api/walmart/orders", `{"orderNumber":"s-3","orderDate":"2024-01-15"}`))
+
+	assert.Equal(t, []string{"Order received successfully"}, transport.messages())
+}
+
+func TestInvalidOrderJSON_DoesNotSendBody(t *testing.T) {
+	router, transport := newSentryRouter(t)
+
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/api/walmart/orders", `{"orderNumber":`))
+
+	require.Len(t, transport.events, 1)
+	assert.NotContains(t, transport.events[0].Contexts["order"], "body")
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/models"
+
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+)
+
+// NDJSONContentType is the media type for newline-delimited JSON.
+const NDJSONContentType = "application/x-ndjson"
+
+// maxNDJSONLineBytes bounds the size of a single order line in a streaming import.
+const maxNDJSONLineBytes = 1 << 20
+
+// StreamOrders imports orders from a newline-delimited JSON body, one models.Order per
+// line. Each line is processed as it is read, so memory use does not grow with the
+// size of the upload, and a BatchOrderResult is streamed back for every line.
+func StreamOrders(c *gin.Context) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != NDJSONContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Content-Type must be %s", NDJSONContentType),
+		})
+		return
+	}
+
+	// Get Sentry hub from context if available
+	hub := sentrygin.GetHubFromContext(c)
+	ctx := c.Request.Context()
+	tenantID := TenantIDFromContext(c)
+	retailer := RetailerFromContext(c)
+	logger := logging.FromContext(ctx)
+
+	c.Header("Content-Type", NDJSONContentType)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	write := func(result models.BatchOrderResult) bool {
+		if err := encoder.Encode(result); err != nil {
+			logger.Warn("Streaming import aborted, client write failed", "error", err)
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineBytes)
+
+	line := 0
+	processedCount := 0
+	failedCount := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			logger.Warn("Streaming import cancelled", "line", line, "error", ctx.Err())
+			return
+		}
+
+		var result models.BatchOrderResult
+		var order models.Order
+		if err := json.Unmarshal([]byte(raw), &order); err != nil {
+			appMetrics.OrderFailed(string(retailer))
+			result = models.BatchOrderResult{
+				Success: false,
+				Error:   fmt.Sprintf("invalid JSON: %v", err),
+			}
+		} else if err := assignRetailer(&order, retailer); err != nil {
+			appMetrics.OrderFailed(orderSource(&order))
+			result = models.BatchOrderResult{
+				OrderNumber: order.OrderNumber,
+				Success:     false,
+				Error:       err.Error(),
+			}
+		} else {
+			result = processBatchOrder(ctx, hub, tenantID, order)
+		}
+		result.Line = line
+
+		if result.Success {
+			processedCount++
+		} else {
+			failedCount++
+		}
+
+		if !write(result) {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		failedCount++
+		write(models.BatchOrderResult{
+			Line:    line + 1,
+			Success: false,
+			Error:   fmt.Sprintf("failed to read line: %v", err),
+		})
+	}
+
+	logger.Info("Streaming import processed",
+		"successful", processedCount, "failed", failedCount, "lines", line)
+}
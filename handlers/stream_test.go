@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeNDJSONResults(t *testing.T, body string) []models.BatchOrderResult {
+	t.Helper()
+	var results []models.BatchOrderResult
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var result models.BatchOrderResult
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestStreamOrders_Success(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/stream", StreamOrders)
+
+	body := strings.Join([]string{
+		`{"orderNumber":"S-1","orderDate":"2024-01-15","orderTotal":10.5}`,
+		``,
+		`{"orderNumber":"S-2","orderDate":"2024-01-16","items":[{"name":"Milk","price":3.99,"quantity":1}]}`,
+		`{"orderNumber":"S-3"}`,
+		`{not json}`,
+	}, "\n")
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	results := decodeNDJSONResults(t, w.Body.String())
+	require.Len(t, results, 4)
+
+	assert.Equal(t, 1, results[0].Line)
+	assert.Equal(t, "S-1", results[0].OrderNumber)
+	assert.True(t, results[0].Success)
+	assert.NotEmpty(t, results[0].ProcessingID)
+
+	assert.Equal(t, 3, results[1].Line)
+	assert.True(t, results[1].Success)
+
+	assert.Equal(t, 4, results[2].Line)
+	assert.False(t, results[2].Success)
+	assert.Contains(t, results[2].Error, "missing order date")
+
+	assert.Equal(t, 5, results[3].Line)
+	assert.False(t, results[3].Success)
+	assert.Contains(t, results[3].Error, "invalid JSON")
+}
+
+func TestStreamOrders_WrongContentType(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/stream", StreamOrders)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/stream",
+		strings.NewReader(`{"orderNumber":"S-1","orderDate":"2024-01-15"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestStreamOrders_LineTooLong(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/walmart/orders/stream", StreamOrders)
+
+	body := `{"orderNumber":"S-1","orderDate":"2024-01-15"}` + "\n" +
+		`{"orderNumber":"` + strings.Repeat("x", maxNDJSONLineBytes) + `"}`
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	results := decodeNDJSONResults(t, w.Body.String())
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, 2, results[1].Line)
+	assert.Contains(t, results[1].Error, "failed to read line")
+}
+
+func TestDeadlines_RouteExtendsServerDefault(t *testing.T) {
+	// Arrange: every request gets a short write deadline, which the stream route lifts
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Deadlines(time.Second, 50*time.Millisecond))
+	slow := func(c *gin.Context) {
+		time.Sleep(150 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	}
+	router.GET("/short", slow)
+	router.GET("/stream", Deadlines(0, 0), slow)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Act
+	_, shortErr := http.Get(server.URL + "/short")
+	resp, err := http.Get(server.URL + "/stream")
+
+	// Assert
+	assert.Error(t, shortErr)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "done", string(body))
+}
@@ -20,12 +20,34 @@ type SyncTracker struct {
 	todayDate            string
 }
 
+// syncTracker tracks the default tenant. Other tenants get their own tracker on
+// first use.
 var syncTracker = &SyncTracker{
 	PendingErrors: []string{},
 }
 
-// GetSyncStatus returns the current sync status
+var (
+	trackersMu sync.Mutex
+	trackers   = map[string]*SyncTracker{models.DefaultTenantID: syncTracker}
+)
+
+// trackerFor returns the sync tracker for a tenant.
+func trackerFor(tenantID string) *SyncTracker {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+
+	tracker, ok := trackers[tenantID]
+	if !ok {
+		tracker = &SyncTracker{PendingErrors: []string{}}
+		trackers[tenantID] = tracker
+	}
+	return tracker
+}
+
+// GetSyncStatus returns the current sync status of the authenticated tenant
 func GetSyncStatus(c *gin.Context) {
+	syncTracker := trackerFor(TenantIDFromContext(c))
+
 	syncTracker.mu.RLock()
 	defer syncTracker.mu.RUnlock()
 
@@ -56,8 +78,10 @@ func GetSyncStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// updateSyncTracker updates the sync tracker with a processed order
-func updateSyncTracker(_ *models.Order) {
+// updateSyncTracker updates a tenant's sync tracker with a processed order
+func updateSyncTracker(tenantID string, _ *models.Order) {
+	syncTracker := trackerFor(tenantID)
+
 	syncTracker.mu.Lock()
 	defer syncTracker.mu.Unlock()
 
@@ -75,5 +99,3 @@ func updateSyncTracker(_ *models.Order) {
 
 	syncTracker.OrdersProcessedTotal++
 }
-
-
@@ -85,7 +85,7 @@ func TestGetSyncStatus_MissingAuth(t *testing.T) {
 	// Test sync status endpoint with missing auth
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware())
+	router.Use(AuthMiddleware(newTestKeyring()))
 	router.GET("/api/walmart/sync-status", GetSyncStatus)
 
 	// Act
@@ -204,7 +204,7 @@ func TestUpdateSyncTracker(t *testing.T) {
 		OrderTotal:  &orderTotal,
 	}
 
-	updateSyncTracker(&order)
+	updateSyncTracker(models.DefaultTenantID, &order)
 
 	syncTracker.mu.RLock()
 	defer syncTracker.mu.RUnlock()
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantIDPattern restricts tenant IDs to short lowercase slugs.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// CreateTenantRequest is the body accepted by CreateTenant.
+type CreateTenantRequest struct {
+	ID   string `json:"id" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateTenantResponse returns a new tenant and its first admin key. The
+// plaintext key is only shown once.
+type CreateTenantResponse struct {
+	Tenant *models.Tenant `json:"tenant"`
+	Key    APIKeyResponse `json:"adminKey"`
+}
+
+// ListTenants returns every tenant on the server.
+func ListTenants(tenants store.TenantStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := tenants.ListTenants(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to list tenants",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"tenants": list,
+		})
+	}
+}
+
+// CreateTenant creates a tenant and issues an admin key for it, which the
+// tenant uses to create its own ingest and read keys.
+func CreateTenant(tenants store.TenantStore, keyring *auth.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateTenantRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+			})
+			return
+		}
+		if !tenantIDPattern.MatchString(req.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid tenant id: use lowercase letters, digits, and dashes",
+			})
+			return
+		}
+
+		tenant := &models.Tenant{ID: req.ID, Name: req.Name}
+		if err := tenants.CreateTenant(c.Request.Context(), tenant); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrExists) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Failed to create tenant: %v", err),
+			})
+			return
+		}
+
+		plaintext, key, err := keyring.Create(tenant.ID, "admin", []auth.Scope{auth.ScopeAdmin}, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Tenant created but admin key could not be issued: %v", err),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, CreateTenantResponse{
+			Tenant: tenant,
+			Key:    APIKeyResponse{Key: plaintext, APIKey: key},
+		})
+	}
+}
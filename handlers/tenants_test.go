@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantRouter(keyring *auth.Keyring, tenants store.TenantStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api", AuthMiddleware(keyring))
+	api.POST("/walmart/orders", RequireScope(auth.ScopeIngest), ReceiveOrders)
+	api.GET("/walmart/orders", RequireScope(auth.ScopeRead), ListOrders)
+	api.GET("/walmart/orders/:orderNumber", RequireScope(auth.ScopeRead), GetOrder)
+	api.GET("/keys", RequireScope(auth.ScopeAdmin), ListAPIKeys(keyring))
+	api.POST("/keys", RequireScope(auth.ScopeAdmin), CreateAPIKey(keyring))
+	api.GET("/tenants", RequireScope(auth.ScopeTenants), ListTenants(tenants))
+	api.POST("/tenants", RequireScope(auth.ScopeTenants), CreateTenant(tenants, keyring))
+	return router
+}
+
+func TestTenants_IsolateOrdersAndKeys(t *testing.T) {
+	// Arrange
+	SetOrderStore(store.NewMemory())
+	defer SetOrderStore(nil)
+	keyring := newTestKeyring()
+	tenants := store.NewMemory()
+	router := newTenantRouter(keyring, tenants)
+
+	w := doKeyRequest(router, "POST", "/api/tenants", "test-secret", []byte(`{"id":"smiths","name":"The Smiths"}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created CreateTenantResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "smiths", created.Tenant.ID)
+	assert.Equal(t, "smiths", created.Key.APIKey.TenantID)
+	adminKey := created.Key.Key
+
+	// Act - each tenant submits an order
+	w = doKeyRequest(router, "POST", "/api/walmart/orders", adminKey, []byte(`{"orderNumber":"smith-1","orderDate":"2024-01-15"}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doKeyRequest(router, "POST", "/api/walmart/orders", "test-secret", []byte(`{"orderNumber":"default-1","orderDate":"2024-01-16"}`))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Assert - each tenant only sees its own orders
+	w = doKeyRequest(router, "GET", "/api/walmart/orders", adminKey, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "smith-1")
+	assert.NotContains(t, w.Body.String(), "default-1")
+
+	w = doKeyRequest(router, "GET", "/api/walmart/orders/default-1", adminKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doKeyRequest(router, "GET", "/api/walmart/orders/default-1", "test-secret", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Keys created by the tenant belong to the tenant
+	w = doKeyRequest(router, "POST", "/api/keys", adminKey, []byte(`{"name":"firefox","scopes":["ingest"]}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = doKeyRequest(router, "GET", "/api/keys", "test-secret", nil)
+	assert.NotContains(t, w.Body.String(), "firefox")
+
+	// A tenant admin cannot manage tenants or grant that scope
+	w = doKeyRequest(router, "GET", "/api/tenants", adminKey, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = doKeyRequest(router, "POST", "/api/keys", adminKey, []byte(`{"name":"escalate","scopes":["tenants"]}`))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCreateTenant_Validation(t *testing.T) {
+	keyring := newTestKeyring()
+	tenants := store.NewMemory()
+	require.NoError(t, tenants.CreateTenant(context.Background(), &models.Tenant{ID: models.DefaultTenantID, Name: "Default"}))
+	router := newTenantRouter(keyring, tenants)
+
+	w := doKeyRequest(router, "POST", "/api/tenants", "test-secret", []byte(`{"id":"Not A Slug","name":"x"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doKeyRequest(router, "POST", "/api/tenants", "test-secret", []byte(`{"id":"default","name":"x"}`))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doKeyRequest(router, "GET", "/api/tenants", "test-secret", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"default"`)
+}
+
+func TestListOrders_InvalidQuery(t *testing.T) {
+	router := newTenantRouter(newTestKeyring(), store.NewMemory())
+
+	w := doKeyRequest(router, "GET", "/api/walmart/orders?from=yesterday", "test-secret", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doKeyRequest(router, "GET", "/api/walmart/orders?limit=0", "test-secret", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
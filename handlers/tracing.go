@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/tracing"
+
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LinkTrace tags the request's Sentry scope and logger with the trace and span IDs
+// of the current request span. It must run after the tracing and Sentry middleware.
+func LinkTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sc := trace.SpanContextFromContext(ctx)
+		if sc.IsValid() {
+			if hub := sentrygin.GetHubFromContext(c); hub != nil {
+				tracing.LinkSentry(ctx, hub.Scope())
+			}
+			logger := logging.FromContext(ctx).With("trace_id", sc.TraceID().String())
+			c.Request = c.Request.WithContext(logging.WithLogger(ctx, logger))
+		}
+
+		c.Next()
+	}
+}
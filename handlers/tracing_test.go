@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLinkTrace_SpansAndLogs(t *testing.T) {
+	// Arrange
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+	previousLogger := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previousLogger)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware(tracing.ServiceName), LinkTrace())
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/batch",
+		bytes.NewBufferString(`{"orders":[{"orderNumber":"trace-1","orderDate":"2024-01-15"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert - the order span is a child of the request span
+	require.Equal(t, http.StatusOK, w.Code)
+	spans := recorder.Ended()
+	names := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		names[s.Name()] = s
+	}
+	require.Contains(t, names, "order.process")
+	require.Contains(t, names, "/api/walmart/orders/batch")
+	request := names["/api/walmart/orders/batch"]
+	assert.Equal(t, request.SpanContext().SpanID(), names["order.process"].Parent().SpanID())
+
+	// Log lines carry the trace ID
+	line := strings.SplitN(strings.TrimSpace(buf.String()), "\n", 2)[0]
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &entry))
+	assert.Equal(t, request.SpanContext().TraceID().String(), entry["trace_id"])
+}
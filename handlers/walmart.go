@@ -2,58 +2,43 @@ package handlers
 
 import (
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"time"
 
+	"monarchmoney-sync-backend/logging"
 	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/webhooks"
 
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates requests using the X-Extension-Key header.
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		extensionKey := c.GetHeader("X-Extension-Key")
-		expectedKey := os.Getenv("EXTENSION_SECRET_KEY")
-
-		// For testing, allow "test-secret" when env var is not set
-		if expectedKey == "" {
-			expectedKey = "test-secret"
-		}
-
-		if extensionKey == "" || extensionKey != expectedKey {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"status":  "error",
-				"message": "Unauthorized: Missing or invalid extension key",
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// ReceiveOrders handles incoming Walmart order data from the Chrome extension.
+// ReceiveOrders handles incoming order data from the Chrome extension for the
+// route's retailer.
 func ReceiveOrders(c *gin.Context) {
 	// Get Sentry hub from context if available
 	hub := sentrygin.GetHubFromContext(c)
+	tenantID := TenantIDFromContext(c)
 
 	var order models.Order
 
 	// Parse JSON request body
 	if err := c.ShouldBindJSON(&order); err != nil {
+		if isBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"status":  "error",
+				"message": "Request body too large",
+			})
+			return
+		}
+
 		// Capture validation errors to Sentry
 		if hub != nil {
 			hub.WithScope(func(scope *sentry.Scope) {
 				scope.SetLevel(sentry.LevelWarning)
 				scope.SetContext("order", map[string]interface{}{
 					"error": err.Error(),
-					"body":  c.Request.Body,
 				})
 				hub.CaptureMessage("Invalid order JSON received")
 			})
@@ -65,11 +50,21 @@ func ReceiveOrders(c *gin.Context) {
 		})
 		return
 	}
+	if err := assignRetailer(&order, RetailerFromContext(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid order: %v", err),
+		})
+		return
+	}
+	appMetrics.OrderReceived(orderSource(&order))
 
 	// Validate items if present
 	if order.Items != nil {
 		for _, item := range order.Items {
 			if item.Price < 0 {
+				appMetrics.OrderFailed(orderSource(&order))
+				publishOrderEvent(tenantID, webhooks.EventOrderFailed, &order, "", "invalid item price")
 				c.JSON(http.StatusBadRequest, gin.H{
 					"status":  "error",
 					"message": "Invalid item price: must be non-negative",
@@ -77,6 +72,8 @@ func ReceiveOrders(c *gin.Context) {
 				return
 			}
 			if item.Quantity <= 0 {
+				appMetrics.OrderFailed(orderSource(&order))
+				publishOrderEvent(tenantID, webhooks.EventOrderFailed, &order, "", "invalid item quantity")
 				c.JSON(http.StatusBadRequest, gin.H{
 					"status":  "error",
 					"message": "Invalid item quantity: must be positive",
@@ -86,6 +83,16 @@ func ReceiveOrders(c *gin.Context) {
 		}
 	}
 
+	if err := validateShipments(order); err != nil {
+		appMetrics.OrderFailed(orderSource(&order))
+		publishOrderEvent(tenantID, webhooks.EventOrderFailed, &order, "", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid shipments: %v", err),
+		})
+		return
+	}
+
 	// Generate processing ID
 	processingID := fmt.Sprintf("proc_%s_%d", order.OrderNumber, time.Now().Unix())
 
@@ -96,24 +103,11 @@ func ReceiveOrders(c *gin.Context) {
 	}
 
 	// Log the received order with additional fields
-	logMsg := fmt.Sprintf("Received Walmart order: %s", order.OrderNumber)
-	if order.OrderTotal != nil {
-		logMsg += fmt.Sprintf(", Total: $%.2f", *order.OrderTotal)
-	}
-	logMsg += fmt.Sprintf(", Items: %d", itemCount)
-	if order.Tax != nil {
-		logMsg += fmt.Sprintf(", Tax: $%.2f", *order.Tax)
-	}
-	if order.DeliveryCharges != nil {
-		logMsg += fmt.Sprintf(", Delivery: $%.2f", *order.DeliveryCharges)
-	}
-	if order.Tip != nil {
-		logMsg += fmt.Sprintf(", Tip: $%.2f", *order.Tip)
-	}
-	log.Println(logMsg)
+	logger := logging.FromContext(c.Request.Context())
+	logger.Info("Received "+RetailerFromContext(c).Name()+" order", orderLogAttrs(&order)...)
 
 	// Track successful order in Sentry
-	if hub != nil {
+	if hub != nil && sentryOrderMessages {
 		hub.WithScope(func(scope *sentry.Scope) {
 			scope.SetLevel(sentry.LevelInfo)
 			contextData := map[string]interface{}{
@@ -134,13 +128,32 @@ func ReceiveOrders(c *gin.Context) {
 				contextData["tip"] = *order.Tip
 			}
 			scope.SetContext("order", contextData)
-			scope.SetTag("order.source", "walmart")
+			scope.SetTag("order.source", orderSource(&order))
 			hub.CaptureMessage("Order received successfully")
 		})
 	}
 
-	// Update sync tracker
-	updateSyncTracker(&order)
+	// Store the order for the tenant
+	created, err := saveOrder(c.Request.Context(), tenantID, &order, processingID)
+	if err != nil {
+		logger.Error("Failed to store order", "order_number", order.OrderNumber, "error", err)
+		appMetrics.OrderFailed(orderSource(&order))
+		if hub != nil {
+			hub.CaptureException(err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to store order",
+		})
+		return
+	}
+
+	// An order the extension sent before is only replaced
+	if created {
+		updateSyncTracker(tenantID, &order)
+		publishOrderEvent(tenantID, webhooks.EventOrderReceived, &order, processingID, "")
+		appMetrics.OrderProcessed(orderSource(&order))
+	}
 
 	// TODO: Process order with Monarch Money SDK
 	// For now, just acknowledge receipt
@@ -157,3 +170,26 @@ func ReceiveOrders(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// orderLogAttrs returns the structured log fields describing an order.
+func orderLogAttrs(order *models.Order) []any {
+	itemCount := 0
+	if order.Items != nil {
+		itemCount = len(order.Items)
+	}
+
+	attrs := []any{"retailer", orderSource(order), "order_number", order.OrderNumber, "items", itemCount}
+	if order.OrderTotal != nil {
+		attrs = append(attrs, "total", *order.OrderTotal)
+	}
+	if order.Tax != nil {
+		attrs = append(attrs, "tax", *order.Tax)
+	}
+	if order.DeliveryCharges != nil {
+		attrs = append(attrs, "delivery", *order.DeliveryCharges)
+	}
+	if order.Tip != nil {
+		attrs = append(attrs, "tip", *order.Tip)
+	}
+	return attrs
+}
@@ -86,7 +86,7 @@ func TestReceiveOrders_MissingAuth(t *testing.T) {
 	// Arrange
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware())
+	router.Use(AuthMiddleware(newTestKeyring()))
 	router.POST("/api/walmart/orders", ReceiveOrders)
 
 	orderTotal := 150.00
@@ -351,7 +351,7 @@ func TestAuthMiddleware_EmptyKey(t *testing.T) {
 	// Test auth middleware with empty key
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware())
+	router.Use(AuthMiddleware(newTestKeyring()))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
@@ -375,7 +375,7 @@ func TestAuthMiddleware_WrongKey(t *testing.T) {
 	// Test auth middleware with wrong key
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(AuthMiddleware())
+	router.Use(AuthMiddleware(newTestKeyring()))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventPublisher receives order processing events. It defaults to a no-op so
+// handlers work without webhooks configured.
+var eventPublisher webhooks.Publisher = webhooks.NopPublisher{}
+
+// SetEventPublisher sets the publisher that order handlers emit events to.
+// Passing nil disables event publishing.
+func SetEventPublisher(p webhooks.Publisher) {
+	if p == nil {
+		p = webhooks.NopPublisher{}
+	}
+	eventPublisher = p
+}
+
+// publishOrderEvent emits an order.* event for the given order to a tenant's subscribers.
+func publishOrderEvent(tenantID string, eventType webhooks.EventType, order *models.Order, processingID, errMsg string) {
+	itemCount := 0
+	if order.Items != nil {
+		itemCount = len(order.Items)
+	}
+
+	eventPublisher.Publish(tenantID, eventType, webhooks.OrderEventData{
+		OrderNumber:  order.OrderNumber,
+		OrderDate:    order.OrderDate,
+		ProcessingID: processingID,
+		ItemCount:    itemCount,
+		TotalAmount:  order.OrderTotal,
+		Error:        errMsg,
+	})
+}
+
+// publishRefundEvent emits an event about one of an order's refunds to the
+// tenant's webhook subscribers. ItemCount is the number of items returned.
+func publishRefundEvent(tenantID string, eventType webhooks.EventType, refund *models.Refund, errMsg string) {
+	eventPublisher.Publish(tenantID, eventType, webhooks.OrderEventData{
+		OrderNumber:   refund.OrderNumber,
+		ItemCount:     len(refund.Items),
+		Error:         errMsg,
+		RefundID:      refund.RefundID,
+		TransactionID: refund.TransactionID,
+	})
+}
+
+// CreateWebhookRequest is the body accepted by CreateWebhook.
+type CreateWebhookRequest struct {
+	URL    string               `json:"url" binding:"required"`
+	Secret string               `json:"secret" binding:"required"`
+	Events []webhooks.EventType `json:"events,omitempty"`
+}
+
+// ListWebhooks returns the webhook subscriptions of the caller's tenant.
+func ListWebhooks(d *webhooks.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"subscriptions": d.Subscriptions(TenantIDFromContext(c)),
+		})
+	}
+}
+
+// CreateWebhook registers a new webhook subscription.
+func CreateWebhook(d *webhooks.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid JSON or validation error: %v", err),
+			})
+			return
+		}
+
+		sub, err := d.Subscribe(TenantIDFromContext(c), req.URL, req.Secret, req.Events)
+		var invalid *webhooks.ValidationError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to save webhook subscription", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to save webhook subscription",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, sub)
+	}
+}
+
+// DeleteWebhook removes a webhook subscription.
+func DeleteWebhook(d *webhooks.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !d.Unsubscribe(TenantIDFromContext(c), c.Param("id")) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "Webhook subscription not found",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveries returns the most recent webhook delivery attempts for the caller's tenant.
+func ListWebhookDeliveries(d *webhooks.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": d.Deliveries(TenantIDFromContext(c)),
+		})
+	}
+}
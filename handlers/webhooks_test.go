@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher captures published events for assertions.
+type recordingPublisher struct {
+	mu      sync.Mutex
+	tenants []string
+	events  []webhooks.EventType
+	data    []webhooks.OrderEventData
+}
+
+func (p *recordingPublisher) Publish(tenantID string, eventType webhooks.EventType, data interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tenants = append(p.tenants, tenantID)
+	p.events = append(p.events, eventType)
+	if d, ok := data.(webhooks.OrderEventData); ok {
+		p.data = append(p.data, d)
+	}
+}
+
+func TestReceiveOrders_PublishesReceivedEvent(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	defer SetEventPublisher(nil)
+
+	router := gin.New()
+	router.POST("/api/walmart/orders", ReceiveOrders)
+
+	order := models.Order{
+		OrderNumber: "EVT-1",
+		OrderDate:   "2024-01-15",
+		Items:       []models.OrderItem{{Name: "Milk", Price: 3.99, Quantity: 1}},
+	}
+	jsonData, _ := json.Marshal(order)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, webhooks.EventOrderReceived, publisher.events[0])
+	assert.Equal(t, "EVT-1", publisher.data[0].OrderNumber)
+	assert.Equal(t, 1, publisher.data[0].ItemCount)
+	assert.NotEmpty(t, publisher.data[0].ProcessingID)
+}
+
+func TestReceiveBatchOrders_PublishesFailedEvent(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	publisher := &recordingPublisher{}
+	SetEventPublisher(publisher)
+	defer SetEventPublisher(nil)
+
+	router := gin.New()
+	router.POST("/api/walmart/orders/batch", ReceiveBatchOrders)
+
+	batch := models.BatchOrdersRequest{
+		Orders: []models.Order{
+			{OrderNumber: "EVT-OK", OrderDate: "2024-01-15"},
+			{OrderNumber: "EVT-BAD", OrderDate: "2024-01-15", Items: []models.OrderItem{{Name: "X", Price: -1, Quantity: 1}}},
+		},
+	}
+	jsonData, _ := json.Marshal(batch)
+
+	// Act
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/walmart/orders/batch", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []webhooks.EventType{webhooks.EventOrderReceived, webhooks.EventOrderFailed}, publisher.events)
+}
+
+func TestWebhookSubscriptionEndpoints(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	dispatcher := webhooks.NewDispatcher(webhooks.DefaultOptions())
+	defer dispatcher.Close()
+
+	router := gin.New()
+	router.GET("/api/webhooks", ListWebhooks(dispatcher))
+	router.POST("/api/webhooks", CreateWebhook(dispatcher))
+	router.DELETE("/api/webhooks/:id", DeleteWebhook(dispatcher))
+	router.GET("/api/webhooks/deliveries", ListWebhookDeliveries(dispatcher))
+
+	// Act - create
+	body := []byte(`{"url":"https://hooks.example.com/hook","secret":"s3cret","events":["order.needs_review"]}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert - created without leaking the secret
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	var sub webhooks.Subscription
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sub))
+	assert.NotEmpty(t, sub.ID)
+
+	// Act - list
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/webhooks", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), sub.ID)
+
+	// Act - deliveries
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/webhooks/deliveries", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Act - delete twice
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/webhooks/"+sub.ID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/webhooks/"+sub.ID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateWebhook_InvalidEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dispatcher := webhooks.NewDispatcher(webhooks.DefaultOptions())
+	defer dispatcher.Close()
+
+	router := gin.New()
+	router.POST("/api/webhooks", CreateWebhook(dispatcher))
+
+	body := []byte(`{"url":"https://hooks.example.com/hook","secret":"s3cret","events":["order.bogus"]}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown event type")
+}
+
+// failingSubscriptionStore fails to save subscriptions, like a vault that cannot be written.
+type failingSubscriptionStore struct{}
+
+func (failingSubscriptionStore) LoadSubscriptions() ([]webhooks.Subscription, error) { return nil, nil }
+
+func (failingSubscriptionStore) SaveSubscription(*webhooks.Subscription) error {
+	return errors.New("vault is read-only")
+}
+
+func (failingSubscriptionStore) DeleteSubscription(*webhooks.Subscription) error { return nil }
+
+func TestCreateWebhook_StoreFailure(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	dispatcher := webhooks.NewDispatcher(webhooks.DefaultOptions())
+	defer dispatcher.Close()
+	require.NoError(t, dispatcher.UseStore(failingSubscriptionStore{}))
+
+	router := gin.New()
+	router.POST("/api/webhooks", CreateWebhook(dispatcher))
+
+	// Act
+	body := []byte(`{"url":"https://hooks.example.com/hook","secret":"s3cret"}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "vault")
+}
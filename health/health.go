@@ -0,0 +1,182 @@
+// Package health runs readiness checks against the components the server depends
+// on and reports per-component status and latency.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Component and overall statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// ErrNotConfigured reports that a component is not set up. It is shown as skipped
+// and does not make the server unready.
+var ErrNotConfigured = errors.New("not configured")
+
+// Checker checks a single component. It should respect ctx cancellation.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to a Checker.
+type CheckFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ComponentStatus is the result of checking one component.
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the result of checking every component.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Ready reports whether every component passed or was skipped.
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+// Checks is a set of named component checks run concurrently with a timeout each.
+type Checks struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Checker
+}
+
+// NewChecks creates an empty set of checks. Each check is cancelled after timeout.
+func NewChecks(timeout time.Duration) *Checks {
+	return &Checks{timeout: timeout, checks: make(map[string]Checker)}
+}
+
+// Add registers a check under name, replacing any existing check with that name.
+func (c *Checks) Add(name string, checker Checker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = checker
+}
+
+// Names returns the registered check names in sorted order.
+func (c *Checks) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run checks every component concurrently and reports the results.
+func (c *Checks) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]Checker, len(c.checks))
+	for name, checker := range c.checks {
+		checks[name] = checker
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusReady, Components: make(map[string]ComponentStatus, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, checker := range checks {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			status := c.runOne(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = status
+			if status.Status == StatusFailed {
+				report.Status = StatusNotReady
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checks) runOne(ctx context.Context, checker Checker) ComponentStatus {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// A check that ignores its context must not hold up the report
+		err = ctx.Err()
+	}
+
+	status := ComponentStatus{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		status.Status = StatusSkipped
+	case err != nil:
+		status.Status = StatusFailed
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Cached wraps checker so its result is reused for ttl. Use it for checks that
+// call external services, which should not be hit on every probe.
+func Cached(checker Checker, ttl time.Duration) Checker {
+	return &cachedChecker{next: checker, ttl: ttl, now: time.Now}
+}
+
+type cachedChecker struct {
+	next Checker
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	err     error
+	checked time.Time
+}
+
+func (c *cachedChecker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && c.now().Sub(c.checked) < c.ttl {
+		return c.err
+	}
+	c.err = c.next.Check(ctx)
+	c.checked = c.now()
+	return c.err
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecks_Run(t *testing.T) {
+	// Arrange
+	checks := NewChecks(time.Second)
+	checks.Add("database", CheckFunc(func(context.Context) error { return nil }))
+	checks.Add("llm", CheckFunc(func(context.Context) error { return ErrNotConfigured }))
+
+	// Act
+	report := checks.Run(context.Background())
+
+	// Assert
+	assert.True(t, report.Ready())
+	assert.Equal(t, StatusReady, report.Status)
+	assert.Equal(t, StatusOK, report.Components["database"].Status)
+	assert.Equal(t, StatusSkipped, report.Components["llm"].Status)
+	assert.Empty(t, report.Components["llm"].Error)
+	assert.Equal(t, []string{"database", "llm"}, checks.Names())
+}
+
+func TestChecks_Run_Failure(t *testing.T) {
+	// Arrange
+	checks := NewChecks(time.Second)
+	checks.Add("database", CheckFunc(func(context.Context) error { return nil }))
+	checks.Add("monarch", CheckFunc(func(context.Context) error { return errors.New("token rejected") }))
+
+	// Act
+	report := checks.Run(context.Background())
+
+	// Assert
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, StatusOK, report.Components["database"].Status)
+	assert.Equal(t, StatusFailed, report.Components["monarch"].Status)
+	assert.Equal(t, "token rejected", report.Components["monarch"].Error)
+}
+
+func TestChecks_Run_Timeout(t *testing.T) {
+	// Arrange: one check ignores its context entirely
+	release := make(chan struct{})
+	defer close(release)
+	checks := NewChecks(20 * time.Millisecond)
+	checks.Add("stuck", CheckFunc(func(context.Context) error {
+		<-release
+		return nil
+	}))
+
+	// Act
+	start := time.Now()
+	report := checks.Run(context.Background())
+
+	// Assert
+	assert.Less(t, time.Since(start), time.Second)
+	require.Contains(t, report.Components, "stuck")
+	assert.Equal(t, StatusFailed, report.Components["stuck"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["stuck"].Error)
+	assert.GreaterOrEqual(t, report.Components["stuck"].LatencyMs, float64(20))
+}
+
+func TestCached(t *testing.T) {
+	// Arrange
+	calls := 0
+	checker := Cached(CheckFunc(func(context.Context) error {
+		calls++
+		return errors.New("down")
+	}), time.Minute).(*cachedChecker)
+	now := time.Now()
+	checker.now = func() time.Time { return now }
+
+	// Act & Assert: the second call within the TTL reuses the result
+	assert.EqualError(t, checker.Check(context.Background()), "down")
+	assert.EqualError(t, checker.Check(context.Background()), "down")
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(2 * time.Minute)
+	assert.EqualError(t, checker.Check(context.Background()), "down")
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Default endpoints probed by the provider and Monarch checks.
+const (
+	OpenAIBaseURL     = "https://api.openai.com/v1"
+	AnthropicBaseURL  = "https://api.anthropic.com/v1"
+	MonarchGraphQLURL = "https://api.monarchmoney.com/graphql"
+)
+
+// anthropicVersion is the API version header Anthropic requires on every request.
+const anthropicVersion = "2023-06-01"
+
+// Secret returns a credential for a check. It returns ErrNotConfigured when the
+// credential is not set, which marks the check as skipped.
+type Secret func(ctx context.Context) (string, error)
+
+// Ollama checks that an Ollama server is reachable by listing its local models.
+func Ollama(client *http.Client, endpoint string) Checker {
+	return CheckFunc(func(ctx context.Context) error {
+		if endpoint == "" {
+			return ErrNotConfigured
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(endpoint, "/")+"/api/tags", nil)
+		if err != nil {
+			return err
+		}
+		return expectOK(client, req)
+	})
+}
+
+// OpenAI checks that the OpenAI API is reachable and accepts the API key.
+func OpenAI(client *http.Client, baseURL string, apiKey Secret) Checker {
+	return CheckFunc(func(ctx context.Context) error {
+		key, err := apiKey(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/models", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		return expectOK(client, req)
+	})
+}
+
+// Anthropic checks that the Anthropic API is reachable and accepts the API key.
+func Anthropic(client *http.Client, baseURL string, apiKey Secret) Checker {
+	return CheckFunc(func(ctx context.Context) error {
+		key, err := apiKey(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/models", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-api-key", key)
+		req.Header.Set("anthropic-version", anthropicVersion)
+		return expectOK(client, req)
+	})
+}
+
+// MonarchToken checks that Monarch accepts the session token by asking the GraphQL
+// API who the token belongs to.
+func MonarchToken(client *http.Client, url string, token Secret) Checker {
+	return CheckFunc(func(ctx context.Context) error {
+		t, err := token(ctx)
+		if err != nil {
+			return err
+		}
+		body, _ := json.Marshal(map[string]string{"query": "query { me { id } }"})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Token "+t)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return errors.New("token rejected")
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+
+		var result struct {
+			Data struct {
+				Me *struct {
+					ID string `json:"id"`
+				} `json:"me"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("token rejected: %s", result.Errors[0].Message)
+		}
+		if result.Data.Me == nil {
+			return errors.New("token rejected")
+		}
+		return nil
+	})
+}
+
+// expectOK sends req and fails unless the response status is 2xx.
+func expectOK(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.New("API key rejected")
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
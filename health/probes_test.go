@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func staticSecret(value string) Secret {
+	return func(context.Context) (string, error) { return value, nil }
+}
+
+func TestOllama(t *testing.T) {
+	// Arrange
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/tags", r.URL.Path)
+		w.Write([]byte(`{"models":[]}`))
+	}))
+	defer srv.Close()
+
+	// Act & Assert
+	assert.NoError(t, Ollama(srv.Client(), srv.URL+"/").Check(context.Background()))
+	assert.ErrorIs(t, Ollama(srv.Client(), "").Check(context.Background()), ErrNotConfigured)
+}
+
+func TestOllama_Unreachable(t *testing.T) {
+	// Arrange
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	// Act
+	err := Ollama(http.DefaultClient, url).Check(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestOpenAI(t *testing.T) {
+	// Arrange
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	// Act & Assert
+	assert.NoError(t, OpenAI(srv.Client(), srv.URL+"/v1", staticSecret("sk-good")).Check(context.Background()))
+	assert.EqualError(t, OpenAI(srv.Client(), srv.URL+"/v1", staticSecret("sk-bad")).Check(context.Background()), "API key rejected")
+}
+
+func TestAnthropic(t *testing.T) {
+	// Arrange
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, anthropicVersion, r.Header.Get("anthropic-version"))
+		if r.Header.Get("x-api-key") != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	// Act & Assert
+	assert.NoError(t, Anthropic(srv.Client(), srv.URL+"/v1", staticSecret("good")).Check(context.Background()))
+	assert.Error(t, Anthropic(srv.Client(), srv.URL+"/v1", staticSecret("bad")).Check(context.Background()))
+}
+
+func TestMonarchToken(t *testing.T) {
+	// Arrange
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		switch r.Header.Get("Authorization") {
+		case "Token good":
+			w.Write([]byte(`{"data":{"me":{"id":"123"}}}`))
+		case "Token expired":
+			w.Write([]byte(`{"data":{"me":null},"errors":[{"message":"Authentication required"}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	notConfigured := func(context.Context) (string, error) { return "", ErrNotConfigured }
+
+	// Act & Assert
+	assert.NoError(t, MonarchToken(srv.Client(), srv.URL, staticSecret("good")).Check(context.Background()))
+	assert.EqualError(t, MonarchToken(srv.Client(), srv.URL, staticSecret("expired")).Check(context.Background()),
+		"token rejected: Authentication required")
+	assert.EqualError(t, MonarchToken(srv.Client(), srv.URL, staticSecret("bad")).Check(context.Background()), "token rejected")
+	assert.ErrorIs(t, MonarchToken(srv.Client(), srv.URL, notConfigured).Check(context.Background()), ErrNotConfigured)
+}
@@ -0,0 +1,291 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// Amazon "Request Your Data" order history columns, from
+// Retail.OrderHistory.1.csv. Headers are matched like Walmart's; other columns
+// are ignored.
+const (
+	amazonOrderID = iota
+	amazonOrderDate
+	amazonProductName
+	amazonQuantity
+	amazonUnitPrice
+	amazonUnitPriceTax
+	amazonShippingCharge
+	amazonTotalOwed
+	amazonOrderStatus
+	amazonShipDate
+	amazonTracking
+	amazonASIN
+	amazonWebsite
+	numAmazonColumns
+)
+
+var amazonColumns = [numAmazonColumns]string{
+	amazonOrderID:        "Order ID",
+	amazonOrderDate:      "Order Date",
+	amazonProductName:    "Product Name",
+	amazonQuantity:       "Quantity",
+	amazonUnitPrice:      "Unit Price",
+	amazonUnitPriceTax:   "Unit Price Tax",
+	amazonShippingCharge: "Shipping Charge",
+	amazonTotalOwed:      "Total Owed",
+	amazonOrderStatus:    "Order Status",
+	amazonShipDate:       "Ship Date",
+	amazonTracking:       "Carrier Name & Tracking Number",
+	amazonASIN:           "ASIN",
+	amazonWebsite:        "Website",
+}
+
+// amazonRequiredColumns must be present in every export.
+var amazonRequiredColumns = []int{
+	amazonOrderID, amazonOrderDate, amazonProductName, amazonQuantity, amazonUnitPrice, amazonTotalOwed,
+}
+
+// amazonMissing are the placeholders Amazon exports instead of empty cells.
+var amazonMissing = map[string]bool{
+	"not available":  true,
+	"not applicable": true,
+}
+
+// ReadAmazonCSV reads the Retail.OrderHistory CSV from an Amazon "Request Your
+// Data" export. Each row is one item of an order; rows are grouped into orders by
+// order ID, in the order each ID first appears. Amazon charges the card as each
+// shipment ships, so shipped items are grouped into shipments by ship date and
+// tracking number, and each shipment's total is what it charged: the sum of its
+// rows' Total Owed, which includes tax, shipping, and discounts. Cancelled items
+// were never charged and are skipped. Dates are exported in UTC and are kept as
+// UTC dates.
+func ReadAmazonCSV(r io.Reader) ([]models.Order, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("no orders found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols, err := mapAmazonColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []*amazonOrder
+	byID := make(map[string]*amazonOrder)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := amazonRow{record: record, cols: cols, line: line}
+		if row.blank() {
+			continue
+		}
+
+		id := row.get(amazonOrderID)
+		if id == "" {
+			return nil, fmt.Errorf("line %d: missing order ID", line)
+		}
+		order, ok := byID[id]
+		if !ok {
+			order = &amazonOrder{
+				Order:     models.Order{Retailer: models.RetailerAmazon, OrderNumber: id},
+				shipments: make(map[string]int),
+			}
+			byID[id] = order
+			orders = append(orders, order)
+		}
+		if err := order.apply(row); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []models.Order
+	for _, o := range orders {
+		// Orders whose items were all cancelled were never charged
+		if len(o.Items) > 0 {
+			result = append(result, o.Order)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no orders found")
+	}
+	return result, nil
+}
+
+// mapAmazonColumns finds each known column in the header row.
+func mapAmazonColumns(header []string) ([numAmazonColumns]int, error) {
+	var cols [numAmazonColumns]int
+	for i := range cols {
+		cols[i] = -1
+	}
+	for i, name := range header {
+		key := normalizeHeader(name)
+		for col, column := range amazonColumns {
+			if cols[col] == -1 && key == normalizeHeader(column) {
+				cols[col] = i
+			}
+		}
+	}
+
+	var missing []string
+	for _, col := range amazonRequiredColumns {
+		if cols[col] == -1 {
+			missing = append(missing, amazonColumns[col])
+		}
+	}
+	if len(missing) > 0 {
+		return cols, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+type amazonRow struct {
+	record []string
+	cols   [numAmazonColumns]int
+	line   int
+}
+
+// get returns a cell, or "" for an absent column or a "Not Available" placeholder.
+func (r amazonRow) get(col int) string {
+	i := r.cols[col]
+	if i < 0 || i >= len(r.record) {
+		return ""
+	}
+	v := strings.TrimSpace(r.record[i])
+	if amazonMissing[strings.ToLower(v)] {
+		return ""
+	}
+	return v
+}
+
+func (r amazonRow) blank() bool {
+	for _, v := range r.record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// money parses an amount, treating an empty cell as zero. Some exports quote
+// amounts in single quotes, such as '-5.99'.
+func (r amazonRow) money(col int) (float64, error) {
+	raw := strings.Trim(r.get(col), "'")
+	if raw == "" {
+		return 0, nil
+	}
+	v, err := parseMoney(raw)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid amount %q in column %q", r.line, raw, amazonColumns[col])
+	}
+	return v, nil
+}
+
+// amazonOrder is an order being assembled from its rows.
+type amazonOrder struct {
+	models.Order
+	// shipments maps each shipment's ship date and tracking number to its index.
+	shipments map[string]int
+}
+
+// apply adds the row's item to the order, and to its shipment if it has shipped.
+func (o *amazonOrder) apply(r amazonRow) error {
+	raw := r.get(amazonOrderDate)
+	date, err := parseDate(raw)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid order date %q", r.line, raw)
+	}
+	if o.OrderDate != "" && o.OrderDate != date {
+		return fmt.Errorf("line %d: order %s has conflicting dates %s and %s", r.line, o.OrderNumber, o.OrderDate, date)
+	}
+	o.OrderDate = date
+
+	if strings.EqualFold(r.get(amazonOrderStatus), "cancelled") {
+		return nil
+	}
+
+	item := models.OrderItem{Name: r.get(amazonProductName), ProductURL: productURL(r.get(amazonWebsite), r.get(amazonASIN))}
+	if item.Name == "" {
+		return fmt.Errorf("line %d: missing product name", r.line)
+	}
+	qty, err := strconv.Atoi(r.get(amazonQuantity))
+	if err != nil {
+		return fmt.Errorf("line %d: invalid quantity %q", r.line, r.get(amazonQuantity))
+	}
+	item.Quantity = qty
+
+	var amounts [numAmazonColumns]float64
+	for _, col := range []int{amazonUnitPrice, amazonUnitPriceTax, amazonShippingCharge, amazonTotalOwed} {
+		v, err := r.money(col)
+		if err != nil {
+			return err
+		}
+		amounts[col] = v
+	}
+	item.Price = amounts[amazonUnitPrice]
+	tax := roundCents(amounts[amazonUnitPriceTax] * float64(qty))
+
+	o.OrderTotal = addAmount(o.OrderTotal, amounts[amazonTotalOwed])
+	o.Tax = addAmount(o.Tax, tax)
+	if amounts[amazonShippingCharge] != 0 || o.DeliveryCharges != nil {
+		o.DeliveryCharges = addAmount(o.DeliveryCharges, amounts[amazonShippingCharge])
+	}
+
+	if shipDate := r.get(amazonShipDate); shipDate != "" {
+		date, err := parseDate(shipDate)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid ship date %q", r.line, shipDate)
+		}
+		tracking := r.get(amazonTracking)
+		key := shipDate + "\x00" + tracking
+		i, ok := o.shipments[key]
+		if !ok {
+			i = len(o.Shipments)
+			o.shipments[key] = i
+			o.Shipments = append(o.Shipments, models.Shipment{ID: strconv.Itoa(i + 1), ShipDate: date, Tracking: tracking})
+		}
+		shipment := &o.Shipments[i]
+		shipment.Total = roundCents(shipment.Total + amounts[amazonTotalOwed])
+		shipment.Tax = addAmount(shipment.Tax, tax)
+		item.ShipmentID = shipment.ID
+	}
+
+	o.Items = append(o.Items, item)
+	return nil
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// productURL links to an item's product page on the Amazon site it was bought
+// from, such as "Amazon.com".
+func productURL(website, asin string) string {
+	if asin == "" {
+		return ""
+	}
+	host := "www.amazon.com"
+	if site := strings.ToLower(website); strings.HasPrefix(site, "amazon.") {
+		host = "www." + site
+	}
+	return "https://" + host + "/dp/" + asin
+}
@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"monarchmoney-sync-backend/models"
+)
+
+func TestReadAmazonCSV_Fixture(t *testing.T) {
+	// Arrange
+	f, err := os.Open("../testdata/amazon_order_history.csv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	// Act
+	orders, err := ReadAmazonCSV(f)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+
+	first := orders[0]
+	assert.Equal(t, models.RetailerAmazon, first.Retailer)
+	assert.Equal(t, "112-1234567-1234567", first.OrderNumber)
+	assert.Equal(t, "2024-02-10", first.OrderDate)
+	assert.Equal(t, 84.40, *first.OrderTotal)
+	assert.Equal(t, 6.44, *first.Tax)
+	assert.Equal(t, 5.99, *first.DeliveryCharges)
+	assert.Nil(t, first.Tip)
+	require.Len(t, first.Items, 3)
+	assert.Equal(t, models.OrderItem{
+		Name:       "Amazon Basics AA Batteries (48 Pack)",
+		Price:      14.49,
+		Quantity:   2,
+		ProductURL: "https://www.amazon.com/dp/B00MNV8E0C",
+		ShipmentID: "1",
+	}, first.Items[1])
+	assert.Equal(t, "2", first.Items[2].ShipmentID)
+
+	// Each shipment is charged separately, including its share of tax and shipping
+	require.Len(t, first.Shipments, 2)
+	assert.Equal(t, models.Shipment{
+		ID: "1", ShipDate: "2024-02-11", Tracking: "UPS(1Z999AA10123456784)", Total: 53.02, Tax: amount(4.05),
+	}, first.Shipments[0])
+	assert.Equal(t, models.Shipment{
+		ID: "2", ShipDate: "2024-02-13", Tracking: "AMZN_US(TBA123456789000)", Total: 31.38, Tax: amount(2.39),
+	}, first.Shipments[1])
+
+	// The cancelled item was never charged
+	second := orders[1]
+	assert.Equal(t, "2024-03-01", second.OrderDate)
+	require.Len(t, second.Items, 1)
+	assert.Equal(t, "Kindle Paperwhite Case", second.Items[0].Name)
+	assert.Equal(t, 14.06, *second.OrderTotal)
+	require.Len(t, second.Shipments, 1)
+	assert.Equal(t, 14.06, second.Shipments[0].Total)
+
+	// Items that have not shipped belong to no shipment
+	third := orders[2]
+	assert.Empty(t, third.Shipments)
+	require.Len(t, third.Items, 1)
+	assert.Empty(t, third.Items[0].ShipmentID)
+	assert.Nil(t, third.DeliveryCharges)
+}
+
+func TestReadAmazonCSV_Errors(t *testing.T) {
+	const header = "Order ID,Order Date,Product Name,Quantity,Unit Price,Total Owed\n"
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{"empty", "", "no orders found"},
+		{"header only", header, "no orders found"},
+		{"missing columns", "Order ID,Order Date\n1,2024-01-01\n", "missing columns: Product Name, Quantity, Unit Price, Total Owed"},
+		{"bad date", header + "1,yesterday,Eggs,1,2.50,2.50\n", `line 2: invalid order date "yesterday"`},
+		{"bad quantity", header + "1,2024-01-01,Eggs,one,2.50,2.50\n", `line 2: invalid quantity "one"`},
+		{"bad amount", header + "1,2024-01-01,Eggs,1,2.50,lots\n", `line 2: invalid amount "lots" in column "Total Owed"`},
+		{"missing order ID", header + ",2024-01-01,Eggs,1,2.50,2.50\n", "line 2: missing order ID"},
+		{"all cancelled", "Order ID,Order Date,Product Name,Quantity,Unit Price,Total Owed,Order Status\n" +
+			"1,2024-01-01,Eggs,1,2.50,2.50,Cancelled\n", "no orders found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadAmazonCSV(strings.NewReader(tt.csv))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
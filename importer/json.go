@@ -0,0 +1,77 @@
+// Package importer reads orders from files exported outside the browser extension
+// so they can be back-filled through the normal ingestion path.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// ReadJSON reads orders in any of the shapes the API accepts: a single order, an
+// array of orders, a batch request ({"orders": [...]}), or newline-delimited JSON
+// with one order per line.
+func ReadJSON(r io.Reader) ([]models.Order, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("no orders found")
+	}
+
+	if trimmed[0] == '[' {
+		var orders []models.Order
+		if err := json.Unmarshal(trimmed, &orders); err != nil {
+			return nil, fmt.Errorf("parse order array: %w", err)
+		}
+		return orders, nil
+	}
+
+	// A single JSON document is either a batch or one order; anything else is NDJSON
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &doc); err == nil {
+		if _, ok := doc["orders"]; ok {
+			var batch models.BatchOrdersRequest
+			if err := json.Unmarshal(trimmed, &batch); err != nil {
+				return nil, fmt.Errorf("parse batch: %w", err)
+			}
+			return batch.Orders, nil
+		}
+		var order models.Order
+		if err := json.Unmarshal(trimmed, &order); err != nil {
+			return nil, fmt.Errorf("parse order: %w", err)
+		}
+		return []models.Order{order}, nil
+	}
+
+	return readNDJSON(trimmed)
+}
+
+func readNDJSON(data []byte) ([]models.Order, error) {
+	var orders []models.Order
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 10<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var order models.Order
+		if err := json.Unmarshal(text, &order); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		orders = append(orders, order)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
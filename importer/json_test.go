@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"single order", `{"orderNumber":"1001"}`, []string{"1001"}},
+		{"array", `[{"orderNumber":"1001"},{"orderNumber":"1002"}]`, []string{"1001", "1002"}},
+		{"batch", `{"orders":[{"orderNumber":"1001"},{"orderNumber":"1002"}]}`, []string{"1001", "1002"}},
+		{"ndjson", "{\"orderNumber\":\"1001\"}\n\n{\"orderNumber\":\"1002\"}\n", []string{"1001", "1002"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			orders, err := ReadJSON(strings.NewReader(tt.input))
+
+			// Assert
+			require.NoError(t, err)
+			var numbers []string
+			for _, o := range orders {
+				numbers = append(numbers, o.OrderNumber)
+			}
+			assert.Equal(t, tt.want, numbers)
+		})
+	}
+}
+
+func TestReadJSON_Errors(t *testing.T) {
+	_, err := ReadJSON(strings.NewReader("  "))
+	assert.EqualError(t, err, "no orders found")
+
+	_, err = ReadJSON(strings.NewReader("{\"orderNumber\":\"1001\"}\nnot json\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
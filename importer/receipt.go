@@ -0,0 +1,427 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// ErrNoReceipt is returned by ReadReceipt for messages that are not purchase
+// receipts, such as shipping notices or marketing mail.
+var ErrNoReceipt = errors.New("message is not a receipt")
+
+var (
+	// Online orders show "Order number: 2000…" or "Order# 2000…"; Walmart Pay
+	// receipts only have the store transaction number, "TC# 1234 5678 …".
+	orderNumberPattern = regexp.MustCompile(`(?i)\border\s*(?:number|no\.?|#)\s*:?\s*#?\s*(\d[\d-]{4,}\d)`)
+	tcNumberPattern    = regexp.MustCompile(`(?i)\bTC\s*#\s*:?\s*(\d[\d ]{4,}\d)`)
+
+	datePattern = regexp.MustCompile(`(?i)\b(\d{1,2}/\d{1,2}/\d{2,4}|\d{4}-\d{2}-\d{2}|(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.? \d{1,2}, \d{4})\b`)
+
+	// amountPattern matches "$1,234.56", "1.58", "-$2.00", or "(2.00)", with the
+	// single-letter tax flag printed after amounts on store receipts.
+	amountPattern = regexp.MustCompile(`^(-|\()?\$?(\d{1,3}(?:,\d{3})*|\d+)\.(\d{2})\)?(?:\s+[A-Z])?$`)
+
+	qtyPattern      = regexp.MustCompile(`(?i)^(?:qty|quantity)\s*:?\s*(\d+)$|^x\s*(\d+)$|^(\d{1,3})\s*x?$`)
+	unitQtyPattern  = regexp.MustCompile(`^(\d+)\s*@\s*\$?(\d+\.\d{2})(?:\s*(?:ea|each))?$`)
+	itemCodePattern = regexp.MustCompile(`^\d{8,}$`)
+)
+
+// ReadReceipt reads a Walmart email receipt from a raw RFC 5322 message, as saved
+// in an .eml file. The HTML part is preferred, falling back to plain text. When the
+// receipt itself has no date, the message's Date header is used.
+func ReadReceipt(r io.Reader) (*models.Order, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+
+	body, isHTML, err := receiptBody(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]string
+	if isHTML {
+		lines, err = htmlLines(body)
+		if err != nil {
+			return nil, fmt.Errorf("parse HTML: %w", err)
+		}
+	} else {
+		lines = textLines(body)
+	}
+
+	order, err := parseReceipt(lines)
+	if err != nil {
+		return nil, err
+	}
+	if order.OrderDate == "" {
+		if date, err := msg.Header.Date(); err == nil {
+			order.OrderDate = date.Format("2006-01-02")
+		}
+	}
+	if order.OrderDate == "" {
+		return nil, fmt.Errorf("receipt %s has no order date", order.OrderNumber)
+	}
+	return order, nil
+}
+
+// receiptBody finds the first text/html part of the message, or the first
+// text/plain part when there is none, and returns it decoded to UTF-8.
+func receiptBody(header textproto.MIMEHeader, body io.Reader) ([]byte, bool, error) {
+	var plain []byte
+	var walk func(header textproto.MIMEHeader, body io.Reader) ([]byte, error)
+	walk = func(header textproto.MIMEHeader, body io.Reader) ([]byte, error) {
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			mediaType, params = "text/plain", nil
+		}
+
+		if strings.HasPrefix(mediaType, "multipart/") {
+			mr := multipart.NewReader(body, params["boundary"])
+			for {
+				part, err := mr.NextRawPart()
+				if errors.Is(err, io.EOF) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, fmt.Errorf("read MIME part: %w", err)
+				}
+				found, err := walk(part.Header, part)
+				if err != nil || found != nil {
+					return found, err
+				}
+			}
+		}
+		if mediaType != "text/html" && mediaType != "text/plain" {
+			return nil, nil
+		}
+		if disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition")); disposition == "attachment" {
+			return nil, nil
+		}
+
+		text, err := decodePart(body, header.Get("Content-Transfer-Encoding"), params["charset"])
+		if err != nil {
+			return nil, err
+		}
+		if mediaType == "text/html" {
+			return text, nil
+		}
+		if plain == nil {
+			plain = text
+		}
+		return nil, nil
+	}
+
+	htmlBody, err := walk(header, body)
+	if err != nil {
+		return nil, false, err
+	}
+	if htmlBody != nil {
+		return htmlBody, true, nil
+	}
+	if plain != nil {
+		return plain, false, nil
+	}
+	return nil, false, fmt.Errorf("%w: no text or HTML part", ErrNoReceipt)
+}
+
+// decodePart undoes the transfer encoding and converts the charset to UTF-8.
+func decodePart(body io.Reader, encoding, charsetLabel string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	if charsetLabel != "" && !strings.EqualFold(charsetLabel, "utf-8") && !strings.EqualFold(charsetLabel, "us-ascii") {
+		decoded, err := charset.NewReaderLabel(charsetLabel, body)
+		if err != nil {
+			return nil, fmt.Errorf("decode charset %q: %w", charsetLabel, err)
+		}
+		body = decoded
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("decode MIME part: %w", err)
+	}
+	return data, nil
+}
+
+// htmlLines flattens an HTML receipt into lines of cells: each table row becomes
+// one line with a cell per column, and other block elements become lines with a
+// single cell.
+func htmlLines(body []byte) ([][]string, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var lines [][]string
+	var cells []string
+	var text strings.Builder
+	endCell := func() {
+		if s := strings.Join(strings.Fields(text.String()), " "); s != "" {
+			cells = append(cells, s)
+		}
+		text.Reset()
+	}
+	endLine := func() {
+		endCell()
+		if len(cells) > 0 {
+			lines = append(lines, cells)
+		}
+		cells = nil
+	}
+
+	// Cells inside a row share its line; other blocks end the line unless they are
+	// nested in a cell, where they only separate its text
+	breakBlock := func(n *html.Node) {
+		switch {
+		case n.Data == "td" || n.Data == "th" || insideCell(n):
+			endCell()
+		default:
+			endLine()
+		}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteByte(' ')
+			return
+		}
+		block := false
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "head", "script", "style", "title":
+				return
+			case "br":
+				breakBlock(n)
+			case "td", "th", "tr", "table", "div", "p", "li", "h1", "h2", "h3", "h4", "h5", "h6":
+				block = true
+				breakBlock(n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			breakBlock(n)
+		}
+	}
+	walk(doc)
+	endLine()
+	return lines, nil
+}
+
+// insideCell reports whether n is nested in a table cell, where blocks only
+// separate the cell's text rather than starting new lines.
+func insideCell(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode {
+			switch p.Data {
+			case "td", "th":
+				return true
+			case "tr", "table":
+				return false
+			}
+		}
+	}
+	return false
+}
+
+var cellSeparator = regexp.MustCompile(`\t|\s{2,}`)
+
+// textLines splits a plain-text receipt into lines, treating tabs and runs of
+// spaces as column breaks.
+func textLines(body []byte) [][]string {
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var cells []string
+		for _, cell := range cellSeparator.Split(strings.TrimSpace(scanner.Text()), -1) {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				cells = append(cells, cell)
+			}
+		}
+		if len(cells) > 0 {
+			lines = append(lines, cells)
+		}
+	}
+	return lines
+}
+
+// parseReceipt reads the order from receipt lines. A line whose last cell is an
+// amount is either a summary line, recognized by its label, or an item.
+func parseReceipt(lines [][]string) (*models.Order, error) {
+	order := &models.Order{Retailer: models.RetailerWalmart}
+	var dated, undated string
+
+	for _, cells := range lines {
+		line := strings.Join(cells, " ")
+		if order.OrderNumber == "" {
+			if m := orderNumberPattern.FindStringSubmatch(line); m != nil {
+				order.OrderNumber = strings.ReplaceAll(m[1], "-", "")
+			} else if m := tcNumberPattern.FindStringSubmatch(line); m != nil {
+				order.OrderNumber = strings.ReplaceAll(m[1], " ", "")
+			}
+		}
+		if m := datePattern.FindString(line); m != "" {
+			if date, err := parseDate(strings.Replace(m, ".", "", 1)); err == nil {
+				if dated == "" && strings.Contains(strings.ToLower(line), "date") {
+					dated = date
+				}
+				if undated == "" {
+					undated = date
+				}
+			}
+		}
+
+		amount, ok := parseAmount(cells[len(cells)-1])
+		if !ok || len(cells) < 2 {
+			continue
+		}
+		label := strings.ToLower(cells[0])
+		switch summaryLabel(label) {
+		case "ignore":
+			continue
+		case "tax":
+			order.Tax = addAmount(order.Tax, amount)
+			continue
+		case "total":
+			if order.OrderTotal == nil {
+				order.OrderTotal = &amount
+			}
+			continue
+		case "delivery":
+			order.DeliveryCharges = addAmount(order.DeliveryCharges, amount)
+			continue
+		case "tip":
+			order.Tip = addAmount(order.Tip, amount)
+			continue
+		}
+
+		if item, ok := parseItem(cells[:len(cells)-1], amount); ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+
+	if order.OrderNumber == "" {
+		return nil, fmt.Errorf("%w: no order number", ErrNoReceipt)
+	}
+	if len(order.Items) == 0 && order.OrderTotal == nil {
+		return nil, fmt.Errorf("%w: no items or total in order %s", ErrNoReceipt, order.OrderNumber)
+	}
+	order.OrderDate = dated
+	if order.OrderDate == "" {
+		order.OrderDate = undated
+	}
+	return order, nil
+}
+
+// Labels of receipt summary lines. They are anchored at the start of the label so
+// item names such as "Total Cereal" or "Savory Crackers" are not mistaken for them.
+var (
+	ignoredLabelPattern  = regexp.MustCompile(`^(sub-?total|savings|you saved|total savings|discounts?|change due|items \(\d+\)|visa|mastercard|amex|discover|debit|cash|payment|paid)\b|\btend\b|\bending in\b`)
+	taxLabelPattern      = regexp.MustCompile(`^(sales |estimated |order )?tax\b`)
+	tipLabelPattern      = regexp.MustCompile(`^(driver |delivery )?tip\b`)
+	deliveryLabelPattern = regexp.MustCompile(`^(delivery|shipping)( fee| charges?| cost)?:?$`)
+	totalLabelPattern    = regexp.MustCompile(`^(order |grand |estimated )?total:?$`)
+)
+
+// summaryLabel classifies the label of an amount line; "" means it is an item.
+func summaryLabel(label string) string {
+	switch {
+	case ignoredLabelPattern.MatchString(label):
+		return "ignore"
+	case taxLabelPattern.MatchString(label):
+		return "tax"
+	case tipLabelPattern.MatchString(label):
+		return "tip"
+	case deliveryLabelPattern.MatchString(label):
+		return "delivery"
+	case totalLabelPattern.MatchString(label):
+		return "total"
+	}
+	return ""
+}
+
+// parseItem reads an item from the cells before its line amount: a name, an
+// optional quantity ("Qty 2", "x2", or "2 @ 1.58"), and optional item codes.
+func parseItem(cells []string, amount float64) (models.OrderItem, bool) {
+	item := models.OrderItem{Quantity: 1}
+	var unit *float64
+	for _, cell := range cells {
+		if m := unitQtyPattern.FindStringSubmatch(cell); m != nil {
+			item.Quantity, _ = strconv.Atoi(m[1])
+			u, _ := strconv.ParseFloat(m[2], 64)
+			unit = &u
+			continue
+		}
+		if m := qtyPattern.FindStringSubmatch(cell); m != nil {
+			item.Quantity, _ = strconv.Atoi(m[1] + m[2] + m[3])
+			continue
+		}
+		if itemCodePattern.MatchString(cell) {
+			continue
+		}
+		if _, ok := parseAmount(cell); ok {
+			continue
+		}
+		if item.Name == "" {
+			item.Name = cell
+		}
+	}
+	if item.Name == "" || item.Quantity <= 0 || amount < 0 {
+		return item, false
+	}
+
+	if unit != nil {
+		item.Price = *unit
+	} else {
+		item.Price = math.Round(amount/float64(item.Quantity)*100) / 100
+	}
+	return item, true
+}
+
+// parseAmount reads a receipt amount; see amountPattern.
+func parseAmount(cell string) (float64, bool) {
+	m := amountPattern.FindStringSubmatch(strings.TrimSpace(cell))
+	if m == nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(m[2], ",", "")+"."+m[3], 64)
+	if err != nil {
+		return 0, false
+	}
+	if m[1] != "" {
+		v = -v
+	}
+	return v, true
+}
+
+func addAmount(sum *float64, amount float64) *float64 {
+	if sum == nil {
+		return &amount
+	}
+	total := math.Round((*sum+amount)*100) / 100
+	return &total
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"monarchmoney-sync-backend/models"
+)
+
+func readReceiptFixture(t *testing.T, name string) (*models.Order, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", "receipts", name))
+	require.NoError(t, err)
+	defer f.Close()
+	return ReadReceipt(f)
+}
+
+func amount(v float64) *float64 {
+	return &v
+}
+
+func TestReadReceipt_Fixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want models.Order
+	}{
+		{
+			file: "online_order.eml",
+			want: models.Order{
+				Retailer:    models.RetailerWalmart,
+				OrderNumber: "200012345678901",
+				OrderDate:   "2024-03-14",
+				OrderTotal:  amount(24.61),
+				Tax:         amount(1.27),
+				Tip:         amount(4.00),
+				Items: []models.OrderItem{
+					{Name: "Great Value Whole Milk, 1 gal", Price: 3.48, Quantity: 2},
+					{Name: "Fresh Bananas, 3 lb bag", Price: 1.58, Quantity: 1},
+					{Name: "Bounty Paper Towels, 6 Double Rolls", Price: 10.80, Quantity: 1},
+				},
+			},
+		},
+		{
+			file: "walmart_pay.eml",
+			want: models.Order{
+				Retailer:    models.RetailerWalmart,
+				OrderNumber: "12345678901234567890",
+				OrderDate:   "2024-03-16",
+				OrderTotal:  amount(8.23),
+				Tax:         amount(0.19),
+				Items: []models.OrderItem{
+					{Name: "GV WHOLE MLK", Price: 3.48, Quantity: 1},
+					{Name: "BANANAS", Price: 0.79, Quantity: 2},
+					{Name: "SAVORY CRACKERS", Price: 2.98, Quantity: 1},
+				},
+			},
+		},
+		{
+			// No date in the body, so the message's Date header is used
+			file: "delivery_latin1.eml",
+			want: models.Order{
+				Retailer:        models.RetailerWalmart,
+				OrderNumber:     "2000987654321",
+				OrderDate:       "2024-04-02",
+				OrderTotal:      amount(43.52),
+				Tax:             amount(0.52),
+				DeliveryCharges: amount(7.95),
+				Tip:             amount(5.00),
+				Items: []models.OrderItem{
+					{Name: "Crème fraîche, 8 oz", Price: 3.97, Quantity: 1},
+					{Name: "Total Cereal, 16 oz", Price: 4.48, Quantity: 1},
+					{Name: "Paper Towels & Napkins Bundle", Price: 10.80, Quantity: 2},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			// Act
+			order, err := readReceiptFixture(t, tt.file)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *order)
+		})
+	}
+}
+
+func TestReadReceipt_NotAReceipt(t *testing.T) {
+	// Act
+	_, err := readReceiptFixture(t, "shipping_notice.eml")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoReceipt)
+}
+
+func TestReadReceipt_PlainText(t *testing.T) {
+	// Arrange
+	msg := "From: receipts@walmart.example.com\r\n" +
+		"Date: Fri, 05 Jan 2024 10:00:00 +0000\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Order number: 2000111122223333\r\n" +
+		"Eggs, 12 ct    Qty 2    $5.00\r\n" +
+		"Total    $5.00\r\n"
+
+	// Act
+	order, err := ReadReceipt(strings.NewReader(msg))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "2000111122223333", order.OrderNumber)
+	assert.Equal(t, "2024-01-05", order.OrderDate)
+	assert.Equal(t, []models.OrderItem{{Name: "Eggs, 12 ct", Price: 2.50, Quantity: 2}}, order.Items)
+}
+
+func TestReadReceipt_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"not a message", "no headers here", "read message"},
+		{"attachment only", "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\n" +
+			"Content-Type: text/html\r\nContent-Disposition: attachment; filename=r.html\r\n\r\n<p>Order number: 123456</p>\r\n--b--\r\n",
+			"no text or HTML part"},
+		{"no order number", "Content-Type: text/plain\r\n\r\nTotal  $5.00\r\n", "no order number"},
+		{"no date", "Content-Type: text/plain\r\n\r\nOrder number: 123456\r\nTotal  $5.00\r\n", "receipt 123456 has no order date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := ReadReceipt(strings.NewReader(tt.msg))
+
+			// Assert
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
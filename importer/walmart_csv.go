@@ -0,0 +1,305 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// Walmart purchase history columns. Headers are matched case-insensitively,
+// ignoring spaces and punctuation, against each column's aliases; other columns
+// are ignored.
+const (
+	colOrderNumber = iota
+	colOrderDate
+	colItemName
+	colQuantity
+	colItemPrice
+	colItemTotal
+	colOrderTotal
+	colTax
+	colDelivery
+	colTip
+	colProductURL
+	colCategory
+	numColumns
+)
+
+var walmartColumns = [numColumns][]string{
+	colOrderNumber: {"Order Number", "Order #", "Order No", "Order ID"},
+	colOrderDate:   {"Order Date", "Date", "Purchase Date"},
+	colItemName:    {"Product Name", "Item Name", "Item", "Item Description", "Description"},
+	colQuantity:    {"Quantity", "Qty", "Item Quantity"},
+	colItemPrice:   {"Item Price", "Unit Price", "Price"},
+	colItemTotal:   {"Item Total", "Line Total", "Total Price"},
+	colOrderTotal:  {"Order Total", "Total", "Grand Total"},
+	colTax:         {"Tax", "Sales Tax", "Order Tax"},
+	colDelivery:    {"Delivery Charges", "Delivery Fee", "Shipping", "Shipping Cost"},
+	colTip:         {"Tip", "Driver Tip", "Delivery Tip"},
+	colProductURL:  {"Product URL", "Product Link", "URL"},
+	colCategory:    {"Category", "Department"},
+}
+
+// dateLayouts are the order date formats seen in exports. Dates are normalized to
+// YYYY-MM-DD, which is what order listing filters compare against.
+var dateLayouts = []string{
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/2006",
+	"01/02/06",
+	"1/2/06",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	time.RFC3339,
+}
+
+// ReadWalmartCSV reads a Walmart purchase history CSV export. Rows are grouped
+// into orders by order number, in the order each number first appears. Each row
+// with a product name is an item; order-level amounts are taken from the first
+// row of the order that has them, so exports that repeat them on every row and
+// exports with a separate summary row both work.
+func ReadWalmartCSV(r io.Reader) ([]models.Order, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("no orders found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols, err := mapColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []*models.Order
+	byNumber := make(map[string]*models.Order)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := csvRow{record: record, cols: cols, line: line}
+		if row.blank() {
+			continue
+		}
+
+		number := row.get(colOrderNumber)
+		if number == "" {
+			return nil, fmt.Errorf("line %d: missing order number", line)
+		}
+		order, ok := byNumber[number]
+		if !ok {
+			order = &models.Order{Retailer: models.RetailerWalmart, OrderNumber: number}
+			byNumber[number] = order
+			orders = append(orders, order)
+		}
+		if err := row.apply(order); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("no orders found")
+	}
+	result := make([]models.Order, len(orders))
+	for i, o := range orders {
+		result[i] = *o
+	}
+	return result, nil
+}
+
+// mapColumns finds each known column in the header row.
+func mapColumns(header []string) ([numColumns]int, error) {
+	var cols [numColumns]int
+	for i := range cols {
+		cols[i] = -1
+	}
+	for i, name := range header {
+		key := normalizeHeader(name)
+		for col, aliases := range walmartColumns {
+			for _, alias := range aliases {
+				if cols[col] == -1 && key == normalizeHeader(alias) {
+					cols[col] = i
+				}
+			}
+		}
+	}
+
+	var missing []string
+	for _, col := range []int{colOrderNumber, colOrderDate} {
+		if cols[col] == -1 {
+			missing = append(missing, walmartColumns[col][0])
+		}
+	}
+	if cols[colItemName] != -1 && cols[colItemPrice] == -1 && cols[colItemTotal] == -1 {
+		missing = append(missing, walmartColumns[colItemPrice][0])
+	}
+	if len(missing) > 0 {
+		return cols, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+// normalizeHeader drops everything but letters, digits, and "#", which also
+// removes a leading byte order mark.
+func normalizeHeader(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '#' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+type csvRow struct {
+	record []string
+	cols   [numColumns]int
+	line   int
+}
+
+func (r csvRow) get(col int) string {
+	i := r.cols[col]
+	if i < 0 || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+func (r csvRow) blank() bool {
+	for _, v := range r.record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// apply merges the row into its order.
+func (r csvRow) apply(order *models.Order) error {
+	if raw := r.get(colOrderDate); raw != "" {
+		date, err := parseDate(raw)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid order date %q", r.line, raw)
+		}
+		if order.OrderDate != "" && order.OrderDate != date {
+			return fmt.Errorf("line %d: order %s has conflicting dates %s and %s", r.line, order.OrderNumber, order.OrderDate, date)
+		}
+		order.OrderDate = date
+	}
+
+	for _, f := range []struct {
+		col  int
+		dest **float64
+	}{
+		{colOrderTotal, &order.OrderTotal},
+		{colTax, &order.Tax},
+		{colDelivery, &order.DeliveryCharges},
+		{colTip, &order.Tip},
+	} {
+		if *f.dest != nil {
+			continue
+		}
+		v, err := r.money(f.col)
+		if err != nil {
+			return err
+		}
+		*f.dest = v
+	}
+
+	name := r.get(colItemName)
+	if name == "" {
+		return nil
+	}
+	item := models.OrderItem{
+		Name:       name,
+		Quantity:   1,
+		ProductURL: r.get(colProductURL),
+		Category:   r.get(colCategory),
+	}
+	if raw := r.get(colQuantity); raw != "" {
+		qty, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid quantity %q", r.line, raw)
+		}
+		item.Quantity = qty
+	}
+
+	price, err := r.money(colItemPrice)
+	if err != nil {
+		return err
+	}
+	if price == nil {
+		// Only the line total is exported; derive the unit price
+		total, err := r.money(colItemTotal)
+		if err != nil {
+			return err
+		}
+		if total == nil {
+			return fmt.Errorf("line %d: missing item price", r.line)
+		}
+		unit := *total
+		if item.Quantity > 0 {
+			unit = math.Round(*total/float64(item.Quantity)*100) / 100
+		}
+		price = &unit
+	}
+	item.Price = *price
+
+	order.Items = append(order.Items, item)
+	return nil
+}
+
+// money parses an amount such as "$1,234.56" or "(2.00)", returning nil for an
+// empty cell.
+func (r csvRow) money(col int) (*float64, error) {
+	raw := r.get(col)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := parseMoney(raw)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: invalid amount %q in column %q", r.line, raw, walmartColumns[col][0])
+	}
+	return &v, nil
+}
+
+// parseMoney parses an amount such as "$1,234.56" or "(2.00)".
+func parseMoney(raw string) (float64, error) {
+	s := strings.NewReplacer("$", "", ",", "", " ", "").Replace(raw)
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.Trim(s, "()")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func parseDate(raw string) (string, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", raw)
+}
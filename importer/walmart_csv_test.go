@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"monarchmoney-sync-backend/models"
+)
+
+func TestReadWalmartCSV_Fixture(t *testing.T) {
+	// Arrange
+	f, err := os.Open("../testdata/walmart_orders.csv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	// Act
+	orders, err := ReadWalmartCSV(f)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+
+	first := orders[0]
+	assert.Equal(t, "200012345678901", first.OrderNumber)
+	assert.Equal(t, models.RetailerWalmart, first.Retailer)
+	assert.Equal(t, "2024-03-14", first.OrderDate)
+	assert.Equal(t, 24.61, *first.OrderTotal)
+	assert.Equal(t, 1.27, *first.Tax)
+	assert.Equal(t, 0.0, *first.DeliveryCharges)
+	assert.Equal(t, 4.0, *first.Tip)
+	require.Len(t, first.Items, 3)
+	assert.Equal(t, models.OrderItem{
+		Name:       "Great Value Whole Milk 1 Gal",
+		Price:      3.48,
+		Quantity:   2,
+		ProductURL: "https://www.walmart.com/ip/10450114",
+		Category:   "Dairy",
+	}, first.Items[0])
+	assert.Equal(t, "Household", first.Items[2].Category)
+
+	second := orders[1]
+	assert.Equal(t, "2024-04-02", second.OrderDate)
+	assert.Equal(t, 1176.43, *second.OrderTotal)
+	assert.Nil(t, second.Tip)
+	assert.Equal(t, `onn. 65" 4K UHD Roku Smart TV`, second.Items[0].Name)
+	assert.Equal(t, 1099.0, second.Items[0].Price)
+}
+
+func TestReadWalmartCSV_GroupsRowsAndSummaryLines(t *testing.T) {
+	// Arrange: header aliases, a BOM, interleaved orders, and a summary row
+	input := "\ufeffOrder #,Date,Item Name,Qty,Item Total,Total,Sales Tax\n" +
+		"1001,2024-01-05,Eggs,2,$5.00,,\n" +
+		"1002,\"Jan 6, 2024\",Rice,1,$3.00,$3.00,$0.00\n" +
+		"1001,2024-01-05,Bread,,$2.50,,\n" +
+		"1001,2024-01-05,,,,$7.91,$0.41\n"
+
+	// Act
+	orders, err := ReadWalmartCSV(strings.NewReader(input))
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "1001", orders[0].OrderNumber)
+	assert.Equal(t, []models.OrderItem{
+		{Name: "Eggs", Price: 2.5, Quantity: 2},
+		{Name: "Bread", Price: 2.5, Quantity: 1},
+	}, orders[0].Items)
+	assert.Equal(t, 7.91, *orders[0].OrderTotal)
+	assert.Equal(t, 0.41, *orders[0].Tax)
+	assert.Equal(t, "2024-01-06", orders[1].OrderDate)
+}
+
+func TestReadWalmartCSV_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "no orders found"},
+		{"header only", "Order Number,Order Date\n", "no orders found"},
+		{"missing columns", "Product Name,Item Price\nEggs,1.00\n", "missing columns: Order Number, Order Date"},
+		{"missing price column", "Order Number,Order Date,Product Name\n1,2024-01-01,Eggs\n", "missing columns: Item Price"},
+		{"missing order number", "Order Number,Order Date\n1,2024-01-01\n,2024-01-02\n", "line 3: missing order number"},
+		{"bad date", "Order Number,Order Date\n1,yesterday\n", `line 2: invalid order date "yesterday"`},
+		{"conflicting dates", "Order Number,Order Date\n1,2024-01-01\n1,2024-01-02\n", "line 3: order 1 has conflicting dates 2024-01-01 and 2024-01-02"},
+		{"bad quantity", "Order Number,Order Date,Product Name,Quantity,Item Price\n1,2024-01-01,Eggs,two,1.00\n", `line 2: invalid quantity "two"`},
+		{"bad amount", "Order Number,Order Date,Order Total\n1,2024-01-01,abc\n", `line 2: invalid amount "abc" in column "Order Total"`},
+		{"missing price", "Order Number,Order Date,Product Name,Item Price\n1,2024-01-01,Eggs,\n", "line 2: missing item price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := ReadWalmartCSV(strings.NewReader(tt.input))
+
+			// Assert
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package jobs runs background work from the durable job queue, so work left
+// unfinished by a restart or a crashed replica is picked up again.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"monarchmoney-sync-backend/store"
+)
+
+// Handler runs one claimed job. Returning an error retries the job with backoff
+// until Options.MaxAttempts, after which it is marked failed.
+type Handler func(ctx context.Context, job *store.Job) error
+
+// RetryError asks for a job to run again at a set time, such as when what it waits
+// for is not ready yet. Unlike other errors, the attempt is not counted, so it
+// neither counts toward MaxAttempts nor lengthens the backoff of later failures.
+type RetryError struct {
+	At  time.Time
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry at %s: %v", e.At.Format(time.RFC3339), e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAt returns an error that runs the job again at at.
+func RetryAt(at time.Time, err error) error {
+	return &RetryError{At: at, Err: err}
+}
+
+// Options configures a Runner.
+type Options struct {
+	// Worker identifies this process when it claims jobs.
+	Worker string
+	// PollInterval is the time between checks for due jobs when the queue is empty.
+	PollInterval time.Duration
+	// Lease is how long a claimed job may run before another worker takes it over.
+	Lease time.Duration
+	// MaxAttempts is the number of failed attempts before a job is marked failed.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubling up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultOptions returns the options used for settings left empty.
+func DefaultOptions() Options {
+	return Options{
+		Worker:         DefaultWorker(),
+		PollInterval:   5 * time.Second,
+		Lease:          5 * time.Minute,
+		MaxAttempts:    8,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     time.Hour,
+	}
+}
+
+// DefaultWorker returns a worker name unique to this process: the host name, the
+// process ID, and a random suffix in case the PID is reused in a new container.
+func DefaultWorker() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Runner claims due jobs from a store one at a time and runs the handler for their
+// kind. Jobs of a kind without a handler fail for good.
+type Runner struct {
+	store    store.JobStore
+	opts     Options
+	handlers map[string]Handler
+
+	// stop ends the poll loop; done is closed once the in-flight job has finished.
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewRunner creates a runner for the jobs in s. Register handlers with Handle
+// before calling Run.
+func NewRunner(s store.JobStore, opts Options) *Runner {
+	defaults := DefaultOptions()
+	if opts.Worker == "" {
+		opts.Worker = defaults.Worker
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaults.PollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaults.Lease
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = defaults.InitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaults.MaxBackoff
+	}
+	return &Runner{
+		store:    s,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Handle registers the handler for jobs of kind.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Run claims and runs jobs until Shutdown is called or ctx is done. Jobs are run
+// with ctx, so cancelling it also interrupts the job in flight.
+func (r *Runner) Run(ctx context.Context) {
+	defer close(r.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stop:
+			return
+		case <-timer.C:
+		}
+
+		// Keep claiming while jobs are due, then wait for the next poll
+		wait := r.opts.PollInterval
+		if r.RunOnce(ctx) {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RunOnce claims and runs one due job, reporting whether there was one.
+func (r *Runner) RunOnce(ctx context.Context) bool {
+	job, err := r.store.ClaimJob(ctx, r.opts.Worker, r.opts.Lease)
+	if errors.Is(err, store.ErrNoJobs) {
+		return false
+	}
+	if err != nil {
+		slog.Warn("Failed to claim job", "error", err)
+		return false
+	}
+
+	logger := slog.With("job_id", job.ID, "kind", job.Kind, "tenant_id", job.TenantID, "attempt", job.Attempts)
+	err = r.run(ctx, job)
+	if err == nil {
+		err = r.store.CompleteJob(ctx, job.ID, r.opts.Worker)
+		if errors.Is(err, store.ErrNotFound) {
+			logger.Warn("Job lease expired before it finished; it may run again")
+		} else if err != nil {
+			logger.Error("Failed to record job completion", "error", err)
+		}
+		return true
+	}
+
+	var retry *RetryError
+	if errors.As(err, &retry) {
+		logger.Info("Job rescheduled", "reason", retry.Err, "run_at", retry.At)
+		if ferr := r.store.RescheduleJob(ctx, job.ID, r.opts.Worker, err.Error(), retry.At); ferr != nil && !errors.Is(ferr, store.ErrNotFound) {
+			logger.Error("Failed to reschedule job", "error", ferr)
+		}
+		return true
+	}
+
+	retryAt := r.retryAt(job)
+	if retryAt == nil {
+		logger.Error("Job failed", "error", err)
+	} else {
+		logger.Info("Job will be retried", "error", err, "retry_at", *retryAt)
+	}
+	if ferr := r.store.FailJob(ctx, job.ID, r.opts.Worker, err.Error(), retryAt); ferr != nil && !errors.Is(ferr, store.ErrNotFound) {
+		logger.Error("Failed to record job failure", "error", ferr)
+	}
+	return true
+}
+
+// Shutdown stops claiming jobs and waits for the job in flight to finish, up to
+// ctx's deadline. A job still running then is claimed again by another worker
+// once its lease expires.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run calls the job's handler, turning a panic into an error so it is retried
+// instead of crashing the process.
+func (r *Runner) run(ctx context.Context, job *store.Job) (err error) {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// retryAt returns when a failed job runs again, or nil when it has failed for good.
+func (r *Runner) retryAt(job *store.Job) *time.Time {
+	if _, ok := r.handlers[job.Kind]; !ok || job.Attempts >= r.opts.MaxAttempts {
+		return nil
+	}
+	backoff := r.opts.InitialBackoff
+	for i := 1; i < job.Attempts && backoff < r.opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.opts.MaxBackoff {
+		backoff = r.opts.MaxBackoff
+	}
+	at := time.Now().Add(backoff)
+	return &at
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOptions() Options {
+	return Options{
+		Worker:         "w1",
+		PollInterval:   5 * time.Millisecond,
+		MaxAttempts:    2,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Hour,
+	}
+}
+
+func enqueue(t *testing.T, s store.JobStore, kind string) *store.Job {
+	t.Helper()
+	job := &store.Job{TenantID: "t1", Kind: kind}
+	require.NoError(t, s.EnqueueJob(context.Background(), job))
+	return job
+}
+
+func getJob(t *testing.T, s store.JobStore, id int64) *store.Job {
+	t.Helper()
+	job, err := s.GetJob(context.Background(), "t1", id)
+	require.NoError(t, err)
+	return job
+}
+
+func TestRunner_RunOnce_CompletesJob(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "echo")
+	r := NewRunner(s, testOptions())
+	var ran *store.Job
+	r.Handle("echo", func(_ context.Context, j *store.Job) error {
+		ran = j
+		return nil
+	})
+
+	// Act
+	claimed := r.RunOnce(context.Background())
+
+	// Assert
+	assert.True(t, claimed)
+	require.NotNil(t, ran)
+	assert.Equal(t, job.ID, ran.ID)
+	assert.Equal(t, store.JobSucceeded, getJob(t, s, job.ID).Status)
+	assert.False(t, r.RunOnce(context.Background()))
+}
+
+func TestRunner_RunOnce_RetriesWithBackoffThenFails(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "flaky")
+	r := NewRunner(s, testOptions())
+	r.Handle("flaky", func(context.Context, *store.Job) error {
+		return errors.New("monarch unavailable")
+	})
+
+	// Act
+	r.RunOnce(context.Background())
+	retried := getJob(t, s, job.ID)
+
+	// Assert: the first failure waits out the backoff
+	assert.Equal(t, store.JobPending, retried.Status)
+	assert.Equal(t, "monarch unavailable", retried.LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), retried.RunAt, 5*time.Second)
+	assert.False(t, r.RunOnce(context.Background()))
+}
+
+func TestRunner_RunOnce_FailsAfterMaxAttempts(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "flaky")
+	opts := testOptions()
+	opts.MaxAttempts = 1
+	r := NewRunner(s, opts)
+	r.Handle("flaky", func(context.Context, *store.Job) error {
+		return errors.New("monarch unavailable")
+	})
+
+	// Act
+	r.RunOnce(context.Background())
+
+	// Assert
+	failed := getJob(t, s, job.ID)
+	assert.Equal(t, store.JobFailed, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+}
+
+func TestRunner_RunOnce_RetryAtIgnoresMaxAttempts(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "waiting")
+	opts := testOptions()
+	opts.MaxAttempts = 1
+	r := NewRunner(s, opts)
+	at := time.Now().Add(6 * time.Hour).UTC().Truncate(time.Second)
+	r.Handle("waiting", func(context.Context, *store.Job) error {
+		return RetryAt(at, errors.New("not posted yet"))
+	})
+
+	// Act
+	r.RunOnce(context.Background())
+
+	// Assert
+	retried := getJob(t, s, job.ID)
+	assert.Equal(t, store.JobPending, retried.Status)
+	assert.True(t, at.Equal(retried.RunAt))
+	assert.Zero(t, retried.Attempts, "scheduled retries are not counted as attempts")
+}
+
+func TestRunner_RunOnce_UnknownKindFails(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "unknown")
+	r := NewRunner(s, testOptions())
+
+	// Act
+	r.RunOnce(context.Background())
+
+	// Assert
+	failed := getJob(t, s, job.ID)
+	assert.Equal(t, store.JobFailed, failed.Status)
+	assert.Contains(t, failed.LastError, "no handler")
+}
+
+func TestRunner_RunOnce_RecoversPanic(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "broken")
+	r := NewRunner(s, testOptions())
+	r.Handle("broken", func(context.Context, *store.Job) error {
+		panic("nil map")
+	})
+
+	// Act
+	r.RunOnce(context.Background())
+
+	// Assert
+	retried := getJob(t, s, job.ID)
+	assert.Equal(t, store.JobPending, retried.Status)
+	assert.Contains(t, retried.LastError, "nil map")
+}
+
+func TestRunner_Shutdown_DrainsInFlightJob(t *testing.T) {
+	// Arrange
+	s := store.NewMemory()
+	job := enqueue(t, s, "slow")
+	r := NewRunner(s, testOptions())
+	started := make(chan struct{})
+	r.Handle("slow", func(context.Context, *store.Job) error {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	go r.Run(context.Background())
+	<-started
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := r.Shutdown(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, store.JobSucceeded, getJob(t, s, job.ID).Status)
+
+	// Jobs enqueued after shutdown are left for another worker
+	later := enqueue(t, s, "slow")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, store.JobPending, getJob(t, s, later.ID).Status)
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = ParseLevel("WARN")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+}
+
+func TestNew_JSONWithRequestID(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+	ctx := WithRequestID(WithLogger(context.Background(), logger), "req-123")
+
+	// Act
+	FromContext(ctx).Debug("hidden")
+	FromContext(ctx).Info("Received Walmart order", "order_number", "100")
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "Received Walmart order", entry["msg"])
+	assert.Equal(t, "req-123", entry["request_id"])
+	assert.Equal(t, "100", entry["order_number"])
+	assert.Equal(t, "req-123", RequestIDFromContext(ctx))
+
+	_, err = New(&buf, "xml", slog.LevelInfo)
+	assert.Error(t, err)
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID("abc-123_DEF"))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("has space"))
+	assert.False(t, ValidRequestID("line\nbreak"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+	assert.True(t, ValidRequestID(NewRequestID()))
+}
@@ -0,0 +1,290 @@
+// Package mailpoll imports email receipts from an IMAP folder, such as a mail
+// filter's "Walmart Receipts" label, so purchases that never appear in the online
+// order list are still synced.
+package mailpoll
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"monarchmoney-sync-backend/importer"
+	"monarchmoney-sync-backend/models"
+)
+
+// TLS modes for connecting to the IMAP server.
+const (
+	// TLSImplicit connects with TLS from the start, usually on port 993.
+	TLSImplicit = "implicit"
+	// TLSStartTLS upgrades a plain connection with STARTTLS, usually on port 143.
+	TLSStartTLS = "starttls"
+	// TLSNone sends credentials in the clear; use it only for local servers.
+	TLSNone = "none"
+)
+
+// DefaultProcessedFlag is the keyword set on messages once they are imported.
+const DefaultProcessedFlag = "$MonarchSynced"
+
+// IngestFunc processes an order read from a receipt. It returns an error when the
+// order should be retried on the next poll, such as when it could not be stored;
+// a result that is not successful means the order was rejected for good.
+type IngestFunc func(ctx context.Context, tenantID string, order models.Order) (models.BatchOrderResult, error)
+
+// Options configures a Poller.
+type Options struct {
+	// Addr is the server's host:port.
+	Addr string
+	// Username and Password log in to the mailbox.
+	Username string
+	Password string
+	// TLS is TLSImplicit, TLSStartTLS, or TLSNone.
+	TLS string
+	// TLSConfig overrides the TLS client configuration, such as to trust a private CA.
+	TLSConfig *tls.Config
+	// Folder is the mailbox to poll.
+	Folder string
+	// ProcessedFlag marks imported messages. Messages without it are polled, so
+	// \Seen works too when the folder is not read by hand.
+	ProcessedFlag string
+	// Interval is the time between polls.
+	Interval time.Duration
+	// Timeout bounds connecting and each IMAP command.
+	Timeout time.Duration
+	// FetchSize is the number of messages downloaded at a time.
+	FetchSize int
+	// TenantID is the tenant receipts are imported for.
+	TenantID string
+}
+
+// DefaultOptions returns the options used for settings left empty.
+func DefaultOptions() Options {
+	return Options{
+		TLS:           TLSImplicit,
+		Folder:        "INBOX",
+		ProcessedFlag: DefaultProcessedFlag,
+		Interval:      5 * time.Minute,
+		Timeout:       time.Minute,
+		FetchSize:     20,
+		TenantID:      models.DefaultTenantID,
+	}
+}
+
+// Result summarizes one poll.
+type Result struct {
+	// Imported receipts were processed into orders.
+	Imported int
+	// Skipped messages were not receipts, or could not be parsed.
+	Skipped int
+	// Rejected receipts failed order validation.
+	Rejected int
+	// Deferred receipts were left unmarked to be retried on the next poll.
+	Deferred int
+}
+
+// Poller imports receipts from an IMAP folder. Each message is marked with the
+// processed flag once it is handled, whether it was imported, rejected, or not a
+// receipt at all, so it is only read once; messages whose orders could not be
+// stored are left unmarked and retried.
+type Poller struct {
+	opts   Options
+	ingest IngestFunc
+
+	// mu serializes polls so a slow poll is never overlapped by the next.
+	mu sync.Mutex
+}
+
+// New creates a poller that passes each receipt's order to ingest.
+func New(opts Options, ingest IngestFunc) *Poller {
+	defaults := DefaultOptions()
+	if opts.TLS == "" {
+		opts.TLS = defaults.TLS
+	}
+	if opts.Folder == "" {
+		opts.Folder = defaults.Folder
+	}
+	if opts.ProcessedFlag == "" {
+		opts.ProcessedFlag = defaults.ProcessedFlag
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.FetchSize <= 0 {
+		opts.FetchSize = defaults.FetchSize
+	}
+	if opts.TenantID == "" {
+		opts.TenantID = defaults.TenantID
+	}
+	return &Poller{opts: opts, ingest: ingest}
+}
+
+// Run polls immediately and then every Interval until ctx is done. Failed polls
+// are logged and retried at the next interval.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := p.Poll(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			slog.Error("Mailbox poll failed", "folder", p.opts.Folder, "error", err)
+		case result != (Result{}):
+			slog.Info("Mailbox polled", "folder", p.opts.Folder, "imported", result.Imported,
+				"skipped", result.Skipped, "rejected", result.Rejected, "deferred", result.Deferred)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll imports the messages in the folder that do not have the processed flag.
+func (p *Poller) Poll(ctx context.Context) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, err := p.connect()
+	if err != nil {
+		return Result{}, err
+	}
+	// Closing the connection interrupts a command in progress when ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = c.Terminate() })
+	defer stop()
+	defer func() { _ = c.Logout() }()
+
+	if _, err := c.Select(p.opts.Folder, false); err != nil {
+		return Result{}, fmt.Errorf("select %q: %w", p.opts.Folder, err)
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{p.opts.ProcessedFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return Result{}, fmt.Errorf("search %q: %w", p.opts.Folder, err)
+	}
+
+	var result Result
+	for len(uids) > 0 {
+		n := min(len(uids), p.opts.FetchSize)
+		if err := p.importMessages(ctx, c, uids[:n], &result); err != nil {
+			return result, err
+		}
+		uids = uids[n:]
+	}
+	return result, nil
+}
+
+func (p *Poller) connect() (*client.Client, error) {
+	dialer := &net.Dialer{Timeout: p.opts.Timeout}
+	tlsConfig := p.opts.TLSConfig
+	if tlsConfig == nil {
+		host, _, _ := net.SplitHostPort(p.opts.Addr)
+		tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+
+	var c *client.Client
+	var err error
+	switch p.opts.TLS {
+	case TLSImplicit:
+		c, err = client.DialWithDialerTLS(dialer, p.opts.Addr, tlsConfig)
+	case TLSStartTLS, TLSNone:
+		c, err = client.DialWithDialer(dialer, p.opts.Addr)
+	default:
+		return nil, fmt.Errorf("unknown TLS mode %q", p.opts.TLS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", p.opts.Addr, err)
+	}
+	c.Timeout = p.opts.Timeout
+	c.ErrorLog = slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug)
+
+	if p.opts.TLS == TLSStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			_ = c.Logout()
+			return nil, fmt.Errorf("start TLS: %w", err)
+		}
+	}
+	if err := c.Login(p.opts.Username, p.opts.Password); err != nil {
+		_ = c.Logout()
+		return nil, fmt.Errorf("log in as %s: %w", p.opts.Username, err)
+	}
+	return c, nil
+}
+
+// importMessages downloads and imports a chunk of messages, then marks the ones
+// that were handled. Bodies are fetched with BODY.PEEK so \Seen is left alone.
+func (p *Poller) importMessages(ctx context.Context, c *client.Client, uids []uint32, result *Result) error {
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+
+	messages := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(set, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages); err != nil {
+		return fmt.Errorf("fetch messages: %w", err)
+	}
+
+	done := new(imap.SeqSet)
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		if p.importMessage(ctx, msg.Uid, body, result) {
+			done.AddNum(msg.Uid)
+		}
+	}
+	if done.Empty() {
+		return nil
+	}
+
+	flags := []interface{}{p.opts.ProcessedFlag}
+	if err := c.UidStore(done, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+		return fmt.Errorf("mark messages processed: %w", err)
+	}
+	return nil
+}
+
+// importMessage imports one message and reports whether it should be marked
+// processed.
+func (p *Poller) importMessage(ctx context.Context, uid uint32, body imap.Literal, result *Result) bool {
+	order, err := importer.ReadReceipt(body)
+	if err != nil {
+		result.Skipped++
+		level := slog.LevelWarn
+		if errors.Is(err, importer.ErrNoReceipt) {
+			level = slog.LevelInfo
+		}
+		slog.Log(ctx, level, "Skipped message", "folder", p.opts.Folder, "uid", uid, "reason", err)
+		return true
+	}
+
+	res, err := p.ingest(ctx, p.opts.TenantID, *order)
+	switch {
+	case err != nil:
+		result.Deferred++
+		slog.Warn("Receipt import deferred", "order_number", order.OrderNumber, "uid", uid, "error", err)
+		return false
+	case !res.Success:
+		result.Rejected++
+		slog.Warn("Receipt rejected", "order_number", order.OrderNumber, "uid", uid, "error", res.Error)
+		return true
+	default:
+		result.Imported++
+		return true
+	}
+}
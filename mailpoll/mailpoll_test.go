@@ -0,0 +1,289 @@
+package mailpoll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"monarchmoney-sync-backend/models"
+)
+
+const testFolder = "Walmart Receipts"
+
+// processed is the processed flag as the server reports it; keywords are
+// case-insensitive and the stub lowercases them.
+var processed = imap.CanonicalFlag(DefaultProcessedFlag)
+
+// stubServer is an in-process IMAP server with an empty receipts folder. The
+// memory backend accepts the user "username" with the password "password".
+type stubServer struct {
+	addr    string
+	mailbox backend.Mailbox
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	require.NoError(t, err)
+	require.NoError(t, user.CreateMailbox(testFolder))
+	mailbox, err := user.GetMailbox(testFolder)
+	require.NoError(t, err)
+
+	srv := server.New(be)
+	srv.AllowInsecureAuth = true
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return &stubServer{addr: listener.Addr().String(), mailbox: mailbox}
+}
+
+// deliver appends a fixture from testdata/receipts to the folder.
+func (s *stubServer) deliver(t *testing.T, fixture string, flags ...string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "receipts", fixture))
+	require.NoError(t, err)
+	require.NoError(t, s.mailbox.CreateMessage(flags, time.Now(), bytes.NewBuffer(data)))
+}
+
+// flags returns each message's flags in folder order.
+func (s *stubServer) flags(t *testing.T) [][]string {
+	t.Helper()
+	set, _ := imap.ParseSeqSet("1:*")
+	messages := make(chan *imap.Message, 10)
+	require.NoError(t, s.mailbox.ListMessages(false, set, []imap.FetchItem{imap.FetchFlags}, messages))
+	var flags [][]string
+	for msg := range messages {
+		flags = append(flags, msg.Flags)
+	}
+	return flags
+}
+
+// recorder is an IngestFunc that records orders and can fail them.
+type recorder struct {
+	mu     sync.Mutex
+	orders []models.Order
+	reject map[string]bool
+	err    error
+}
+
+func (r *recorder) ingest(_ context.Context, tenantID string, order models.Order) (models.BatchOrderResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return models.BatchOrderResult{OrderNumber: order.OrderNumber, Error: r.err.Error()}, r.err
+	}
+	if r.reject[order.OrderNumber] {
+		return models.BatchOrderResult{OrderNumber: order.OrderNumber, Error: "invalid"}, nil
+	}
+	r.orders = append(r.orders, order)
+	return models.BatchOrderResult{OrderNumber: order.OrderNumber, Success: true}, nil
+}
+
+func (r *recorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *recorder) numbers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var numbers []string
+	for _, o := range r.orders {
+		numbers = append(numbers, o.OrderNumber)
+	}
+	return numbers
+}
+
+func newTestPoller(srv *stubServer, rec *recorder) *Poller {
+	return New(Options{
+		Addr:     srv.addr,
+		Username: "username",
+		Password: "password",
+		TLS:      TLSNone,
+		Folder:   testFolder,
+		Timeout:  5 * time.Second,
+	}, rec.ingest)
+}
+
+func TestPoller_ImportsAndMarksMessages(t *testing.T) {
+	// Arrange
+	srv := newStubServer(t)
+	srv.deliver(t, "online_order.eml")
+	srv.deliver(t, "shipping_notice.eml")
+	srv.deliver(t, "walmart_pay.eml", imap.SeenFlag)
+	rec := &recorder{}
+	poller := newTestPoller(srv, rec)
+
+	// Act
+	first, err := poller.Poll(context.Background())
+	require.NoError(t, err)
+	second, err := poller.Poll(context.Background())
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, Result{Imported: 2, Skipped: 1}, first)
+	assert.Equal(t, Result{}, second, "processed messages are not read again")
+	assert.Equal(t, []string{"200012345678901", "12345678901234567890"}, rec.numbers())
+
+	flags := srv.flags(t)
+	require.Len(t, flags, 3)
+	assert.ElementsMatch(t, []string{processed}, flags[0], "fetching must not set \\Seen")
+	assert.ElementsMatch(t, []string{processed}, flags[1])
+	assert.ElementsMatch(t, []string{imap.SeenFlag, processed}, flags[2])
+}
+
+func TestPoller_RejectedReceiptsAreMarked(t *testing.T) {
+	// Arrange
+	srv := newStubServer(t)
+	srv.deliver(t, "online_order.eml")
+	rec := &recorder{reject: map[string]bool{"200012345678901": true}}
+	poller := newTestPoller(srv, rec)
+
+	// Act
+	result, err := poller.Poll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, Result{Rejected: 1}, result)
+	assert.Equal(t, [][]string{{processed}}, srv.flags(t))
+}
+
+func TestPoller_DefersOrdersThatCannotBeStored(t *testing.T) {
+	// Arrange
+	srv := newStubServer(t)
+	srv.deliver(t, "online_order.eml")
+	srv.deliver(t, "delivery_latin1.eml")
+	rec := &recorder{err: errors.New("database is down")}
+	poller := newTestPoller(srv, rec)
+
+	// Act
+	deferred, err := poller.Poll(context.Background())
+	require.NoError(t, err)
+	rec.setErr(nil)
+	retried, err := poller.Poll(context.Background())
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, Result{Deferred: 2}, deferred)
+	assert.Equal(t, Result{Imported: 2}, retried)
+	assert.Equal(t, []string{"200012345678901", "2000987654321"}, rec.numbers())
+}
+
+func TestPoller_FetchesInChunks(t *testing.T) {
+	// Arrange
+	srv := newStubServer(t)
+	for i := 0; i < 5; i++ {
+		srv.deliver(t, "walmart_pay.eml")
+	}
+	rec := &recorder{}
+	poller := newTestPoller(srv, rec)
+	poller.opts.FetchSize = 2
+
+	// Act
+	result, err := poller.Poll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, Result{Imported: 5}, result)
+	for _, flags := range srv.flags(t) {
+		assert.Equal(t, []string{processed}, flags)
+	}
+}
+
+func TestPoller_CustomProcessedFlag(t *testing.T) {
+	// Arrange: use \Seen, as for a folder nobody reads by hand
+	srv := newStubServer(t)
+	srv.deliver(t, "online_order.eml", imap.SeenFlag)
+	srv.deliver(t, "walmart_pay.eml")
+	rec := &recorder{}
+	poller := newTestPoller(srv, rec)
+	poller.opts.ProcessedFlag = imap.SeenFlag
+
+	// Act
+	result, err := poller.Poll(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, Result{Imported: 1}, result)
+	assert.Equal(t, []string{"12345678901234567890"}, rec.numbers())
+}
+
+func TestPoller_Errors(t *testing.T) {
+	srv := newStubServer(t)
+
+	t.Run("bad password", func(t *testing.T) {
+		poller := newTestPoller(srv, &recorder{})
+		poller.opts.Password = "wrong"
+		_, err := poller.Poll(context.Background())
+		assert.ErrorContains(t, err, "log in as username")
+	})
+
+	t.Run("missing folder", func(t *testing.T) {
+		poller := newTestPoller(srv, &recorder{})
+		poller.opts.Folder = "Nope"
+		_, err := poller.Poll(context.Background())
+		assert.ErrorContains(t, err, `select "Nope"`)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		poller := newTestPoller(srv, &recorder{})
+		poller.opts.Addr = addr
+		_, err = poller.Poll(context.Background())
+		assert.ErrorContains(t, err, "connect to "+addr)
+	})
+}
+
+func TestPoller_RunPollsUntilCancelled(t *testing.T) {
+	// Arrange: the first poll defers the receipt, so it is imported by a later one.
+	// The memory backend is not safe for concurrent use, so nothing is delivered
+	// while Run is polling.
+	srv := newStubServer(t)
+	srv.deliver(t, "online_order.eml")
+	rec := &recorder{err: errors.New("database is down")}
+	poller := newTestPoller(srv, rec)
+	poller.opts.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Act
+	stopped := make(chan struct{})
+	go func() {
+		poller.Run(ctx)
+		close(stopped)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	rec.setErr(nil)
+	require.Eventually(t, func() bool { return len(rec.numbers()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	// Assert
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
@@ -2,43 +2,101 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"monarchmoney-sync-backend/auth"
 	"monarchmoney-sync-backend/config"
 	"monarchmoney-sync-backend/handlers"
+	"monarchmoney-sync-backend/health"
+	"monarchmoney-sync-backend/jobs"
+	"monarchmoney-sync-backend/logging"
+	"monarchmoney-sync-backend/mailpoll"
+	"monarchmoney-sync-backend/metrics"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/monarch"
+	"monarchmoney-sync-backend/ratelimit"
+	"monarchmoney-sync-backend/scrub"
+	"monarchmoney-sync-backend/store"
+	"monarchmoney-sync-backend/store/sqlstore"
+	"monarchmoney-sync-backend/tracing"
+	"monarchmoney-sync-backend/vault"
+	"monarchmoney-sync-backend/webhooks"
 
 	"github.com/getsentry/sentry-go"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
+	os.Exit(run(os.Args[1:]))
+}
+
+// runServe runs the HTTP server until it is shut down by a signal.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Load and validate configuration
+	cfg, err := loadConfig(*configFile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	// Set up structured logging
+	logger, err := newLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		return 1
+	}
+	slog.SetDefault(logger)
 
 	// Initialize Sentry if DSN is provided
 	if cfg.IsSentryEnabled() {
-		if err := sentry.Init(sentry.ClientOptions{
-			Dsn:              cfg.SentryDSN,
-			TracesSampleRate: 1.0,
-			Environment:      cfg.GinMode,
-			BeforeSend: func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
-				// Filter out sensitive information
-				if event.Request != nil {
-					event.Request.Headers = nil
-					event.Request.Cookies = ""
-				}
-				return event
-			},
-		}); err != nil {
-			log.Printf("Sentry initialization failed: %v\n", err)
+		if err := initSentry(cfg); err != nil {
+			slog.Error("Sentry initialization failed", "error", err)
 		} else {
 			defer sentry.Flush(2 * time.Second)
-			log.Println("Sentry error tracking initialized")
+			slog.Info("Sentry error tracking initialized")
 		}
 	}
 
+	// Set up tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+		Environment: cfg.GinMode,
+	})
+	if err != nil {
+		return failed("Failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("Failed to flush traces", "error", err)
+		}
+	}()
+
+	handlers.SetSentryOrderMessages(cfg.SentryOrderMessages)
+
 	// Set Gin mode
 	if cfg.GinMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -46,26 +104,611 @@ func main() {
 		gin.SetMode(gin.DebugMode)
 	}
 
+	// Open the database and bring its schema up to date
+	database, err := openDatabase(context.Background(), cfg)
+	if err != nil {
+		return failed("Failed to open database", err)
+	}
+	if database != nil {
+		defer database.Close()
+	}
+
+	// Set up tenants and order storage
+	db, err := newStore(cfg, database)
+	if err != nil {
+		return failed("Failed to load tenants", err)
+	}
+	handlers.SetOrderStore(store.TraceOrders(db))
+	handlers.SetRefundStore(store.TraceRefunds(db))
+	handlers.SetJobQueue(db)
+	handlers.SetAuditLog(db)
+
+	// Set up webhook delivery
+	webhookOpts := webhooks.DefaultOptions()
+	webhookOpts.AllowPrivateNetworks = cfg.WebhookAllowPrivateNetworks
+	dispatcher := webhooks.NewDispatcher(webhookOpts)
+	defer dispatcher.Close()
+	if cfg.IsWebhookConfigured() {
+		events := make([]webhooks.EventType, 0, len(cfg.WebhookEvents))
+		for _, e := range cfg.WebhookEvents {
+			events = append(events, webhooks.EventType(e))
+		}
+		if _, err := dispatcher.SubscribeConfigured(models.DefaultTenantID, cfg.WebhookURL, cfg.WebhookSecret, events); err != nil {
+			slog.Warn("Webhook subscription from config ignored", "error", err)
+		}
+	}
+	handlers.SetEventPublisher(dispatcher)
+
+	// Set up metrics
+	appMetrics := metrics.New()
+	appMetrics.RegisterQueue("webhooks", dispatcher.QueueDepth)
+	handlers.SetMetrics(appMetrics)
+
+	// Bound batch processing
+	handlers.SetBatchLimits(handlers.BatchLimits{
+		MaxOrders: cfg.BatchMaxOrders,
+		Workers:   cfg.BatchWorkers,
+	})
+
+	// Set up API keys
+	keyring, err := newKeyring(cfg)
+	if err != nil {
+		return failed("Failed to load API keys", err)
+	}
+
+	// Set up the credential vault
+	credentials, err := newVault(cfg)
+	if err != nil {
+		return failed("Failed to open credential vault", err)
+	}
+	keyring.SetSigningSecrets(signingSecrets{credentials})
+
+	// Match refunds to Monarch transactions with each tenant's stored token
+	if cfg.MonarchMatchRefunds {
+		handlers.SetTransactions(newMonarchClient(cfg, credentials))
+	} else {
+		slog.Warn("MONARCH_MATCH_REFUNDS is off, refunds will be stored unmatched")
+	}
+	if cfg.APIKeysFile != "" && cfg.CredentialsFile == "" {
+		slog.Warn("CREDENTIALS_FILE not set, API keys will need rotating to sign requests after a restart")
+	}
+
+	// Load stored webhook subscriptions, then queue the events they missed
+	if cfg.WebhookSubscriptionsFile != "" {
+		subs, err := webhooks.NewFileStore(cfg.WebhookSubscriptionsFile, webhookSecrets{credentials})
+		if err == nil {
+			err = dispatcher.UseStore(subs)
+		}
+		if err != nil {
+			return failed("Failed to load webhook subscriptions", err)
+		}
+	}
+	if cfg.WebhookCheckpointFile != "" {
+		restored, err := dispatcher.RestoreCheckpoint(cfg.WebhookCheckpointFile)
+		if err != nil {
+			slog.Warn("Failed to restore checkpointed webhook events", "error", err)
+		} else if restored > 0 {
+			slog.Info("Restored checkpointed webhook events", "count", restored)
+		}
+	}
+
+	// Set up rate limiting
+	limits, err := newRateLimits(cfg)
+	if err != nil {
+		return failed("Invalid rate limit configuration", err)
+	}
+
+	// Set up readiness checks
+	checks, err := newHealthChecks(cfg, db, dispatcher, credentials)
+	if err != nil {
+		return failed("Invalid health check configuration", err)
+	}
+
 	// Create router with config
-	router := setupRouter(cfg)
+	router := setupRouter(cfg, &services{
+		dispatcher: dispatcher,
+		keyring:    keyring,
+		tenants:    store.TraceTenants(db),
+		vault:      credentials,
+		metrics:    appMetrics,
+		health:     checks,
+		limiter:    ratelimit.NewMemoryBackend(),
+		limits:     limits,
+	})
+
+	// Rotate secrets on SIGHUP
+	go reloadSecrets(*configFile, cfg, keyring, credentials)
+
+	// Import email receipts from an IMAP folder
+	if cfg.IsIMAPConfigured() {
+		if _, err := db.GetTenant(context.Background(), cfg.IMAPTenant); err != nil {
+			return failed("Invalid IMAP_TENANT", fmt.Errorf("tenant %q: %w", cfg.IMAPTenant, err))
+		}
+		pollCtx, stopPolling := context.WithCancel(context.Background())
+		defer stopPolling()
+		go newMailPoller(cfg).Run(pollCtx)
+		slog.Info("Polling IMAP folder for receipts", "addr", cfg.IMAPAddr, "folder", cfg.IMAPFolder, "interval", cfg.IMAPPollInterval)
+	}
+
+	// Run background jobs, such as matching refunds whose transactions post late.
+	// Every replica claims from the same queue.
+	runner := jobs.NewRunner(db, jobs.DefaultOptions())
+	runner.Handle(handlers.RefundMatchJob, handlers.MatchRefundJob)
+	go runner.Run(context.Background())
 
-	// Start server
-	log.Printf("Starting Walmart-Monarch Sync Backend on port %s\n", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
+	// Start server; returns after a graceful shutdown so deferred flushes run
+	if err := serve(cfg, router, dispatcher, runner); err != nil {
 		sentry.CaptureException(err)
-		log.Fatalf("Failed to start server: %v", err)
+		return failed("Failed to start server", err)
+	}
+	return 0
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then stops accepting
+// connections and drains in-flight requests, the running background job, and
+// webhook deliveries within SHUTDOWN_TIMEOUT. Deliveries still pending are
+// checkpointed when configured; unfinished jobs stay in the job store.
+func serve(cfg *config.Config, handler http.Handler, dispatcher *webhooks.Dispatcher, runner *jobs.Runner) error {
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: handler,
+		// Body and response deadlines are set per route by handlers.Deadlines, so
+		// streaming imports can outlast them
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Starting Walmart-Monarch Sync Backend", "port", cfg.Port)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills the process without waiting
+	stop()
+
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests did not finish before the shutdown deadline", "error", err)
+		_ = srv.Close()
+	}
+
+	if err := runner.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Background job did not finish before the shutdown deadline; another worker will retry it", "error", err)
+	}
+
+	pending := dispatcher.Shutdown(shutdownCtx)
+	switch {
+	case len(pending) == 0:
+	case cfg.WebhookCheckpointFile == "":
+		slog.Warn("Undelivered webhook events dropped; set WEBHOOK_CHECKPOINT_FILE to keep them", "count", len(pending))
+	default:
+		if err := webhooks.SaveCheckpoint(cfg.WebhookCheckpointFile, pending); err != nil {
+			slog.Error("Failed to checkpoint undelivered webhook events", "count", len(pending), "error", err)
+		} else {
+			slog.Info("Checkpointed undelivered webhook events", "count", len(pending), "path", cfg.WebhookCheckpointFile)
+		}
+	}
+
+	slog.Info("Server stopped")
+	return nil
+}
+
+// initSentry configures the global Sentry client from cfg.
+func initSentry(cfg *config.Config) error {
+	scrubOptions := scrub.Options{AllowOrderDetails: cfg.SentrySendOrderDetails}
+	return sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.SentryDSN,
+		SampleRate:       cfg.SentrySampleRate,
+		EnableTracing:    cfg.SentryTracesSampleRate > 0,
+		TracesSampleRate: cfg.SentryTracesSampleRate,
+		Environment:      cfg.GinMode,
+		// Remove headers, cookies, and bodies, plus item names, product URLs,
+		// and amounts unless explicitly allowed
+		BeforeSend:            scrub.BeforeSend(scrubOptions),
+		BeforeSendTransaction: scrub.BeforeSend(scrubOptions),
+	})
+}
+
+// newLogger creates the application logger from the configured level and format.
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, level)
+	if err != nil {
+		return nil, fmt.Errorf("LOG_FORMAT: %w", err)
+	}
+	return logger, nil
+}
+
+// failed logs why the server could not start or keep running and returns the exit
+// code, so runServe's deferred cleanup still runs.
+func failed(msg string, err error) int {
+	slog.Error(msg, "error", err)
+	return 1
+}
+
+// services holds the long-lived components shared by request handlers.
+type services struct {
+	dispatcher *webhooks.Dispatcher
+	keyring    *auth.Keyring
+	tenants    store.TenantStore
+	vault      *vault.Vault
+	metrics    *metrics.Metrics
+	health     *health.Checks
+	limiter    ratelimit.Backend
+	limits     rateLimits
+}
+
+// rateLimits are the parsed rate limits for each route group.
+type rateLimits struct {
+	ip     ratelimit.Limit
+	key    ratelimit.Limit
+	ingest ratelimit.Limit
+	admin  ratelimit.Limit
+}
+
+// newRateLimits parses the configured rate limits.
+func newRateLimits(cfg *config.Config) (rateLimits, error) {
+	var limits rateLimits
+	for _, l := range []struct {
+		name  string
+		value string
+		dst   *ratelimit.Limit
+	}{
+		{"RATE_LIMIT_IP", cfg.RateLimitIP, &limits.ip},
+		{"RATE_LIMIT_KEY", cfg.RateLimitKey, &limits.key},
+		{"RATE_LIMIT_INGEST", cfg.RateLimitIngest, &limits.ingest},
+		{"RATE_LIMIT_ADMIN", cfg.RateLimitAdmin, &limits.admin},
+	} {
+		parsed, err := ratelimit.ParseLimit(l.value)
+		if err != nil {
+			return rateLimits{}, fmt.Errorf("%s: %w", l.name, err)
+		}
+		*l.dst = parsed
+	}
+	return limits, nil
+}
+
+// openDatabase connects to DATABASE_URL, returning nil when it is not set, and
+// applies pending migrations unless DATABASE_AUTO_MIGRATE is off. It refuses a
+// schema newer than this binary, or an outdated one that it may not migrate.
+func openDatabase(ctx context.Context, cfg *config.Config) (*sqlstore.DB, error) {
+	if cfg.DatabaseURL == "" {
+		return nil, nil
+	}
+	db, err := sqlstore.Open(cfg.DatabaseURL, databaseOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	m, err := sqlstore.NewMigrator(db)
+	if err == nil {
+		if cfg.DatabaseAutoMigrate {
+			err = migrateDatabase(ctx, m)
+		} else {
+			err = m.Check(ctx)
+		}
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	slog.Info("Database ready", "dialect", db.Dialect(), "schema_version", m.Latest())
+	return db, nil
+}
+
+func migrateDatabase(ctx context.Context, m *sqlstore.Migrator) error {
+	applied, err := m.Up(ctx)
+	for _, mig := range applied {
+		slog.Info("Applied database migration", "version", mig.Version, "name", mig.Name)
+	}
+	return err
+}
+
+// databaseOptions returns the connection pool settings from cfg.
+func databaseOptions(cfg *config.Config) sqlstore.Options {
+	return sqlstore.Options{
+		MaxOpenConns:    cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:    cfg.DatabaseMaxIdleConns,
+		ConnMaxLifetime: cfg.DatabaseConnMaxLifetime,
+	}
+}
+
+// dataStore persists tenants and their orders and refunds, the background job
+// queue, and the audit log.
+type dataStore interface {
+	store.OrderStore
+	store.RefundStore
+	store.TenantStore
+	store.JobStore
+	store.AuditStore
+	store.Pinger
+}
+
+// newStore creates the tenant and order store: the database when one is open,
+// otherwise memory, persisting tenants to a file when TENANTS_FILE is set. With a
+// database, tenants from TENANTS_FILE are imported once.
+func newStore(cfg *config.Config, database *sqlstore.DB) (dataStore, error) {
+	ctx := context.Background()
+	var db dataStore
+	if database != nil {
+		sqlDB := sqlstore.New(database)
+		if cfg.TenantsFile != "" {
+			if err := importTenants(ctx, sqlDB, cfg.TenantsFile); err != nil {
+				return nil, err
+			}
+		}
+		db = sqlDB
+	} else {
+		mem := store.NewMemory()
+		if cfg.TenantsFile != "" {
+			if err := mem.LoadTenants(cfg.TenantsFile); err != nil {
+				return nil, err
+			}
+		}
+		db = mem
+	}
+
+	err := store.EnsureTenant(ctx, db, &models.Tenant{
+		ID:   models.DefaultTenantID,
+		Name: "Default household",
+	})
+	return db, err
+}
+
+// importTenants adds the tenants in a TENANTS_FILE that the store does not have.
+func importTenants(ctx context.Context, s store.TenantStore, path string) error {
+	file := store.NewMemory()
+	if err := file.LoadTenants(path); err != nil {
+		return err
+	}
+	tenants, err := file.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		if err := store.EnsureTenant(ctx, s, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookSecrets seals webhook subscription secrets in the credential vault.
+type webhookSecrets struct {
+	vault *vault.Vault
+}
+
+func (s webhookSecrets) SetSecret(tenantID, subscriptionID, secret string) error {
+	_, err := s.vault.Set(tenantID, vault.WebhookSecretName(subscriptionID), secret)
+	return err
+}
+
+func (s webhookSecrets) Secret(tenantID, subscriptionID string) (string, error) {
+	return s.vault.Get(tenantID, vault.WebhookSecretName(subscriptionID))
+}
+
+func (s webhookSecrets) DeleteSecret(tenantID, subscriptionID string) error {
+	err := s.vault.Delete(tenantID, vault.WebhookSecretName(subscriptionID))
+	if errors.Is(err, vault.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// signingSecrets seals the secrets signed requests are verified with in the
+// credential vault.
+type signingSecrets struct {
+	vault *vault.Vault
+}
+
+func (s signingSecrets) SetSigningSecret(tenantID, keyID, secret string) error {
+	_, err := s.vault.Set(tenantID, vault.SigningSecretName(keyID), secret)
+	return err
+}
+
+func (s signingSecrets) SigningSecret(tenantID, keyID string) (string, error) {
+	return s.vault.Get(tenantID, vault.SigningSecretName(keyID))
+}
+
+// newVault opens the credential vault. Credentials sealed with a previous master
+// key are re-encrypted under the current one, and plaintext credentials still set
+// in the environment are imported into the default tenant.
+func newVault(cfg *config.Config) (*vault.Vault, error) {
+	encoded := cfg.VaultMasterKey
+	if cfg.VaultMasterKeyFile != "" {
+		data, err := os.ReadFile(cfg.VaultMasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read master key: %w", err)
+		}
+		encoded = string(data)
+	}
+
+	var current *vault.MasterKey
+	var err error
+	switch {
+	case encoded != "":
+		if current, err = vault.ParseMasterKey(encoded); err != nil {
+			return nil, fmt.Errorf("VAULT_MASTER_KEY: %w", err)
+		}
+	case cfg.CredentialsFile != "":
+		return nil, fmt.Errorf("CREDENTIALS_FILE requires VAULT_MASTER_KEY or VAULT_MASTER_KEY_FILE")
+	default:
+		slog.Warn("VAULT_MASTER_KEY not set, stored credentials will not survive a restart")
+		if current, err = vault.GenerateMasterKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := make([]*vault.MasterKey, 0, len(cfg.VaultPreviousKeys))
+	for i, encoded := range cfg.VaultPreviousKeys {
+		key, err := vault.ParseMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_PREVIOUS_MASTER_KEYS[%d]: %w", i, err)
+		}
+		previous = append(previous, key)
+	}
+
+	var credStore vault.Store = vault.NewMemoryStore()
+	if cfg.CredentialsFile != "" {
+		fileStore, err := vault.NewFileStore(cfg.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		credStore = fileStore
+	}
+
+	v := vault.New(credStore, vault.NewKeys(current, previous...))
+	if len(previous) > 0 {
+		rotated, err := v.Rotate()
+		if err != nil {
+			return nil, fmt.Errorf("rotate master key: %w", err)
+		}
+		slog.Info("Re-encrypted credentials under the current master key", "count", rotated, "key_id", current.ID())
+	}
+
+	for name, value := range map[string]string{
+		vault.MonarchToken: cfg.MonarchAPIKey,
+		vault.OpenAIAPIKey: cfg.OpenAIAPIKey,
+		vault.ClaudeAPIKey: cfg.ClaudeAPIKey,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := v.Get(models.DefaultTenantID, name); !errors.Is(err, vault.ErrNotFound) {
+			continue
+		}
+		if _, err := v.Set(models.DefaultTenantID, name, value); err != nil {
+			return nil, fmt.Errorf("import %s: %w", name, err)
+		}
+		slog.Info("Imported credential from the environment into the vault", "name", name)
+	}
+
+	return v, nil
+}
+
+// newMonarchClient creates the Monarch client that refunds are matched and split
+// through, calling Monarch with each tenant's token from the vault.
+func newMonarchClient(cfg *config.Config, v *vault.Vault) *monarch.Client {
+	token := func(_ context.Context, tenantID string) (string, error) {
+		value, err := v.Get(tenantID, vault.MonarchToken)
+		if errors.Is(err, vault.ErrNotFound) {
+			return "", monarch.ErrNoToken
+		}
+		return value, err
+	}
+	return monarch.New(&http.Client{Timeout: cfg.MonarchTimeout}, cfg.MonarchGraphQLURL, token)
+}
+
+// newHealthChecks sets up the readiness checks: the store, the webhook queue, the
+// configured LLM provider, and the default tenant's Monarch token. Checks that call
+// external services are cached so frequent probes do not hit their APIs.
+func newHealthChecks(cfg *config.Config, db store.Pinger, dispatcher *webhooks.Dispatcher, v *vault.Vault) (*health.Checks, error) {
+	client := &http.Client{Timeout: cfg.HealthCheckTimeout}
+	secret := func(name string) health.Secret {
+		return func(context.Context) (string, error) {
+			value, err := v.Get(models.DefaultTenantID, name)
+			if errors.Is(err, vault.ErrNotFound) {
+				return "", health.ErrNotConfigured
+			}
+			return value, err
+		}
+	}
+
+	var llm health.Checker
+	switch cfg.LLMProvider {
+	case "":
+		llm = health.CheckFunc(func(context.Context) error { return health.ErrNotConfigured })
+	case config.LLMProviderOllama:
+		llm = health.Ollama(client, cfg.OllamaEndpoint)
+	case config.LLMProviderOpenAI:
+		llm = health.OpenAI(client, health.OpenAIBaseURL, secret(vault.OpenAIAPIKey))
+	case config.LLMProviderClaude:
+		llm = health.Anthropic(client, health.AnthropicBaseURL, secret(vault.ClaudeAPIKey))
+	default:
+		return nil, fmt.Errorf("LLM_PROVIDER: unknown provider %q (want ollama, openai, or claude)", cfg.LLMProvider)
+	}
+
+	checks := health.NewChecks(cfg.HealthCheckTimeout)
+	checks.Add("database", health.CheckFunc(db.Ping))
+	checks.Add("queue", health.CheckFunc(dispatcher.Check))
+	checks.Add("llm", health.Cached(llm, cfg.HealthCheckCacheTTL))
+	checks.Add("monarch", health.Cached(
+		health.MonarchToken(client, cfg.MonarchGraphQLURL, secret(vault.MonarchToken)),
+		cfg.HealthCheckCacheTTL))
+	return checks, nil
+}
+
+// newMailPoller creates the IMAP receipt poller, which processes orders exactly
+// like the API does.
+func newMailPoller(cfg *config.Config) *mailpoll.Poller {
+	return mailpoll.New(mailpoll.Options{
+		Addr:          cfg.IMAPAddr,
+		Username:      cfg.IMAPUsername,
+		Password:      cfg.IMAPPassword,
+		TLS:           cfg.IMAPTLS,
+		Folder:        cfg.IMAPFolder,
+		ProcessedFlag: cfg.IMAPProcessedFlag,
+		Interval:      cfg.IMAPPollInterval,
+		TenantID:      cfg.IMAPTenant,
+	}, handlers.ProcessOrder)
+}
+
+// newKeyring creates the API keyring, persisting keys to a file when configured.
+func newKeyring(cfg *config.Config) (*auth.Keyring, error) {
+	var keyStore auth.Store = auth.NewMemoryStore()
+	if cfg.APIKeysFile != "" {
+		fileStore, err := auth.NewFileStore(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keyStore = fileStore
+	} else {
+		slog.Warn("API_KEYS_FILE not set, issued API keys will not survive a restart")
 	}
+
+	keyring := auth.NewKeyring(keyStore)
+	keyring.SetLegacyKey(cfg.ExtensionKey)
+	keyring.SetSignaturePolicy(auth.SignaturePolicy{
+		Required:     cfg.RequireSignedRequests,
+		MaxSkew:      cfg.SignatureMaxSkew,
+		MaxBodyBytes: cfg.MaxRequestBodyBytes,
+	})
+	return keyring, nil
 }
 
-func setupRouter(cfg *config.Config) *gin.Engine {
+func setupRouter(cfg *config.Config, svc *services) *gin.Engine {
 	router := gin.New()
 
-	// Add logging middleware
-	router.Use(gin.Logger())
+	// Only believe X-Forwarded-For from configured proxies, so clients cannot pick
+	// the IP they are rate limited by
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		slog.Error("Invalid TRUSTED_PROXIES, trusting no proxies", "error", err)
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	// Add recovery middleware that works with Sentry
 	router.Use(gin.Recovery())
 
+	// Bound reading each request and writing its response
+	router.Use(handlers.Deadlines(cfg.ReadTimeout, cfg.WriteTimeout))
+
+	// Record request counts and latencies
+	router.Use(handlers.RequestMetrics(svc.metrics))
+
 	// Add Sentry middleware if enabled
 	if cfg.IsSentryEnabled() {
 		router.Use(sentrygin.New(sentrygin.Options{
@@ -75,19 +718,88 @@ func setupRouter(cfg *config.Config) *gin.Engine {
 		}))
 	}
 
-	// Health check endpoint (no auth required)
+	// Trace each request, linking the trace to Sentry events and log lines
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	router.Use(handlers.LinkTrace())
+
+	// Assign request IDs and log each request; runs after Sentry so the ID is
+	// tagged on the request's hub
+	router.Use(handlers.RequestID())
+	router.Use(handlers.RequestLogger())
+
+	// Health check endpoints (no auth required)
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health/live", handlers.HealthCheck)
+	router.GET("/health/ready", handlers.ReadinessCheck(svc.health))
+
+	// Prometheus metrics (no auth required; restrict at the network level)
+	router.GET("/metrics", handlers.MetricsHandler(svc.metrics))
 
 	// API routes group with authentication
 	api := router.Group("/api")
-	api.Use(handlers.AuthMiddleware())
+	api.Use(handlers.RateLimitByIP(svc.limiter, "api", svc.limits.ip))
+	api.Use(handlers.AuthMiddleware(svc.keyring))
+	api.Use(handlers.RateLimitByKey(svc.limiter, "api", svc.limits.key))
+	bodyLimit := handlers.MaxBodySize(cfg.MaxRequestBodyBytes)
+	ingest := handlers.RequireScope(auth.ScopeIngest)
+	read := handlers.RequireScope(auth.ScopeRead)
 	{
-		// Walmart endpoints
-		walmart := api.Group("/walmart")
+		// Order endpoints for each retailer. The Walmart routes the extension has
+		// always used, such as /api/walmart/orders, are this family's Walmart members.
+		retailer := api.Group("/:retailer", handlers.RequireRetailer(),
+			handlers.RateLimitByKey(svc.limiter, "ingest", svc.limits.ingest))
+		{
+			retailer.POST("/orders", ingest, bodyLimit, handlers.ReceiveOrders)
+			retailer.POST("/orders/batch", ingest, bodyLimit, handlers.ReceiveBatchOrders)
+			retailer.POST("/orders/import", ingest, bodyLimit, handlers.ImportOrdersCSV)
+			retailer.POST("/orders/receipt", ingest, bodyLimit, handlers.ReceiveReceipt)
+			// Streaming import is read line by line, so the body limit does not apply,
+			// and may take longer than other requests
+			retailer.POST("/orders/stream", ingest,
+				handlers.Deadlines(cfg.StreamTimeout, cfg.StreamTimeout), handlers.StreamOrders)
+			retailer.POST("/orders/reprocess", ingest, bodyLimit, handlers.ReprocessOrders)
+			retailer.GET("/orders", read, handlers.ListOrders)
+			retailer.GET("/orders/:orderNumber", read, handlers.GetOrder)
+			retailer.GET("/orders/:orderNumber/refunds", read, handlers.ListOrderRefunds)
+			retailer.POST("/refunds", ingest, bodyLimit, handlers.ReceiveRefund)
+			retailer.GET("/sync-status", read, handlers.GetSyncStatus)
+		}
+
+		// Webhook subscription management
+		admin := handlers.RateLimitByKey(svc.limiter, "admin", svc.limits.admin)
+		hooks := api.Group("/webhooks", handlers.RequireScope(auth.ScopeAdmin), admin)
+		{
+			hooks.GET("", handlers.ListWebhooks(svc.dispatcher))
+			hooks.POST("", bodyLimit, handlers.CreateWebhook(svc.dispatcher))
+			hooks.DELETE("/:id", handlers.DeleteWebhook(svc.dispatcher))
+			hooks.GET("/deliveries", handlers.ListWebhookDeliveries(svc.dispatcher))
+		}
+
+		// API key management
+		keys := api.Group("/keys", handlers.RequireScope(auth.ScopeAdmin), admin)
+		{
+			keys.GET("", handlers.ListAPIKeys(svc.keyring))
+			keys.POST("", bodyLimit, handlers.CreateAPIKey(svc.keyring))
+			keys.POST("/:id/rotate", bodyLimit, handlers.RotateAPIKey(svc.keyring))
+			keys.DELETE("/:id", handlers.RevokeAPIKey(svc.keyring))
+		}
+
+		// Per-tenant Monarch and LLM provider credentials
+		creds := api.Group("/credentials", handlers.RequireScope(auth.ScopeAdmin), admin)
+		{
+			creds.GET("", handlers.ListCredentials(svc.vault))
+			creds.PUT("/:name", bodyLimit, handlers.SetCredential(svc.vault))
+			creds.DELETE("/:name", handlers.DeleteCredential(svc.vault))
+		}
+
+		// Audit log of credential, API key, and transaction changes
+		api.GET("/audit", handlers.RequireScope(auth.ScopeAdmin), admin, handlers.ListAudit)
+
+		// Tenant management, across the whole server
+		tenants := api.Group("/tenants", handlers.RequireScope(auth.ScopeTenants), admin)
 		{
-			walmart.POST("/orders", handlers.ReceiveOrders)
-			walmart.POST("/orders/batch", handlers.ReceiveBatchOrders)
-			walmart.GET("/sync-status", handlers.GetSyncStatus)
+			tenants.GET("", handlers.ListTenants(svc.tenants))
+			tenants.POST("", bodyLimit, handlers.CreateTenant(svc.tenants, svc.keyring))
 		}
 
 		// Test endpoint for Sentry (only in debug mode)
@@ -0,0 +1,189 @@
+// Package metrics exposes Prometheus metrics for the sync backend. A nil *Metrics
+// is valid and records nothing, so handlers work without metrics configured.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "monarch_sync"
+
+// Monarch API call outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Metrics holds every collector exported on /metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	ordersReceived  *prometheus.CounterVec
+	ordersProcessed *prometheus.CounterVec
+	ordersFailed    *prometheus.CounterVec
+	batchSize       prometheus.Histogram
+
+	categorizerDuration *prometheus.HistogramVec
+	categorizerCost     *prometheus.CounterVec
+	monarchCalls        *prometheus.CounterVec
+}
+
+// New creates a registry with the application's collectors plus the standard Go
+// runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route, and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route, and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		ordersReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_received_total",
+			Help:      "Orders received, by source.",
+		}, []string{"source"}),
+		ordersProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_processed_total",
+			Help:      "Orders processed successfully, by source.",
+		}, []string{"source"}),
+		ordersFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_failed_total",
+			Help:      "Orders that failed validation or processing, by source.",
+		}, []string{"source"}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size_orders",
+			Help:      "Number of orders per batch request.",
+			Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		categorizerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "categorizer_request_duration_seconds",
+			Help:      "Categorizer call latency by provider.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"provider"}),
+		categorizerCost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "categorizer_cost_usd_total",
+			Help:      "Estimated categorizer spend in US dollars by provider.",
+		}, []string{"provider"}),
+		monarchCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "monarch_api_calls_total",
+			Help:      "Monarch API calls by operation and outcome.",
+		}, []string{"operation", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.ordersReceived,
+		m.ordersProcessed,
+		m.ordersFailed,
+		m.batchSize,
+		m.categorizerDuration,
+		m.categorizerCost,
+		m.monarchCalls,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterQueue exports the depth of a named queue, read from depth at scrape time.
+func (m *Metrics) RegisterQueue(name string, depth func() int) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "queue_depth",
+		Help:        "Items waiting in a queue.",
+		ConstLabels: prometheus.Labels{"queue": name},
+	}, func() float64 { return float64(depth()) }))
+}
+
+// ObserveRequest records a completed HTTP request. route is the matched route
+// pattern, not the raw path, to keep label cardinality bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// OrderReceived counts an order accepted for processing.
+func (m *Metrics) OrderReceived(source string) {
+	if m != nil {
+		m.ordersReceived.WithLabelValues(source).Inc()
+	}
+}
+
+// OrderProcessed counts an order processed successfully.
+func (m *Metrics) OrderProcessed(source string) {
+	if m != nil {
+		m.ordersProcessed.WithLabelValues(source).Inc()
+	}
+}
+
+// OrderFailed counts an order that failed validation or processing.
+func (m *Metrics) OrderFailed(source string) {
+	if m != nil {
+		m.ordersFailed.WithLabelValues(source).Inc()
+	}
+}
+
+// ObserveBatchSize records the number of orders in a batch request.
+func (m *Metrics) ObserveBatchSize(n int) {
+	if m != nil {
+		m.batchSize.Observe(float64(n))
+	}
+}
+
+// ObserveCategorizer records a categorizer call and its estimated cost.
+func (m *Metrics) ObserveCategorizer(provider string, elapsed time.Duration, costUSD float64) {
+	if m == nil {
+		return
+	}
+	m.categorizerDuration.WithLabelValues(provider).Observe(elapsed.Seconds())
+	if costUSD > 0 {
+		m.categorizerCost.WithLabelValues(provider).Add(costUSD)
+	}
+}
+
+// MonarchCall records the outcome of a Monarch API call.
+func (m *Metrics) MonarchCall(operation string, err error) {
+	if m == nil {
+		return
+	}
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	m.monarchCalls.WithLabelValues(operation, outcome).Inc()
+}
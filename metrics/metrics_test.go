@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Exposition(t *testing.T) {
+	// Arrange
+	m := New()
+	depth := 3
+	m.RegisterQueue("webhooks", func() int { return depth })
+
+	// Act
+	m.ObserveRequest("POST", "/api/walmart/orders", 200, 15*time.Millisecond)
+	m.OrderReceived("walmart")
+	m.OrderProcessed("walmart")
+	m.OrderFailed("walmart")
+	m.ObserveBatchSize(12)
+	m.ObserveCategorizer("openai", 800*time.Millisecond, 0.002)
+	m.MonarchCall("update_transaction", nil)
+	m.MonarchCall("update_transaction", errors.New("boom"))
+
+	// Assert
+	body := scrape(t, m)
+	assert.Contains(t, body, `monarch_sync_http_requests_total{method="POST",route="/api/walmart/orders",status="200"} 1`)
+	assert.Contains(t, body, `monarch_sync_http_request_duration_seconds_count{method="POST",route="/api/walmart/orders",status="200"} 1`)
+	assert.Contains(t, body, `monarch_sync_orders_received_total{source="walmart"} 1`)
+	assert.Contains(t, body, `monarch_sync_orders_processed_total{source="walmart"} 1`)
+	assert.Contains(t, body, `monarch_sync_orders_failed_total{source="walmart"} 1`)
+	assert.Contains(t, body, `monarch_sync_batch_size_orders_sum 12`)
+	assert.Contains(t, body, `monarch_sync_categorizer_cost_usd_total{provider="openai"} 0.002`)
+	assert.Contains(t, body, `monarch_sync_monarch_api_calls_total{operation="update_transaction",outcome="error"} 1`)
+	assert.Contains(t, body, `monarch_sync_queue_depth{queue="webhooks"} 3`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.ObserveRequest("GET", "/health", 200, time.Millisecond)
+		m.OrderReceived("walmart")
+		m.ObserveBatchSize(1)
+		m.RegisterQueue("webhooks", func() int { return 0 })
+	})
+}
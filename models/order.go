@@ -3,8 +3,11 @@ package models
 
 import "time"
 
-// Order represents a Walmart order received from the Chrome extension.
+// Order represents a retailer order received from the Chrome extension or an
+// importer. Retailer is empty for orders sent before other retailers were
+// supported; handlers fill it in from the route.
 type Order struct {
+	Retailer        Retailer    `json:"retailer,omitempty"`
 	OrderNumber     string      `json:"orderNumber" binding:"required"`
 	OrderDate       string      `json:"orderDate" binding:"required"`
 	OrderTotal      *float64    `json:"orderTotal,omitempty"`
@@ -12,15 +15,33 @@ type Order struct {
 	DeliveryCharges *float64    `json:"deliveryCharges,omitempty"`
 	Tip             *float64    `json:"tip,omitempty"`
 	Items           []OrderItem `json:"items,omitempty"`
+	// Shipments are the separate card charges of an order billed as each shipment
+	// ships, as Amazon does. Orders charged once have none.
+	Shipments []Shipment `json:"shipments,omitempty"`
 }
 
-// OrderItem represents an individual item within a Walmart order.
+// OrderItem represents an individual item within an order.
 type OrderItem struct {
 	Name       string  `json:"name" binding:"required"`
 	Price      float64 `json:"price" binding:"required"`
 	Quantity   int     `json:"quantity" binding:"required"`
 	ProductURL string  `json:"productUrl,omitempty"`
 	Category   string  `json:"category,omitempty"`
+	// ShipmentID is the ID of the shipment the item was charged with, if the order
+	// has shipments. Items that have not shipped yet have none.
+	ShipmentID string `json:"shipmentId,omitempty"`
+}
+
+// Shipment is one charge of an order billed per shipment.
+type Shipment struct {
+	// ID identifies the shipment within its order.
+	ID       string `json:"id"`
+	ShipDate string `json:"shipDate,omitempty"`
+	// Tracking is the carrier and tracking number, when the retailer reports them.
+	Tracking string `json:"tracking,omitempty"`
+	// Total is the amount charged for the shipment, including tax and shipping.
+	Total float64  `json:"total"`
+	Tax   *float64 `json:"tax,omitempty"`
 }
 
 // OrderResponse represents the API response after processing an order.
@@ -50,6 +71,7 @@ type BatchOrdersResponse struct {
 
 // BatchOrderResult represents the result of processing a single order in a batch.
 type BatchOrderResult struct {
+	Line         int    `json:"line,omitempty"`
 	OrderNumber  string `json:"orderNumber"`
 	Success      bool   `json:"success"`
 	ProcessingID string `json:"processingId,omitempty"`
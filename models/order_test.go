@@ -145,4 +145,3 @@ func TestBatchOrdersRequest(t *testing.T) {
 	assert.Equal(t, "456", batchRequest.Orders[1].OrderNumber)
 	assert.Equal(t, 75.50, *batchRequest.Orders[1].OrderTotal)
 }
-
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// A refund's transaction is looked for from RefundMatchDaysBefore days before the
+// refund date, since retailers and banks date refunds differently, to
+// RefundMatchDaysAfter days after it, since card refunds can take a week to post.
+const (
+	RefundMatchDaysBefore = 2
+	RefundMatchDaysAfter  = 10
+)
+
+// Refund is money a retailer paid back for items returned from an order. It goes
+// back to the card the order was charged to, so it shows up in Monarch as a
+// positive transaction from the retailer.
+type Refund struct {
+	Retailer    Retailer `json:"retailer,omitempty"`
+	RefundID    string   `json:"refundId" binding:"required"`
+	OrderNumber string   `json:"orderNumber" binding:"required"`
+	RefundDate  string   `json:"refundDate" binding:"required"`
+	// Amount is the total refunded, including tax.
+	Amount float64      `json:"amount" binding:"required"`
+	Items  []RefundItem `json:"items"`
+	// TransactionID is the Monarch transaction the refund was matched to, once it
+	// has been.
+	TransactionID string `json:"transactionId,omitempty"`
+}
+
+// RefundItem is an item returned from the refund's order.
+type RefundItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	// Amount is what was refunded for the item, when the retailer itemizes it.
+	Amount *float64 `json:"amount,omitempty"`
+	// Price and Category are copied from the order's item by LinkItems.
+	Price    float64 `json:"price,omitempty"`
+	Category string  `json:"category,omitempty"`
+}
+
+// Match statuses of a refund, as reported by RefundResponse.
+const (
+	// RefundMatched is a refund whose transaction has been split.
+	RefundMatched = "matched"
+	// RefundMatchPending is a refund whose transaction has not posted yet, which
+	// is looked for again in the background.
+	RefundMatchPending = "pending"
+	// RefundMatchNotConfigured is a refund stored unmatched because no Monarch
+	// transactions source is configured to match it to.
+	RefundMatchNotConfigured = "not_configured"
+)
+
+// RefundResponse represents the API response after receiving a refund.
+type RefundResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	RefundID string `json:"refundId"`
+	OrderID  string `json:"orderId"`
+	// Matched reports whether the refund's Monarch transaction has been found and
+	// split into Splits.
+	Matched bool `json:"matched"`
+	// MatchStatus is one of the RefundMatch constants.
+	MatchStatus   string    `json:"matchStatus"`
+	TransactionID string    `json:"transactionId,omitempty"`
+	Splits        []Split   `json:"splits"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// LinkItems finds each returned item in the refund's original order by name and
+// copies its price and category, so the refund is split the way the purchase
+// was. Items returned in the order's earlier refunds count against what was
+// bought, so it fails if an item is not in the order or more were returned, in
+// all, than bought.
+func (r *Refund) LinkItems(order *Order, earlier []Refund) error {
+	returned := make([]int, len(order.Items))
+	for _, e := range earlier {
+		for _, item := range e.Items {
+			// Earlier refunds were checked when received, so what no longer fits,
+			// such as after the order was updated, is not counted
+			linkItem(order, returned, &item)
+		}
+	}
+
+	for i := range r.Items {
+		item := &r.Items[i]
+		switch found, linked := linkItem(order, returned, item); {
+		case !found:
+			return fmt.Errorf("item %q is not in order %s", item.Name, order.OrderNumber)
+		case !linked:
+			return fmt.Errorf("more of item %q returned than order %s has", item.Name, order.OrderNumber)
+		}
+	}
+	return nil
+}
+
+// linkItem links item to the first of the order's items with its name that has
+// enough left that were not returned, adding it to returned.
+func linkItem(order *Order, returned []int, item *RefundItem) (found, linked bool) {
+	for j, bought := range order.Items {
+		if !strings.EqualFold(strings.TrimSpace(bought.Name), strings.TrimSpace(item.Name)) {
+			continue
+		}
+		found = true
+		if returned[j]+item.Quantity > bought.Quantity {
+			continue
+		}
+		returned[j] += item.Quantity
+		item.Price, item.Category = bought.Price, bought.Category
+		return true, true
+	}
+	return found, false
+}
+
+// CheckAmount checks that a refund linked by LinkItems pays back no more than its
+// items cost, with at most the order's tax on top, and that together with the
+// order's earlier refunds it pays back no more than the order's total, when that
+// is known.
+func (r *Refund) CheckAmount(order *Order, earlier []Refund) error {
+	// Amounts are compared in cents so rounding does not reject exact refunds
+	cents := func(amount float64) int64 { return int64(math.Round(amount * 100)) }
+
+	var items float64
+	for _, item := range r.Items {
+		items += item.Price * float64(item.Quantity)
+	}
+	if order.Tax != nil {
+		items += *order.Tax
+	}
+	if cents(r.Amount) > cents(items) {
+		return fmt.Errorf("amount %.2f is more than the %.2f paid for the returned items", r.Amount, items)
+	}
+
+	if order.OrderTotal == nil {
+		return nil
+	}
+	refunded := r.Amount
+	for _, e := range earlier {
+		refunded += e.Amount
+	}
+	if cents(refunded) > cents(*order.OrderTotal) {
+		return fmt.Errorf("amount %.2f brings the refunds of order %s to %.2f, more than its total of %.2f",
+			r.Amount, order.OrderNumber, refunded, *order.OrderTotal)
+	}
+	return nil
+}
+
+// Splits divides the refund among the categories of its items, in proportion to
+// what each item was refunded or, when that is not itemized, to its price and
+// quantity. The splits add up to Amount, with rounding left to the last one.
+func (r *Refund) Splits() []Split {
+	var categories []string
+	weights := make(map[string]float64)
+	var sum float64
+	for _, item := range r.Items {
+		weight := item.Price * float64(item.Quantity)
+		if item.Amount != nil {
+			weight = *item.Amount
+		}
+		if _, ok := weights[item.Category]; !ok {
+			categories = append(categories, item.Category)
+		}
+		weights[item.Category] += weight
+		sum += weight
+	}
+	if len(categories) == 0 {
+		return []Split{{Amount: r.Amount}}
+	}
+	if sum <= 0 {
+		// Nothing to weigh by, such as free items, so share it evenly
+		for category := range weights {
+			weights[category] = 1
+		}
+		sum = float64(len(weights))
+	}
+
+	total := math.Round(r.Amount * 100)
+	remaining := total
+	splits := make([]Split, len(categories))
+	for i, category := range categories {
+		share := remaining
+		if i < len(categories)-1 {
+			share = math.Round(total * weights[category] / sum)
+		}
+		remaining -= share
+		splits[i] = Split{Category: category, Amount: share / 100}
+	}
+	return splits
+}
+
+// MatchWindow returns the first and last dates, as YYYY-MM-DD, that the refund's
+// Monarch transaction may be dated.
+func (r *Refund) MatchWindow() (from, to string, err error) {
+	date, err := parseDay(r.RefundDate)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid refund date %q", r.RefundDate)
+	}
+	return date.AddDate(0, 0, -RefundMatchDaysBefore).Format(dayLayout),
+		date.AddDate(0, 0, RefundMatchDaysAfter).Format(dayLayout), nil
+}
+
+// MatchTransaction picks the Monarch transaction that is the refund: a credit of
+// exactly Amount from the refund's retailer, dated inside MatchWindow and as close
+// to the refund date as possible. Transactions in claimed, which are already
+// matched to other refunds, are skipped.
+func (r *Refund) MatchTransaction(txns []Transaction, claimed map[string]bool) (Transaction, bool) {
+	refunded, err := parseDay(r.RefundDate)
+	if err != nil {
+		return Transaction{}, false
+	}
+	retailer := r.Retailer
+	if retailer == "" {
+		retailer = DefaultRetailer
+	}
+	from := refunded.AddDate(0, 0, -RefundMatchDaysBefore)
+	to := refunded.AddDate(0, 0, RefundMatchDaysAfter)
+
+	var best Transaction
+	var bestDistance time.Duration
+	found := false
+	for _, txn := range txns {
+		if claimed[txn.ID] || txn.Amount <= 0 || math.Round(txn.Amount*100) != math.Round(r.Amount*100) ||
+			!retailer.MatchesMerchant(txn.Merchant) {
+			continue
+		}
+		date, err := parseDay(txn.Date)
+		if err != nil || date.Before(from) || date.After(to) {
+			continue
+		}
+		distance := date.Sub(refunded)
+		if distance < 0 {
+			distance = -distance
+		}
+		if !found || distance < bestDistance {
+			best, bestDistance, found = txn, distance, true
+		}
+	}
+	return best, found
+}
+
+const dayLayout = "2006-01-02"
+
+// parseDay parses the YYYY-MM-DD date at the start of s, ignoring any time after it.
+func parseDay(s string) (time.Time, error) {
+	if len(s) > len(dayLayout) {
+		s = s[:len(dayLayout)]
+	}
+	return time.Parse(dayLayout, s)
+}
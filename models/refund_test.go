@@ -0,0 +1,178 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refundedOrder() *Order {
+	return &Order{
+		OrderNumber: "1001",
+		OrderDate:   "2024-03-01",
+		Items: []OrderItem{
+			{Name: "Paper Towels", Price: 19.99, Quantity: 1, Category: "Household"},
+			{Name: "Milk", Price: 3.50, Quantity: 2, Category: "Groceries"},
+			{Name: "Bread", Price: 2.50, Quantity: 1, Category: "Groceries"},
+		},
+	}
+}
+
+func TestRefund_LinkItems(t *testing.T) {
+	t.Run("copies price and category", func(t *testing.T) {
+		refund := Refund{Items: []RefundItem{{Name: "milk ", Quantity: 2}, {Name: "Paper Towels", Quantity: 1}}}
+
+		require.NoError(t, refund.LinkItems(refundedOrder(), nil))
+
+		assert.Equal(t, RefundItem{Name: "milk ", Quantity: 2, Price: 3.50, Category: "Groceries"}, refund.Items[0])
+		assert.Equal(t, "Household", refund.Items[1].Category)
+	})
+
+	t.Run("item not in order", func(t *testing.T) {
+		refund := Refund{Items: []RefundItem{{Name: "Eggs", Quantity: 1}}}
+
+		err := refund.LinkItems(refundedOrder(), nil)
+
+		assert.EqualError(t, err, `item "Eggs" is not in order 1001`)
+	})
+
+	t.Run("more returned than bought", func(t *testing.T) {
+		refund := Refund{Items: []RefundItem{{Name: "Milk", Quantity: 1}, {Name: "Milk", Quantity: 2}}}
+
+		err := refund.LinkItems(refundedOrder(), nil)
+
+		assert.EqualError(t, err, `more of item "Milk" returned than order 1001 has`)
+	})
+
+	t.Run("counts earlier refunds", func(t *testing.T) {
+		earlier := []Refund{{Items: []RefundItem{{Name: "Milk", Quantity: 1}}}}
+		refund := Refund{Items: []RefundItem{{Name: "Milk", Quantity: 2}}}
+
+		err := refund.LinkItems(refundedOrder(), earlier)
+
+		assert.EqualError(t, err, `more of item "Milk" returned than order 1001 has`)
+	})
+}
+
+func TestRefund_CheckAmount(t *testing.T) {
+	tax, total := 1.50, 30.99
+	order := refundedOrder()
+	order.Tax, order.OrderTotal = &tax, &total
+	milk := func(amount float64) Refund {
+		return Refund{Amount: amount, Items: []RefundItem{{Name: "Milk", Quantity: 2, Price: 3.50}}}
+	}
+
+	tests := []struct {
+		name    string
+		refund  Refund
+		earlier []Refund
+		err     string
+	}{
+		{"items with tax", milk(8.50), nil, ""},
+		{"more than the items", milk(8.51), nil, "amount 8.51 is more than the 8.50 paid for the returned items"},
+		{"within the order total", milk(7), []Refund{{Amount: 23.99}}, ""},
+		{"more than the order total", milk(7), []Refund{{Amount: 24}},
+			"amount 7.00 brings the refunds of order 1001 to 31.00, more than its total of 30.99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.refund.CheckAmount(order, tt.earlier)
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRefund_Splits(t *testing.T) {
+	t.Run("by price and quantity", func(t *testing.T) {
+		refund := Refund{Amount: 32.39, Items: []RefundItem{
+			{Name: "Paper Towels", Quantity: 1, Price: 19.99, Category: "Household"},
+			{Name: "Milk", Quantity: 2, Price: 3.50, Category: "Groceries"},
+			{Name: "Bread", Quantity: 1, Price: 2.50, Category: "Groceries"},
+		}}
+
+		splits := refund.Splits()
+
+		// 19.99 of 29.49 bought, scaled up to the refund with its tax
+		assert.Equal(t, []Split{{Category: "Household", Amount: 21.96}, {Category: "Groceries", Amount: 10.43}}, splits)
+	})
+
+	t.Run("by itemized amounts", func(t *testing.T) {
+		towels, milk := 10.0, 5.0
+		refund := Refund{Amount: 15, Items: []RefundItem{
+			{Name: "Paper Towels", Quantity: 1, Price: 19.99, Amount: &towels, Category: "Household"},
+			{Name: "Milk", Quantity: 2, Price: 3.50, Amount: &milk, Category: "Groceries"},
+		}}
+
+		assert.Equal(t, []Split{{Category: "Household", Amount: 10}, {Category: "Groceries", Amount: 5}}, refund.Splits())
+	})
+
+	t.Run("rounding goes to the last split", func(t *testing.T) {
+		refund := Refund{Amount: 10, Items: []RefundItem{
+			{Quantity: 1, Price: 1, Category: "A"},
+			{Quantity: 1, Price: 1, Category: "B"},
+			{Quantity: 1, Price: 1, Category: "C"},
+		}}
+
+		assert.Equal(t, []Split{{Category: "A", Amount: 3.33}, {Category: "B", Amount: 3.33}, {Category: "C", Amount: 3.34}},
+			refund.Splits())
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		refund := Refund{Amount: 4.20}
+
+		assert.Equal(t, []Split{{Amount: 4.20}}, refund.Splits())
+	})
+}
+
+func TestRefund_MatchTransaction(t *testing.T) {
+	refund := Refund{Retailer: RetailerWalmart, RefundDate: "2024-03-10", Amount: 32.39}
+	txns := []Transaction{
+		{ID: "purchase", Date: "2024-03-10", Merchant: "Walmart", Amount: -32.39},
+		{ID: "other amount", Date: "2024-03-10", Merchant: "Walmart", Amount: 32.40},
+		{ID: "other merchant", Date: "2024-03-10", Merchant: "Target", Amount: 32.39},
+		{ID: "too early", Date: "2024-03-07", Merchant: "Walmart", Amount: 32.39},
+		{ID: "later", Date: "2024-03-15", Merchant: "WAL-MART #1234", Amount: 32.39},
+		{ID: "closest", Date: "2024-03-12", Merchant: "Walmart.com", Amount: 32.39},
+		{ID: "too late", Date: "2024-03-21", Merchant: "Walmart", Amount: 32.39},
+	}
+
+	t.Run("closest credit from the retailer", func(t *testing.T) {
+		txn, ok := refund.MatchTransaction(txns, nil)
+
+		require.True(t, ok)
+		assert.Equal(t, "closest", txn.ID)
+	})
+
+	t.Run("skips claimed transactions", func(t *testing.T) {
+		txn, ok := refund.MatchTransaction(txns, map[string]bool{"closest": true})
+
+		require.True(t, ok)
+		assert.Equal(t, "later", txn.ID)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, ok := refund.MatchTransaction(txns[:4], nil)
+
+		assert.False(t, ok)
+	})
+}
+
+func TestRefund_MatchWindow(t *testing.T) {
+	refund := Refund{RefundDate: "2024-02-28T10:00:00Z"}
+
+	from, to, err := refund.MatchWindow()
+
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-26", from)
+	assert.Equal(t, "2024-03-09", to)
+
+	_, _, err = (&Refund{RefundDate: "soon"}).MatchWindow()
+	assert.EqualError(t, err, `invalid refund date "soon"`)
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Retailer identifies the store an order was placed with.
+type Retailer string
+
+// Supported retailers.
+const (
+	RetailerWalmart Retailer = "walmart"
+	RetailerAmazon  Retailer = "amazon"
+	RetailerTarget  Retailer = "target"
+	RetailerCostco  Retailer = "costco"
+)
+
+// DefaultRetailer is assumed for orders that do not name a retailer, which were
+// all sent before other retailers were supported.
+const DefaultRetailer = RetailerWalmart
+
+// retailerInfo describes how a retailer appears in Monarch.
+type retailerInfo struct {
+	name string
+	// merchants match the merchant names Monarch shows for the retailer's card
+	// charges, including raw statement descriptors.
+	merchants []*regexp.Regexp
+	// excluded match charges from the retailer that never correspond to an order,
+	// such as fuel or subscriptions.
+	excluded []*regexp.Regexp
+}
+
+var retailers = map[Retailer]retailerInfo{
+	RetailerWalmart: {
+		name: "Walmart",
+		merchants: patterns(
+			`\bwal[\s-]?mart\b`,
+			`\bwalmart\.com\b`,
+			`\bwm\s+super\s?cent(er|re)?\b`,
+		),
+		excluded: patterns(`\bfuel\b`, `\bgas\b`),
+	},
+	RetailerAmazon: {
+		name: "Amazon",
+		merchants: patterns(
+			`\bamazon\b`,
+			`\bamzn\b`,
+		),
+		excluded: patterns(`\bprime\s+video\b`, `\bweb\s+services\b`, `\baws\b`, `\bkindle\s+(svcs|unltd|unlimited)\b`),
+	},
+	RetailerTarget: {
+		name: "Target",
+		merchants: patterns(
+			`^target\b`,
+			`\btarget\.com\b`,
+		),
+	},
+	RetailerCostco: {
+		name: "Costco",
+		merchants: patterns(
+			`\bcostco\b`,
+		),
+		excluded: patterns(`\bgas\b`, `\bfuel\b`),
+	},
+}
+
+func patterns(exprs ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(exprs))
+	for i, expr := range exprs {
+		compiled[i] = regexp.MustCompile(`(?i)` + expr)
+	}
+	return compiled
+}
+
+// Retailers returns every supported retailer.
+func Retailers() []Retailer {
+	return []Retailer{RetailerWalmart, RetailerAmazon, RetailerTarget, RetailerCostco}
+}
+
+// ParseRetailer returns the retailer with the given identifier, ignoring case.
+func ParseRetailer(s string) (Retailer, error) {
+	r := Retailer(strings.ToLower(strings.TrimSpace(s)))
+	if !r.Valid() {
+		return "", fmt.Errorf("unknown retailer %q", s)
+	}
+	return r, nil
+}
+
+// Valid reports whether r is a supported retailer.
+func (r Retailer) Valid() bool {
+	_, ok := retailers[r]
+	return ok
+}
+
+// Name returns the retailer's display name, such as "Walmart".
+func (r Retailer) Name() string {
+	if info, ok := retailers[r]; ok {
+		return info.name
+	}
+	return string(r)
+}
+
+// MatchesMerchant reports whether a Monarch transaction's merchant name, or the
+// original statement description, is a charge or refund from the retailer.
+// Monarch usually cleans names up ("Walmart"), but unrecognized merchants keep
+// the card network's descriptor ("WM SUPERCENTER #1234", "AMZN Mktp US*2K4").
+func (r Retailer) MatchesMerchant(merchant string) bool {
+	info, ok := retailers[r]
+	if !ok {
+		return false
+	}
+	for _, re := range info.excluded {
+		if re.MatchString(merchant) {
+			return false
+		}
+	}
+	for _, re := range info.merchants {
+		if re.MatchString(merchant) {
+			return true
+		}
+	}
+	return false
+}
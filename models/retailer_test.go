@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetailer(t *testing.T) {
+	for _, r := range Retailers() {
+		parsed, err := ParseRetailer(string(r))
+		require.NoError(t, err)
+		assert.Equal(t, r, parsed)
+	}
+
+	parsed, err := ParseRetailer(" Amazon ")
+	require.NoError(t, err)
+	assert.Equal(t, RetailerAmazon, parsed)
+
+	_, err = ParseRetailer("kroger")
+	assert.EqualError(t, err, `unknown retailer "kroger"`)
+	_, err = ParseRetailer("")
+	assert.Error(t, err)
+}
+
+func TestRetailer_Name(t *testing.T) {
+	assert.Equal(t, "Walmart", RetailerWalmart.Name())
+	assert.Equal(t, "Costco", RetailerCostco.Name())
+	assert.Equal(t, "kroger", Retailer("kroger").Name())
+}
+
+func TestRetailer_MatchesMerchant(t *testing.T) {
+	tests := []struct {
+		retailer Retailer
+		merchant string
+		want     bool
+	}{
+		{RetailerWalmart, "Walmart", true},
+		{RetailerWalmart, "WAL-MART #1234", true},
+		{RetailerWalmart, "Wal Mart Super Center", true},
+		{RetailerWalmart, "WM SUPERCENTER #5678", true},
+		{RetailerWalmart, "Walmart.com 8009666546 AR", true},
+		{RetailerWalmart, "WALMART FUEL 1234", false},
+		{RetailerWalmart, "Murphy USA", false},
+
+		{RetailerAmazon, "Amazon", true},
+		{RetailerAmazon, "AMZN Mktp US*2K4AB12C3", true},
+		{RetailerAmazon, "Amazon.com*MK1AB2CD3", true},
+		{RetailerAmazon, "Amazon Prime Video", false},
+		{RetailerAmazon, "Amazon Web Services", false},
+		{RetailerAmazon, "Walmart", false},
+
+		{RetailerTarget, "Target", true},
+		{RetailerTarget, "TARGET 00012345 CHICAGO IL", true},
+		{RetailerTarget, "TARGET.COM *", true},
+		{RetailerTarget, "On Target Archery", false},
+
+		{RetailerCostco, "Costco", true},
+		{RetailerCostco, "COSTCO WHSE #0123", true},
+		{RetailerCostco, "COSTCO GAS #0123", false},
+
+		{Retailer("kroger"), "Kroger", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.retailer)+"/"+tt.merchant, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.retailer.MatchesMerchant(tt.merchant))
+		})
+	}
+}
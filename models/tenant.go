@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DefaultTenantID identifies the household that owns data created before tenancy,
+// including orders submitted with the shared EXTENSION_SECRET_KEY.
+const DefaultTenantID = "default"
+
+// Tenant is a household or user that owns API keys, orders, and webhook subscriptions.
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Transaction is a Monarch transaction. Purchases are negative amounts and
+// refunds positive ones.
+type Transaction struct {
+	ID       string  `json:"id"`
+	Date     string  `json:"date"`
+	Merchant string  `json:"merchant"`
+	Amount   float64 `json:"amount"`
+}
+
+// Split is the part of a transaction assigned to one category. An empty category
+// leaves that part uncategorized.
+type Split struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
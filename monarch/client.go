@@ -0,0 +1,236 @@
+// Package monarch is a client for the Monarch Money GraphQL API, which refunds are
+// matched to their transactions and split by category through.
+package monarch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// ErrNoToken is returned for a tenant that has no Monarch session token stored.
+var ErrNoToken = errors.New("no Monarch token stored for tenant")
+
+// uncategorized is the Monarch category parts of a split without a category are
+// assigned to.
+const uncategorized = "Uncategorized"
+
+// pageSize is how many transactions are listed per request.
+const pageSize = 100
+
+// maxResponseBytes bounds the responses read from Monarch.
+const maxResponseBytes = 10 << 20
+
+// Token returns a tenant's Monarch session token, or ErrNoToken.
+type Token func(ctx context.Context, tenantID string) (string, error)
+
+// Client calls Monarch on behalf of each tenant with the tenant's token.
+type Client struct {
+	http  *http.Client
+	url   string
+	token Token
+}
+
+// New creates a client that sends requests to url with httpClient.
+func New(httpClient *http.Client, url string, token Token) *Client {
+	return &Client{http: httpClient, url: url, token: token}
+}
+
+const listTransactionsQuery = `query GetTransactionsList($offset: Int, $limit: Int, $filters: TransactionFilterInput) {
+  allTransactions(filters: $filters) {
+    totalCount
+    results(offset: $offset, limit: $limit, orderBy: date) {
+      id
+      date
+      amount
+      merchant { name }
+    }
+  }
+}`
+
+// ListTransactions returns the tenant's transactions dated from to to, which are
+// inclusive YYYY-MM-DD dates.
+func (c *Client) ListTransactions(ctx context.Context, tenantID, from, to string) ([]models.Transaction, error) {
+	var txns []models.Transaction
+	for offset := 0; ; offset += pageSize {
+		var data struct {
+			AllTransactions struct {
+				TotalCount int `json:"totalCount"`
+				Results    []struct {
+					ID       string  `json:"id"`
+					Date     string  `json:"date"`
+					Amount   float64 `json:"amount"`
+					Merchant *struct {
+						Name string `json:"name"`
+					} `json:"merchant"`
+				} `json:"results"`
+			} `json:"allTransactions"`
+		}
+		err := c.do(ctx, tenantID, listTransactionsQuery, map[string]any{
+			"offset":  offset,
+			"limit":   pageSize,
+			"filters": map[string]any{"startDate": from, "endDate": to},
+		}, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range data.AllTransactions.Results {
+			txn := models.Transaction{ID: r.ID, Date: r.Date, Amount: r.Amount}
+			if r.Merchant != nil {
+				txn.Merchant = r.Merchant.Name
+			}
+			txns = append(txns, txn)
+		}
+		if len(data.AllTransactions.Results) < pageSize || len(txns) >= data.AllTransactions.TotalCount {
+			return txns, nil
+		}
+	}
+}
+
+const categoriesQuery = `query GetCategories {
+  categories {
+    id
+    name
+  }
+}`
+
+const transactionQuery = `query GetTransaction($id: UUID!) {
+  getTransaction(id: $id) {
+    id
+    merchant { name }
+  }
+}`
+
+const splitTransactionMutation = `mutation SplitTransaction($input: UpdateTransactionSplitMutationInput!) {
+  updateTransactionSplit(input: $input) {
+    errors { message }
+    transaction { id hasSplitTransactions }
+  }
+}`
+
+// SplitTransaction replaces a transaction's category with splits that add up to
+// its amount. Splits are matched to the tenant's Monarch categories by name, and
+// ones without a category go to Uncategorized.
+func (c *Client) SplitTransaction(ctx context.Context, tenantID, transactionID string, splits []models.Split) error {
+	var categories struct {
+		Categories []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"categories"`
+	}
+	if err := c.do(ctx, tenantID, categoriesQuery, nil, &categories); err != nil {
+		return fmt.Errorf("list categories: %w", err)
+	}
+	categoryID := func(name string) (string, bool) {
+		if name == "" {
+			name = uncategorized
+		}
+		for _, category := range categories.Categories {
+			if strings.EqualFold(strings.TrimSpace(category.Name), strings.TrimSpace(name)) {
+				return category.ID, true
+			}
+		}
+		return "", false
+	}
+
+	// Each split keeps the transaction's merchant
+	var txn struct {
+		GetTransaction *struct {
+			Merchant *struct {
+				Name string `json:"name"`
+			} `json:"merchant"`
+		} `json:"getTransaction"`
+	}
+	if err := c.do(ctx, tenantID, transactionQuery, map[string]any{"id": transactionID}, &txn); err != nil {
+		return fmt.Errorf("get transaction: %w", err)
+	}
+	if txn.GetTransaction == nil {
+		return fmt.Errorf("transaction %s not found", transactionID)
+	}
+	var merchant string
+	if txn.GetTransaction.Merchant != nil {
+		merchant = txn.GetTransaction.Merchant.Name
+	}
+
+	splitData := make([]map[string]any, len(splits))
+	for i, split := range splits {
+		id, ok := categoryID(split.Category)
+		if !ok {
+			return fmt.Errorf("no Monarch category named %q", split.Category)
+		}
+		splitData[i] = map[string]any{"merchantName": merchant, "amount": split.Amount, "categoryId": id}
+	}
+
+	var result struct {
+		UpdateTransactionSplit struct {
+			Errors *struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"updateTransactionSplit"`
+	}
+	err := c.do(ctx, tenantID, splitTransactionMutation, map[string]any{
+		"input": map[string]any{"transactionId": transactionID, "splitData": splitData},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if e := result.UpdateTransactionSplit.Errors; e != nil && e.Message != "" {
+		return fmt.Errorf("split rejected: %s", e.Message)
+	}
+	return nil
+}
+
+// do sends a GraphQL request with the tenant's token and decodes its data into out.
+func (c *Client) do(ctx context.Context, tenantID, query string, variables map[string]any, out any) error {
+	token, err := c.token(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.New("token rejected")
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("monarch: %s", result.Errors[0].Message)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package monarch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"monarchmoney-sync-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphQLRequest is a request the fake Monarch server received.
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// newMonarch starts a fake Monarch that answers each request with respond, and
+// returns a client for it whose only tenant is t1.
+func newMonarch(t *testing.T, respond func(req graphQLRequest) string) (*Client, *[]graphQLRequest) {
+	t.Helper()
+	var received []graphQLRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token tok-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received = append(received, req)
+		_, _ = w.Write([]byte(respond(req)))
+	}))
+	t.Cleanup(server.Close)
+
+	token := func(_ context.Context, tenantID string) (string, error) {
+		if tenantID != "t1" {
+			return "", ErrNoToken
+		}
+		return "tok-1", nil
+	}
+	return New(server.Client(), server.URL, token), &received
+}
+
+func TestClient_ListTransactions(t *testing.T) {
+	// Arrange
+	client, received := newMonarch(t, func(graphQLRequest) string {
+		return `{"data":{"allTransactions":{"totalCount":2,"results":[
+			{"id":"1","date":"2024-03-12","amount":26.99,"merchant":{"name":"Walmart"}},
+			{"id":"2","date":"2024-03-13","amount":-5,"merchant":null}]}}}`
+	})
+
+	// Act
+	txns, err := client.ListTransactions(context.Background(), "t1", "2024-03-08", "2024-03-20")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []models.Transaction{
+		{ID: "1", Date: "2024-03-12", Merchant: "Walmart", Amount: 26.99},
+		{ID: "2", Date: "2024-03-13", Amount: -5},
+	}, txns)
+	require.Len(t, *received, 1)
+	assert.Equal(t, map[string]any{"startDate": "2024-03-08", "endDate": "2024-03-20"}, (*received)[0].Variables["filters"])
+}
+
+func TestClient_SplitTransaction(t *testing.T) {
+	// Arrange
+	client, received := newMonarch(t, func(req graphQLRequest) string {
+		switch {
+		case strings.Contains(req.Query, "categories"):
+			return `{"data":{"categories":[{"id":"c1","name":"Household"},{"id":"c2","name":"Uncategorized"}]}}`
+		case strings.Contains(req.Query, "getTransaction"):
+			return `{"data":{"getTransaction":{"id":"txn","merchant":{"name":"Walmart"}}}}`
+		default:
+			return `{"data":{"updateTransactionSplit":{"errors":null,"transaction":{"id":"txn"}}}}`
+		}
+	})
+
+	// Act
+	err := client.SplitTransaction(context.Background(), "t1", "txn",
+		[]models.Split{{Category: "household", Amount: 20}, {Amount: 6.99}})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, *received, 3)
+	input := (*received)[2].Variables["input"].(map[string]any)
+	assert.Equal(t, "txn", input["transactionId"])
+	assert.Equal(t, []any{
+		map[string]any{"merchantName": "Walmart", "amount": 20.0, "categoryId": "c1"},
+		map[string]any{"merchantName": "Walmart", "amount": 6.99, "categoryId": "c2"},
+	}, input["splitData"])
+}
+
+func TestClient_Errors(t *testing.T) {
+	client, _ := newMonarch(t, func(req graphQLRequest) string {
+		if strings.Contains(req.Query, "categories") {
+			return `{"data":{"categories":[]}}`
+		}
+		return `{"errors":[{"message":"bad filter"}]}`
+	})
+	ctx := context.Background()
+
+	_, err := client.ListTransactions(ctx, "t1", "2024-03-08", "2024-03-20")
+	assert.EqualError(t, err, "monarch: bad filter")
+
+	_, err = client.ListTransactions(ctx, "t2", "2024-03-08", "2024-03-20")
+	assert.ErrorIs(t, err, ErrNoToken)
+
+	err = client.SplitTransaction(ctx, "t1", "txn", []models.Split{{Category: "Toys", Amount: 1}})
+	assert.Error(t, err)
+}
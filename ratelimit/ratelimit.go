@@ -0,0 +1,164 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable storage
+// backends so limits can be shared between server replicas.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Rate requests per Per, with bursts of up to Rate requests.
+type Limit struct {
+	Rate int
+	Per  time.Duration
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Per > 0
+}
+
+// String formats the limit in the form accepted by ParseLimit.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Rate, l.Per)
+}
+
+// perSecond is the refill rate in tokens per second.
+func (l Limit) perSecond() float64 {
+	return float64(l.Rate) / l.Per.Seconds()
+}
+
+// ParseLimit parses limits such as "100/m", "1000/1h", or "5/30s".
+// "off", "0", and the empty string disable limiting.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+
+	rate, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <count>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(rate))
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit count %q", rate)
+	}
+
+	per = strings.TrimSpace(per)
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit period %q", per)
+	}
+
+	return Limit{Rate: n, Per: d}, nil
+}
+
+// Result describes the outcome of a rate limit check.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity.
+	Limit int
+	// Remaining is the number of requests that may be made immediately.
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed. Zero when allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Backend stores token buckets. Implementations must be safe for concurrent use.
+type Backend interface {
+	// Allow takes a token from the bucket identified by key, if one is available.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket is the state of a single token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time elapsed since it was last used and takes a
+// token if one is available.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Rate)
+	rate := limit.perSecond()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	result := Result{Limit: limit.Rate}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.ResetAfter = seconds((capacity - b.tokens) / rate)
+
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// MemoryBackend keeps buckets in process memory. Limits are per replica.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	sweepAt time.Time
+}
+
+// NewMemoryBackend creates an empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// idleBucketTTL is how long an untouched bucket is kept. Any bucket idle this long
+// has fully refilled for every practical limit, so dropping it changes nothing.
+const idleBucketTTL = 24 * time.Hour
+
+// Allow implements Backend.
+func (m *MemoryBackend) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.After(m.sweepAt) {
+		for k, b := range m.buckets {
+			if now.Sub(b.last) > idleBucketTTL {
+				delete(m.buckets, k)
+			}
+		}
+		m.sweepAt = now.Add(time.Hour)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Rate), last: now}
+		m.buckets[key] = b
+	}
+
+	return b.take(limit, now), nil
+}
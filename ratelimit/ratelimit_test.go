@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Limit
+		wantErr  bool
+	}{
+		{input: "100/m", expected: Limit{Rate: 100, Per: time.Minute}},
+		{input: "1000/1h", expected: Limit{Rate: 1000, Per: time.Hour}},
+		{input: "5/30s", expected: Limit{Rate: 5, Per: 30 * time.Second}},
+		{input: "off", expected: Limit{}},
+		{input: "", expected: Limit{}},
+		{input: "100", wantErr: true},
+		{input: "x/m", wantErr: true},
+		{input: "10/fortnight", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			limit, err := ParseLimit(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, limit)
+		})
+	}
+}
+
+func TestMemoryBackend_TokenBucket(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return now }
+	limit := Limit{Rate: 3, Per: time.Minute}
+	ctx := context.Background()
+
+	// Act & Assert - burst up to capacity
+	for i := 2; i >= 0; i-- {
+		result, err := backend.Allow(ctx, "ip:1.2.3.4", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := backend.Allow(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 20*time.Second, result.RetryAfter)
+	assert.Equal(t, time.Minute, result.ResetAfter)
+
+	// Other keys have their own bucket
+	result, _ = backend.Allow(ctx, "ip:5.6.7.8", limit)
+	assert.True(t, result.Allowed)
+
+	// One token refills after Per/Rate
+	now = now.Add(20 * time.Second)
+	result, _ = backend.Allow(ctx, "ip:1.2.3.4", limit)
+	assert.True(t, result.Allowed)
+	result, _ = backend.Allow(ctx, "ip:1.2.3.4", limit)
+	assert.False(t, result.Allowed)
+}
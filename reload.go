@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"monarchmoney-sync-backend/auth"
+	"monarchmoney-sync-backend/config"
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/vault"
+)
+
+// reloadSecrets reloads configuration from the same sources as at startup, including
+// the serve -config file at path, on every SIGHUP and applies rotated secrets, so
+// keys mounted from files can change without a restart. Other settings take effect
+// on the next restart. It does not return.
+func reloadSecrets(path string, current *config.Config, keyring *auth.Keyring, v *vault.Vault) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		next, err := loadConfig(path)
+		if err == nil {
+			err = next.Validate()
+		}
+		if err != nil {
+			slog.Error("Config reload failed, keeping current secrets", "error", err)
+			continue
+		}
+
+		rotated := applySecrets(current, next, keyring, v)
+		current = next
+		slog.Info("Reloaded secrets", "rotated", rotated)
+	}
+}
+
+// applySecrets applies secrets that differ between current and next and returns
+// the names of those it changed. Monarch and LLM provider keys from the
+// environment belong to the default tenant; unsetting one leaves the vault as is.
+func applySecrets(current, next *config.Config, keyring *auth.Keyring, v *vault.Vault) []string {
+	rotated := []string{}
+
+	if next.ExtensionKey != current.ExtensionKey {
+		keyring.SetLegacyKey(next.ExtensionKey)
+		rotated = append(rotated, "EXTENSION_SECRET_KEY")
+	}
+
+	for _, c := range []struct {
+		env     string
+		name    string
+		current string
+		next    string
+	}{
+		{"MONARCH_API_KEY", vault.MonarchToken, current.MonarchAPIKey, next.MonarchAPIKey},
+		{"OPENAI_API_KEY", vault.OpenAIAPIKey, current.OpenAIAPIKey, next.OpenAIAPIKey},
+		{"CLAUDE_API_KEY", vault.ClaudeAPIKey, current.ClaudeAPIKey, next.ClaudeAPIKey},
+	} {
+		if c.next == c.current || c.next == "" {
+			continue
+		}
+		if _, err := v.Set(models.DefaultTenantID, c.name, c.next); err != nil {
+			slog.Error("Failed to store rotated credential", "name", c.name, "error", err)
+			continue
+		}
+		rotated = append(rotated, c.env)
+	}
+
+	if next.SentryDSN != current.SentryDSN {
+		switch {
+		case !current.IsSentryEnabled():
+			slog.Warn("SENTRY_DSN set after startup; restart to enable Sentry")
+		case !next.IsSentryEnabled():
+			slog.Warn("SENTRY_DSN unset after startup; restart to disable Sentry")
+		default:
+			if err := initSentry(next); err != nil {
+				slog.Error("Failed to apply rotated Sentry DSN", "error", err)
+			} else {
+				rotated = append(rotated, "SENTRY_DSN")
+			}
+		}
+	}
+
+	return rotated
+}
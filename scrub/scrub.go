@@ -0,0 +1,122 @@
+// Package scrub removes personal and financial details from Sentry events before
+// they leave the server: item names, product URLs, and amounts.
+package scrub
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Filtered replaces scrubbed values.
+const Filtered = "[Filtered]"
+
+// sensitiveKeys are context, extra, and breadcrumb keys whose values describe what
+// was bought or what it cost. Keys are compared case-insensitively with
+// underscores and dashes removed.
+var sensitiveKeys = map[string]bool{
+	"amount":          true,
+	"body":            true,
+	"deliverycharges": true,
+	"delivery":        true,
+	"items":           true,
+	"itemname":        true,
+	"name":            true,
+	"ordertotal":      true,
+	"price":           true,
+	"producturl":      true,
+	"subtotal":        true,
+	"tax":             true,
+	"tip":             true,
+	"total":           true,
+	"url":             true,
+}
+
+var (
+	urlPattern    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	amountPattern = regexp.MustCompile(`\$\s?\d[\d,]*(\.\d+)?`)
+)
+
+// Options controls what the scrubber removes.
+type Options struct {
+	// AllowOrderDetails keeps item names, product URLs, and amounts. Headers,
+	// cookies, query strings, and request bodies are always removed.
+	AllowOrderDetails bool
+}
+
+// BeforeSend returns a sentry.ClientOptions.BeforeSend hook that scrubs events.
+func BeforeSend(opts Options) func(*sentry.Event, *sentry.EventHint) *sentry.Event {
+	return func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+		Event(event, opts)
+		return event
+	}
+}
+
+// Event scrubs event in place.
+func Event(event *sentry.Event, opts Options) {
+	if event == nil {
+		return
+	}
+
+	if event.Request != nil {
+		event.Request.Headers = nil
+		event.Request.Cookies = ""
+		event.Request.Data = ""
+		event.Request.QueryString = ""
+	}
+
+	if opts.AllowOrderDetails {
+		return
+	}
+
+	event.Message = scrubString(event.Message)
+	for name, ctx := range event.Contexts {
+		event.Contexts[name] = scrubMap(ctx)
+	}
+	event.Extra = scrubMap(event.Extra)
+	for i := range event.Exception {
+		event.Exception[i].Value = scrubString(event.Exception[i].Value)
+	}
+	for _, crumb := range event.Breadcrumbs {
+		crumb.Message = scrubString(crumb.Message)
+		crumb.Data = scrubMap(crumb.Data)
+	}
+}
+
+func scrubMap(m map[string]interface{}) map[string]interface{} {
+	for key, value := range m {
+		if isSensitive(key) {
+			m[key] = Filtered
+			continue
+		}
+		m[key] = scrubValue(value)
+	}
+	return m
+}
+
+func scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return scrubString(v)
+	case map[string]interface{}:
+		return scrubMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = scrubValue(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func scrubString(s string) string {
+	s = urlPattern.ReplaceAllString(s, Filtered)
+	return amountPattern.ReplaceAllString(s, Filtered)
+}
+
+func isSensitive(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[normalized]
+}
@@ -0,0 +1,74 @@
+package scrub
+
+import (
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func orderEvent() *sentry.Event {
+	event := sentry.NewEvent()
+	event.Message = "Price changed to $12.99 for https://www.walmart.com/ip/123"
+	event.Request = &sentry.Request{
+		URL:         "http://localhost/api/walmart/orders",
+		Headers:     map[string]string{"X-Extension-Key": "secret"},
+		Cookies:     "session=abc",
+		Data:        `{"items":[{"name":"Milk"}]}`,
+		QueryString: "from=2024-01-01",
+	}
+	event.Contexts["order"] = map[string]interface{}{
+		"order_number": "200013441396407",
+		"total":        45.67,
+		"items": []interface{}{
+			map[string]interface{}{"name": "Milk", "price": 3.49},
+		},
+		"note": "see https://www.walmart.com/ip/456",
+	}
+	event.Extra = map[string]interface{}{"deliveryCharges": 5.99, "items_count": 2}
+	event.Breadcrumbs = []*sentry.Breadcrumb{
+		{Message: "total was $45.67", Data: map[string]interface{}{"product_url": "https://example.com/p"}},
+	}
+	return event
+}
+
+func TestEvent_RemovesOrderDetails(t *testing.T) {
+	event := orderEvent()
+
+	Event(event, Options{})
+
+	assert.Nil(t, event.Request.Headers)
+	assert.Empty(t, event.Request.Cookies)
+	assert.Empty(t, event.Request.Data)
+	assert.Empty(t, event.Request.QueryString)
+	assert.Equal(t, "Price changed to [Filtered] for [Filtered]", event.Message)
+
+	order := event.Contexts["order"]
+	assert.Equal(t, "200013441396407", order["order_number"])
+	assert.Equal(t, Filtered, order["total"])
+	assert.Equal(t, Filtered, order["items"])
+	assert.Equal(t, "see [Filtered]", order["note"])
+
+	assert.Equal(t, Filtered, event.Extra["deliveryCharges"])
+	assert.Equal(t, 2, event.Extra["items_count"])
+
+	assert.Equal(t, "total was [Filtered]", event.Breadcrumbs[0].Message)
+	assert.Equal(t, Filtered, event.Breadcrumbs[0].Data["product_url"])
+}
+
+func TestEvent_AllowOrderDetails(t *testing.T) {
+	event := orderEvent()
+
+	BeforeSend(Options{AllowOrderDetails: true})(event, nil)
+
+	// Credentials and bodies are still removed
+	assert.Nil(t, event.Request.Headers)
+	assert.Empty(t, event.Request.Data)
+	// Order details are kept
+	assert.Equal(t, 45.67, event.Contexts["order"]["total"])
+	assert.Contains(t, event.Message, "$12.99")
+}
+
+func TestEvent_Nil(t *testing.T) {
+	assert.NotPanics(t, func() { Event(nil, Options{}) })
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records who changed what, such as a split applied to a transaction.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	TenantID  string          `json:"tenantId"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Subject   string          `json:"subject"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AuditFilter narrows ListAudit results. An empty Subject matches every entry.
+type AuditFilter struct {
+	Subject string
+	Limit   int
+}
+
+// AuditStore is an append-only audit log per tenant.
+type AuditStore interface {
+	// AppendAudit records an entry and sets its ID and, when zero, CreatedAt.
+	AppendAudit(ctx context.Context, entry *AuditEntry) error
+	// ListAudit returns a tenant's entries, newest first.
+	ListAudit(ctx context.Context, tenantID string, filter AuditFilter) ([]*AuditEntry, error)
+}
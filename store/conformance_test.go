@@ -0,0 +1,26 @@
+package store_test
+
+import (
+	"testing"
+
+	"monarchmoney-sync-backend/store"
+	"monarchmoney-sync-backend/store/storetest"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	t.Run("Tenants", func(t *testing.T) {
+		storetest.RunTenantStore(t, func(t *testing.T) store.TenantStore { return store.NewMemory() })
+	})
+	t.Run("Orders", func(t *testing.T) {
+		storetest.RunOrderStore(t, func(t *testing.T) storetest.OrderStore { return store.NewMemory() })
+	})
+	t.Run("Refunds", func(t *testing.T) {
+		storetest.RunRefundStore(t, func(t *testing.T) storetest.RefundStore { return store.NewMemory() })
+	})
+	t.Run("Jobs", func(t *testing.T) {
+		storetest.RunJobStore(t, func(t *testing.T) storetest.JobStore { return store.NewMemory() })
+	})
+	t.Run("Audit", func(t *testing.T) {
+		storetest.RunAuditStore(t, func(t *testing.T) storetest.AuditStore { return store.NewMemory() })
+	})
+}
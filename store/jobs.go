@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNoJobs is returned by ClaimJob when no job is due.
+var ErrNoJobs = errors.New("no jobs due")
+
+// JobStatus is where a job is in its lifecycle.
+type JobStatus string
+
+// Job statuses.
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is a unit of background work, such as processing an order, that one worker
+// at a time claims from a queue shared by every replica.
+type Job struct {
+	ID        int64           `json:"id"`
+	TenantID  string          `json:"tenantId"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Status    JobStatus       `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	RunAt     time.Time       `json:"runAt"`
+	LockedBy  string          `json:"lockedBy,omitempty"`
+	LockedAt  *time.Time      `json:"lockedAt,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// JobStore is a durable job queue.
+type JobStore interface {
+	// EnqueueJob adds a pending job, due at RunAt or immediately when it is zero,
+	// and sets its ID and timestamps.
+	EnqueueJob(ctx context.Context, job *Job) error
+	// ClaimJob locks the oldest due job for worker and marks it running. Jobs left
+	// running for longer than lease, such as by a crashed replica, are claimed
+	// again. It returns ErrNoJobs when nothing is due.
+	ClaimJob(ctx context.Context, worker string, lease time.Duration) (*Job, error)
+	// CompleteJob marks a job claimed by worker as succeeded. It returns
+	// ErrNotFound if worker no longer holds the job, such as after its lease
+	// expired and another worker claimed it.
+	CompleteJob(ctx context.Context, id int64, worker string) error
+	// FailJob records a failed attempt of a job claimed by worker. The job is
+	// retried at retryAt, or marked failed for good when retryAt is nil. Like
+	// CompleteJob, it returns ErrNotFound if worker no longer holds the job.
+	FailJob(ctx context.Context, id int64, worker, reason string, retryAt *time.Time) error
+	// RescheduleJob releases a job claimed by worker to run again at runAt, such
+	// as when what it waits for is not ready yet. Unlike FailJob, the attempt is
+	// not counted. Like CompleteJob, it returns ErrNotFound if worker no longer
+	// holds the job.
+	RescheduleJob(ctx context.Context, id int64, worker, reason string, runAt time.Time) error
+	// GetJob returns one of a tenant's jobs by ID, or ErrNotFound.
+	GetJob(ctx context.Context, tenantID string, id int64) (*Job, error)
+}
@@ -0,0 +1,462 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"monarchmoney-sync-backend/internal/jsonfile"
+	"monarchmoney-sync-backend/models"
+)
+
+// Memory is an in-memory OrderStore, RefundStore, TenantStore, JobStore, and
+// AuditStore. Everything but tenants is lost on restart; tenants can be persisted
+// to a JSON file with LoadTenants. Its jobs are only shared by the workers of one
+// process, and only the latest maxFinishedJobs finished jobs are kept.
+type Memory struct {
+	mu          sync.RWMutex
+	tenants     map[string]models.Tenant
+	orders      map[string]map[retailerKey]OrderRecord
+	refunds     map[string]map[retailerKey]RefundRecord
+	jobs        map[int64]*Job
+	lastJobID   int64
+	audit       []AuditEntry
+	tenantsFile string
+
+	// finishedJobs lists finished jobs oldest first, so the oldest are pruned
+	finishedJobs []int64
+}
+
+// NewMemory creates an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		tenants: make(map[string]models.Tenant),
+		orders:  make(map[string]map[retailerKey]OrderRecord),
+		refunds: make(map[string]map[retailerKey]RefundRecord),
+		jobs:    make(map[int64]*Job),
+	}
+}
+
+// maxFinishedJobs is how many succeeded and failed jobs the memory store keeps
+// for GetJob; older ones are dropped.
+const maxFinishedJobs = 1000
+
+// retailerKey identifies an order or refund within a tenant. Order numbers and
+// refund IDs are only unique per retailer.
+type retailerKey struct {
+	retailer models.Retailer
+	id       string
+}
+
+// LoadTenants reads tenants from path and writes every later change back to it.
+func (m *Memory) LoadTenants(path string) error {
+	var tenants []models.Tenant
+	if _, err := jsonfile.Read(path, &tenants); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tenants {
+		m.tenants[t.ID] = t
+	}
+	m.tenantsFile = path
+	return nil
+}
+
+// Ping always succeeds unless ctx is done; the memory store has no backend.
+func (m *Memory) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// SaveOrder implements OrderStore.
+func (m *Memory) SaveOrder(_ context.Context, rec *OrderRecord) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orders, ok := m.orders[rec.TenantID]
+	if !ok {
+		orders = make(map[retailerKey]OrderRecord)
+		m.orders[rec.TenantID] = orders
+	}
+
+	now := time.Now().UTC()
+	saved := *rec
+	saved.UpdatedAt = now
+	if saved.Order.Retailer == "" {
+		saved.Order.Retailer = models.DefaultRetailer
+	}
+
+	key := retailerKey{saved.Order.Retailer, saved.Order.OrderNumber}
+	existing, exists := orders[key]
+	if exists {
+		saved.ReceivedAt = existing.ReceivedAt
+	} else if saved.ReceivedAt.IsZero() {
+		saved.ReceivedAt = now
+	}
+
+	orders[key] = saved
+	*rec = saved
+	return !exists, nil
+}
+
+// GetOrder implements OrderStore.
+func (m *Memory) GetOrder(_ context.Context, tenantID string, retailer models.Retailer, orderNumber string) (*OrderRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.orders[tenantID][retailerKey{retailer, orderNumber}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+// ListOrders implements OrderStore.
+func (m *Memory) ListOrders(_ context.Context, tenantID string, filter OrderFilter) ([]*OrderRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := make([]*OrderRecord, 0, len(m.orders[tenantID]))
+	for _, r := range m.orders[tenantID] {
+		rec := r
+		if filter.Matches(&rec.Order) {
+			records = append(records, &rec)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Order.OrderDate != records[j].Order.OrderDate {
+			return records[i].Order.OrderDate > records[j].Order.OrderDate
+		}
+		return records[i].Order.OrderNumber < records[j].Order.OrderNumber
+	})
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+// SaveRefund implements RefundStore.
+func (m *Memory) SaveRefund(_ context.Context, rec *RefundRecord, check RefundCheck) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	refunds, ok := m.refunds[rec.TenantID]
+	if !ok {
+		refunds = make(map[retailerKey]RefundRecord)
+		m.refunds[rec.TenantID] = refunds
+	}
+
+	now := time.Now().UTC()
+	saved := *rec
+	saved.UpdatedAt = now
+	if saved.Refund.Retailer == "" {
+		saved.Refund.Retailer = models.DefaultRetailer
+	}
+
+	key := retailerKey{saved.Refund.Retailer, saved.Refund.RefundID}
+	if check != nil {
+		var earlier []models.Refund
+		for k, r := range refunds {
+			if k != key && r.Refund.Retailer == saved.Refund.Retailer && r.Refund.OrderNumber == saved.Refund.OrderNumber {
+				earlier = append(earlier, r.Refund)
+			}
+		}
+		if err := check(&saved.Refund, earlier); err != nil {
+			return false, err
+		}
+	}
+	existing, exists := refunds[key]
+	saved.Refund.TransactionID = existing.Refund.TransactionID
+	if exists {
+		saved.ReceivedAt = existing.ReceivedAt
+	} else if saved.ReceivedAt.IsZero() {
+		saved.ReceivedAt = now
+	}
+
+	refunds[key] = saved
+	*rec = saved
+	return !exists, nil
+}
+
+// ListRefunds implements RefundStore.
+func (m *Memory) ListRefunds(_ context.Context, tenantID string, retailer models.Retailer, orderNumber string) ([]*RefundRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var records []*RefundRecord
+	for _, r := range m.refunds[tenantID] {
+		rec := r
+		if rec.Refund.Retailer == retailer && rec.Refund.OrderNumber == orderNumber {
+			records = append(records, &rec)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Refund.RefundDate != records[j].Refund.RefundDate {
+			return records[i].Refund.RefundDate < records[j].Refund.RefundDate
+		}
+		return records[i].Refund.RefundID < records[j].Refund.RefundID
+	})
+	return records, nil
+}
+
+// ClaimTransaction implements RefundStore.
+func (m *Memory) ClaimTransaction(_ context.Context, tenantID string, retailer models.Retailer, refundID, transactionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := retailerKey{retailer, refundID}
+	rec, ok := m.refunds[tenantID][key]
+	if !ok {
+		return ErrNotFound
+	}
+	if rec.Refund.TransactionID != "" {
+		return ErrExists
+	}
+	for _, other := range m.refunds[tenantID] {
+		if other.Refund.TransactionID == transactionID {
+			return ErrExists
+		}
+	}
+
+	rec.Refund.TransactionID = transactionID
+	rec.UpdatedAt = time.Now().UTC()
+	m.refunds[tenantID][key] = rec
+	return nil
+}
+
+// ReleaseTransaction implements RefundStore.
+func (m *Memory) ReleaseTransaction(_ context.Context, tenantID string, retailer models.Retailer, refundID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := retailerKey{retailer, refundID}
+	rec, ok := m.refunds[tenantID][key]
+	if !ok {
+		return ErrNotFound
+	}
+	rec.Refund.TransactionID = ""
+	rec.UpdatedAt = time.Now().UTC()
+	m.refunds[tenantID][key] = rec
+	return nil
+}
+
+// EnqueueJob implements JobStore.
+func (m *Memory) EnqueueJob(_ context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	m.lastJobID++
+	saved := Job{
+		ID:        m.lastJobID,
+		TenantID:  job.TenantID,
+		Kind:      job.Kind,
+		Payload:   job.Payload,
+		Status:    JobPending,
+		RunAt:     job.RunAt.UTC(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if job.RunAt.IsZero() {
+		saved.RunAt = now
+	}
+	m.jobs[saved.ID] = &saved
+	*job = saved
+	return nil
+}
+
+// ClaimJob implements JobStore.
+func (m *Memory) ClaimJob(_ context.Context, worker string, lease time.Duration) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	var due *Job
+	for _, j := range m.jobs {
+		pending := j.Status == JobPending && !j.RunAt.After(now)
+		expired := j.Status == JobRunning && j.LockedAt != nil && j.LockedAt.Before(now.Add(-lease))
+		if !pending && !expired {
+			continue
+		}
+		if due == nil || j.RunAt.Before(due.RunAt) || (j.RunAt.Equal(due.RunAt) && j.ID < due.ID) {
+			due = j
+		}
+	}
+	if due == nil {
+		return nil, ErrNoJobs
+	}
+
+	due.Status = JobRunning
+	due.Attempts++
+	due.LockedBy = worker
+	due.LockedAt = &now
+	due.UpdatedAt = now
+	claimed := *due
+	return &claimed, nil
+}
+
+// CompleteJob implements JobStore.
+func (m *Memory) CompleteJob(_ context.Context, id int64, worker string) error {
+	return m.finishJob(id, worker, func(j *Job) {
+		j.Status = JobSucceeded
+		j.LastError = ""
+	})
+}
+
+// FailJob implements JobStore.
+func (m *Memory) FailJob(_ context.Context, id int64, worker, reason string, retryAt *time.Time) error {
+	return m.finishJob(id, worker, func(j *Job) {
+		j.Status = JobFailed
+		j.LastError = reason
+		j.RunAt = time.Now().UTC()
+		if retryAt != nil {
+			j.Status = JobPending
+			j.RunAt = retryAt.UTC()
+		}
+	})
+}
+
+// RescheduleJob implements JobStore.
+func (m *Memory) RescheduleJob(_ context.Context, id int64, worker, reason string, runAt time.Time) error {
+	return m.finishJob(id, worker, func(j *Job) {
+		j.Status = JobPending
+		j.Attempts--
+		j.LastError = reason
+		j.RunAt = runAt.UTC()
+	})
+}
+
+// finishJob applies update to a job that worker holds and releases it.
+func (m *Memory) finishJob(id int64, worker string, update func(j *Job)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok || j.Status != JobRunning || j.LockedBy != worker {
+		return ErrNotFound
+	}
+	update(j)
+	j.LockedBy, j.LockedAt = "", nil
+	j.UpdatedAt = time.Now().UTC()
+
+	if j.Status == JobSucceeded || j.Status == JobFailed {
+		m.finishedJobs = append(m.finishedJobs, id)
+		for len(m.finishedJobs) > maxFinishedJobs {
+			delete(m.jobs, m.finishedJobs[0])
+			m.finishedJobs = m.finishedJobs[1:]
+		}
+	}
+	return nil
+}
+
+// GetJob implements JobStore.
+func (m *Memory) GetJob(_ context.Context, tenantID string, id int64) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.jobs[id]
+	if !ok || j.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	job := *j
+	return &job, nil
+}
+
+// AppendAudit implements AuditStore.
+func (m *Memory) AppendAudit(_ context.Context, entry *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ID = int64(len(m.audit) + 1)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	m.audit = append(m.audit, *entry)
+	return nil
+}
+
+// ListAudit implements AuditStore.
+func (m *Memory) ListAudit(_ context.Context, tenantID string, filter AuditFilter) ([]*AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []*AuditEntry
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		e := m.audit[i]
+		if e.TenantID != tenantID || (filter.Subject != "" && e.Subject != filter.Subject) {
+			continue
+		}
+		entries = append(entries, &e)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// CreateTenant implements TenantStore.
+func (m *Memory) CreateTenant(_ context.Context, tenant *models.Tenant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tenants[tenant.ID]; ok {
+		return ErrExists
+	}
+	if tenant.CreatedAt.IsZero() {
+		tenant.CreatedAt = time.Now().UTC()
+	}
+	m.tenants[tenant.ID] = *tenant
+
+	if m.tenantsFile != "" {
+		if err := jsonfile.Write(m.tenantsFile, m.sortedTenants()); err != nil {
+			delete(m.tenants, tenant.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTenant implements TenantStore.
+func (m *Memory) GetTenant(_ context.Context, id string) (*models.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.tenants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+// ListTenants implements TenantStore.
+func (m *Memory) ListTenants(_ context.Context) ([]*models.Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sorted := m.sortedTenants()
+	tenants := make([]*models.Tenant, len(sorted))
+	for i := range sorted {
+		tenants[i] = &sorted[i]
+	}
+	return tenants, nil
+}
+
+// sortedTenants returns the tenants ordered by creation time. Callers hold m.mu.
+func (m *Memory) sortedTenants() []models.Tenant {
+	tenants := make([]models.Tenant, 0, len(m.tenants))
+	for _, t := range m.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		if !tenants[i].CreatedAt.Equal(tenants[j].CreatedAt) {
+			return tenants[i].CreatedAt.Before(tenants[j].CreatedAt)
+		}
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder(number, date string) models.Order {
+	return models.Order{OrderNumber: number, OrderDate: date}
+}
+
+func TestMemory_SaveOrder_UpsertsPerTenant(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	created, err := m.SaveOrder(ctx, &OrderRecord{TenantID: "t1", Order: testOrder("100", "2024-01-15"), ProcessingID: "p1"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	first, err := m.GetOrder(ctx, "t1", models.DefaultRetailer, "100")
+	require.NoError(t, err)
+
+	created, err = m.SaveOrder(ctx, &OrderRecord{TenantID: "t1", Order: testOrder("100", "2024-01-15"), ProcessingID: "p2"})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	second, err := m.GetOrder(ctx, "t1", models.DefaultRetailer, "100")
+	require.NoError(t, err)
+	assert.Equal(t, "p2", second.ProcessingID)
+	assert.Equal(t, first.ReceivedAt, second.ReceivedAt)
+
+	// The same order number belongs to each tenant independently
+	_, err = m.GetOrder(ctx, "t2", models.DefaultRetailer, "100")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemory_ListOrders_Filter(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	for _, o := range []models.Order{
+		testOrder("1", "2024-01-01"),
+		testOrder("2", "2024-02-01"),
+		testOrder("3", "2024-03-01T10:00:00Z"),
+	} {
+		_, err := m.SaveOrder(ctx, &OrderRecord{TenantID: "t1", Order: o})
+		require.NoError(t, err)
+	}
+	_, err := m.SaveOrder(ctx, &OrderRecord{TenantID: "t2", Order: testOrder("4", "2024-02-15")})
+	require.NoError(t, err)
+
+	all, err := m.ListOrders(ctx, "t1", OrderFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "3", all[0].Order.OrderNumber)
+
+	ranged, err := m.ListOrders(ctx, "t1", OrderFilter{From: "2024-02-01", To: "2024-03-01"})
+	require.NoError(t, err)
+	require.Len(t, ranged, 2)
+
+	limited, err := m.ListOrders(ctx, "t1", OrderFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, limited, 1)
+}
+
+func TestMemory_Tenants(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+
+	m := NewMemory()
+	require.NoError(t, m.LoadTenants(path))
+	require.NoError(t, m.CreateTenant(ctx, &models.Tenant{ID: "home", Name: "Home"}))
+	assert.ErrorIs(t, m.CreateTenant(ctx, &models.Tenant{ID: "home", Name: "Again"}), ErrExists)
+	require.NoError(t, EnsureTenant(ctx, m, &models.Tenant{ID: "home", Name: "Again"}))
+
+	// Act - reload from disk
+	reloaded := NewMemory()
+	require.NoError(t, reloaded.LoadTenants(path))
+
+	// Assert
+	tenant, err := reloaded.GetTenant(ctx, "home")
+	require.NoError(t, err)
+	assert.Equal(t, "Home", tenant.Name)
+	assert.False(t, tenant.CreatedAt.IsZero())
+
+	_, err = reloaded.GetTenant(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemory_PrunesOldestFinishedJobs(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	m := NewMemory()
+	pending := &Job{TenantID: "t1", Kind: "sync", RunAt: time.Now().Add(time.Hour)}
+	require.NoError(t, m.EnqueueJob(ctx, pending))
+
+	// Act
+	var first, last int64
+	for i := 0; i < maxFinishedJobs+1; i++ {
+		job := &Job{TenantID: "t1", Kind: "sync"}
+		require.NoError(t, m.EnqueueJob(ctx, job))
+		claimed, err := m.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, m.CompleteJob(ctx, claimed.ID, "w"))
+		if i == 0 {
+			first = claimed.ID
+		}
+		last = claimed.ID
+	}
+
+	// Assert
+	_, err := m.GetJob(ctx, "t1", first)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.GetJob(ctx, "t1", last)
+	assert.NoError(t, err)
+	_, err = m.GetJob(ctx, "t1", pending.ID)
+	assert.NoError(t, err, "jobs still to run are kept")
+	assert.Len(t, m.jobs, maxFinishedJobs+1)
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// RefundRecord is a refund as stored for a tenant.
+type RefundRecord struct {
+	TenantID   string        `json:"tenantId"`
+	Refund     models.Refund `json:"refund"`
+	ReceivedAt time.Time     `json:"receivedAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+// RefundCheck validates a refund against the other refunds stored for its order,
+// and may fill in the refund before it is saved. It runs in the same transaction
+// as the save, with no other refund of the order being saved, so refunds saved
+// at once are each checked against the other.
+type RefundCheck func(refund *models.Refund, earlier []models.Refund) error
+
+// RefundStore persists refunds per tenant.
+type RefundStore interface {
+	// SaveRefund inserts a refund, or replaces the tenant's existing refund with the
+	// same retailer and refund ID. It reports whether the refund was newly created.
+	// Refunds without a retailer are saved as models.DefaultRetailer. The refund's
+	// TransactionID is not saved: new refunds are unmatched and replaced ones keep
+	// theirs, which only ClaimTransaction and ReleaseTransaction change. A check,
+	// when given, is run first and nothing is saved if it fails.
+	SaveRefund(ctx context.Context, rec *RefundRecord, check RefundCheck) (created bool, err error)
+	// ListRefunds returns a tenant's refunds of an order from a retailer, oldest
+	// refund date first.
+	ListRefunds(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) ([]*RefundRecord, error)
+	// ClaimTransaction matches a stored refund to a Monarch transaction in one step,
+	// so that two refunds are never matched to the same transaction. It returns
+	// ErrExists if the refund is already matched or another of the tenant's
+	// refunds has the transaction, and ErrNotFound if the refund is not stored.
+	ClaimTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID, transactionID string) error
+	// ReleaseTransaction leaves a refund unmatched again, such as when its claimed
+	// transaction could not be split.
+	ReleaseTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID string) error
+}
@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"monarchmoney-sync-backend/store"
+)
+
+// AppendAudit implements store.AuditStore.
+func (s *Store) AppendAudit(ctx context.Context, entry *store.AuditEntry) error {
+	createdAt := entry.CreatedAt.UTC().Truncate(time.Microsecond)
+	if entry.CreatedAt.IsZero() {
+		createdAt = s.timestamp()
+	}
+
+	var id int64
+	err := s.queryRow(ctx, s.db.db, `INSERT INTO audit_log (tenant_id, actor, action, subject, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		entry.TenantID, entry.Actor, entry.Action, entry.Subject, nullJSON(entry.Details), createdAt).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	entry.ID = id
+	entry.CreatedAt = createdAt
+	return nil
+}
+
+// ListAudit implements store.AuditStore. Entries are ordered by insertion, which
+// is also creation order unless CreatedAt was set by the caller.
+func (s *Store) ListAudit(ctx context.Context, tenantID string, filter store.AuditFilter) ([]*store.AuditEntry, error) {
+	query := `SELECT id, tenant_id, actor, action, subject, details, created_at FROM audit_log WHERE tenant_id = ?`
+	args := []interface{}{tenantID}
+	if filter.Subject != "" {
+		query += ` AND subject = ?`
+		args = append(args, filter.Subject)
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, s.db.db, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*store.AuditEntry
+	for rows.Next() {
+		var e store.AuditEntry
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Actor, &e.Action, &e.Subject, &details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("list audit entries: %w", err)
+		}
+		if len(details) > 0 {
+			e.Details = json.RawMessage(details)
+		}
+		e.CreatedAt = e.CreatedAt.UTC()
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
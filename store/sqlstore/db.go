@@ -0,0 +1,139 @@
+// Package sqlstore keeps data in a SQL database selected by DATABASE_URL, and
+// manages the database schema with versioned migrations embedded in the binary.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Registers the "pgx" driver for PostgreSQL.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	// Registers the pure Go "sqlite" driver, so no C toolchain is needed.
+	_ "modernc.org/sqlite"
+)
+
+// Dialect is the SQL flavor a database speaks. Migrations are kept per dialect.
+type Dialect string
+
+// Supported dialects.
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// Options configures the connection pool. SQLite always uses one connection.
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultOptions returns pool settings suited to a single small server.
+func DefaultOptions() Options {
+	return Options{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+	}
+}
+
+// DB is an open database connection pool.
+type DB struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// Open connects to the database named by url. SQLite URLs take the form
+// sqlite:///absolute/path.db, sqlite://relative/path.db, or sqlite://:memory:;
+// PostgreSQL URLs are postgres:// or postgresql:// connection strings.
+func Open(url string, opts Options) (*DB, error) {
+	dialect, driver, dsn, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if dialect == DialectSQLite {
+		// SQLite allows one writer at a time, and every connection to :memory:
+		// would otherwise see its own empty database
+		db.SetMaxOpenConns(1)
+	} else {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return &DB{db: db, dialect: dialect}, nil
+}
+
+// parseURL maps a DATABASE_URL to a dialect, driver name, and data source name.
+func parseURL(url string) (dialect Dialect, driver, dsn string, err error) {
+	scheme, rest, ok := strings.Cut(url, ":")
+	if !ok || scheme == "" {
+		return "", "", "", fmt.Errorf("invalid database URL %q: missing scheme", url)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "sqlite", "sqlite3":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return "", "", "", fmt.Errorf("invalid database URL %q: missing file path", url)
+		}
+		params := "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+		if path != ":memory:" {
+			params += "&_pragma=journal_mode(WAL)"
+		}
+		return DialectSQLite, "sqlite", "file:" + path + params, nil
+	case "postgres", "postgresql":
+		return DialectPostgres, "pgx", url, nil
+	default:
+		return "", "", "", fmt.Errorf("unsupported database URL scheme %q (want sqlite or postgres)", scheme)
+	}
+}
+
+// Dialect returns the SQL flavor of the database.
+func (d *DB) Dialect() Dialect {
+	return d.dialect
+}
+
+// Ping checks that the database is reachable.
+func (d *DB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Close closes every connection in the pool.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... for PostgreSQL. Queries in this
+// package never contain a literal question mark.
+func (d *DB) rebind(query string) string {
+	if d.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
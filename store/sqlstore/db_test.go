@@ -0,0 +1,69 @@
+package sqlstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		dialect Dialect
+		path    string
+		wantErr bool
+	}{
+		{url: "sqlite:///var/lib/sync/data.db", dialect: DialectSQLite, path: "file:/var/lib/sync/data.db?"},
+		{url: "sqlite://data.db", dialect: DialectSQLite, path: "file:data.db?"},
+		{url: "sqlite:data.db", dialect: DialectSQLite, path: "file:data.db?"},
+		{url: "sqlite://:memory:", dialect: DialectSQLite, path: "file::memory:?"},
+		{url: "postgresql://sync:pw@db:5432/sync?sslmode=require", dialect: DialectPostgres, path: "postgresql://sync:pw@db:5432/sync"},
+		{url: "postgres://localhost/sync", dialect: DialectPostgres, path: "postgres://localhost/sync"},
+		{url: "sqlite://", wantErr: true},
+		{url: "mysql://localhost/sync", wantErr: true},
+		{url: "data.db", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			dialect, _, dsn, err := parseURL(tt.url)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.dialect, dialect)
+			assert.Contains(t, dsn, tt.path)
+			if dialect == DialectSQLite {
+				assert.Contains(t, dsn, "foreign_keys(1)")
+			}
+		})
+	}
+}
+
+func TestOpen_File(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "sync.db")
+
+	// Act
+	db, err := Open("sqlite://"+path, DefaultOptions())
+
+	// Assert
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, DialectSQLite, db.Dialect())
+	assert.NoError(t, db.Ping(context.Background()))
+	assert.FileExists(t, path)
+}
+
+func TestDB_Rebind(t *testing.T) {
+	query := `SELECT id FROM jobs WHERE status = ? AND run_at <= ? LIMIT ?`
+
+	assert.Equal(t, query, (&DB{dialect: DialectSQLite}).rebind(query))
+	assert.Equal(t, `SELECT id FROM jobs WHERE status = $1 AND run_at <= $2 LIMIT $3`,
+		(&DB{dialect: DialectPostgres}).rebind(query))
+}
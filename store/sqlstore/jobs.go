@@ -0,0 +1,162 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"monarchmoney-sync-backend/store"
+)
+
+const jobColumns = `id, tenant_id, kind, payload, status, attempts, last_error, run_at,
+	locked_by, locked_at, created_at, updated_at`
+
+// EnqueueJob implements store.JobStore.
+func (s *Store) EnqueueJob(ctx context.Context, job *store.Job) error {
+	now := s.timestamp()
+	runAt := job.RunAt.UTC().Truncate(time.Microsecond)
+	if job.RunAt.IsZero() {
+		runAt = now
+	}
+
+	var id int64
+	err := s.queryRow(ctx, s.db.db, `INSERT INTO jobs
+		(tenant_id, kind, payload, status, attempts, last_error, run_at, locked_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, '', ?, '', ?, ?)
+		RETURNING id`,
+		job.TenantID, job.Kind, nullJSON(job.Payload), store.JobPending, runAt, now, now).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("enqueue job: %w", err)
+	}
+
+	job.ID = id
+	job.Status = store.JobPending
+	job.Attempts = 0
+	job.LastError = ""
+	job.RunAt = runAt
+	job.LockedBy, job.LockedAt = "", nil
+	job.CreatedAt, job.UpdatedAt = now, now
+	return nil
+}
+
+// ClaimJob implements store.JobStore. On PostgreSQL, FOR UPDATE SKIP LOCKED lets
+// replicas claim different jobs concurrently without waiting on each other; SQLite
+// serializes writers, so the plain subquery is already safe there.
+func (s *Store) ClaimJob(ctx context.Context, worker string, lease time.Duration) (*store.Job, error) {
+	now := s.timestamp()
+	lock := ""
+	if s.db.dialect == DialectPostgres {
+		lock = ` FOR UPDATE SKIP LOCKED`
+	}
+
+	row := s.queryRow(ctx, s.db.db, `UPDATE jobs
+		SET status = ?, attempts = attempts + 1, locked_by = ?, locked_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)
+			ORDER BY run_at, id
+			LIMIT 1`+lock+`
+		)
+		RETURNING `+jobColumns,
+		store.JobRunning, worker, now, now,
+		store.JobPending, now, store.JobRunning, now.Add(-lease))
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNoJobs
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job: %w", err)
+	}
+	return job, nil
+}
+
+// CompleteJob implements store.JobStore.
+func (s *Store) CompleteJob(ctx context.Context, id int64, worker string) error {
+	res, err := s.exec(ctx, s.db.db, `UPDATE jobs
+		SET status = ?, last_error = '', locked_by = '', locked_at = NULL, updated_at = ?
+		WHERE id = ? AND status = ? AND locked_by = ?`,
+		store.JobSucceeded, s.timestamp(), id, store.JobRunning, worker)
+	return updated(res, err, "complete job")
+}
+
+// FailJob implements store.JobStore.
+func (s *Store) FailJob(ctx context.Context, id int64, worker, reason string, retryAt *time.Time) error {
+	now := s.timestamp()
+	status, runAt := store.JobFailed, now
+	if retryAt != nil {
+		status, runAt = store.JobPending, retryAt.UTC().Truncate(time.Microsecond)
+	}
+	res, err := s.exec(ctx, s.db.db, `UPDATE jobs
+		SET status = ?, last_error = ?, run_at = ?, locked_by = '', locked_at = NULL, updated_at = ?
+		WHERE id = ? AND status = ? AND locked_by = ?`,
+		status, reason, runAt, now, id, store.JobRunning, worker)
+	return updated(res, err, "fail job")
+}
+
+// RescheduleJob implements store.JobStore.
+func (s *Store) RescheduleJob(ctx context.Context, id int64, worker, reason string, runAt time.Time) error {
+	res, err := s.exec(ctx, s.db.db, `UPDATE jobs
+		SET status = ?, attempts = attempts - 1, last_error = ?, run_at = ?, locked_by = '', locked_at = NULL, updated_at = ?
+		WHERE id = ? AND status = ? AND locked_by = ?`,
+		store.JobPending, reason, runAt.UTC().Truncate(time.Microsecond), s.timestamp(), id, store.JobRunning, worker)
+	return updated(res, err, "reschedule job")
+}
+
+// GetJob implements store.JobStore.
+func (s *Store) GetJob(ctx context.Context, tenantID string, id int64) (*store.Job, error) {
+	job, err := scanJob(s.queryRow(ctx, s.db.db, `SELECT `+jobColumns+` FROM jobs WHERE tenant_id = ? AND id = ?`,
+		tenantID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job: %w", err)
+	}
+	return job, nil
+}
+
+func scanJob(row *sql.Row) (*store.Job, error) {
+	var job store.Job
+	var payload []byte
+	var lockedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.TenantID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.LastError,
+		&job.RunAt, &job.LockedBy, &lockedAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		job.Payload = json.RawMessage(payload)
+	}
+	if lockedAt.Valid {
+		t := lockedAt.Time.UTC()
+		job.LockedAt = &t
+	}
+	job.RunAt, job.CreatedAt, job.UpdatedAt = job.RunAt.UTC(), job.CreatedAt.UTC(), job.UpdatedAt.UTC()
+	return &job, nil
+}
+
+// updated maps an UPDATE that matched no rows to store.ErrNotFound.
+func updated(res sql.Result, err error, op string) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// nullJSON stores an empty JSON document as NULL.
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
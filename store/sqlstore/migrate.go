@@ -0,0 +1,339 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the schema for each dialect as migrations/<dialect>/
+// NNNN_name.up.sql and NNNN_name.down.sql pairs, numbered from 1 without gaps.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Errors returned by Migrator.Check and Migrator.Up.
+var (
+	ErrSchemaTooNew   = errors.New("database schema is newer than this binary")
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+)
+
+// Migration is one schema version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a schema version and when it was applied, if it has been.
+// Known is false for versions recorded by a newer binary.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Known     bool
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// loadMigrations reads migration pairs from dir, ordered by version.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: names %q and %q differ", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mig := range migrations {
+		if mig.Version != i+1 {
+			return nil, fmt.Errorf("migration %d_%s: expected version %d", mig.Version, mig.Name, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrator applies and reverts schema migrations, recording applied versions in
+// the schema_migrations table.
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+	now        func() time.Time
+}
+
+// NewMigrator creates a migrator for the migrations embedded for db's dialect.
+func NewMigrator(db *DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", string(db.dialect)))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, now: time.Now}, nil
+}
+
+// Latest returns the newest schema version this binary knows.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the newest schema version applied to the database, or 0 for an
+// empty database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return newest(applied), nil
+}
+
+// Status lists every known migration and every applied one, oldest first.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name, Known: true}
+		if a, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &a.at
+		}
+		statuses = append(statuses, s)
+	}
+	for version, a := range applied {
+		if version > m.Latest() {
+			at := a.at
+			statuses = append(statuses, MigrationStatus{Version: version, Name: a.name, AppliedAt: &at})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check returns ErrSchemaTooNew when the database was migrated by a newer binary,
+// and ErrSchemaOutdated when migrations are pending.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.checkNotNewer(applied); err != nil {
+		return err
+	}
+	if pending := m.pending(applied); len(pending) > 0 {
+		return fmt.Errorf("%w: %d migrations pending (run migrate)", ErrSchemaOutdated, len(pending))
+	}
+	return nil
+}
+
+// Up applies every pending migration, each in its own transaction, and returns
+// the ones applied. It refuses to touch a schema newer than this binary.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkNotNewer(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.pending(applied) {
+		mig := mig
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			// Another replica may have applied it since applied was read
+			if ok, err := m.isApplied(ctx, tx, mig.Version); err != nil || ok {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.db.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				mig.Version, mig.Name, m.now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the newest steps applied migrations, newest first, and returns the
+// ones reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkNotNewer(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if ok, err := m.isApplied(ctx, tx, mig.Version); err != nil || !ok {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.db.rebind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+type appliedMigration struct {
+	name string
+	at   time.Time
+}
+
+// applied returns the applied versions, creating the schema_migrations table on
+// first use.
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	timestamp := "TIMESTAMP"
+	if m.db.dialect == DialectPostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at `+timestamp+` NOT NULL
+		)`)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.at); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		a.at = a.at.UTC()
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) checkNotNewer(applied map[int]appliedMigration) error {
+	if v := newest(applied); v > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, this binary knows up to %d", ErrSchemaTooNew, v, m.Latest())
+	}
+	return nil
+}
+
+// pending returns known migrations that have not been applied, oldest first.
+func (m *Migrator) pending(applied map[int]appliedMigration) []Migration {
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// migrationLockID is the PostgreSQL advisory lock that serializes migrations
+// across replicas starting at the same time.
+const migrationLockID = 7_041_946_312
+
+// inTx runs fn in a transaction, holding the migration lock on PostgreSQL. SQLite
+// transactions are already serialized.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if m.db.dialect == DialectPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("lock migrations: %w", err)
+		}
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) isApplied(ctx context.Context, tx *sql.Tx, version int) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, m.db.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), version).Scan(&n)
+	return n > 0, err
+}
+
+func newest(applied map[int]appliedMigration) int {
+	v := 0
+	for version := range applied {
+		if version > v {
+			v = version
+		}
+	}
+	return v
+}
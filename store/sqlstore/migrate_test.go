@@ -0,0 +1,294 @@
+package sqlstore
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open("sqlite://:memory:", DefaultOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"m/0001_widgets.up.sql":     {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		"m/0001_widgets.down.sql":   {Data: []byte("DROP TABLE widgets;")},
+		"m/0002_gadgets.up.sql":     {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY); CREATE INDEX gadgets_id ON gadgets (id);")},
+		"m/0002_gadgets.down.sql":   {Data: []byte("DROP TABLE gadgets;")},
+		"m/0003_sprockets.up.sql":   {Data: []byte("CREATE TABLE sprockets (id INTEGER PRIMARY KEY);")},
+		"m/0003_sprockets.down.sql": {Data: []byte("DROP TABLE sprockets;")},
+	}
+}
+
+func testMigrator(t *testing.T, db *DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	migrations, err := loadMigrations(fsys, "m")
+	require.NoError(t, err)
+	return &Migrator{db: db, migrations: migrations, now: func() time.Time {
+		return time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	}}
+}
+
+func tableExists(t *testing.T, db *DB, name string) bool {
+	t.Helper()
+	var n int
+	err := db.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	require.NoError(t, err)
+	return n == 1
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	db := openTestDB(t)
+
+	m, err := NewMigrator(db)
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, m.Latest())
+	assert.Equal(t, "initial", m.migrations[0].Name)
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name:  "bad file name",
+			files: fstest.MapFS{"m/widgets.sql": {}},
+			want:  "name must be",
+		},
+		{
+			name:  "missing down",
+			files: fstest.MapFS{"m/0001_widgets.up.sql": {Data: []byte("SELECT 1;")}},
+			want:  "needs both up and down",
+		},
+		{
+			name: "gap",
+			files: fstest.MapFS{
+				"m/0002_widgets.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0002_widgets.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			want: "expected version 1",
+		},
+		{
+			name: "mismatched names",
+			files: fstest.MapFS{
+				"m/0001_widgets.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0001_gadgets.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			want: "differ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, "m")
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	// Arrange
+	db := openTestDB(t)
+	m := testMigrator(t, db, testMigrations())
+	ctx := context.Background()
+
+	// Act
+	applied, err := m.Up(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, applied, 3)
+	assert.True(t, tableExists(t, db, "widgets"))
+	assert.True(t, tableExists(t, db, "sprockets"))
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.NoError(t, m.Check(ctx))
+
+	again, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+}
+
+func TestMigrator_Down(t *testing.T) {
+	// Arrange
+	db := openTestDB(t)
+	m := testMigrator(t, db, testMigrations())
+	ctx := context.Background()
+	_, err := m.Up(ctx)
+	require.NoError(t, err)
+
+	// Act
+	reverted, err := m.Down(ctx, 2)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	assert.Equal(t, 3, reverted[0].Version)
+	assert.Equal(t, 2, reverted[1].Version)
+	assert.True(t, tableExists(t, db, "widgets"))
+	assert.False(t, tableExists(t, db, "gadgets"))
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.ErrorIs(t, m.Check(ctx), ErrSchemaOutdated)
+}
+
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	// Arrange
+	db := openTestDB(t)
+	files := testMigrations()
+	files["m/0002_gadgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE gadgets (id INTEGER); SELECT * FROM missing;")}
+	m := testMigrator(t, db, files)
+	ctx := context.Background()
+
+	// Act
+	applied, err := m.Up(ctx)
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "apply migration 2_gadgets")
+	assert.Len(t, applied, 1)
+	assert.False(t, tableExists(t, db, "gadgets"))
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+}
+
+func TestMigrator_RefusesNewerSchema(t *testing.T) {
+	// Arrange - a newer binary applied all three migrations
+	db := openTestDB(t)
+	ctx := context.Background()
+	_, err := testMigrator(t, db, testMigrations()).Up(ctx)
+	require.NoError(t, err)
+
+	older := testMigrations()
+	delete(older, "m/0003_sprockets.up.sql")
+	delete(older, "m/0003_sprockets.down.sql")
+	m := testMigrator(t, db, older)
+
+	// Act & Assert
+	assert.ErrorIs(t, m.Check(ctx), ErrSchemaTooNew)
+	_, err = m.Up(ctx)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+	_, err = m.Down(ctx, 1)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+	assert.True(t, tableExists(t, db, "sprockets"))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.False(t, statuses[2].Known)
+	assert.Equal(t, "sprockets", statuses[2].Name)
+}
+
+func TestMigrator_Status(t *testing.T) {
+	// Arrange
+	db := openTestDB(t)
+	m := testMigrator(t, db, testMigrations())
+	ctx := context.Background()
+	_, err := m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+
+	// Act
+	statuses, err := m.Status(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	require.NotNil(t, statuses[0].AppliedAt)
+	assert.True(t, statuses[0].AppliedAt.Equal(m.now()))
+	assert.NotNil(t, statuses[1].AppliedAt)
+	assert.Nil(t, statuses[2].AppliedAt)
+	assert.True(t, statuses[2].Known)
+}
+
+func TestMigrator_EmbeddedSchemaRoundTrips(t *testing.T) {
+	// Arrange
+	db := openTestDB(t)
+	m, err := NewMigrator(db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Act & Assert
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.True(t, tableExists(t, db, "orders"))
+
+	_, err = m.Down(ctx, m.Latest())
+	require.NoError(t, err)
+	assert.False(t, tableExists(t, db, "orders"))
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, m.Check(ctx))
+}
+
+func TestMigrator_RetailerKeysKeepOrders(t *testing.T) {
+	// Arrange: an order and refund stored before order numbers were per retailer
+	db := openTestDB(t)
+	m, err := NewMigrator(db)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, 2)
+	require.NoError(t, err)
+	for _, stmt := range []string{
+		`INSERT INTO tenants (id, name, created_at) VALUES ('t1', 'T1', '2024-01-01 00:00:00')`,
+		`INSERT INTO orders (tenant_id, retailer, order_number, order_date, processing_id, received_at, updated_at)
+			VALUES ('t1', 'amazon', '100', '2024-01-15', 'p1', '2024-01-15 00:00:00', '2024-01-15 00:00:00')`,
+		`INSERT INTO order_items (tenant_id, order_number, position, name, price, quantity)
+			VALUES ('t1', '100', 0, 'Cable', 9.99, 1)`,
+		`INSERT INTO refunds (tenant_id, refund_id, retailer, order_number, refund_date, amount, transaction_id,
+			received_at, updated_at)
+			VALUES ('t1', 'R1', 'amazon', '100', '2024-01-20', 9.99, 'txn-1', '2024-01-20 00:00:00', '2024-01-20 00:00:00')`,
+		`INSERT INTO refunds (tenant_id, refund_id, retailer, order_number, refund_date, amount, received_at, updated_at)
+			VALUES ('t1', 'R2', 'amazon', '100', '2024-01-21', 1, '2024-01-21 00:00:00', '2024-01-21 00:00:00')`,
+		`INSERT INTO refund_items (tenant_id, refund_id, position, name, quantity, price)
+			VALUES ('t1', 'R1', 0, 'Cable', 1, 9.99)`,
+	} {
+		_, err := db.db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	// Act
+	_, err = m.Up(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	s := New(db)
+	rec, err := s.GetOrder(ctx, "t1", models.RetailerAmazon, "100")
+	require.NoError(t, err)
+	require.Len(t, rec.Order.Items, 1)
+	assert.Equal(t, "Cable", rec.Order.Items[0].Name)
+	refunds, err := s.ListRefunds(ctx, "t1", models.RetailerAmazon, "100")
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	assert.Len(t, refunds[0].Refund.Items, 1)
+	assert.Equal(t, "txn-1", refunds[0].Refund.TransactionID)
+	err = s.ClaimTransaction(ctx, "t1", models.RetailerAmazon, "R2", "txn-1")
+	assert.ErrorIs(t, err, store.ErrExists, "matched refunds keep their transactions claimed")
+}
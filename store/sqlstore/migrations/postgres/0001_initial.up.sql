@@ -0,0 +1,34 @@
+CREATE TABLE tenants (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE orders (
+    tenant_id        TEXT NOT NULL REFERENCES tenants (id),
+    order_number     TEXT NOT NULL,
+    order_date       TEXT NOT NULL,
+    order_total      DOUBLE PRECISION,
+    tax              DOUBLE PRECISION,
+    delivery_charges DOUBLE PRECISION,
+    tip              DOUBLE PRECISION,
+    processing_id    TEXT NOT NULL,
+    received_at      TIMESTAMPTZ NOT NULL,
+    updated_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, order_number)
+);
+
+CREATE INDEX orders_tenant_date ON orders (tenant_id, order_date);
+
+CREATE TABLE order_items (
+    tenant_id    TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    name         TEXT NOT NULL,
+    price        DOUBLE PRECISION NOT NULL,
+    quantity     INTEGER NOT NULL,
+    product_url  TEXT NOT NULL DEFAULT '',
+    category     TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, order_number, position),
+    FOREIGN KEY (tenant_id, order_number) REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE
+);
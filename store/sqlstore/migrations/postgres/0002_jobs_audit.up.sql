@@ -0,0 +1,28 @@
+CREATE TABLE jobs (
+    id         BIGSERIAL PRIMARY KEY,
+    tenant_id  TEXT NOT NULL REFERENCES tenants (id),
+    kind       TEXT NOT NULL,
+    payload    JSONB,
+    status     TEXT NOT NULL,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    run_at     TIMESTAMPTZ NOT NULL,
+    locked_by  TEXT NOT NULL DEFAULT '',
+    locked_at  TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX jobs_due ON jobs (status, run_at);
+
+CREATE TABLE audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    tenant_id  TEXT NOT NULL REFERENCES tenants (id),
+    actor      TEXT NOT NULL,
+    action     TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    details    JSONB,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_log_tenant_subject ON audit_log (tenant_id, subject);
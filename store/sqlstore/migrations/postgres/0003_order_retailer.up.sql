@@ -0,0 +1,4 @@
+-- Orders received before other retailers were supported are all from Walmart
+ALTER TABLE orders ADD COLUMN retailer TEXT NOT NULL DEFAULT 'walmart';
+
+CREATE INDEX orders_tenant_retailer_date ON orders (tenant_id, retailer, order_date);
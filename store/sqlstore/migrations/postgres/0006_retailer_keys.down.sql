@@ -0,0 +1,35 @@
+-- Order numbers and refund IDs go back to being unique per tenant; where two
+-- retailers share one, only one of them is kept.
+DELETE FROM orders o USING orders other
+WHERE other.tenant_id = o.tenant_id AND other.order_number = o.order_number AND other.retailer < o.retailer;
+DELETE FROM refunds r USING refunds other
+WHERE other.tenant_id = r.tenant_id AND other.refund_id = r.refund_id AND other.retailer < r.retailer;
+
+DROP INDEX refunds_tenant_order;
+CREATE INDEX refunds_tenant_order ON refunds (tenant_id, order_number);
+
+ALTER TABLE order_items DROP CONSTRAINT order_items_order_fkey;
+ALTER TABLE order_shipments DROP CONSTRAINT order_shipments_order_fkey;
+ALTER TABLE refund_items DROP CONSTRAINT refund_items_refund_fkey;
+
+ALTER TABLE orders DROP CONSTRAINT orders_pkey,
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (tenant_id, order_number);
+ALTER TABLE order_items DROP CONSTRAINT order_items_pkey,
+    ADD CONSTRAINT order_items_pkey PRIMARY KEY (tenant_id, order_number, position);
+ALTER TABLE order_shipments DROP CONSTRAINT order_shipments_pkey,
+    ADD CONSTRAINT order_shipments_pkey PRIMARY KEY (tenant_id, order_number, position);
+ALTER TABLE refunds DROP CONSTRAINT refunds_pkey,
+    ADD CONSTRAINT refunds_pkey PRIMARY KEY (tenant_id, refund_id);
+ALTER TABLE refund_items DROP CONSTRAINT refund_items_pkey,
+    ADD CONSTRAINT refund_items_pkey PRIMARY KEY (tenant_id, refund_id, position);
+
+ALTER TABLE order_items DROP COLUMN retailer;
+ALTER TABLE order_shipments DROP COLUMN retailer;
+ALTER TABLE refund_items DROP COLUMN retailer;
+
+ALTER TABLE order_items ADD CONSTRAINT order_items_tenant_id_order_number_fkey FOREIGN KEY (tenant_id, order_number)
+    REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE;
+ALTER TABLE order_shipments ADD CONSTRAINT order_shipments_tenant_id_order_number_fkey
+    FOREIGN KEY (tenant_id, order_number) REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE;
+ALTER TABLE refund_items ADD CONSTRAINT refund_items_tenant_id_refund_id_fkey FOREIGN KEY (tenant_id, refund_id)
+    REFERENCES refunds (tenant_id, refund_id) ON DELETE CASCADE;
@@ -0,0 +1,40 @@
+-- Order numbers and refund IDs are only unique per retailer
+ALTER TABLE order_items DROP CONSTRAINT order_items_tenant_id_order_number_fkey;
+ALTER TABLE order_shipments DROP CONSTRAINT order_shipments_tenant_id_order_number_fkey;
+ALTER TABLE refund_items DROP CONSTRAINT refund_items_tenant_id_refund_id_fkey;
+
+ALTER TABLE order_items ADD COLUMN retailer TEXT;
+UPDATE order_items i SET retailer = o.retailer
+FROM orders o WHERE o.tenant_id = i.tenant_id AND o.order_number = i.order_number;
+ALTER TABLE order_items ALTER COLUMN retailer SET NOT NULL;
+
+ALTER TABLE order_shipments ADD COLUMN retailer TEXT;
+UPDATE order_shipments s SET retailer = o.retailer
+FROM orders o WHERE o.tenant_id = s.tenant_id AND o.order_number = s.order_number;
+ALTER TABLE order_shipments ALTER COLUMN retailer SET NOT NULL;
+
+ALTER TABLE refund_items ADD COLUMN retailer TEXT;
+UPDATE refund_items i SET retailer = r.retailer
+FROM refunds r WHERE r.tenant_id = i.tenant_id AND r.refund_id = i.refund_id;
+ALTER TABLE refund_items ALTER COLUMN retailer SET NOT NULL;
+
+ALTER TABLE orders DROP CONSTRAINT orders_pkey,
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (tenant_id, retailer, order_number);
+ALTER TABLE order_items DROP CONSTRAINT order_items_pkey,
+    ADD CONSTRAINT order_items_pkey PRIMARY KEY (tenant_id, retailer, order_number, position);
+ALTER TABLE order_shipments DROP CONSTRAINT order_shipments_pkey,
+    ADD CONSTRAINT order_shipments_pkey PRIMARY KEY (tenant_id, retailer, order_number, position);
+ALTER TABLE refunds DROP CONSTRAINT refunds_pkey,
+    ADD CONSTRAINT refunds_pkey PRIMARY KEY (tenant_id, retailer, refund_id);
+ALTER TABLE refund_items DROP CONSTRAINT refund_items_pkey,
+    ADD CONSTRAINT refund_items_pkey PRIMARY KEY (tenant_id, retailer, refund_id, position);
+
+ALTER TABLE order_items ADD CONSTRAINT order_items_order_fkey FOREIGN KEY (tenant_id, retailer, order_number)
+    REFERENCES orders (tenant_id, retailer, order_number) ON DELETE CASCADE;
+ALTER TABLE order_shipments ADD CONSTRAINT order_shipments_order_fkey FOREIGN KEY (tenant_id, retailer, order_number)
+    REFERENCES orders (tenant_id, retailer, order_number) ON DELETE CASCADE;
+ALTER TABLE refund_items ADD CONSTRAINT refund_items_refund_fkey FOREIGN KEY (tenant_id, retailer, refund_id)
+    REFERENCES refunds (tenant_id, retailer, refund_id) ON DELETE CASCADE;
+
+DROP INDEX refunds_tenant_order;
+CREATE INDEX refunds_tenant_order ON refunds (tenant_id, retailer, order_number);
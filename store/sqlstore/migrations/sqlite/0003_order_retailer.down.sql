@@ -0,0 +1,2 @@
+DROP INDEX orders_tenant_retailer_date;
+ALTER TABLE orders DROP COLUMN retailer;
@@ -0,0 +1,2 @@
+ALTER TABLE order_items DROP COLUMN shipment_id;
+DROP TABLE order_shipments;
@@ -0,0 +1,14 @@
+CREATE TABLE order_shipments (
+    tenant_id    TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    shipment_id  TEXT NOT NULL,
+    ship_date    TEXT NOT NULL DEFAULT '',
+    tracking     TEXT NOT NULL DEFAULT '',
+    total        REAL NOT NULL,
+    tax          REAL,
+    PRIMARY KEY (tenant_id, order_number, position),
+    FOREIGN KEY (tenant_id, order_number) REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE
+);
+
+ALTER TABLE order_items ADD COLUMN shipment_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,27 @@
+CREATE TABLE refunds (
+    tenant_id      TEXT NOT NULL REFERENCES tenants (id),
+    refund_id      TEXT NOT NULL,
+    retailer       TEXT NOT NULL,
+    order_number   TEXT NOT NULL,
+    refund_date    TEXT NOT NULL,
+    amount         REAL NOT NULL,
+    transaction_id TEXT NOT NULL DEFAULT '',
+    received_at    TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, refund_id)
+);
+
+CREATE INDEX refunds_tenant_order ON refunds (tenant_id, order_number);
+
+CREATE TABLE refund_items (
+    tenant_id TEXT NOT NULL,
+    refund_id TEXT NOT NULL,
+    position  INTEGER NOT NULL,
+    name      TEXT NOT NULL,
+    quantity  INTEGER NOT NULL,
+    amount    REAL,
+    price     REAL NOT NULL,
+    category  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, refund_id, position),
+    FOREIGN KEY (tenant_id, refund_id) REFERENCES refunds (tenant_id, refund_id) ON DELETE CASCADE
+);
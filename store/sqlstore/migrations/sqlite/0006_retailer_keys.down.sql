@@ -0,0 +1,121 @@
+-- Order numbers and refund IDs go back to being unique per tenant; where two
+-- retailers share one, only one of them is kept.
+CREATE TABLE orders_new AS SELECT * FROM orders;
+CREATE TABLE order_items_new AS SELECT * FROM order_items;
+CREATE TABLE order_shipments_new AS SELECT * FROM order_shipments;
+CREATE TABLE refunds_new AS SELECT * FROM refunds;
+CREATE TABLE refund_items_new AS SELECT * FROM refund_items;
+
+DROP TABLE order_items;
+DROP TABLE order_shipments;
+DROP TABLE orders;
+DROP TABLE refund_items;
+DROP TABLE refunds;
+
+CREATE TABLE orders (
+    tenant_id        TEXT NOT NULL REFERENCES tenants (id),
+    order_number     TEXT NOT NULL,
+    order_date       TEXT NOT NULL,
+    order_total      REAL,
+    tax              REAL,
+    delivery_charges REAL,
+    tip              REAL,
+    processing_id    TEXT NOT NULL,
+    received_at      TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL,
+    retailer         TEXT NOT NULL DEFAULT 'walmart',
+    PRIMARY KEY (tenant_id, order_number)
+);
+
+CREATE INDEX orders_tenant_date ON orders (tenant_id, order_date);
+CREATE INDEX orders_tenant_retailer_date ON orders (tenant_id, retailer, order_date);
+
+CREATE TABLE order_items (
+    tenant_id    TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    name         TEXT NOT NULL,
+    price        REAL NOT NULL,
+    quantity     INTEGER NOT NULL,
+    product_url  TEXT NOT NULL DEFAULT '',
+    category     TEXT NOT NULL DEFAULT '',
+    shipment_id  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, order_number, position),
+    FOREIGN KEY (tenant_id, order_number) REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE
+);
+
+CREATE TABLE order_shipments (
+    tenant_id    TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    shipment_id  TEXT NOT NULL,
+    ship_date    TEXT NOT NULL DEFAULT '',
+    tracking     TEXT NOT NULL DEFAULT '',
+    total        REAL NOT NULL,
+    tax          REAL,
+    PRIMARY KEY (tenant_id, order_number, position),
+    FOREIGN KEY (tenant_id, order_number) REFERENCES orders (tenant_id, order_number) ON DELETE CASCADE
+);
+
+CREATE TABLE refunds (
+    tenant_id      TEXT NOT NULL REFERENCES tenants (id),
+    refund_id      TEXT NOT NULL,
+    retailer       TEXT NOT NULL,
+    order_number   TEXT NOT NULL,
+    refund_date    TEXT NOT NULL,
+    amount         REAL NOT NULL,
+    transaction_id TEXT NOT NULL DEFAULT '',
+    received_at    TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, refund_id)
+);
+
+CREATE INDEX refunds_tenant_order ON refunds (tenant_id, order_number);
+
+CREATE TABLE refund_items (
+    tenant_id TEXT NOT NULL,
+    refund_id TEXT NOT NULL,
+    position  INTEGER NOT NULL,
+    name      TEXT NOT NULL,
+    quantity  INTEGER NOT NULL,
+    amount    REAL,
+    price     REAL NOT NULL,
+    category  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, refund_id, position),
+    FOREIGN KEY (tenant_id, refund_id) REFERENCES refunds (tenant_id, refund_id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO orders (tenant_id, order_number, order_date, order_total, tax, delivery_charges, tip,
+    processing_id, received_at, updated_at, retailer)
+SELECT tenant_id, order_number, order_date, order_total, tax, delivery_charges, tip,
+    processing_id, received_at, updated_at, retailer
+FROM orders_new;
+
+INSERT INTO order_items (tenant_id, order_number, position, name, price, quantity, product_url, category,
+    shipment_id)
+SELECT i.tenant_id, i.order_number, i.position, i.name, i.price, i.quantity, i.product_url, i.category,
+    i.shipment_id
+FROM order_items_new i
+JOIN orders o ON o.tenant_id = i.tenant_id AND o.retailer = i.retailer AND o.order_number = i.order_number;
+
+INSERT INTO order_shipments (tenant_id, order_number, position, shipment_id, ship_date, tracking, total, tax)
+SELECT s.tenant_id, s.order_number, s.position, s.shipment_id, s.ship_date, s.tracking, s.total, s.tax
+FROM order_shipments_new s
+JOIN orders o ON o.tenant_id = s.tenant_id AND o.retailer = s.retailer AND o.order_number = s.order_number;
+
+INSERT OR IGNORE INTO refunds (tenant_id, refund_id, retailer, order_number, refund_date, amount,
+    transaction_id, received_at, updated_at)
+SELECT tenant_id, refund_id, retailer, order_number, refund_date, amount,
+    transaction_id, received_at, updated_at
+FROM refunds_new;
+
+INSERT INTO refund_items (tenant_id, refund_id, position, name, quantity, amount, price, category)
+SELECT i.tenant_id, i.refund_id, i.position, i.name, i.quantity, i.amount, i.price, i.category
+FROM refund_items_new i
+JOIN refunds r ON r.tenant_id = i.tenant_id AND r.retailer = i.retailer AND r.refund_id = i.refund_id;
+
+DROP TABLE orders_new;
+DROP TABLE order_items_new;
+DROP TABLE order_shipments_new;
+DROP TABLE refunds_new;
+DROP TABLE refund_items_new;
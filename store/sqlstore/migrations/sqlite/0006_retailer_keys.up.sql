@@ -0,0 +1,129 @@
+-- Order numbers and refund IDs are only unique per retailer. SQLite cannot change
+-- a primary key, so the tables are copied aside and rebuilt.
+CREATE TABLE orders_old AS SELECT * FROM orders;
+CREATE TABLE order_items_old AS SELECT * FROM order_items;
+CREATE TABLE order_shipments_old AS SELECT * FROM order_shipments;
+CREATE TABLE refunds_old AS SELECT * FROM refunds;
+CREATE TABLE refund_items_old AS SELECT * FROM refund_items;
+
+DROP TABLE order_items;
+DROP TABLE order_shipments;
+DROP TABLE orders;
+DROP TABLE refund_items;
+DROP TABLE refunds;
+
+CREATE TABLE orders (
+    tenant_id        TEXT NOT NULL REFERENCES tenants (id),
+    retailer         TEXT NOT NULL,
+    order_number     TEXT NOT NULL,
+    order_date       TEXT NOT NULL,
+    order_total      REAL,
+    tax              REAL,
+    delivery_charges REAL,
+    tip              REAL,
+    processing_id    TEXT NOT NULL,
+    received_at      TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, retailer, order_number)
+);
+
+CREATE INDEX orders_tenant_date ON orders (tenant_id, order_date);
+CREATE INDEX orders_tenant_retailer_date ON orders (tenant_id, retailer, order_date);
+
+CREATE TABLE order_items (
+    tenant_id    TEXT NOT NULL,
+    retailer     TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    name         TEXT NOT NULL,
+    price        REAL NOT NULL,
+    quantity     INTEGER NOT NULL,
+    product_url  TEXT NOT NULL DEFAULT '',
+    category     TEXT NOT NULL DEFAULT '',
+    shipment_id  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, retailer, order_number, position),
+    FOREIGN KEY (tenant_id, retailer, order_number)
+        REFERENCES orders (tenant_id, retailer, order_number) ON DELETE CASCADE
+);
+
+CREATE TABLE order_shipments (
+    tenant_id    TEXT NOT NULL,
+    retailer     TEXT NOT NULL,
+    order_number TEXT NOT NULL,
+    position     INTEGER NOT NULL,
+    shipment_id  TEXT NOT NULL,
+    ship_date    TEXT NOT NULL DEFAULT '',
+    tracking     TEXT NOT NULL DEFAULT '',
+    total        REAL NOT NULL,
+    tax          REAL,
+    PRIMARY KEY (tenant_id, retailer, order_number, position),
+    FOREIGN KEY (tenant_id, retailer, order_number)
+        REFERENCES orders (tenant_id, retailer, order_number) ON DELETE CASCADE
+);
+
+CREATE TABLE refunds (
+    tenant_id      TEXT NOT NULL REFERENCES tenants (id),
+    retailer       TEXT NOT NULL,
+    refund_id      TEXT NOT NULL,
+    order_number   TEXT NOT NULL,
+    refund_date    TEXT NOT NULL,
+    amount         REAL NOT NULL,
+    transaction_id TEXT NOT NULL DEFAULT '',
+    received_at    TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (tenant_id, retailer, refund_id)
+);
+
+CREATE INDEX refunds_tenant_order ON refunds (tenant_id, retailer, order_number);
+
+CREATE TABLE refund_items (
+    tenant_id TEXT NOT NULL,
+    retailer  TEXT NOT NULL,
+    refund_id TEXT NOT NULL,
+    position  INTEGER NOT NULL,
+    name      TEXT NOT NULL,
+    quantity  INTEGER NOT NULL,
+    amount    REAL,
+    price     REAL NOT NULL,
+    category  TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (tenant_id, retailer, refund_id, position),
+    FOREIGN KEY (tenant_id, retailer, refund_id)
+        REFERENCES refunds (tenant_id, retailer, refund_id) ON DELETE CASCADE
+);
+
+INSERT INTO orders (tenant_id, retailer, order_number, order_date, order_total, tax, delivery_charges, tip,
+    processing_id, received_at, updated_at)
+SELECT tenant_id, retailer, order_number, order_date, order_total, tax, delivery_charges, tip,
+    processing_id, received_at, updated_at
+FROM orders_old;
+
+INSERT INTO order_items (tenant_id, retailer, order_number, position, name, price, quantity, product_url,
+    category, shipment_id)
+SELECT i.tenant_id, o.retailer, i.order_number, i.position, i.name, i.price, i.quantity, i.product_url,
+    i.category, i.shipment_id
+FROM order_items_old i
+JOIN orders_old o ON o.tenant_id = i.tenant_id AND o.order_number = i.order_number;
+
+INSERT INTO order_shipments (tenant_id, retailer, order_number, position, shipment_id, ship_date, tracking,
+    total, tax)
+SELECT s.tenant_id, o.retailer, s.order_number, s.position, s.shipment_id, s.ship_date, s.tracking,
+    s.total, s.tax
+FROM order_shipments_old s
+JOIN orders_old o ON o.tenant_id = s.tenant_id AND o.order_number = s.order_number;
+
+INSERT INTO refunds (tenant_id, retailer, refund_id, order_number, refund_date, amount, transaction_id,
+    received_at, updated_at)
+SELECT tenant_id, retailer, refund_id, order_number, refund_date, amount, transaction_id,
+    received_at, updated_at
+FROM refunds_old;
+
+INSERT INTO refund_items (tenant_id, retailer, refund_id, position, name, quantity, amount, price, category)
+SELECT i.tenant_id, r.retailer, i.refund_id, i.position, i.name, i.quantity, i.amount, i.price, i.category
+FROM refund_items_old i
+JOIN refunds_old r ON r.tenant_id = i.tenant_id AND r.refund_id = i.refund_id;
+
+DROP TABLE orders_old;
+DROP TABLE order_items_old;
+DROP TABLE order_shipments_old;
+DROP TABLE refunds_old;
+DROP TABLE refund_items_old;
@@ -0,0 +1 @@
+DROP TABLE refund_transactions;
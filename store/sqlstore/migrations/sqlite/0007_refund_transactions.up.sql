@@ -0,0 +1,17 @@
+-- Each Monarch transaction is matched to at most one refund. A refund claims its
+-- transaction here in the same transaction that matches it, so two refunds
+-- matched at once cannot both take it.
+CREATE TABLE refund_transactions (
+    tenant_id      TEXT NOT NULL,
+    transaction_id TEXT NOT NULL,
+    retailer       TEXT NOT NULL,
+    refund_id      TEXT NOT NULL,
+    PRIMARY KEY (tenant_id, transaction_id),
+    UNIQUE (tenant_id, retailer, refund_id),
+    FOREIGN KEY (tenant_id, retailer, refund_id) REFERENCES refunds (tenant_id, retailer, refund_id) ON DELETE CASCADE
+);
+
+INSERT INTO refund_transactions (tenant_id, transaction_id, retailer, refund_id)
+SELECT tenant_id, transaction_id, retailer, refund_id FROM refunds
+WHERE transaction_id <> ''
+ON CONFLICT DO NOTHING;
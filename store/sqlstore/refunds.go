@@ -0,0 +1,249 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+)
+
+const refundColumns = `refund_id, retailer, order_number, refund_date, amount, transaction_id,
+	received_at, updated_at`
+
+// SaveRefund implements store.RefundStore. The refund and its items are replaced
+// together in one transaction. On PostgreSQL a checked refund locks its order's
+// row first, so refunds of one order are checked and saved one at a time; SQLite
+// serializes transactions already.
+func (s *Store) SaveRefund(ctx context.Context, rec *store.RefundRecord, check store.RefundCheck) (bool, error) {
+	now := s.timestamp()
+	saved := *rec
+	saved.UpdatedAt = now
+	if saved.ReceivedAt.IsZero() {
+		saved.ReceivedAt = now
+	} else {
+		saved.ReceivedAt = saved.ReceivedAt.UTC().Truncate(time.Microsecond)
+	}
+	r := &saved.Refund
+	if r.Retailer == "" {
+		r.Retailer = models.DefaultRetailer
+	}
+
+	var created bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if check != nil {
+			if err := s.checkRefund(ctx, tx, saved.TenantID, r, check); err != nil {
+				return err
+			}
+		}
+
+		res, err := s.exec(ctx, tx, `INSERT INTO refunds (tenant_id, `+refundColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (tenant_id, retailer, refund_id) DO NOTHING`,
+			saved.TenantID, r.RefundID, r.Retailer, r.OrderNumber, r.RefundDate, r.Amount, "",
+			saved.ReceivedAt, saved.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		created = n == 1
+		r.TransactionID = ""
+
+		if !created {
+			err := s.queryRow(ctx, tx, `UPDATE refunds
+				SET order_number = ?, refund_date = ?, amount = ?, updated_at = ?
+				WHERE tenant_id = ? AND retailer = ? AND refund_id = ?
+				RETURNING received_at, transaction_id`,
+				r.OrderNumber, r.RefundDate, r.Amount, saved.UpdatedAt,
+				saved.TenantID, r.Retailer, r.RefundID).Scan(&saved.ReceivedAt, &r.TransactionID)
+			if err != nil {
+				return err
+			}
+			saved.ReceivedAt = saved.ReceivedAt.UTC()
+			if _, err := s.exec(ctx, tx, `DELETE FROM refund_items WHERE tenant_id = ? AND retailer = ? AND refund_id = ?`,
+				saved.TenantID, r.Retailer, r.RefundID); err != nil {
+				return err
+			}
+		}
+
+		for i, item := range r.Items {
+			_, err := s.exec(ctx, tx, `INSERT INTO refund_items
+				(tenant_id, retailer, refund_id, position, name, quantity, amount, price, category)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				saved.TenantID, r.Retailer, r.RefundID, i, item.Name, item.Quantity, item.Amount, item.Price, item.Category)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("save refund: %w", err)
+	}
+
+	*rec = saved
+	return created, nil
+}
+
+// ListRefunds implements store.RefundStore.
+func (s *Store) ListRefunds(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) ([]*store.RefundRecord, error) {
+	var recs []*store.RefundRecord
+	err := s.inTx(ctx, func(tx *sql.Tx) (err error) {
+		recs, err = s.listRefunds(ctx, tx, tenantID, retailer, orderNumber)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list refunds: %w", err)
+	}
+	return recs, nil
+}
+
+// listRefunds reads an order's refunds and their items in tx.
+func (s *Store) listRefunds(ctx context.Context, tx *sql.Tx, tenantID string, retailer models.Retailer, orderNumber string) ([]*store.RefundRecord, error) {
+	var recs []*store.RefundRecord
+	rows, err := s.query(ctx, tx, `SELECT tenant_id, `+refundColumns+` FROM refunds
+		WHERE tenant_id = ? AND retailer = ? AND order_number = ?
+		ORDER BY refund_date, refund_id`, tenantID, retailer, orderNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*store.RefundRecord)
+	for rows.Next() {
+		rec := &store.RefundRecord{}
+		r := &rec.Refund
+		if err := rows.Scan(&rec.TenantID, &r.RefundID, &r.Retailer, &r.OrderNumber, &r.RefundDate, &r.Amount,
+			&r.TransactionID, &rec.ReceivedAt, &rec.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rec.ReceivedAt, rec.UpdatedAt = rec.ReceivedAt.UTC(), rec.UpdatedAt.UTC()
+		recs = append(recs, rec)
+		byID[r.RefundID] = rec
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, nil
+	}
+
+	items, err := s.query(ctx, tx, `SELECT i.refund_id, i.name, i.quantity, i.amount, i.price, i.category
+		FROM refund_items i
+		JOIN refunds r ON r.tenant_id = i.tenant_id AND r.retailer = i.retailer AND r.refund_id = i.refund_id
+		WHERE i.tenant_id = ? AND i.retailer = ? AND r.order_number = ?
+		ORDER BY i.refund_id, i.position`, tenantID, retailer, orderNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer items.Close()
+	for items.Next() {
+		var id string
+		var item models.RefundItem
+		if err := items.Scan(&id, &item.Name, &item.Quantity, &item.Amount, &item.Price, &item.Category); err != nil {
+			return nil, err
+		}
+		if rec, ok := byID[id]; ok {
+			rec.Refund.Items = append(rec.Refund.Items, item)
+		}
+	}
+	return recs, items.Err()
+}
+
+// checkRefund runs check on r with the other refunds stored for its order.
+func (s *Store) checkRefund(ctx context.Context, tx *sql.Tx, tenantID string, r *models.Refund, check store.RefundCheck) error {
+	if s.db.dialect == DialectPostgres {
+		var locked int
+		err := s.queryRow(ctx, tx, `SELECT 1 FROM orders
+			WHERE tenant_id = ? AND retailer = ? AND order_number = ? FOR UPDATE`,
+			tenantID, r.Retailer, r.OrderNumber).Scan(&locked)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	recs, err := s.listRefunds(ctx, tx, tenantID, r.Retailer, r.OrderNumber)
+	if err != nil {
+		return err
+	}
+	var earlier []models.Refund
+	for _, rec := range recs {
+		if rec.Refund.RefundID != r.RefundID {
+			earlier = append(earlier, rec.Refund)
+		}
+	}
+	return check(r, earlier)
+}
+
+// ClaimTransaction implements store.RefundStore. The refund is matched and the
+// transaction claimed in refund_transactions in one transaction, whose primary key
+// keeps a transaction from being claimed twice.
+func (s *Store) ClaimTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID, transactionID string) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := s.exec(ctx, tx, `UPDATE refunds SET transaction_id = ?, updated_at = ?
+			WHERE tenant_id = ? AND retailer = ? AND refund_id = ? AND transaction_id = ''`,
+			transactionID, s.timestamp(), tenantID, retailer, refundID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			var exists int
+			err := s.queryRow(ctx, tx, `SELECT 1 FROM refunds WHERE tenant_id = ? AND retailer = ? AND refund_id = ?`,
+				tenantID, retailer, refundID).Scan(&exists)
+			if errors.Is(err, sql.ErrNoRows) {
+				return store.ErrNotFound
+			} else if err != nil {
+				return err
+			}
+			return store.ErrExists
+		}
+
+		res, err = s.exec(ctx, tx, `INSERT INTO refund_transactions (tenant_id, transaction_id, retailer, refund_id)
+			VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`, tenantID, transactionID, retailer, refundID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return store.ErrExists
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("claim transaction: %w", err)
+	}
+	return nil
+}
+
+// ReleaseTransaction implements store.RefundStore.
+func (s *Store) ReleaseTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID string) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := s.exec(ctx, tx, `UPDATE refunds SET transaction_id = '', updated_at = ?
+			WHERE tenant_id = ? AND retailer = ? AND refund_id = ?`,
+			s.timestamp(), tenantID, retailer, refundID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return store.ErrNotFound
+		}
+		_, err = s.exec(ctx, tx, `DELETE FROM refund_transactions WHERE tenant_id = ? AND retailer = ? AND refund_id = ?`,
+			tenantID, retailer, refundID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("release transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+)
+
+// Store is a store.OrderStore, RefundStore, TenantStore, JobStore, and AuditStore
+// backed by a migrated database. It is safe for concurrent use by every replica sharing the
+// database.
+type Store struct {
+	db  *DB
+	now func() time.Time
+}
+
+var (
+	_ store.OrderStore  = (*Store)(nil)
+	_ store.RefundStore = (*Store)(nil)
+	_ store.TenantStore = (*Store)(nil)
+	_ store.JobStore    = (*Store)(nil)
+	_ store.AuditStore  = (*Store)(nil)
+	_ store.Pinger      = (*Store)(nil)
+)
+
+// New creates a store on db, whose schema must be up to date.
+func New(db *DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// Ping implements store.Pinger.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// timestamp returns the current time at the microsecond precision PostgreSQL
+// keeps, so saved and loaded records compare equal.
+func (s *Store) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Microsecond)
+}
+
+func (s *Store) exec(ctx context.Context, q querier, query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(ctx, s.db.rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, q querier, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(ctx, s.db.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, q querier, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, s.db.rebind(query), args...)
+}
+
+// querier is a *sql.DB or *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, committing when it returns nil.
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateTenant implements store.TenantStore.
+func (s *Store) CreateTenant(ctx context.Context, tenant *models.Tenant) error {
+	createdAt := tenant.CreatedAt.UTC().Truncate(time.Microsecond)
+	if tenant.CreatedAt.IsZero() {
+		createdAt = s.timestamp()
+	}
+	res, err := s.exec(ctx, s.db.db,
+		`INSERT INTO tenants (id, name, created_at) VALUES (?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		tenant.ID, tenant.Name, createdAt)
+	if err != nil {
+		return fmt.Errorf("create tenant: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrExists
+	}
+	tenant.CreatedAt = createdAt
+	return nil
+}
+
+// GetTenant implements store.TenantStore.
+func (s *Store) GetTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	var t models.Tenant
+	err := s.queryRow(ctx, s.db.db, `SELECT id, name, created_at FROM tenants WHERE id = ?`, id).
+		Scan(&t.ID, &t.Name, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get tenant: %w", err)
+	}
+	t.CreatedAt = t.CreatedAt.UTC()
+	return &t, nil
+}
+
+// ListTenants implements store.TenantStore.
+func (s *Store) ListTenants(ctx context.Context) ([]*models.Tenant, error) {
+	rows, err := s.query(ctx, s.db.db, `SELECT id, name, created_at FROM tenants ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []*models.Tenant
+	for rows.Next() {
+		var t models.Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("list tenants: %w", err)
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		tenants = append(tenants, &t)
+	}
+	return tenants, rows.Err()
+}
+
+const orderColumns = `retailer, order_number, order_date, order_total, tax, delivery_charges, tip,
+	processing_id, received_at, updated_at`
+
+// SaveOrder implements store.OrderStore. The order and its items are replaced
+// together in one transaction.
+func (s *Store) SaveOrder(ctx context.Context, rec *store.OrderRecord) (bool, error) {
+	now := s.timestamp()
+	saved := *rec
+	saved.UpdatedAt = now
+	if saved.ReceivedAt.IsZero() {
+		saved.ReceivedAt = now
+	} else {
+		saved.ReceivedAt = saved.ReceivedAt.UTC().Truncate(time.Microsecond)
+	}
+	o := &saved.Order
+	if o.Retailer == "" {
+		o.Retailer = models.DefaultRetailer
+	}
+
+	var created bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := s.exec(ctx, tx, `INSERT INTO orders (tenant_id, `+orderColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (tenant_id, retailer, order_number) DO NOTHING`,
+			saved.TenantID, o.Retailer, o.OrderNumber, o.OrderDate, o.OrderTotal, o.Tax, o.DeliveryCharges, o.Tip,
+			saved.ProcessingID, saved.ReceivedAt, saved.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		created = n == 1
+
+		if !created {
+			// Keep when the order was first received, as the memory store does
+			err := s.queryRow(ctx, tx, `UPDATE orders
+				SET order_date = ?, order_total = ?, tax = ?, delivery_charges = ?, tip = ?,
+					processing_id = ?, updated_at = ?
+				WHERE tenant_id = ? AND retailer = ? AND order_number = ?
+				RETURNING received_at`,
+				o.OrderDate, o.OrderTotal, o.Tax, o.DeliveryCharges, o.Tip,
+				saved.ProcessingID, saved.UpdatedAt, saved.TenantID, o.Retailer, o.OrderNumber).Scan(&saved.ReceivedAt)
+			if err != nil {
+				return err
+			}
+			saved.ReceivedAt = saved.ReceivedAt.UTC()
+			for _, table := range []string{"order_items", "order_shipments"} {
+				if _, err := s.exec(ctx, tx, `DELETE FROM `+table+` WHERE tenant_id = ? AND retailer = ? AND order_number = ?`,
+					saved.TenantID, o.Retailer, o.OrderNumber); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, item := range o.Items {
+			_, err := s.exec(ctx, tx, `INSERT INTO order_items
+				(tenant_id, retailer, order_number, position, name, price, quantity, product_url, category, shipment_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				saved.TenantID, o.Retailer, o.OrderNumber, i, item.Name, item.Price, item.Quantity, item.ProductURL, item.Category,
+				item.ShipmentID)
+			if err != nil {
+				return err
+			}
+		}
+		for i, shipment := range o.Shipments {
+			_, err := s.exec(ctx, tx, `INSERT INTO order_shipments
+				(tenant_id, retailer, order_number, position, shipment_id, ship_date, tracking, total, tax)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				saved.TenantID, o.Retailer, o.OrderNumber, i, shipment.ID, shipment.ShipDate, shipment.Tracking,
+				shipment.Total, shipment.Tax)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("save order: %w", err)
+	}
+
+	*rec = saved
+	return created, nil
+}
+
+// GetOrder implements store.OrderStore.
+func (s *Store) GetOrder(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) (*store.OrderRecord, error) {
+	recs, err := s.listOrders(ctx, `tenant_id = ? AND retailer = ? AND order_number = ?`,
+		[]interface{}{tenantID, retailer, orderNumber}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("get order: %w", err)
+	}
+	if len(recs) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return recs[0], nil
+}
+
+// ListOrders implements store.OrderStore.
+func (s *Store) ListOrders(ctx context.Context, tenantID string, filter store.OrderFilter) ([]*store.OrderRecord, error) {
+	where := `tenant_id = ?`
+	args := []interface{}{tenantID}
+	if filter.Retailer != "" {
+		where += ` AND retailer = ?`
+		args = append(args, filter.Retailer)
+	}
+	if filter.From != "" {
+		where += ` AND substr(order_date, 1, 10) >= ?`
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where += ` AND substr(order_date, 1, 10) <= ?`
+		args = append(args, filter.To)
+	}
+
+	recs, err := s.listOrders(ctx, where, args, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	return recs, nil
+}
+
+// orderKey identifies an order within a tenant.
+type orderKey struct {
+	retailer models.Retailer
+	number   string
+}
+
+// listOrders loads the orders matching where, newest order date first, and then
+// their items.
+func (s *Store) listOrders(ctx context.Context, where string, args []interface{}, limit int) ([]*store.OrderRecord, error) {
+	selected := `SELECT tenant_id, ` + orderColumns + ` FROM orders WHERE ` + where +
+		` ORDER BY order_date DESC, order_number`
+	if limit > 0 {
+		selected += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	var recs []*store.OrderRecord
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := s.query(ctx, tx, selected, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		byKey := make(map[orderKey]*store.OrderRecord)
+		for rows.Next() {
+			rec := &store.OrderRecord{}
+			o := &rec.Order
+			if err := rows.Scan(&rec.TenantID, &o.Retailer, &o.OrderNumber, &o.OrderDate, &o.OrderTotal, &o.Tax,
+				&o.DeliveryCharges, &o.Tip, &rec.ProcessingID, &rec.ReceivedAt, &rec.UpdatedAt); err != nil {
+				return err
+			}
+			rec.ReceivedAt, rec.UpdatedAt = rec.ReceivedAt.UTC(), rec.UpdatedAt.UTC()
+			recs = append(recs, rec)
+			byKey[orderKey{o.Retailer, o.OrderNumber}] = rec
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			return nil
+		}
+
+		childArgs := append([]interface{}{recs[0].TenantID}, args...)
+		items, err := s.query(ctx, tx, `SELECT retailer, order_number, name, price, quantity, product_url, category,
+				shipment_id
+			FROM order_items
+			WHERE tenant_id = ? AND (retailer, order_number) IN (SELECT retailer, order_number FROM (`+selected+`) AS selected)
+			ORDER BY retailer, order_number, position`, childArgs...)
+		if err != nil {
+			return err
+		}
+		defer items.Close()
+		for items.Next() {
+			var key orderKey
+			var item models.OrderItem
+			if err := items.Scan(&key.retailer, &key.number, &item.Name, &item.Price, &item.Quantity, &item.ProductURL, &item.Category,
+				&item.ShipmentID); err != nil {
+				return err
+			}
+			if rec, ok := byKey[key]; ok {
+				rec.Order.Items = append(rec.Order.Items, item)
+			}
+		}
+		if err := items.Close(); err != nil {
+			return err
+		}
+
+		shipments, err := s.query(ctx, tx, `SELECT retailer, order_number, shipment_id, ship_date, tracking, total, tax
+			FROM order_shipments
+			WHERE tenant_id = ? AND (retailer, order_number) IN (SELECT retailer, order_number FROM (`+selected+`) AS selected)
+			ORDER BY retailer, order_number, position`, childArgs...)
+		if err != nil {
+			return err
+		}
+		defer shipments.Close()
+		for shipments.Next() {
+			var key orderKey
+			var shipment models.Shipment
+			if err := shipments.Scan(&key.retailer, &key.number, &shipment.ID, &shipment.ShipDate, &shipment.Tracking, &shipment.Total,
+				&shipment.Tax); err != nil {
+				return err
+			}
+			if rec, ok := byKey[key]; ok {
+				rec.Order.Shipments = append(rec.Order.Shipments, shipment)
+			}
+		}
+		return shipments.Err()
+	})
+	return recs, err
+}
@@ -0,0 +1,92 @@
+// Package store persists tenants and the orders they submit. Every order query is
+// scoped to a tenant.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+)
+
+// Errors returned by stores.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+)
+
+// OrderRecord is an order as stored for a tenant.
+type OrderRecord struct {
+	TenantID     string       `json:"tenantId"`
+	Order        models.Order `json:"order"`
+	ProcessingID string       `json:"processingId"`
+	ReceivedAt   time.Time    `json:"receivedAt"`
+	UpdatedAt    time.Time    `json:"updatedAt"`
+}
+
+// OrderFilter narrows ListOrders results. Dates are compared as YYYY-MM-DD strings
+// against models.Order.OrderDate and are inclusive; empty values are unbounded.
+// An empty Retailer matches orders from every retailer.
+type OrderFilter struct {
+	Retailer models.Retailer
+	From     string
+	To       string
+	Limit    int
+}
+
+// Matches reports whether an order is from the filter's retailer and falls inside
+// its date range.
+func (f OrderFilter) Matches(order *models.Order) bool {
+	if f.Retailer != "" && order.Retailer != f.Retailer {
+		return false
+	}
+	date := order.OrderDate
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	if f.From != "" && date < f.From {
+		return false
+	}
+	if f.To != "" && date > f.To {
+		return false
+	}
+	return true
+}
+
+// OrderStore persists orders per tenant.
+type OrderStore interface {
+	// SaveOrder inserts an order, or replaces the tenant's existing order with the
+	// same retailer and order number. It reports whether the order was newly
+	// created. Orders without a retailer are saved as models.DefaultRetailer.
+	SaveOrder(ctx context.Context, rec *OrderRecord) (created bool, err error)
+	// GetOrder returns a tenant's order from a retailer by number, or ErrNotFound.
+	GetOrder(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) (*OrderRecord, error)
+	// ListOrders returns a tenant's orders, newest order date first.
+	ListOrders(ctx context.Context, tenantID string, filter OrderFilter) ([]*OrderRecord, error)
+}
+
+// TenantStore persists tenants.
+type TenantStore interface {
+	// CreateTenant inserts a tenant, or returns ErrExists.
+	CreateTenant(ctx context.Context, tenant *models.Tenant) error
+	// GetTenant returns a tenant by ID, or ErrNotFound.
+	GetTenant(ctx context.Context, id string) (*models.Tenant, error)
+	// ListTenants returns all tenants ordered by creation time.
+	ListTenants(ctx context.Context) ([]*models.Tenant, error)
+}
+
+// Pinger is implemented by stores that can report whether their backend is
+// reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// EnsureTenant creates the tenant if it does not already exist.
+func EnsureTenant(ctx context.Context, s TenantStore, tenant *models.Tenant) error {
+	err := s.CreateTenant(ctx, tenant)
+	if errors.Is(err, ErrExists) {
+		return nil
+	}
+	return err
+}
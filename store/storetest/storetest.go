@@ -0,0 +1,649 @@
+// Package storetest is a conformance suite for store implementations. Every
+// backend runs the same tests, so the memory, SQLite, and PostgreSQL stores
+// behave alike.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OrderStore is an order store together with the tenants its orders belong to.
+type OrderStore interface {
+	store.OrderStore
+	store.TenantStore
+}
+
+// RefundStore is a refund store together with the tenants its refunds belong to.
+type RefundStore interface {
+	store.RefundStore
+	store.TenantStore
+}
+
+// JobStore is a job queue together with the tenants its jobs belong to.
+type JobStore interface {
+	store.JobStore
+	store.TenantStore
+}
+
+// AuditStore is an audit log together with the tenants its entries belong to.
+type AuditStore interface {
+	store.AuditStore
+	store.TenantStore
+}
+
+func float(v float64) *float64 { return &v }
+
+func createTenants(t *testing.T, s store.TenantStore, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, s.CreateTenant(context.Background(), &models.Tenant{ID: id, Name: "Tenant " + id}))
+	}
+}
+
+// RunTenantStore tests a TenantStore. newStore returns an empty store.
+func RunTenantStore(t *testing.T, newStore func(t *testing.T) store.TenantStore) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		tenant := &models.Tenant{ID: "home", Name: "Home"}
+		require.NoError(t, s.CreateTenant(ctx, tenant))
+		assert.False(t, tenant.CreatedAt.IsZero())
+		assert.ErrorIs(t, s.CreateTenant(ctx, &models.Tenant{ID: "home", Name: "Again"}), store.ErrExists)
+		require.NoError(t, store.EnsureTenant(ctx, s, &models.Tenant{ID: "home", Name: "Again"}))
+
+		got, err := s.GetTenant(ctx, "home")
+		require.NoError(t, err)
+		assert.Equal(t, "Home", got.Name)
+		assert.True(t, tenant.CreatedAt.Equal(got.CreatedAt))
+
+		_, err = s.GetTenant(ctx, "missing")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("ListOrderedByCreation", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, s.CreateTenant(ctx, &models.Tenant{ID: "b", Name: "B", CreatedAt: base.Add(time.Hour)}))
+		require.NoError(t, s.CreateTenant(ctx, &models.Tenant{ID: "a", Name: "A", CreatedAt: base.Add(2 * time.Hour)}))
+		require.NoError(t, s.CreateTenant(ctx, &models.Tenant{ID: "c", Name: "C", CreatedAt: base}))
+
+		tenants, err := s.ListTenants(ctx)
+
+		require.NoError(t, err)
+		require.Len(t, tenants, 3)
+		assert.Equal(t, []string{"c", "b", "a"}, []string{tenants[0].ID, tenants[1].ID, tenants[2].ID})
+	})
+}
+
+// RunOrderStore tests an OrderStore. newStore returns an empty store.
+func RunOrderStore(t *testing.T, newStore func(t *testing.T) OrderStore) {
+	t.Run("SaveUpsertsPerTenant", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+
+		first := &store.OrderRecord{TenantID: "t1", ProcessingID: "p1", Order: models.Order{
+			OrderNumber: "100", OrderDate: "2024-01-15",
+			Items: []models.OrderItem{{Name: "Milk", Price: 3.49, Quantity: 1}, {Name: "Bread", Price: 2.5, Quantity: 2}},
+		}}
+		created, err := s.SaveOrder(ctx, first)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.False(t, first.ReceivedAt.IsZero())
+
+		second := &store.OrderRecord{TenantID: "t1", ProcessingID: "p2", Order: models.Order{
+			OrderNumber: "100", OrderDate: "2024-01-15", OrderTotal: float(5.99),
+			Items: []models.OrderItem{{Name: "Eggs", Price: 5.99, Quantity: 1}},
+		}}
+		created, err = s.SaveOrder(ctx, second)
+		require.NoError(t, err)
+		assert.False(t, created)
+
+		got, err := s.GetOrder(ctx, "t1", models.DefaultRetailer, "100")
+		require.NoError(t, err)
+		assert.Equal(t, "p2", got.ProcessingID)
+		assert.True(t, first.ReceivedAt.Equal(got.ReceivedAt), "received time is kept on update")
+		assert.True(t, second.ReceivedAt.Equal(got.ReceivedAt))
+		require.Len(t, got.Order.Items, 1, "items are replaced, not appended")
+		assert.Equal(t, "Eggs", got.Order.Items[0].Name)
+
+		// The same order number belongs to each tenant independently
+		_, err = s.GetOrder(ctx, "t2", models.DefaultRetailer, "100")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("OrderNumbersArePerRetailer", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		for _, o := range []models.Order{
+			{Retailer: models.RetailerWalmart, OrderNumber: "100", OrderDate: "2024-01-15",
+				Items: []models.OrderItem{{Name: "Milk", Price: 3.49, Quantity: 1}}},
+			{Retailer: models.RetailerAmazon, OrderNumber: "100", OrderDate: "2024-01-16",
+				Items: []models.OrderItem{{Name: "Cable", Price: 9.99, Quantity: 1}}},
+		} {
+			created, err := s.SaveOrder(ctx, &store.OrderRecord{TenantID: "t1", Order: o})
+			require.NoError(t, err)
+			assert.True(t, created, "an order number from another retailer is a different order")
+		}
+
+		walmart, err := s.GetOrder(ctx, "t1", models.RetailerWalmart, "100")
+		require.NoError(t, err)
+		amazon, err := s.GetOrder(ctx, "t1", models.RetailerAmazon, "100")
+		require.NoError(t, err)
+		_, err = s.GetOrder(ctx, "t1", models.RetailerTarget, "100")
+
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		require.Len(t, walmart.Order.Items, 1)
+		assert.Equal(t, "Milk", walmart.Order.Items[0].Name)
+		require.Len(t, amazon.Order.Items, 1)
+		assert.Equal(t, "Cable", amazon.Order.Items[0].Name)
+		all, err := s.ListOrders(ctx, "t1", store.OrderFilter{})
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("RoundTripsEveryField", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		order := models.Order{
+			Retailer:        models.RetailerTarget,
+			OrderNumber:     "200",
+			OrderDate:       "2024-02-01T15:04:05Z",
+			OrderTotal:      float(42.17),
+			Tax:             float(2.17),
+			DeliveryCharges: float(0),
+			Items: []models.OrderItem{
+				{Name: "Paper towels", Price: 19.99, Quantity: 2, ProductURL: "https://www.walmart.com/ip/1", Category: "Household", ShipmentID: "2"},
+				{Name: "Apples", Price: 0.01, Quantity: 1, ShipmentID: "1"},
+			},
+			Shipments: []models.Shipment{
+				{ID: "2", ShipDate: "2024-02-03", Tracking: "UPS 1Z999", Total: 42.16, Tax: float(2.17)},
+				{ID: "1", Total: 0.01},
+			},
+		}
+		_, err := s.SaveOrder(ctx, &store.OrderRecord{TenantID: "t1", ProcessingID: "p1", Order: order})
+		require.NoError(t, err)
+
+		got, err := s.GetOrder(ctx, "t1", models.RetailerTarget, "200")
+
+		require.NoError(t, err)
+		assert.Equal(t, order, got.Order)
+		assert.Nil(t, got.Order.Tip)
+		assert.Equal(t, "t1", got.TenantID)
+	})
+
+	t.Run("ListFiltersAndOrders", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+		for _, o := range []models.Order{
+			{OrderNumber: "1", OrderDate: "2024-01-01", Items: []models.OrderItem{{Name: "A", Price: 1, Quantity: 1}}},
+			{OrderNumber: "2", OrderDate: "2024-02-01"},
+			{OrderNumber: "3", OrderDate: "2024-03-01T10:00:00Z", Items: []models.OrderItem{{Name: "C", Price: 3, Quantity: 1}}},
+			{OrderNumber: "4", OrderDate: "2024-02-01", Retailer: models.RetailerAmazon},
+		} {
+			_, err := s.SaveOrder(ctx, &store.OrderRecord{TenantID: "t1", Order: o})
+			require.NoError(t, err)
+		}
+		_, err := s.SaveOrder(ctx, &store.OrderRecord{TenantID: "t2", Order: models.Order{OrderNumber: "5", OrderDate: "2024-02-15"}})
+		require.NoError(t, err)
+
+		all, err := s.ListOrders(ctx, "t1", store.OrderFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "2", "4", "1"}, numbers(all))
+		require.Len(t, all[0].Order.Items, 1)
+		assert.Equal(t, "C", all[0].Order.Items[0].Name)
+		assert.Empty(t, all[1].Order.Items)
+
+		ranged, err := s.ListOrders(ctx, "t1", store.OrderFilter{From: "2024-02-01", To: "2024-03-01"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "2", "4"}, numbers(ranged))
+
+		limited, err := s.ListOrders(ctx, "t1", store.OrderFilter{From: "2024-01-01", Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "2"}, numbers(limited))
+
+		amazon, err := s.ListOrders(ctx, "t1", store.OrderFilter{Retailer: models.RetailerAmazon})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4"}, numbers(amazon))
+		walmart, err := s.ListOrders(ctx, "t1", store.OrderFilter{Retailer: models.DefaultRetailer})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"3", "2", "1"}, numbers(walmart), "orders without a retailer are the default's")
+
+		none, err := s.ListOrders(ctx, "missing", store.OrderFilter{})
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+}
+
+func numbers(recs []*store.OrderRecord) []string {
+	n := make([]string, len(recs))
+	for i, r := range recs {
+		n[i] = r.Order.OrderNumber
+	}
+	return n
+}
+
+// RunRefundStore tests a RefundStore. newStore returns an empty store.
+func RunRefundStore(t *testing.T, newStore func(t *testing.T) RefundStore) {
+	t.Run("SaveUpsertsAndRoundTrips", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+
+		refund := models.Refund{
+			Retailer:    models.RetailerAmazon,
+			RefundID:    "R1",
+			OrderNumber: "100",
+			RefundDate:  "2024-03-10",
+			Amount:      32.39,
+			Items: []models.RefundItem{
+				{Name: "Paper towels", Quantity: 1, Price: 19.99, Category: "Household"},
+				{Name: "Milk", Quantity: 2, Amount: float(7.42), Price: 3.5, Category: "Groceries"},
+			},
+		}
+		rec := &store.RefundRecord{TenantID: "t1", Refund: refund}
+		created, err := s.SaveRefund(ctx, rec, nil)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.False(t, rec.ReceivedAt.IsZero())
+
+		got, err := s.ListRefunds(ctx, "t1", models.RetailerAmazon, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, refund, got[0].Refund)
+		assert.Equal(t, rec.ReceivedAt, got[0].ReceivedAt)
+
+		require.NoError(t, s.ClaimTransaction(ctx, "t1", models.RetailerAmazon, "R1", "txn-1"))
+		replaced := refund
+		replaced.Items = refund.Items[:1]
+		again := &store.RefundRecord{TenantID: "t1", Refund: replaced}
+		created, err = s.SaveRefund(ctx, again, nil)
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, rec.ReceivedAt, again.ReceivedAt, "ReceivedAt is kept on update")
+		matched := replaced
+		matched.TransactionID = "txn-1"
+		assert.Equal(t, matched, again.Refund, "the claimed transaction is kept on update")
+
+		got, err = s.ListRefunds(ctx, "t1", models.RetailerAmazon, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, matched, got[0].Refund)
+
+		other, err := s.ListRefunds(ctx, "t2", models.RetailerAmazon, "100")
+		require.NoError(t, err)
+		assert.Empty(t, other)
+
+		// Another retailer's refund and order with the same IDs are kept apart
+		walmart := refund
+		walmart.Retailer = models.RetailerWalmart
+		created, err = s.SaveRefund(ctx, &store.RefundRecord{TenantID: "t1", Refund: walmart}, nil)
+		require.NoError(t, err)
+		assert.True(t, created)
+		got, err = s.ListRefunds(ctx, "t1", models.RetailerAmazon, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, matched, got[0].Refund)
+		got, err = s.ListRefunds(ctx, "t1", models.RetailerWalmart, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, walmart, got[0].Refund)
+	})
+
+	t.Run("ChecksAgainstTheOrdersOtherRefunds", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		refund := func(id string) *store.RefundRecord {
+			return &store.RefundRecord{TenantID: "t1", Refund: models.Refund{
+				RefundID: id, OrderNumber: "100", RefundDate: "2024-03-10", Amount: 1,
+				Items: []models.RefundItem{{Name: "Milk", Quantity: 1}},
+			}}
+		}
+		onlyOne := func(r *models.Refund, earlier []models.Refund) error {
+			if len(earlier) > 0 {
+				return errors.New("order already refunded")
+			}
+			r.Items[0].Category = "Groceries"
+			return nil
+		}
+
+		_, err := s.SaveRefund(ctx, refund("R1"), onlyOne)
+		require.NoError(t, err)
+		_, err = s.SaveRefund(ctx, refund("R1"), onlyOne)
+		require.NoError(t, err, "a refund is not checked against itself")
+		_, err = s.SaveRefund(ctx, refund("R2"), onlyOne)
+		assert.ErrorContains(t, err, "order already refunded")
+
+		got, err := s.ListRefunds(ctx, "t1", models.DefaultRetailer, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "Groceries", got[0].Refund.Items[0].Category, "the check fills in the refund")
+	})
+
+	t.Run("ChecksConcurrentSavesOneAtATime", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		onlyOne := func(_ *models.Refund, earlier []models.Refund) error {
+			if len(earlier) > 0 {
+				return errors.New("order already refunded")
+			}
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				refund := models.Refund{RefundID: fmt.Sprintf("R%d", i), OrderNumber: "100", RefundDate: "2024-03-10", Amount: 1}
+				_, _ = s.SaveRefund(ctx, &store.RefundRecord{TenantID: "t1", Refund: refund}, onlyOne)
+			}(i)
+		}
+		wg.Wait()
+
+		got, err := s.ListRefunds(ctx, "t1", models.DefaultRetailer, "100")
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+	})
+
+	t.Run("ClaimsEachTransactionOnce", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+		for _, tenantID := range []string{"t1", "t2"} {
+			for _, id := range []string{"R1", "R2"} {
+				refund := models.Refund{RefundID: id, OrderNumber: "100", RefundDate: "2024-03-10", Amount: 1}
+				_, err := s.SaveRefund(ctx, &store.RefundRecord{TenantID: tenantID, Refund: refund}, nil)
+				require.NoError(t, err)
+			}
+		}
+		retailer := models.DefaultRetailer
+
+		require.NoError(t, s.ClaimTransaction(ctx, "t1", retailer, "R1", "txn-1"))
+		assert.ErrorIs(t, s.ClaimTransaction(ctx, "t1", retailer, "R2", "txn-1"), store.ErrExists,
+			"another refund has the transaction")
+		assert.ErrorIs(t, s.ClaimTransaction(ctx, "t1", retailer, "R1", "txn-2"), store.ErrExists,
+			"the refund is already matched")
+		assert.ErrorIs(t, s.ClaimTransaction(ctx, "t1", retailer, "R9", "txn-2"), store.ErrNotFound)
+		assert.NoError(t, s.ClaimTransaction(ctx, "t2", retailer, "R1", "txn-1"), "tenants are kept apart")
+
+		require.NoError(t, s.ReleaseTransaction(ctx, "t1", retailer, "R1"))
+		require.NoError(t, s.ClaimTransaction(ctx, "t1", retailer, "R2", "txn-1"), "a released transaction can be claimed")
+		assert.ErrorIs(t, s.ReleaseTransaction(ctx, "t1", retailer, "R9"), store.ErrNotFound)
+
+		got, err := s.ListRefunds(ctx, "t1", retailer, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Empty(t, got[0].Refund.TransactionID)
+		assert.Equal(t, "txn-1", got[1].Refund.TransactionID)
+	})
+
+	t.Run("ListsAnOrdersRefundsByDate", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		for _, r := range []models.Refund{
+			{RefundID: "b", OrderNumber: "100", RefundDate: "2024-03-12", Amount: 1},
+			{RefundID: "c", OrderNumber: "200", RefundDate: "2024-03-11", Amount: 2},
+			{RefundID: "a", OrderNumber: "100", RefundDate: "2024-03-14", Amount: 3},
+		} {
+			_, err := s.SaveRefund(ctx, &store.RefundRecord{TenantID: "t1", Refund: r}, nil)
+			require.NoError(t, err)
+		}
+
+		got, err := s.ListRefunds(ctx, "t1", models.DefaultRetailer, "100")
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "b", got[0].Refund.RefundID)
+		assert.Equal(t, "a", got[1].Refund.RefundID)
+		assert.Equal(t, models.DefaultRetailer, got[0].Refund.Retailer, "refunds without a retailer are the default's")
+		assert.Empty(t, got[0].Refund.Items)
+	})
+}
+
+// RunJobStore tests a JobStore. newStore returns an empty store.
+func RunJobStore(t *testing.T, newStore func(t *testing.T) JobStore) {
+	t.Run("ClaimsOldestDueJob", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		now := time.Now().UTC()
+
+		later := &store.Job{TenantID: "t1", Kind: "process_order", RunAt: now.Add(time.Hour)}
+		older := &store.Job{TenantID: "t1", Kind: "process_order", RunAt: now.Add(-2 * time.Minute), Payload: []byte(`{"orderNumber":"100"}`)}
+		newer := &store.Job{TenantID: "t1", Kind: "process_order", RunAt: now.Add(-time.Minute)}
+		for _, j := range []*store.Job{later, older, newer} {
+			require.NoError(t, s.EnqueueJob(ctx, j))
+			assert.NotZero(t, j.ID)
+			assert.Equal(t, store.JobPending, j.Status)
+		}
+
+		job, err := s.ClaimJob(ctx, "worker-1", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, older.ID, job.ID)
+		assert.Equal(t, store.JobRunning, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.Equal(t, "worker-1", job.LockedBy)
+		assert.NotNil(t, job.LockedAt)
+		assert.JSONEq(t, `{"orderNumber":"100"}`, string(job.Payload))
+
+		job, err = s.ClaimJob(ctx, "worker-2", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, newer.ID, job.ID)
+
+		_, err = s.ClaimJob(ctx, "worker-3", time.Minute)
+		assert.ErrorIs(t, err, store.ErrNoJobs, "jobs due later are not claimed")
+	})
+
+	t.Run("CompleteAndFail", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		for i := 0; i < 3; i++ {
+			require.NoError(t, s.EnqueueJob(ctx, &store.Job{TenantID: "t1", Kind: "sync"}))
+		}
+
+		done, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, s.CompleteJob(ctx, done.ID, "w"))
+		got, err := s.GetJob(ctx, "t1", done.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.JobSucceeded, got.Status)
+		assert.Empty(t, got.LockedBy)
+		assert.Nil(t, got.LockedAt)
+
+		retried, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		retryAt := time.Now().Add(-time.Second)
+		require.NoError(t, s.FailJob(ctx, retried.ID, "w", "monarch unavailable", &retryAt))
+		got, err = s.GetJob(ctx, "t1", retried.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.JobPending, got.Status)
+		assert.Equal(t, "monarch unavailable", got.LastError)
+
+		again, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, retried.ID, again.ID, "the retry is due before the last job")
+		assert.Equal(t, 2, again.Attempts)
+		require.NoError(t, s.FailJob(ctx, again.ID, "w", "bad payload", nil))
+		got, err = s.GetJob(ctx, "t1", again.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.JobFailed, got.Status)
+		assert.Equal(t, "bad payload", got.LastError)
+
+		last, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		assert.NotEqual(t, retried.ID, last.ID, "failed jobs are not claimed")
+
+		_, err = s.ClaimJob(ctx, "w", time.Minute)
+		assert.ErrorIs(t, err, store.ErrNoJobs)
+
+		assert.ErrorIs(t, s.CompleteJob(ctx, 999999, "w"), store.ErrNotFound)
+		_, err = s.GetJob(ctx, "t1", 999999)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("RescheduleDoesNotCountTheAttempt", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		require.NoError(t, s.EnqueueJob(ctx, &store.Job{TenantID: "t1", Kind: "match_refund"}))
+
+		job, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		runAt := time.Now().Add(-time.Second)
+		require.NoError(t, s.RescheduleJob(ctx, job.ID, "w", "not posted yet", runAt))
+		got, err := s.GetJob(ctx, "t1", job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.JobPending, got.Status)
+		assert.Equal(t, 0, got.Attempts)
+		assert.Equal(t, "not posted yet", got.LastError)
+		assert.Empty(t, got.LockedBy)
+
+		again, err := s.ClaimJob(ctx, "w", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, job.ID, again.ID)
+		assert.Equal(t, 1, again.Attempts)
+		assert.ErrorIs(t, s.RescheduleJob(ctx, job.ID, "other", "", runAt), store.ErrNotFound)
+	})
+
+	t.Run("GetJobIsTenantScoped", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+		job := &store.Job{TenantID: "t1", Kind: "sync"}
+		require.NoError(t, s.EnqueueJob(ctx, job))
+
+		_, err := s.GetJob(ctx, "t2", job.ID)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		got, err := s.GetJob(ctx, "t1", job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, job.ID, got.ID)
+	})
+
+	t.Run("ReclaimsExpiredLease", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		require.NoError(t, s.EnqueueJob(ctx, &store.Job{TenantID: "t1", Kind: "sync"}))
+
+		first, err := s.ClaimJob(ctx, "crashed", time.Hour)
+		require.NoError(t, err)
+		_, err = s.ClaimJob(ctx, "w", time.Hour)
+		assert.ErrorIs(t, err, store.ErrNoJobs)
+		time.Sleep(5 * time.Millisecond)
+
+		second, err := s.ClaimJob(ctx, "w", time.Millisecond)
+
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, "w", second.LockedBy)
+		assert.Equal(t, 2, second.Attempts)
+
+		// The crashed worker no longer holds the job, so it cannot finish it
+		assert.ErrorIs(t, s.CompleteJob(ctx, first.ID, "crashed"), store.ErrNotFound)
+		assert.ErrorIs(t, s.FailJob(ctx, first.ID, "crashed", "timed out", nil), store.ErrNotFound)
+		require.NoError(t, s.CompleteJob(ctx, second.ID, "w"))
+		assert.ErrorIs(t, s.CompleteJob(ctx, second.ID, "w"), store.ErrNotFound, "a finished job is no longer held")
+	})
+
+	t.Run("ConcurrentClaimsAreExclusive", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1")
+		const jobs, workers = 20, 4
+		for i := 0; i < jobs; i++ {
+			require.NoError(t, s.EnqueueJob(ctx, &store.Job{TenantID: "t1", Kind: "sync"}))
+		}
+
+		var (
+			mu      sync.Mutex
+			claimed = make(map[int64]string)
+			errs    []error
+			wg      sync.WaitGroup
+		)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(worker string) {
+				defer wg.Done()
+				for {
+					job, err := s.ClaimJob(ctx, worker, time.Hour)
+					if errors.Is(err, store.ErrNoJobs) {
+						return
+					}
+					mu.Lock()
+					if err != nil {
+						errs = append(errs, err)
+						mu.Unlock()
+						return
+					}
+					if prev, ok := claimed[job.ID]; ok {
+						errs = append(errs, fmt.Errorf("job %d claimed by %s and %s", job.ID, prev, worker))
+					}
+					claimed[job.ID] = worker
+					mu.Unlock()
+				}
+			}(fmt.Sprintf("worker-%d", w))
+		}
+		wg.Wait()
+
+		assert.Empty(t, errs)
+		assert.Len(t, claimed, jobs)
+	})
+}
+
+// RunAuditStore tests an AuditStore. newStore returns an empty store.
+func RunAuditStore(t *testing.T, newStore func(t *testing.T) AuditStore) {
+	t.Run("AppendAndList", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		createTenants(t, s, "t1", "t2")
+
+		entries := []*store.AuditEntry{
+			{TenantID: "t1", Actor: "key:abc", Action: "order.received", Subject: "order:100"},
+			{TenantID: "t1", Actor: "system", Action: "split.applied", Subject: "order:100", Details: []byte(`{"categories": ["Groceries"]}`)},
+			{TenantID: "t1", Actor: "system", Action: "order.received", Subject: "order:200"},
+			{TenantID: "t2", Actor: "system", Action: "order.received", Subject: "order:100"},
+		}
+		for _, e := range entries {
+			require.NoError(t, s.AppendAudit(ctx, e))
+			assert.NotZero(t, e.ID)
+			assert.False(t, e.CreatedAt.IsZero())
+		}
+
+		all, err := s.ListAudit(ctx, "t1", store.AuditFilter{})
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		assert.Equal(t, entries[2].ID, all[0].ID, "newest first")
+
+		forOrder, err := s.ListAudit(ctx, "t1", store.AuditFilter{Subject: "order:100"})
+		require.NoError(t, err)
+		require.Len(t, forOrder, 2)
+		assert.Equal(t, "split.applied", forOrder[0].Action)
+		assert.JSONEq(t, `{"categories":["Groceries"]}`, string(forOrder[0].Details))
+		assert.Nil(t, forOrder[1].Details)
+		assert.True(t, entries[0].CreatedAt.Equal(forOrder[1].CreatedAt))
+
+		limited, err := s.ListAudit(ctx, "t1", store.AuditFilter{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, limited, 1)
+	})
+}
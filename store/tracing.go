@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+
+	"monarchmoney-sync-backend/models"
+	"monarchmoney-sync-backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TraceOrders wraps an OrderStore so every call is recorded as a span.
+func TraceOrders(s OrderStore) OrderStore {
+	return tracedOrders{s}
+}
+
+type tracedOrders struct {
+	next OrderStore
+}
+
+func (t tracedOrders) SaveOrder(ctx context.Context, rec *OrderRecord) (created bool, err error) {
+	ctx, span := tracing.Start(ctx, "store.SaveOrder",
+		attribute.String("tenant.id", rec.TenantID),
+		attribute.String("order.number", rec.Order.OrderNumber))
+	defer func() { tracing.End(span, err) }()
+
+	created, err = t.next.SaveOrder(ctx, rec)
+	span.SetAttributes(attribute.Bool("order.created", created))
+	return created, err
+}
+
+func (t tracedOrders) GetOrder(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) (rec *OrderRecord, err error) {
+	ctx, span := tracing.Start(ctx, "store.GetOrder",
+		attribute.String("tenant.id", tenantID),
+		attribute.String("order.retailer", string(retailer)),
+		attribute.String("order.number", orderNumber))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetOrder(ctx, tenantID, retailer, orderNumber)
+}
+
+func (t tracedOrders) ListOrders(ctx context.Context, tenantID string, filter OrderFilter) (recs []*OrderRecord, err error) {
+	ctx, span := tracing.Start(ctx, "store.ListOrders", attribute.String("tenant.id", tenantID))
+	defer func() { tracing.End(span, err) }()
+
+	recs, err = t.next.ListOrders(ctx, tenantID, filter)
+	span.SetAttributes(attribute.Int("orders.count", len(recs)))
+	return recs, err
+}
+
+// TraceRefunds wraps a RefundStore so every call is recorded as a span.
+func TraceRefunds(s RefundStore) RefundStore {
+	return tracedRefunds{s}
+}
+
+type tracedRefunds struct {
+	next RefundStore
+}
+
+func (t tracedRefunds) SaveRefund(ctx context.Context, rec *RefundRecord, check RefundCheck) (created bool, err error) {
+	ctx, span := tracing.Start(ctx, "store.SaveRefund",
+		attribute.String("tenant.id", rec.TenantID),
+		attribute.String("order.number", rec.Refund.OrderNumber),
+		attribute.String("refund.id", rec.Refund.RefundID))
+	defer func() { tracing.End(span, err) }()
+
+	created, err = t.next.SaveRefund(ctx, rec, check)
+	span.SetAttributes(attribute.Bool("refund.created", created))
+	return created, err
+}
+
+func (t tracedRefunds) ListRefunds(ctx context.Context, tenantID string, retailer models.Retailer, orderNumber string) (recs []*RefundRecord, err error) {
+	ctx, span := tracing.Start(ctx, "store.ListRefunds",
+		attribute.String("tenant.id", tenantID),
+		attribute.String("order.retailer", string(retailer)),
+		attribute.String("order.number", orderNumber))
+	defer func() { tracing.End(span, err) }()
+
+	recs, err = t.next.ListRefunds(ctx, tenantID, retailer, orderNumber)
+	span.SetAttributes(attribute.Int("refunds.count", len(recs)))
+	return recs, err
+}
+
+func (t tracedRefunds) ClaimTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID, transactionID string) (err error) {
+	ctx, span := tracing.Start(ctx, "store.ClaimTransaction",
+		attribute.String("tenant.id", tenantID),
+		attribute.String("order.retailer", string(retailer)),
+		attribute.String("refund.id", refundID),
+		attribute.String("transaction.id", transactionID))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ClaimTransaction(ctx, tenantID, retailer, refundID, transactionID)
+}
+
+func (t tracedRefunds) ReleaseTransaction(ctx context.Context, tenantID string, retailer models.Retailer, refundID string) (err error) {
+	ctx, span := tracing.Start(ctx, "store.ReleaseTransaction",
+		attribute.String("tenant.id", tenantID),
+		attribute.String("order.retailer", string(retailer)),
+		attribute.String("refund.id", refundID))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ReleaseTransaction(ctx, tenantID, retailer, refundID)
+}
+
+// TraceTenants wraps a TenantStore so every call is recorded as a span.
+func TraceTenants(s TenantStore) TenantStore {
+	return tracedTenants{s}
+}
+
+type tracedTenants struct {
+	next TenantStore
+}
+
+func (t tracedTenants) CreateTenant(ctx context.Context, tenant *models.Tenant) (err error) {
+	ctx, span := tracing.Start(ctx, "store.CreateTenant", attribute.String("tenant.id", tenant.ID))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.CreateTenant(ctx, tenant)
+}
+
+func (t tracedTenants) GetTenant(ctx context.Context, id string) (tenant *models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "store.GetTenant", attribute.String("tenant.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.GetTenant(ctx, id)
+}
+
+func (t tracedTenants) ListTenants(ctx context.Context) (tenants []*models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "store.ListTenants")
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.ListTenants(ctx)
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"monarchmoney-sync-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceOrders_RecordsSpans(t *testing.T) {
+	// Arrange
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+	s := TraceOrders(NewMemory())
+
+	// Act
+	_, err := s.SaveOrder(context.Background(), &OrderRecord{TenantID: "t1", Order: testOrder("100", "2024-01-15")})
+	require.NoError(t, err)
+	_, err = s.GetOrder(context.Background(), "t1", models.DefaultRetailer, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Assert
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "store.SaveOrder", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("tenant.id", "t1"))
+	assert.Equal(t, "store.GetOrder", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useRecorder installs a tracer provider that records spans in memory.
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestSetup_StdoutExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, SampleRatio: 1, Writer: &buf})
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "test.span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, buf.String(), `"Name":"test.span"`)
+	assert.Contains(t, buf.String(), ServiceName)
+}
+
+func TestSetup_Validation(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Options{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, span := Start(context.Background(), "failing")
+	End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "boom", spans[0].Status().Description)
+}
+
+func TestLinkSentry(t *testing.T) {
+	useRecorder(t)
+	ctx, span := Start(context.Background(), "request")
+	defer span.End()
+
+	scope := sentry.NewScope()
+	LinkSentry(ctx, scope)
+	event := scope.ApplyToEvent(sentry.NewEvent(), nil, nil)
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), event.Tags["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), event.Tags["span_id"])
+}
@@ -0,0 +1,124 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MasterKeySize is the length of a master key in bytes (AES-256).
+const MasterKeySize = 32
+
+// Errors returned when decrypting.
+var (
+	ErrUnknownKey = errors.New("credential encrypted with an unknown master key")
+	ErrDecrypt    = errors.New("credential could not be decrypted")
+)
+
+// MasterKey is an AES-256 key used to encrypt credentials at rest.
+type MasterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// ParseMasterKey decodes a 32-byte key given as base64 or hex.
+func ParseMasterKey(encoded string) (*MasterKey, error) {
+	encoded = strings.TrimSpace(encoded)
+	var raw []byte
+	if b, err := hex.DecodeString(encoded); err == nil && len(b) == MasterKeySize {
+		raw = b
+	} else if b, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(b) == MasterKeySize {
+		raw = b
+	} else {
+		return nil, fmt.Errorf("master key must be %d bytes encoded as base64 or hex", MasterKeySize)
+	}
+	return newMasterKey(raw)
+}
+
+// GenerateMasterKey returns a random master key.
+func GenerateMasterKey() (*MasterKey, error) {
+	raw := make([]byte, MasterKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate master key: %w", err)
+	}
+	return newMasterKey(raw)
+}
+
+func newMasterKey(raw []byte) (*MasterKey, error) {
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &MasterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// ID is a short fingerprint identifying the key without revealing it.
+func (k *MasterKey) ID() string {
+	return k.id
+}
+
+// seal encrypts plaintext, binding it to aad. The result is the random nonce
+// followed by the ciphertext.
+func (k *MasterKey) seal(plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func (k *MasterKey) open(sealed, aad []byte) ([]byte, error) {
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:n], sealed[n:], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Keys is the current master key plus previous keys that are still accepted for
+// decryption while credentials are re-encrypted.
+type Keys struct {
+	current  *MasterKey
+	previous map[string]*MasterKey
+}
+
+// NewKeys creates a key set that encrypts with current and can decrypt with any key.
+func NewKeys(current *MasterKey, previous ...*MasterKey) *Keys {
+	keys := &Keys{current: current, previous: make(map[string]*MasterKey, len(previous))}
+	for _, k := range previous {
+		if k.id != current.id {
+			keys.previous[k.id] = k
+		}
+	}
+	return keys
+}
+
+// Current returns the key used for new encryptions.
+func (k *Keys) Current() *MasterKey {
+	return k.current
+}
+
+func (k *Keys) lookup(id string) (*MasterKey, error) {
+	if id == k.current.id {
+		return k.current, nil
+	}
+	if key, ok := k.previous[id]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"monarchmoney-sync-backend/internal/jsonfile"
+)
+
+// Record is an encrypted credential as stored. Ciphertext is only readable with
+// the master key identified by KeyID.
+type Record struct {
+	TenantID   string    `json:"tenantId"`
+	Name       string    `json:"name"`
+	KeyID      string    `json:"keyId"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Hint       string    `json:"hint"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Store persists encrypted credentials.
+type Store interface {
+	Put(rec *Record) error
+	Get(tenantID, name string) (*Record, error)
+	Delete(tenantID, name string) (bool, error)
+	// List returns a tenant's records, or every record when tenantID is empty.
+	List(tenantID string) ([]*Record, error)
+}
+
+type recordKey struct {
+	tenantID string
+	name     string
+}
+
+// MemoryStore keeps encrypted credentials in memory. They are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[recordKey]Record
+}
+
+// NewMemoryStore creates an empty in-memory credential store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[recordKey]Record)}
+}
+
+// Put inserts or replaces a record.
+func (s *MemoryStore) Put(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[recordKey{rec.TenantID, rec.Name}] = *rec
+	return nil
+}
+
+// Get returns a tenant's record by name, or ErrNotFound.
+func (s *MemoryStore) Get(tenantID, name string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[recordKey{tenantID, name}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+// Delete removes a record, reporting whether it existed.
+func (s *MemoryStore) Delete(tenantID, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := recordKey{tenantID, name}
+	if _, ok := s.records[k]; !ok {
+		return false, nil
+	}
+	delete(s.records, k)
+	return true, nil
+}
+
+// List returns records ordered by tenant and name.
+func (s *MemoryStore) List(tenantID string) ([]*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		if tenantID != "" && r.TenantID != tenantID {
+			continue
+		}
+		rec := r
+		records = append(records, &rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].TenantID != records[j].TenantID {
+			return records[i].TenantID < records[j].TenantID
+		}
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+// FileStore keeps encrypted credentials in memory and writes them to a JSON file
+// on every change.
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore loads records from path, creating the file on first write if it
+// does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	var records []Record
+	if _, err := jsonfile.Read(path, &records); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		s.records[recordKey{r.TenantID, r.Name}] = r
+	}
+	return s, nil
+}
+
+// Put inserts or replaces a record and rewrites the credentials file.
+func (s *FileStore) Put(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemoryStore.Put(rec); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// Delete removes a record and rewrites the credentials file.
+func (s *FileStore) Delete(tenantID, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted, err := s.MemoryStore.Delete(tenantID, name)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, s.flush()
+}
+
+// flush writes every record to disk. Callers hold s.mu.
+func (s *FileStore) flush() error {
+	records, err := s.MemoryStore.List("")
+	if err != nil {
+		return err
+	}
+	stored := make([]Record, len(records))
+	for i, r := range records {
+		stored[i] = *r
+	}
+	return jsonfile.Write(s.path, stored)
+}
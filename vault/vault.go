@@ -0,0 +1,222 @@
+// Package vault stores per-tenant secrets such as Monarch session tokens and LLM
+// provider API keys, encrypted at rest with AES-256-GCM under a master key.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Credential names accepted by the vault.
+const (
+	MonarchToken = "monarch_token"
+	OpenAIAPIKey = "openai_api_key"
+	ClaudeAPIKey = "claude_api_key"
+)
+
+// Names lists every credential the vault accepts.
+var Names = []string{MonarchToken, OpenAIAPIKey, ClaudeAPIKey}
+
+// Prefixes of the names of the server's own secrets. They are sealed like
+// credentials, and rotated with them, but are not credentials: ValidName rejects
+// them and List leaves them out.
+const (
+	webhookSecretPrefix = "webhook:"
+	signingSecretPrefix = "signing:"
+)
+
+// WebhookSecretName returns the name a webhook subscription's secret is stored
+// under.
+func WebhookSecretName(subscriptionID string) string {
+	return webhookSecretPrefix + subscriptionID
+}
+
+// SigningSecretName returns the name the secret that an API key's signed requests
+// are verified with is stored under.
+func SigningSecretName(keyID string) string {
+	return signingSecretPrefix + keyID
+}
+
+// internalName reports whether name is one of the server's own secrets.
+func internalName(name string) bool {
+	return strings.HasPrefix(name, webhookSecretPrefix) || strings.HasPrefix(name, signingSecretPrefix)
+}
+
+// ErrNotFound is returned when a tenant has no credential with the given name.
+var ErrNotFound = errors.New("credential not found")
+
+// hintLength is how many trailing characters of a credential are kept in
+// plaintext so operators can tell credentials apart. The server's own secrets are
+// never listed, so they keep no hint.
+const hintLength = 4
+
+// Credential describes a stored secret without revealing it.
+type Credential struct {
+	Name      string    `json:"name"`
+	Hint      string    `json:"hint,omitempty"`
+	KeyID     string    `json:"keyId"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Vault encrypts, stores, and decrypts credentials.
+type Vault struct {
+	store Store
+	keys  *Keys
+	now   func() time.Time
+}
+
+// New creates a vault backed by store and encrypting with keys.
+func New(store Store, keys *Keys) *Vault {
+	return &Vault{store: store, keys: keys, now: time.Now}
+}
+
+// ValidName reports whether name is a credential the vault accepts.
+func ValidName(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Set stores or replaces a tenant's credential.
+func (v *Vault) Set(tenantID, name, secret string) (*Credential, error) {
+	if !ValidName(name) && !internalName(name) {
+		return nil, fmt.Errorf("unknown credential %q", name)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("credential value is required")
+	}
+
+	key := v.keys.Current()
+	ciphertext, err := key.seal([]byte(secret), additionalData(tenantID, name))
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Record{
+		TenantID:   tenantID,
+		Name:       name,
+		KeyID:      key.ID(),
+		Ciphertext: ciphertext,
+		UpdatedAt:  v.now().UTC(),
+	}
+	if !internalName(name) {
+		rec.Hint = hint(secret)
+	}
+	if err := v.store.Put(rec); err != nil {
+		return nil, fmt.Errorf("save credential: %w", err)
+	}
+	return describe(rec), nil
+}
+
+// Get decrypts a tenant's credential.
+func (v *Vault) Get(tenantID, name string) (string, error) {
+	rec, err := v.store.Get(tenantID, name)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := v.open(rec)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// List describes a tenant's credentials. The server's own secrets are not included.
+func (v *Vault) List(tenantID string) ([]*Credential, error) {
+	records, err := v.store.List(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]*Credential, 0, len(records))
+	for _, rec := range records {
+		if ValidName(rec.Name) {
+			creds = append(creds, describe(rec))
+		}
+	}
+	return creds, nil
+}
+
+// Delete removes a tenant's credential, or returns ErrNotFound.
+func (v *Vault) Delete(tenantID, name string) error {
+	deleted, err := v.store.Delete(tenantID, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Rotate re-encrypts every credential sealed with a previous master key under the
+// current one and returns how many were rewritten. After it succeeds the previous
+// keys are no longer needed. Hints saved for the server's own secrets by earlier
+// versions are dropped on the way.
+func (v *Vault) Rotate() (int, error) {
+	records, err := v.store.List("")
+	if err != nil {
+		return 0, err
+	}
+
+	current := v.keys.Current()
+	rotated := 0
+	for _, rec := range records {
+		staleHint := internalName(rec.Name) && rec.Hint != ""
+		if rec.KeyID == current.ID() && !staleHint {
+			continue
+		}
+		plaintext, err := v.open(rec)
+		if err != nil {
+			return rotated, fmt.Errorf("%s/%s: %w", rec.TenantID, rec.Name, err)
+		}
+		ciphertext, err := current.seal(plaintext, additionalData(rec.TenantID, rec.Name))
+		if err != nil {
+			return rotated, err
+		}
+		rec.KeyID = current.ID()
+		rec.Ciphertext = ciphertext
+		if internalName(rec.Name) {
+			rec.Hint = ""
+		}
+		if err := v.store.Put(rec); err != nil {
+			return rotated, fmt.Errorf("save credential: %w", err)
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+func (v *Vault) open(rec *Record) ([]byte, error) {
+	key, err := v.keys.lookup(rec.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.open(rec.Ciphertext, additionalData(rec.TenantID, rec.Name))
+}
+
+// additionalData binds a ciphertext to its tenant and name so records cannot be
+// swapped between tenants in the store.
+func additionalData(tenantID, name string) []byte {
+	return []byte(tenantID + "\x00" + name)
+}
+
+func hint(secret string) string {
+	if len(secret) < 3*hintLength {
+		return ""
+	}
+	return "..." + secret[len(secret)-hintLength:]
+}
+
+func describe(rec *Record) *Credential {
+	return &Credential{
+		Name:      rec.Name,
+		Hint:      rec.Hint,
+		KeyID:     rec.KeyID,
+		UpdatedAt: rec.UpdatedAt,
+	}
+}
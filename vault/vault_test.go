@@ -0,0 +1,188 @@
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustKey(t *testing.T) *MasterKey {
+	t.Helper()
+	key, err := GenerateMasterKey()
+	require.NoError(t, err)
+	return key
+}
+
+func TestParseMasterKey(t *testing.T) {
+	raw := strings.Repeat("k", MasterKeySize)
+
+	fromHex, err := ParseMasterKey(hex.EncodeToString([]byte(raw)))
+	require.NoError(t, err)
+	fromBase64, err := ParseMasterKey(base64.StdEncoding.EncodeToString([]byte(raw)) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, fromHex.ID(), fromBase64.ID())
+
+	_, err = ParseMasterKey("too-short")
+	assert.Error(t, err)
+}
+
+func TestVault_SetGetDelete(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	v := New(store, NewKeys(mustKey(t)))
+
+	// Act
+	cred, err := v.Set("t1", MonarchToken, "monarch-session-token-1234")
+	require.NoError(t, err)
+
+	// Assert - stored encrypted, listed without the secret
+	assert.Equal(t, "...1234", cred.Hint)
+	rec, err := store.Get("t1", MonarchToken)
+	require.NoError(t, err)
+	assert.NotContains(t, string(rec.Ciphertext), "monarch-session-token")
+
+	secret, err := v.Get("t1", MonarchToken)
+	require.NoError(t, err)
+	assert.Equal(t, "monarch-session-token-1234", secret)
+
+	_, err = v.Get("t2", MonarchToken)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	creds, err := v.List("t1")
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, MonarchToken, creds[0].Name)
+
+	require.NoError(t, v.Delete("t1", MonarchToken))
+	assert.ErrorIs(t, v.Delete("t1", MonarchToken), ErrNotFound)
+}
+
+func TestVault_SetValidation(t *testing.T) {
+	v := New(NewMemoryStore(), NewKeys(mustKey(t)))
+
+	_, err := v.Set("t1", "aws_secret", "x")
+	assert.Error(t, err)
+
+	_, err = v.Set("t1", OpenAIAPIKey, "")
+	assert.Error(t, err)
+}
+
+func TestVault_WebhookSecretsAreNotListed(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	v := New(store, NewKeys(mustKey(t)))
+	_, err := v.Set("t1", MonarchToken, "monarch-session-token-1234")
+	require.NoError(t, err)
+
+	// Act
+	_, err = v.Set("t1", WebhookSecretName("whs_1"), "hook-signing-secret")
+	require.NoError(t, err)
+
+	// Assert - sealed like a credential, but only readable by name
+	rec, err := store.Get("t1", WebhookSecretName("whs_1"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(rec.Ciphertext), "hook-signing-secret")
+	assert.Empty(t, rec.Hint, "no part of the server's own secrets is kept in plaintext")
+	secret, err := v.Get("t1", WebhookSecretName("whs_1"))
+	require.NoError(t, err)
+	assert.Equal(t, "hook-signing-secret", secret)
+
+	creds, err := v.List("t1")
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, MonarchToken, creds[0].Name)
+	assert.False(t, ValidName(WebhookSecretName("whs_1")))
+}
+
+func TestVault_RecordsAreBoundToTenant(t *testing.T) {
+	store := NewMemoryStore()
+	v := New(store, NewKeys(mustKey(t)))
+	_, err := v.Set("t1", OpenAIAPIKey, "sk-tenant-one-secret")
+	require.NoError(t, err)
+
+	// Copy the ciphertext into another tenant's record
+	rec, err := store.Get("t1", OpenAIAPIKey)
+	require.NoError(t, err)
+	rec.TenantID = "t2"
+	require.NoError(t, store.Put(rec))
+
+	_, err = v.Get("t2", OpenAIAPIKey)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestVault_RotateMasterKey(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	oldKey, newKey := mustKey(t), mustKey(t)
+	_, err := New(store, NewKeys(oldKey)).Set("t1", ClaudeAPIKey, "sk-ant-secret-value")
+	require.NoError(t, err)
+
+	// Without the old key the credential is unreadable
+	_, err = New(store, NewKeys(newKey)).Get("t1", ClaudeAPIKey)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Act
+	v := New(store, NewKeys(newKey, oldKey))
+	rotated, err := v.Rotate()
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 1, rotated)
+	secret, err := New(store, NewKeys(newKey)).Get("t1", ClaudeAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-ant-secret-value", secret)
+
+	rotated, err = v.Rotate()
+	require.NoError(t, err)
+	assert.Zero(t, rotated)
+}
+
+func TestVault_RotateDropsHintsOfServerSecrets(t *testing.T) {
+	// Arrange: a signing secret saved with a hint by an earlier version
+	store := NewMemoryStore()
+	v := New(store, NewKeys(mustKey(t)))
+	_, err := v.Set("t1", SigningSecretName("key_1"), "request-signing-secret")
+	require.NoError(t, err)
+	rec, err := store.Get("t1", SigningSecretName("key_1"))
+	require.NoError(t, err)
+	rec.Hint = "...cret"
+	require.NoError(t, store.Put(rec))
+
+	// Act
+	rotated, err := v.Rotate()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, rotated)
+	rec, err = store.Get("t1", SigningSecretName("key_1"))
+	require.NoError(t, err)
+	assert.Empty(t, rec.Hint)
+	secret, err := v.Get("t1", SigningSecretName("key_1"))
+	require.NoError(t, err)
+	assert.Equal(t, "request-signing-secret", secret)
+}
+
+func TestFileStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	key := mustKey(t)
+	store, err := NewFileStore(path)
+	require.NoError(t, err)
+	_, err = New(store, NewKeys(key)).Set("t1", MonarchToken, "monarch-token-abcd")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "monarch-token")
+
+	reloaded, err := NewFileStore(path)
+	require.NoError(t, err)
+	secret, err := New(reloaded, NewKeys(key)).Get("t1", MonarchToken)
+	require.NoError(t, err)
+	assert.Equal(t, "monarch-token-abcd", secret)
+}
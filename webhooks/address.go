@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// validateURL checks that a webhook URL is an absolute http(s) URL. Unless
+// allowPrivate is set, hosts that are loopback, private, or link-local
+// addresses are refused so subscriptions cannot be used to reach the server's
+// own network.
+func validateURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use http or https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("webhook url has no host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook url must not contain credentials")
+	}
+	if allowPrivate {
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("webhook url host %s is not allowed", host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+		return fmt.Errorf("webhook url host %s is not allowed", host)
+	}
+	return nil
+}
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routable on the
+// internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// guardedClient returns an HTTP client that refuses to connect to non-public
+// addresses. Checking the address being dialed, rather than only the URL, also
+// covers hostnames that resolve to internal addresses and redirects to them.
+func guardedClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(ap.Addr()) {
+				return fmt.Errorf("webhook address %s is not allowed", ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"monarchmoney-sync-backend/internal/jsonfile"
+)
+
+// PendingEvent is an event that was not delivered to a subscription before
+// shutdown. It names the subscription rather than carrying its URL and secret,
+// so a checkpoint never holds secrets and events for subscriptions removed in the
+// meantime are not delivered.
+type PendingEvent struct {
+	SubscriptionID string          `json:"subscriptionId"`
+	TenantID       string          `json:"tenantId"`
+	EventID        string          `json:"eventId"`
+	EventType      EventType       `json:"eventType"`
+	Body           json.RawMessage `json:"body"`
+}
+
+func (j job) pending() PendingEvent {
+	return PendingEvent{
+		SubscriptionID: j.sub.ID,
+		TenantID:       j.sub.TenantID,
+		EventID:        j.event.ID,
+		EventType:      j.event.Type,
+		Body:           j.body,
+	}
+}
+
+// SaveCheckpoint writes undelivered events to path so RestoreCheckpoint can queue
+// them again on the next start. The file is readable only by its owner.
+func SaveCheckpoint(path string, pending []PendingEvent) error {
+	return jsonfile.Write(path, pending)
+}
+
+// RestoreCheckpoint queues the events saved at path for delivery and removes the
+// file. Events are sent to the subscription as it is now, so subscriptions must be
+// loaded first; events for subscriptions that no longer exist are dropped. It
+// returns the number of events queued; a missing file is not an error.
+func (d *Dispatcher) RestoreCheckpoint(path string) (int, error) {
+	var pending []PendingEvent
+	found, err := jsonfile.Read(path, &pending)
+	if err != nil || !found {
+		return 0, err
+	}
+
+	queued := 0
+	for _, p := range pending {
+		sub, ok := d.subscription(p.SubscriptionID)
+		if !ok || sub.TenantID != p.TenantID {
+			slog.Warn("Webhook subscription no longer exists, dropping checkpointed event", "event", p.EventType, "subscription", p.SubscriptionID)
+			continue
+		}
+		j := job{sub: sub, event: Event{ID: p.EventID, Type: p.EventType}, body: p.Body}
+		select {
+		case d.queue <- j:
+			queued++
+		default:
+			slog.Warn("Webhook queue full, dropping checkpointed event", "event", p.EventType, "subscription", p.SubscriptionID)
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return queued, fmt.Errorf("remove %s: %w", path, err)
+	}
+	return queued, nil
+}
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher_Shutdown_DrainsQueue(t *testing.T) {
+	// Arrange
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(testOptions())
+	_, err := d.Subscribe("t1", server.URL, "hook-secret", nil)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		d.Publish("t1", EventOrderReceived, OrderEventData{OrderNumber: "123"})
+	}
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pending := d.Shutdown(ctx)
+
+	// Assert
+	assert.Empty(t, pending)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+	assert.Equal(t, 0, d.QueueDepth())
+
+	// Events published after shutdown are dropped
+	d.Publish("t1", EventOrderReceived, OrderEventData{OrderNumber: "456"})
+	assert.Equal(t, 0, d.QueueDepth())
+	assert.EqualError(t, d.Check(context.Background()), "dispatcher stopped")
+}
+
+func TestDispatcher_Shutdown_CheckpointsUndelivered(t *testing.T) {
+	// Arrange: the subscriber is down until the dispatcher restarts
+	var (
+		up   atomic.Bool
+		mu   sync.Mutex
+		body []byte
+		req  *http.Request
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		req = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	subsPath := filepath.Join(t.TempDir(), "subscriptions.json")
+	secrets := memorySecrets{}
+	store, err := NewFileStore(subsPath, secrets)
+	require.NoError(t, err)
+
+	opts := testOptions()
+	opts.InitialBackoff = time.Minute
+	opts.MaxBackoff = time.Minute
+	d := NewDispatcher(opts)
+	require.NoError(t, d.UseStore(store))
+	sub, err := d.Subscribe("t1", server.URL, "hook-secret", nil)
+	require.NoError(t, err)
+	d.Publish("t1", EventOrderFailed, OrderEventData{OrderNumber: "123"})
+	d.Publish("t1", EventOrderFailed, OrderEventData{OrderNumber: "456"})
+	waitForDeliveries(t, d, 1)
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	pending := d.Shutdown(ctx)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	require.NoError(t, SaveCheckpoint(path, pending))
+
+	up.Store(true)
+	restarted := NewDispatcher(testOptions())
+	defer restarted.Close()
+	reopened, err := NewFileStore(subsPath, secrets)
+	require.NoError(t, err)
+	require.NoError(t, restarted.UseStore(reopened))
+	restored, err := restarted.RestoreCheckpoint(path)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, sub.ID, pending[0].SubscriptionID)
+	assert.Equal(t, 2, restored)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	assert.NotContains(t, string(readFile(t, subsPath)), "hook-secret")
+
+	deliveries := waitForDeliveries(t, restarted, 2)
+	assert.True(t, deliveries[0].Success)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, Verify("hook-secret", req.Header.Get(HeaderTimestamp), body, req.Header.Get(HeaderSignature)))
+	assert.Equal(t, string(EventOrderFailed), req.Header.Get(HeaderEvent))
+}
+
+func TestDispatcher_RestoreCheckpoint_DropsUnknownSubscriptions(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	require.NoError(t, SaveCheckpoint(path, []PendingEvent{{
+		SubscriptionID: "whs_removed",
+		TenantID:       "t1",
+		EventID:        "evt_1",
+		EventType:      EventOrderFailed,
+		Body:           []byte(`{}`),
+	}}))
+	d := NewDispatcher(testOptions())
+	defer d.Close()
+
+	// Act
+	restored, err := d.RestoreCheckpoint(path)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Zero(t, restored)
+	assert.Zero(t, d.QueueDepth())
+}
+
+func TestDispatcher_RestoreCheckpoint_MissingFile(t *testing.T) {
+	// Arrange
+	d := NewDispatcher(testOptions())
+	defer d.Close()
+
+	// Act
+	restored, err := d.RestoreCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, restored)
+}
+
+// memorySecrets keeps subscription secrets in a map in place of the vault.
+type memorySecrets map[string]string
+
+func (m memorySecrets) SetSecret(tenantID, subscriptionID, secret string) error {
+	m[tenantID+"/"+subscriptionID] = secret
+	return nil
+}
+
+func (m memorySecrets) Secret(tenantID, subscriptionID string) (string, error) {
+	secret, ok := m[tenantID+"/"+subscriptionID]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return secret, nil
+}
+
+func (m memorySecrets) DeleteSecret(tenantID, subscriptionID string) error {
+	delete(m, tenantID+"/"+subscriptionID)
+	return nil
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
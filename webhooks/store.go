@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"monarchmoney-sync-backend/internal/jsonfile"
+)
+
+// SubscriptionStore persists subscriptions, including their secrets, so they
+// survive a restart.
+type SubscriptionStore interface {
+	// LoadSubscriptions returns every stored subscription with its secret.
+	LoadSubscriptions() ([]Subscription, error)
+	SaveSubscription(sub *Subscription) error
+	DeleteSubscription(sub *Subscription) error
+}
+
+// Secrets seals subscription secrets at rest, such as in the credential vault.
+type Secrets interface {
+	SetSecret(tenantID, subscriptionID, secret string) error
+	Secret(tenantID, subscriptionID string) (string, error)
+	DeleteSecret(tenantID, subscriptionID string) error
+}
+
+// FileStore keeps subscriptions in a JSON file. Secrets are never written to the
+// file; they are kept in Secrets.
+type FileStore struct {
+	path    string
+	secrets Secrets
+
+	mu   sync.Mutex
+	subs []Subscription
+}
+
+// NewFileStore loads subscriptions from path, creating the file on first write if
+// it does not exist.
+func NewFileStore(path string, secrets Secrets) (*FileStore, error) {
+	s := &FileStore{path: path, secrets: secrets}
+	if _, err := jsonfile.Read(path, &s.subs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSubscriptions returns the stored subscriptions with their secrets.
+// Subscriptions whose secret cannot be read are skipped.
+func (s *FileStore) LoadSubscriptions() ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		secret, err := s.secrets.Secret(sub.TenantID, sub.ID)
+		if err != nil {
+			slog.Warn("Webhook subscription secret unavailable, skipping subscription", "subscription", sub.ID, "error", err)
+			continue
+		}
+		sub.Secret = secret
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// SaveSubscription seals the subscription's secret and adds it to the file.
+func (s *FileStore) SaveSubscription(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.secrets.SetSecret(sub.TenantID, sub.ID, sub.Secret); err != nil {
+		return fmt.Errorf("seal webhook secret: %w", err)
+	}
+	subs := append(s.without(sub.ID), *sub)
+	if err := jsonfile.Write(s.path, subs); err != nil {
+		return err
+	}
+	s.subs = subs
+	return nil
+}
+
+// DeleteSubscription removes the subscription and its secret.
+func (s *FileStore) DeleteSubscription(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.without(sub.ID)
+	if err := jsonfile.Write(s.path, subs); err != nil {
+		return err
+	}
+	s.subs = subs
+	if err := s.secrets.DeleteSecret(sub.TenantID, sub.ID); err != nil {
+		return fmt.Errorf("delete webhook secret: %w", err)
+	}
+	return nil
+}
+
+// without returns the stored subscriptions other than id. Callers hold s.mu.
+func (s *FileStore) without(id string) []Subscription {
+	subs := make([]Subscription, 0, len(s.subs)+1)
+	for _, sub := range s.subs {
+		if sub.ID != id {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
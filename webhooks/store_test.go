@@ -0,0 +1,44 @@
+package webhooks
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_PersistsSubscriptions(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	secrets := memorySecrets{}
+	store, err := NewFileStore(path, secrets)
+	require.NoError(t, err)
+	d := NewDispatcher(testOptions())
+	defer d.Close()
+	require.NoError(t, d.UseStore(store))
+	_, err = d.SubscribeConfigured("t1", "http://example.com/configured", "config-secret", nil)
+	require.NoError(t, err)
+	kept, err := d.Subscribe("t1", "http://example.com/kept", "kept-secret", []EventType{EventOrderFailed})
+	require.NoError(t, err)
+	removed, err := d.Subscribe("t1", "http://example.com/removed", "removed-secret", nil)
+	require.NoError(t, err)
+	require.True(t, d.Unsubscribe("t1", removed.ID))
+
+	// Act
+	reopened, err := NewFileStore(path, secrets)
+	require.NoError(t, err)
+	restarted := NewDispatcher(testOptions())
+	defer restarted.Close()
+	require.NoError(t, restarted.UseStore(reopened))
+
+	// Assert
+	subs := restarted.Subscriptions("t1")
+	require.Len(t, subs, 1)
+	assert.Equal(t, kept.ID, subs[0].ID)
+	assert.Equal(t, "http://example.com/kept", subs[0].URL)
+	assert.Equal(t, "kept-secret", subs[0].Secret)
+	assert.Equal(t, []EventType{EventOrderFailed}, subs[0].Events)
+	assert.Len(t, secrets, 1)
+	assert.NotContains(t, string(readFile(t, path)), "kept-secret")
+}
@@ -0,0 +1,732 @@
+// Package webhooks delivers signed JSON notifications about order processing events
+// to subscribed HTTP endpoints (Home Assistant, chat bots, and similar automations).
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of processing event being delivered.
+type EventType string
+
+// Supported event types. Orders themselves are not split in Monarch, so
+// order.split_applied and order.needs_review are only sent for an order's refunds.
+const (
+	EventOrderReceived     EventType = "order.received"
+	EventOrderSplitApplied EventType = "order.split_applied"
+	EventOrderNeedsReview  EventType = "order.needs_review"
+	EventOrderFailed       EventType = "order.failed"
+)
+
+// EventTypes lists every event type a subscription may filter on.
+var EventTypes = []EventType{
+	EventOrderReceived,
+	EventOrderSplitApplied,
+	EventOrderNeedsReview,
+	EventOrderFailed,
+}
+
+// IsValidEventType reports whether t is a known event type.
+func IsValidEventType(t EventType) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON envelope POSTed to subscribers.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// OrderEventData is the payload carried by order.* events.
+type OrderEventData struct {
+	OrderNumber  string   `json:"orderNumber"`
+	OrderDate    string   `json:"orderDate,omitempty"`
+	ProcessingID string   `json:"processingId,omitempty"`
+	ItemCount    int      `json:"itemCount"`
+	TotalAmount  *float64 `json:"totalAmount,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	// RefundID and TransactionID identify the refund, and the Monarch transaction
+	// split for it, that an event about one of the order's refunds is for.
+	RefundID      string `json:"refundId,omitempty"`
+	TransactionID string `json:"transactionId,omitempty"`
+}
+
+// Subscription is a registered webhook endpoint owned by a tenant.
+type Subscription struct {
+	ID        string      `json:"id"`
+	TenantID  string      `json:"tenantId"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"-"`
+	Events    []EventType `json:"events,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Matches reports whether the subscription wants events of type t.
+// A subscription without an event filter receives every event.
+func (s *Subscription) Matches(t EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery records a single delivery attempt of an event to a subscription.
+type Delivery struct {
+	ID             string    `json:"id"`
+	TenantID       string    `json:"tenantId"`
+	SubscriptionID string    `json:"subscriptionId"`
+	EventID        string    `json:"eventId"`
+	EventType      EventType `json:"eventType"`
+	URL            string    `json:"url"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"statusCode,omitempty"`
+	Success        bool      `json:"success"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"durationMs"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Publisher is implemented by anything that can emit a tenant's processing events.
+type Publisher interface {
+	Publish(tenantID string, eventType EventType, data interface{})
+}
+
+// NopPublisher discards every event. It is used when webhooks are not configured.
+type NopPublisher struct{}
+
+// Publish implements Publisher.
+func (NopPublisher) Publish(string, EventType, interface{}) {}
+
+// Options configures a Dispatcher.
+type Options struct {
+	// MaxAttempts is the number of delivery attempts per subscription before giving up.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles on each attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Timeout bounds each individual HTTP request.
+	Timeout time.Duration
+	// QueueSize is the number of events that may be buffered awaiting delivery,
+	// both in the shared queue and for each subscription.
+	QueueSize int
+	// DeliveryLogSize is the number of most recent delivery attempts retained.
+	DeliveryLogSize int
+	// AllowPrivateNetworks permits subscriptions to loopback, private, and
+	// link-local addresses, such as a Home Assistant instance on the LAN.
+	AllowPrivateNetworks bool
+	// Client overrides the HTTP client used for deliveries.
+	Client *http.Client
+}
+
+// ConfiguredSubscriptionID is the ID of the subscription set up by
+// SubscribeConfigured. It stays the same across restarts so checkpointed events
+// are still delivered to it.
+const ConfiguredSubscriptionID = "whs_config"
+
+// DefaultOptions returns the options used when none are specified.
+func DefaultOptions() Options {
+	return Options{
+		MaxAttempts:     5,
+		InitialBackoff:  time.Second,
+		MaxBackoff:      time.Minute,
+		Timeout:         10 * time.Second,
+		QueueSize:       256,
+		DeliveryLogSize: 500,
+	}
+}
+
+type job struct {
+	sub   Subscription
+	event Event
+	body  []byte
+}
+
+// Dispatcher fans events out to matching subscriptions, retrying failed deliveries
+// with exponential backoff and keeping a bounded log of delivery attempts. Each
+// subscription is delivered to by its own worker, in the order its events were
+// published, so a slow or failing endpoint only holds up its own events.
+type Dispatcher struct {
+	opts   Options
+	client *http.Client
+
+	mu            sync.RWMutex
+	store         SubscriptionStore
+	subscriptions map[string]*Subscription
+	deliveries    []Delivery
+	// workers holds each subscription's queue, which only run sends to
+	workers map[string]chan job
+
+	queue      chan job
+	unfinished []job
+	draining   chan struct{}
+	drainOnce  sync.Once
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher and starts delivering events.
+// Call Close to stop it.
+func NewDispatcher(opts Options) *Dispatcher {
+	defaults := DefaultOptions()
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = defaults.InitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaults.MaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaults.QueueSize
+	}
+	if opts.DeliveryLogSize <= 0 {
+		opts.DeliveryLogSize = defaults.DeliveryLogSize
+	}
+
+	client := opts.Client
+	switch {
+	case client != nil:
+	case opts.AllowPrivateNetworks:
+		client = &http.Client{Timeout: opts.Timeout}
+	default:
+		client = guardedClient(opts.Timeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		opts:          opts,
+		client:        client,
+		subscriptions: make(map[string]*Subscription),
+		workers:       make(map[string]chan job),
+		queue:         make(chan job, opts.QueueSize),
+		draining:      make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	d.wg.Add(1)
+	go d.run()
+
+	return d
+}
+
+// UseStore loads the subscriptions saved in store and persists later changes to
+// it. Subscriptions made before UseStore, such as the configured one, are not
+// saved.
+func (d *Dispatcher) UseStore(store SubscriptionStore) error {
+	subs, err := store.LoadSubscriptions()
+	if err != nil {
+		return fmt.Errorf("load webhook subscriptions: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range subs {
+		d.subscriptions[subs[i].ID] = &subs[i]
+	}
+	d.store = store
+	return nil
+}
+
+// ValidationError is returned for a subscription that is not valid, unlike an
+// error saving one.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid returns a ValidationError with a formatted message.
+func invalid(format string, args ...any) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
+
+// Subscribe registers a new subscription for a tenant and returns it. The URL must
+// be http or https and, unless private networks are allowed, must not point at an
+// internal address; a subscription that is not valid is a *ValidationError.
+func (d *Dispatcher) Subscribe(tenantID, url, secret string, events []EventType) (*Subscription, error) {
+	sub, err := d.newSubscription(newID("whs"), tenantID, url, secret, events)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.store != nil {
+		if err := d.store.SaveSubscription(sub); err != nil {
+			return nil, fmt.Errorf("save webhook subscription: %w", err)
+		}
+	}
+	d.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+// SubscribeConfigured registers the subscription from the server's configuration
+// under ConfiguredSubscriptionID. It is never persisted.
+func (d *Dispatcher) SubscribeConfigured(tenantID, url, secret string, events []EventType) (*Subscription, error) {
+	sub, err := d.newSubscription(ConfiguredSubscriptionID, tenantID, url, secret, events)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (d *Dispatcher) newSubscription(id, tenantID, url, secret string, events []EventType) (*Subscription, error) {
+	if tenantID == "" {
+		return nil, invalid("tenant is required")
+	}
+	if url == "" {
+		return nil, invalid("webhook url is required")
+	}
+	if err := validateURL(url, d.opts.AllowPrivateNetworks); err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+	if secret == "" {
+		return nil, invalid("webhook secret is required")
+	}
+	for _, e := range events {
+		if !IsValidEventType(e) {
+			return nil, invalid("unknown event type %q", e)
+		}
+	}
+
+	return &Subscription{
+		ID:        id,
+		TenantID:  tenantID,
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Unsubscribe removes a tenant's subscription. It returns false if the tenant has no
+// such subscription.
+func (d *Dispatcher) Unsubscribe(tenantID, id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subscriptions[id]
+	if !ok || sub.TenantID != tenantID {
+		return false
+	}
+	delete(d.subscriptions, id)
+	if d.store != nil && id != ConfiguredSubscriptionID {
+		if err := d.store.DeleteSubscription(sub); err != nil {
+			slog.Error("Failed to delete stored webhook subscription", "subscription", id, "error", err)
+		}
+	}
+	return true
+}
+
+// subscription returns a copy of the subscription with the given ID.
+func (d *Dispatcher) subscription(id string) (Subscription, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	sub, ok := d.subscriptions[id]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
+// Subscriptions returns a snapshot of a tenant's subscriptions.
+func (d *Dispatcher) Subscriptions(tenantID string) []Subscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	subs := make([]Subscription, 0, len(d.subscriptions))
+	for _, s := range d.subscriptions {
+		if s.TenantID == tenantID {
+			subs = append(subs, *s)
+		}
+	}
+	return subs
+}
+
+// Deliveries returns a tenant's logged delivery attempts, most recent first.
+func (d *Dispatcher) Deliveries(tenantID string) []Delivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	out := make([]Delivery, 0, len(d.deliveries))
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if d.deliveries[i].TenantID == tenantID {
+			out = append(out, d.deliveries[i])
+		}
+	}
+	return out
+}
+
+// Publish queues an event for delivery to every matching subscription of the tenant.
+// It never blocks the caller; events are dropped if the queue is full or the
+// dispatcher is shutting down.
+func (d *Dispatcher) Publish(tenantID string, eventType EventType, data interface{}) {
+	select {
+	case <-d.draining:
+		slog.Warn("Webhook dispatcher shutting down, dropping event", "event", eventType)
+		return
+	default:
+	}
+
+	event := Event{
+		ID:        newID("evt"),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Webhook event could not be encoded", "event", eventType, "error", err)
+		return
+	}
+
+	d.mu.RLock()
+	var matched []Subscription
+	for _, s := range d.subscriptions {
+		if s.TenantID == tenantID && s.Matches(eventType) {
+			matched = append(matched, *s)
+		}
+	}
+	d.mu.RUnlock()
+
+	for _, sub := range matched {
+		select {
+		case d.queue <- job{sub: sub, event: event, body: body}:
+		default:
+			slog.Warn("Webhook queue full, dropping event", "event", eventType, "subscription", sub.ID)
+		}
+	}
+}
+
+// QueueDepth returns the number of events waiting to be delivered.
+func (d *Dispatcher) QueueDepth() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	depth := len(d.queue)
+	for _, jobs := range d.workers {
+		depth += len(jobs)
+	}
+	return depth
+}
+
+// Check reports an error when delivery has stopped or the queue is full, so
+// events are being dropped.
+func (d *Dispatcher) Check(ctx context.Context) error {
+	if d.ctx.Err() != nil {
+		return errors.New("dispatcher stopped")
+	}
+	select {
+	case <-d.draining:
+		return errors.New("dispatcher shutting down")
+	default:
+	}
+	if len(d.queue) >= cap(d.queue) {
+		return fmt.Errorf("queue full (%d events)", cap(d.queue))
+	}
+	return ctx.Err()
+}
+
+// Close stops delivery. Queued events that have not been delivered are discarded.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Shutdown stops accepting events and waits for queued and in-flight deliveries to
+// finish. If ctx is done first, in-flight deliveries are cancelled. Events that were
+// not delivered are returned so they can be checkpointed.
+func (d *Dispatcher) Shutdown(ctx context.Context) []PendingEvent {
+	d.drainOnce.Do(func() { close(d.draining) })
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+
+	d.mu.Lock()
+	unfinished := d.unfinished
+	d.unfinished = nil
+	d.mu.Unlock()
+
+	pending := make([]PendingEvent, 0, len(unfinished)+d.QueueDepth())
+	for _, j := range unfinished {
+		pending = append(pending, j.pending())
+	}
+	d.mu.RLock()
+	for _, jobs := range d.workers {
+		pending = appendQueued(pending, jobs)
+	}
+	d.mu.RUnlock()
+	return appendQueued(pending, d.queue)
+}
+
+// appendQueued appends the jobs left in a queue, which may have been closed.
+func appendQueued(pending []PendingEvent, jobs chan job) []PendingEvent {
+	for {
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				return pending
+			}
+			pending = append(pending, j.pending())
+		default:
+			return pending
+		}
+	}
+}
+
+// workerIdleCheck is how often workers of removed subscriptions are stopped.
+const workerIdleCheck = time.Minute
+
+// run hands queued events to their subscription's worker.
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(workerIdleCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case j := <-d.queue:
+			d.dispatch(j)
+		case <-ticker.C:
+			d.stopRemovedWorkers()
+		case <-d.draining:
+			if len(d.queue) > 0 {
+				d.dispatch(<-d.queue)
+				continue
+			}
+			// Workers deliver what they hold, then stop
+			d.mu.Lock()
+			for _, jobs := range d.workers {
+				close(jobs)
+			}
+			d.mu.Unlock()
+			return
+		}
+	}
+}
+
+// dispatch queues a job for its subscription's worker, starting the worker on
+// the subscription's first event.
+func (d *Dispatcher) dispatch(j job) {
+	d.mu.Lock()
+	jobs, ok := d.workers[j.sub.ID]
+	if !ok {
+		jobs = make(chan job, d.opts.QueueSize)
+		d.workers[j.sub.ID] = jobs
+		d.wg.Add(1)
+		go d.work(jobs)
+	}
+	d.mu.Unlock()
+
+	select {
+	case jobs <- j:
+	default:
+		slog.Warn("Webhook subscription queue full, dropping event", "event", j.event.Type, "subscription", j.sub.ID)
+	}
+}
+
+// stopRemovedWorkers stops the idle workers of subscriptions that were removed.
+func (d *Dispatcher) stopRemovedWorkers() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, jobs := range d.workers {
+		if _, ok := d.subscriptions[id]; !ok && len(jobs) == 0 {
+			close(jobs)
+			delete(d.workers, id)
+		}
+	}
+}
+
+// work delivers one subscription's jobs in order until its queue is closed.
+func (d *Dispatcher) work(jobs <-chan job) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case j, ok := <-jobs:
+			if !ok {
+				return
+			}
+			d.deliver(j)
+		}
+	}
+}
+
+// deliver attempts to send a job, retrying with exponential backoff.
+func (d *Dispatcher) deliver(j job) {
+	backoff := d.opts.InitialBackoff
+
+	for attempt := 1; attempt <= d.opts.MaxAttempts; attempt++ {
+		delivery := d.attempt(j, attempt)
+		d.record(delivery)
+
+		if delivery.Success {
+			return
+		}
+		if d.ctx.Err() != nil {
+			d.keepUnfinished(j)
+			return
+		}
+		if !retryable(delivery.StatusCode) {
+			return
+		}
+		if attempt == d.opts.MaxAttempts {
+			slog.Warn("Webhook delivery failed",
+				"event", j.event.Type, "subscription", j.sub.ID, "attempts", attempt)
+			return
+		}
+
+		select {
+		case <-d.ctx.Done():
+			d.keepUnfinished(j)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > d.opts.MaxBackoff {
+			backoff = d.opts.MaxBackoff
+		}
+	}
+}
+
+// keepUnfinished holds a job interrupted by shutdown so Shutdown can return it.
+func (d *Dispatcher) keepUnfinished(j job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unfinished = append(d.unfinished, j)
+}
+
+func (d *Dispatcher) attempt(j job, attempt int) Delivery {
+	start := time.Now()
+	delivery := Delivery{
+		ID:             newID("whd"),
+		TenantID:       j.sub.TenantID,
+		SubscriptionID: j.sub.ID,
+		EventID:        j.event.ID,
+		EventType:      j.event.Type,
+		URL:            j.sub.URL,
+		Attempt:        attempt,
+		Timestamp:      start,
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.sub.URL, bytes.NewReader(j.body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "monarchmoney-sync-backend-webhooks")
+	req.Header.Set(HeaderEvent, string(j.event.Type))
+	req.Header.Set(HeaderEventID, j.event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(j.sub.Secret, timestamp, j.body))
+
+	resp, err := d.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return delivery
+}
+
+func (d *Dispatcher) record(delivery Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deliveries = append(d.deliveries, delivery)
+	if over := len(d.deliveries) - d.opts.DeliveryLogSize; over > 0 {
+		d.deliveries = d.deliveries[over:]
+	}
+}
+
+// retryable reports whether a delivery with the given status should be retried.
+// A zero status means the request never got a response (network error, timeout).
+func retryable(status int) bool {
+	switch {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+func newID(prefix string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+	}
+	return prefix + "_" + hex.EncodeToString(b)
+}